@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -11,6 +12,48 @@ type Config struct {
 	Port        string
 	DatabaseURL string
 	LogLevel    string
+
+	// Event spool (write-ahead log for accepted events)
+	EventSpoolEnabled      bool
+	EventSpoolDir          string
+	EventSpoolSegmentBytes int64
+
+	// Deduplication of client-supplied event IDs
+	EventDedupeEnabled bool
+	EventDedupeWindow  time.Duration
+
+	// Hourly rollups of pageview traffic
+	RollupsEnabled        bool
+	RollupCompactInterval time.Duration
+
+	// Realtime streams of live visitors and recent events
+	RealtimeEnabled  bool
+	RealtimeInterval time.Duration
+
+	// Export jobs and the local store of their files
+	ExportDir     string
+	ExportWorkers int
+	ExportTTL     time.Duration
+
+	// Outgoing email for digests; email is disabled without SMTPHost
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Base URL of the dashboard that digests link to
+	DashboardURL string
+
+	// Alert rule evaluation and webhook delivery
+	AlertsEnabled            bool
+	AlertInterval            time.Duration
+	AlertWebhookTimeout      time.Duration
+	AlertWebhookAllowPrivate bool
+
+	// Revenue reporting currency and rates for converting other currencies into it
+	RevenueCurrency string
+	CurrencyRates   string
 }
 
 func Load() (*Config, error) {
@@ -22,6 +65,39 @@ func Load() (*Config, error) {
 		Port:        getEnvOrDefault("PORT", "3002"),
 		DatabaseURL: getEnvOrDefault("DATABASE_URL", ""),
 		LogLevel:    getEnvOrDefault("LOG_LEVEL", "info"),
+
+		EventSpoolEnabled:      GetEnvAsBool("EVENT_SPOOL_ENABLED", true),
+		EventSpoolDir:          getEnvOrDefault("EVENT_SPOOL_DIR", "data/spool"),
+		EventSpoolSegmentBytes: int64(GetEnvAsInt("EVENT_SPOOL_SEGMENT_MB", 16)) * 1024 * 1024,
+
+		EventDedupeEnabled: GetEnvAsBool("EVENT_DEDUPE_ENABLED", true),
+		EventDedupeWindow:  GetEnvAsDuration("EVENT_DEDUPE_WINDOW", 24*time.Hour),
+
+		RollupsEnabled:        GetEnvAsBool("ROLLUPS_ENABLED", true),
+		RollupCompactInterval: GetEnvAsDuration("ROLLUP_COMPACT_INTERVAL", time.Minute),
+
+		RealtimeEnabled:  GetEnvAsBool("REALTIME_ENABLED", true),
+		RealtimeInterval: GetEnvAsDuration("REALTIME_INTERVAL", time.Second),
+
+		ExportDir:     getEnvOrDefault("EXPORT_DIR", "data/exports"),
+		ExportWorkers: GetEnvAsInt("EXPORT_WORKERS", 2),
+		ExportTTL:     GetEnvAsDuration("EXPORT_TTL", 24*time.Hour),
+
+		SMTPHost:     getEnvOrDefault("SMTP_HOST", ""),
+		SMTPPort:     GetEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnvOrDefault("SMTP_USERNAME", ""),
+		SMTPPassword: getEnvOrDefault("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnvOrDefault("SMTP_FROM", "Seentics <reports@localhost>"),
+
+		DashboardURL: getEnvOrDefault("DASHBOARD_URL", ""),
+
+		AlertsEnabled:            GetEnvAsBool("ALERTS_ENABLED", true),
+		AlertInterval:            GetEnvAsDuration("ALERT_INTERVAL", time.Minute),
+		AlertWebhookTimeout:      GetEnvAsDuration("ALERT_WEBHOOK_TIMEOUT", 10*time.Second),
+		AlertWebhookAllowPrivate: GetEnvAsBool("ALERT_WEBHOOK_ALLOW_PRIVATE", false),
+
+		RevenueCurrency: getEnvOrDefault("REVENUE_CURRENCY", "USD"),
+		CurrencyRates:   getEnvOrDefault("CURRENCY_RATES", ""),
 	}
 
 	// Validate required fields for production
@@ -58,3 +134,12 @@ func GetEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func GetEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
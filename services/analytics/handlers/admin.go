@@ -1,25 +1,31 @@
 package handlers
 
 import (
+	"analytics-app/models"
 	"analytics-app/repository"
+	"analytics-app/services"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 )
 
 type AdminHandler struct {
-	funnelRepo *repository.FunnelRepository
-	eventRepo  *repository.EventRepository
-	logger     zerolog.Logger
+	funnelRepo   *repository.FunnelRepository
+	eventRepo    *repository.EventRepository
+	eventService *services.EventService
+	logger       zerolog.Logger
 }
 
-func NewAdminHandler(funnelRepo *repository.FunnelRepository, eventRepo *repository.EventRepository, logger zerolog.Logger) *AdminHandler {
+func NewAdminHandler(funnelRepo *repository.FunnelRepository, eventRepo *repository.EventRepository, eventService *services.EventService, logger zerolog.Logger) *AdminHandler {
 	return &AdminHandler{
-		funnelRepo: funnelRepo,
-		eventRepo:  eventRepo,
-		logger:     logger,
+		funnelRepo:   funnelRepo,
+		eventRepo:    eventRepo,
+		eventService: eventService,
+		logger:       logger,
 	}
 }
 
@@ -138,3 +144,82 @@ func (h *AdminHandler) GetFunnelsList(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// GetDeadLetters returns events that could not be stored after all retries
+func (h *AdminHandler) GetDeadLetters(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	events, total, err := h.eventService.ListDeadLetters(c.Request.Context(), websiteID, limit, offset)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get dead-letter events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead-letter events"})
+		return
+	}
+
+	if events == nil {
+		events = []models.DeadLetterEvent{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ReplayDeadLetters writes dead-lettered events back to the events table
+func (h *AdminHandler) ReplayDeadLetters(c *gin.Context) {
+	var req models.DeadLetterReplayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	result, err := h.eventService.ReplayDeadLetters(c.Request.Context(), &req)
+	if err != nil {
+		h.writeDeadLetterError(c, err, "Failed to replay dead-letter events")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PurgeDeadLetters deletes dead-lettered events by ID, or by website and age. Emptying
+// the whole table takes all=true.
+func (h *AdminHandler) PurgeDeadLetters(c *gin.Context) {
+	req := models.DeadLetterPurgeRequest{
+		IDs:       c.QueryArray("id"),
+		WebsiteID: c.Query("website_id"),
+		All:       c.Query("all") == "true",
+	}
+	if value := c.Query("before"); value != "" {
+		before, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be an RFC3339 timestamp"})
+			return
+		}
+		req.Before = &before
+	}
+
+	deleted, err := h.eventService.PurgeDeadLetters(c.Request.Context(), &req)
+	if err != nil {
+		h.writeDeadLetterError(c, err, "Failed to purge dead-letter events")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+func (h *AdminHandler) writeDeadLetterError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrInvalidDeadLetterRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dead-letter request", "details": err.Error()})
+		return
+	}
+	h.logger.Error().Err(err).Msg(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
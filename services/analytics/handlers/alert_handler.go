@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+type AlertHandler struct {
+	service *services.AlertService
+	logger  zerolog.Logger
+}
+
+func NewAlertHandler(service *services.AlertService, logger zerolog.Logger) *AlertHandler {
+	return &AlertHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *AlertHandler) CreateAlert(c *gin.Context) {
+	var req models.CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind alert data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid alert data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rule, err := h.service.CreateAlert(c.Request.Context(), &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to create alert")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	rules, err := h.service.GetAlerts(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alerts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rules,
+	})
+}
+
+func (h *AlertHandler) GetAlert(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	rule, err := h.service.GetAlert(c.Request.Context(), ruleID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get alert")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alert": rule})
+}
+
+func (h *AlertHandler) UpdateAlert(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	var req models.UpdateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind alert update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid alert data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rule, err := h.service.UpdateAlert(c.Request.Context(), ruleID, &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to update alert")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alert": rule})
+}
+
+func (h *AlertHandler) DeleteAlert(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	if err := h.service.DeleteAlert(c.Request.Context(), ruleID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete alert")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Alert deleted successfully",
+	})
+}
+
+// GetDeliveries returns an alert's most recent webhook deliveries
+func (h *AlertHandler) GetDeliveries(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	deliveries, err := h.service.GetDeliveries(c.Request.Context(), ruleID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get alert deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    deliveries,
+	})
+}
+
+// TestAlert queues a test delivery to an alert's webhook
+func (h *AlertHandler) TestAlert(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	delivery, err := h.service.TestAlert(c.Request.Context(), ruleID)
+	if err != nil {
+		h.writeError(c, err, "Failed to test alert")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    delivery,
+	})
+}
+
+// writeError maps invalid alerts to 400 and missing ones to 404
+func (h *AlertHandler) writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAlert):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert data", "details": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+	default:
+		h.logger.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
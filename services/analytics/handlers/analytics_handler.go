@@ -1,27 +1,95 @@
 package handlers
 
 import (
+	"analytics-app/models"
 	"analytics-app/services"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog"
 )
 
 type AnalyticsHandler struct {
-	service *services.AnalyticsService
-	logger  zerolog.Logger
+	service   *services.AnalyticsService
+	anomalies *services.AnomalyService
+	logger    zerolog.Logger
 }
 
-func NewAnalyticsHandler(service *services.AnalyticsService, logger zerolog.Logger) *AnalyticsHandler {
+func NewAnalyticsHandler(service *services.AnalyticsService, anomalies *services.AnomalyService, logger zerolog.Logger) *AnalyticsHandler {
 	return &AnalyticsHandler{
-		service: service,
-		logger:  logger,
+		service:   service,
+		anomalies: anomalies,
+		logger:    logger,
 	}
 }
 
+// parseDateRange reads the reporting period from the start, end, days and timezone query
+// parameters. It writes a 400 response and returns false if they are invalid.
+func (h *AnalyticsHandler) parseDateRange(c *gin.Context, defaultDays int) (models.DateRange, bool) {
+	days := defaultDays
+	if d := c.Query("days"); d != "" {
+		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
+			days = parsedDays
+		}
+	}
+
+	dateRange, err := models.NewDateRange(c.Query("start"), c.Query("end"), days, c.Query("timezone"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range", "details": err.Error()})
+		return models.DateRange{}, false
+	}
+
+	return dateRange, true
+}
+
+// parseFilters reads the repeatable filter query parameter ("dimension:operator:value")
+// and the segment parameter (a saved segment's ID). It writes a 400 response and
+// returns false if any filter is invalid, or a 404 if the segment is not the website's.
+// Channel filters get the website's channel rules.
+func (h *AnalyticsHandler) parseFilters(c *gin.Context) (models.Filters, bool) {
+	filters, err := models.ParseFilters(c.QueryArray("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+		return nil, false
+	}
+
+	if raw := c.Query("segment"); raw != "" {
+		segmentID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+			return nil, false
+		}
+		segment, err := h.service.GetSegment(c.Request.Context(), c.Param("website_id"), segmentID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+			return nil, false
+		}
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to get segment")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get segment"})
+			return nil, false
+		}
+		filters = append(filters, segment.Filter())
+	}
+
+	if filters.HasChannel() {
+		rules, err := h.service.GetChannelRules(c.Request.Context(), c.Param("website_id"))
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to get channel rules")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel rules"})
+			return nil, false
+		}
+		filters = filters.WithChannels(rules)
+	}
+
+	return filters, true
+}
+
 func (h *AnalyticsHandler) GetDashboard(c *gin.Context) {
 	websiteID := c.Param("website_id")
 	if websiteID == "" {
@@ -29,14 +97,17 @@ func (h *AnalyticsHandler) GetDashboard(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
 	}
 
-	data, err := h.service.GetDashboard(c.Request.Context(), websiteID, days)
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.service.GetDashboard(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get dashboard data")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard data"})
@@ -53,11 +124,14 @@ func (h *AnalyticsHandler) GetTopPages(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -67,7 +141,7 @@ func (h *AnalyticsHandler) GetTopPages(c *gin.Context) {
 		}
 	}
 
-	pages, err := h.service.GetTopPages(c.Request.Context(), websiteID, days, limit)
+	pages, err := h.service.GetTopPages(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top pages")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top pages"})
@@ -76,11 +150,87 @@ func (h *AnalyticsHandler) GetTopPages(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id": websiteID,
-		"date_range": fmt.Sprintf("%d days", days),
+		"date_range": dateRange.Label(),
 		"top_pages":  pages,
 	})
 }
 
+func (h *AnalyticsHandler) GetEntryPages(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	limit := 10
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	pages, err := h.service.GetEntryPages(c.Request.Context(), websiteID, dateRange, filters, limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get entry pages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get entry pages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id":  websiteID,
+		"date_range":  dateRange.Label(),
+		"entry_pages": pages,
+	})
+}
+
+func (h *AnalyticsHandler) GetExitPages(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	limit := 10
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	pages, err := h.service.GetExitPages(c.Request.Context(), websiteID, dateRange, filters, limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get exit pages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get exit pages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id": websiteID,
+		"date_range": dateRange.Label(),
+		"exit_pages": pages,
+	})
+}
+
 func (h *AnalyticsHandler) GetPageUTMBreakdown(c *gin.Context) {
 	websiteID := c.Param("website_id")
 	if websiteID == "" {
@@ -94,15 +244,18 @@ func (h *AnalyticsHandler) GetPageUTMBreakdown(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	// Get page UTM breakdown from repository
-	breakdown, err := h.service.GetPageUTMBreakdown(c.Request.Context(), websiteID, pagePath, days)
+	breakdown, err := h.service.GetPageUTMBreakdown(c.Request.Context(), websiteID, pagePath, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get page UTM breakdown")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get page UTM breakdown"})
@@ -119,11 +272,14 @@ func (h *AnalyticsHandler) GetTopReferrers(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -133,7 +289,7 @@ func (h *AnalyticsHandler) GetTopReferrers(c *gin.Context) {
 		}
 	}
 
-	referrers, err := h.service.GetTopReferrers(c.Request.Context(), websiteID, days, limit)
+	referrers, err := h.service.GetTopReferrers(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top referrers")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top referrers"})
@@ -142,7 +298,7 @@ func (h *AnalyticsHandler) GetTopReferrers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":    websiteID,
-		"date_range":    fmt.Sprintf("%d days", days),
+		"date_range":    dateRange.Label(),
 		"top_referrers": referrers,
 	})
 }
@@ -154,11 +310,14 @@ func (h *AnalyticsHandler) GetTopSources(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -168,7 +327,7 @@ func (h *AnalyticsHandler) GetTopSources(c *gin.Context) {
 		}
 	}
 
-	sources, err := h.service.GetTopSources(c.Request.Context(), websiteID, days, limit)
+	sources, err := h.service.GetTopSources(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top sources")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top sources"})
@@ -177,7 +336,7 @@ func (h *AnalyticsHandler) GetTopSources(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":   websiteID,
-		"date_range":   fmt.Sprintf("%d days", days),
+		"date_range":   dateRange.Label(),
 		"top_sources": sources,
 	})
 }
@@ -189,11 +348,14 @@ func (h *AnalyticsHandler) GetTopCountries(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -203,7 +365,7 @@ func (h *AnalyticsHandler) GetTopCountries(c *gin.Context) {
 		}
 	}
 
-	countries, err := h.service.GetTopCountries(c.Request.Context(), websiteID, days, limit)
+	countries, err := h.service.GetTopCountries(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top countries")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top countries"})
@@ -212,7 +374,7 @@ func (h *AnalyticsHandler) GetTopCountries(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":    websiteID,
-		"date_range":    fmt.Sprintf("%d days", days),
+		"date_range":    dateRange.Label(),
 		"top_countries": countries,
 	})
 }
@@ -224,11 +386,14 @@ func (h *AnalyticsHandler) GetTopBrowsers(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -238,7 +403,7 @@ func (h *AnalyticsHandler) GetTopBrowsers(c *gin.Context) {
 		}
 	}
 
-	browsers, err := h.service.GetTopBrowsers(c.Request.Context(), websiteID, days, limit)
+	browsers, err := h.service.GetTopBrowsers(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top browsers")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top browsers"})
@@ -247,7 +412,7 @@ func (h *AnalyticsHandler) GetTopBrowsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":   websiteID,
-		"date_range":   fmt.Sprintf("%d days", days),
+		"date_range":   dateRange.Label(),
 		"top_browsers": browsers,
 	})
 }
@@ -259,11 +424,14 @@ func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -273,7 +441,7 @@ func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
 		}
 	}
 
-	devices, err := h.service.GetTopDevices(c.Request.Context(), websiteID, days, limit)
+	devices, err := h.service.GetTopDevices(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top devices")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top devices"})
@@ -282,7 +450,7 @@ func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":  websiteID,
-		"date_range":  fmt.Sprintf("%d days", days),
+		"date_range":  dateRange.Label(),
 		"top_devices": devices,
 	})
 }
@@ -294,11 +462,14 @@ func (h *AnalyticsHandler) GetTopOS(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	limit := 10
@@ -308,7 +479,7 @@ func (h *AnalyticsHandler) GetTopOS(c *gin.Context) {
 		}
 	}
 
-	osList, err := h.service.GetTopOS(c.Request.Context(), websiteID, days, limit)
+	osList, err := h.service.GetTopOS(c.Request.Context(), websiteID, dateRange, filters, limit)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top OS")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top OS"})
@@ -317,7 +488,7 @@ func (h *AnalyticsHandler) GetTopOS(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id": websiteID,
-		"date_range": fmt.Sprintf("%d days", days),
+		"date_range": dateRange.Label(),
 		"top_os":     osList,
 	})
 }
@@ -329,14 +500,17 @@ func (h *AnalyticsHandler) GetTrafficSummary(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
 	}
 
-	summary, err := h.service.GetTrafficSummary(c.Request.Context(), websiteID, days)
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.service.GetTrafficSummary(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get traffic summary")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get traffic summary"})
@@ -353,26 +527,43 @@ func (h *AnalyticsHandler) GetDailyStats(c *gin.Context) {
 		return
 	}
 
-	days := 30
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	// Get real data from database via service
-	stats, err := h.service.GetDailyStats(c.Request.Context(), websiteID, days)
+	stats, err := h.service.GetDailyStats(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get daily stats")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get daily stats"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"website_id":  websiteID,
-		"date_range":  fmt.Sprintf("%d days", days),
+		"date_range":  dateRange.Label(),
+		"timezone":    dateRange.Timezone,
 		"daily_stats": stats,
-	})
+	}
+
+	// Score the days against their seasonal baseline with ?anomalies=true. The stats
+	// are still served without them when scoring fails.
+	if c.Query("anomalies") == "true" {
+		anomalies, err := h.anomalies.DetectDailyAnomalies(c.Request.Context(), websiteID, dateRange, filters, stats)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to detect daily anomalies")
+		} else {
+			response["anomalies"] = anomalies
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *AnalyticsHandler) GetHourlyStats(c *gin.Context) {
@@ -382,29 +573,47 @@ func (h *AnalyticsHandler) GetHourlyStats(c *gin.Context) {
 		return
 	}
 
-	// Get timezone from query parameter, default to UTC
-	timezone := c.Query("timezone")
-	if timezone == "" {
-		timezone = "UTC"
+	// Defaults to the last 24 hours
+	dateRange, ok := h.parseDateRange(c, 1)
+	if !ok {
+		return
+	}
+	if err := dateRange.ValidateHourly(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date range", "details": err.Error()})
+		return
 	}
 
-	// Debug logging
-	fmt.Printf("DEBUG Handler: timezone=%s\n", timezone)
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
 
-	// Always fetch last 24 hours for hourly stats
-	stats, err := h.service.GetHourlyStats(c.Request.Context(), websiteID, 1, timezone)
+	stats, err := h.service.GetHourlyStats(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get hourly stats")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get hourly stats"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"website_id":   websiteID,
-		"date_range":   "24 hours",
-		"timezone":     timezone,
+		"date_range":   dateRange.Label(),
+		"timezone":     dateRange.Timezone,
 		"hourly_stats": stats,
-	})
+	}
+
+	// Score the hours against their seasonal baseline with ?anomalies=true. The stats
+	// are still served without them when scoring fails.
+	if c.Query("anomalies") == "true" {
+		anomalies, err := h.anomalies.DetectHourlyAnomalies(c.Request.Context(), websiteID, dateRange, filters, stats)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("Failed to detect hourly anomalies")
+		} else {
+			response["anomalies"] = anomalies
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *AnalyticsHandler) GetCustomEvents(c *gin.Context) {
@@ -414,21 +623,24 @@ func (h *AnalyticsHandler) GetCustomEvents(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
 	// Get custom events data from repository
-	customEvents, err := h.service.GetCustomEvents(c.Request.Context(), websiteID, days)
+	customEvents, err := h.service.GetCustomEvents(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get custom events")
 		// Return empty data in the format the frontend expects
 		c.JSON(http.StatusOK, gin.H{
 			"website_id":    websiteID,
-			"date_range":    fmt.Sprintf("%d days", days),
+			"date_range":    dateRange.Label(),
 			"top_events":    []interface{}{},
 			"timeseries":    []interface{}{},
 			"total_events":  0,
@@ -449,12 +661,12 @@ func (h *AnalyticsHandler) GetCustomEvents(c *gin.Context) {
 	}
 
 	// Get UTM performance data for this website
-	utmData, _ := h.service.GetUTMAnalytics(c.Request.Context(), websiteID, days)
+	utmData, _ := h.service.GetUTMAnalytics(c.Request.Context(), websiteID, dateRange, filters)
 
 	// Transform the data to match frontend expectations
 	transformedEvents := gin.H{
 		"website_id":      websiteID,
-		"date_range":      fmt.Sprintf("%d days", days),
+		"date_range":      dateRange.Label(),
 		"top_events":      customEvents,
 		"timeseries":      []interface{}{}, // TODO: Add timeseries data
 		"total_events":    totalEvents,
@@ -484,6 +696,32 @@ func (h *AnalyticsHandler) GetActivityTrends(c *gin.Context) {
 	})
 }
 
+// GetVisitorProfile returns a single visitor's sessions, events and goal conversions
+func (h *AnalyticsHandler) GetVisitorProfile(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	visitorID := c.Param("visitor_id")
+	if websiteID == "" || visitorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id and visitor_id are required"})
+		return
+	}
+
+	profile, err := h.service.GetVisitorProfile(c.Request.Context(), websiteID, visitorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get visitor profile")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get visitor profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id": websiteID,
+		"visitor":    profile,
+	})
+}
+
 // GetLiveVisitors returns the number of currently active visitors
 func (h *AnalyticsHandler) GetLiveVisitors(c *gin.Context) {
 	websiteID := c.Param("website_id")
@@ -515,14 +753,17 @@ func (h *AnalyticsHandler) GetGeolocationBreakdown(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
 	}
 
-	breakdown, err := h.service.GetGeolocationBreakdown(c.Request.Context(), websiteID, days)
+	breakdown, err := h.service.GetGeolocationBreakdown(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get geolocation breakdown")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get geolocation breakdown"})
@@ -531,3 +772,226 @@ func (h *AnalyticsHandler) GetGeolocationBreakdown(c *gin.Context) {
 
 	c.JSON(http.StatusOK, breakdown)
 }
+
+// GetRetention returns the cohort retention matrix. Visitors are grouped by the day, week
+// or month (period) they were first seen in; retention counts returning visits, or the
+// event type given by event_type.
+func (h *AnalyticsHandler) GetRetention(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	period, err := models.ParseRetentionPeriod(c.Query("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period", "details": err.Error()})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 56)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.GetRetention(c.Request.Context(), websiteID, dateRange, filters, period, c.Query("event_type"))
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get retention")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get retention"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetPaths returns Sankey-ready user flows from start_page forward, or to end_page backward
+func (h *AnalyticsHandler) GetPaths(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	steps, _ := strconv.Atoi(c.Query("steps"))
+	pagesPerStep, _ := strconv.Atoi(c.Query("pages_per_step"))
+	q, err := models.NewPathQuery(c.Query("start_page"), c.Query("end_page"), steps, pagesPerStep)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path query", "details": err.Error()})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.GetPaths(c.Request.Context(), websiteID, dateRange, filters, q)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get user paths")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user paths"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetGoalStats returns completions, conversions and conversion rate for each active goal
+func (h *AnalyticsHandler) GetGoalStats(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	goals, err := h.service.GetGoalStats(c.Request.Context(), websiteID, dateRange, filters)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get goal conversions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goal conversions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id": websiteID,
+		"date_range": dateRange.Label(),
+		"goals":      goals,
+	})
+}
+
+// GetGoalBreakdown returns the top values of a dimension (referrer, page, utm_source, ...)
+// with each active goal's conversions next to them
+func (h *AnalyticsHandler) GetGoalBreakdown(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dimension := c.DefaultQuery("dimension", "referrer")
+	if _, ok := models.FilterColumns[dimension]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dimension", "details": "unknown dimension " + strconv.Quote(dimension)})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	limit := 10
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	breakdown, err := h.service.GetGoalBreakdown(c.Request.Context(), websiteID, dateRange, filters, dimension, limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get goal breakdown")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goal breakdown"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id": websiteID,
+		"date_range": dateRange.Label(),
+		"dimension":  dimension,
+		"breakdown":  breakdown,
+	})
+}
+
+// GetAttribution credits goal_id or funnel_id conversions to sources, mediums, campaigns
+// or referrers using the selected attribution model
+func (h *AnalyticsHandler) GetAttribution(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	lookbackDays, _ := strconv.Atoi(c.Query("lookback_days"))
+	q, err := models.NewAttributionQuery(c.Query("model"), c.Query("dimension"), lookbackDays, c.Query("goal_id"), c.Query("funnel_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribution query", "details": err.Error()})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.GetAttribution(c.Request.Context(), websiteID, dateRange, filters, q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get attribution")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attribution"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetChannels returns visitors, sessions, pageviews and revenue per marketing channel
+func (h *AnalyticsHandler) GetChannels(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, ok := h.parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	filters, ok := h.parseFilters(c)
+	if !ok {
+		return
+	}
+
+	channels, err := h.service.GetChannels(c.Request.Context(), websiteID, dateRange, filters)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get channels")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id": websiteID,
+		"date_range": dateRange.Label(),
+		"channels":   channels,
+	})
+}
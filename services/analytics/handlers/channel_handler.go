@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+type ChannelHandler struct {
+	service *services.ChannelService
+	logger  zerolog.Logger
+}
+
+func NewChannelHandler(service *services.ChannelService, logger zerolog.Logger) *ChannelHandler {
+	return &ChannelHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetChannelRules returns the website's own rules and the built-in rules tried after them
+func (h *ChannelHandler) GetChannelRules(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	rules, err := h.service.GetRules(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get channel rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id":    websiteID,
+		"rules":         rules.Rules,
+		"updated_at":    rules.UpdatedAt,
+		"default_rules": models.DefaultChannelRules,
+	})
+}
+
+func (h *ChannelHandler) UpdateChannelRules(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	var req models.UpdateChannelRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind channel rules")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid channel rules",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rules, err := h.service.UpdateRules(c.Request.Context(), websiteID, req.Rules)
+	if errors.Is(err, services.ErrInvalidChannelRules) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel rules", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update channel rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update channel rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rules,
+	})
+}
+
+func (h *ChannelHandler) ResetChannelRules(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	if err := h.service.ResetRules(c.Request.Context(), websiteID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to reset channel rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset channel rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Channel rules reset to the built-in rules",
+	})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+type DigestHandler struct {
+	service *services.DigestService
+	logger  zerolog.Logger
+}
+
+func NewDigestHandler(service *services.DigestService, logger zerolog.Logger) *DigestHandler {
+	return &DigestHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *DigestHandler) CreateDigest(c *gin.Context) {
+	var req models.CreateDigestScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind digest data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid digest data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	schedule, err := h.service.CreateDigest(c.Request.Context(), &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to create digest")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    schedule,
+	})
+}
+
+func (h *DigestHandler) GetDigests(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	schedules, err := h.service.GetDigests(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get digests")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get digests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    schedules,
+	})
+}
+
+func (h *DigestHandler) GetDigest(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("digest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	schedule, err := h.service.GetDigest(c.Request.Context(), scheduleID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get digest")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"digest": schedule})
+}
+
+func (h *DigestHandler) UpdateDigest(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("digest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	var req models.UpdateDigestScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind digest update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid digest data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	schedule, err := h.service.UpdateDigest(c.Request.Context(), scheduleID, &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to update digest")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"digest": schedule})
+}
+
+func (h *DigestHandler) DeleteDigest(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("digest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	if err := h.service.DeleteDigest(c.Request.Context(), scheduleID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete digest")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete digest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Digest deleted successfully",
+	})
+}
+
+// PreviewDigest renders the digest a schedule would send now, as HTML or with
+// ?format=text as plain text
+func (h *DigestHandler) PreviewDigest(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("digest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	msg, err := h.service.PreviewDigest(c.Request.Context(), scheduleID)
+	if err != nil {
+		h.writeError(c, err, "Failed to preview digest")
+		return
+	}
+
+	if c.Query("format") == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+}
+
+// SendDigest sends a schedule's digest to its recipients now
+func (h *DigestHandler) SendDigest(c *gin.Context) {
+	scheduleID, err := uuid.Parse(c.Param("digest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	if err := h.service.SendDigest(c.Request.Context(), scheduleID); err != nil {
+		h.writeError(c, err, "Failed to send digest")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Digest sent successfully",
+	})
+}
+
+// writeError maps invalid digests to 400, missing ones to 404 and sends without email
+// configured to 503
+func (h *DigestHandler) writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDigest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest data", "details": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Digest not found"})
+	case errors.Is(err, services.ErrEmailDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured"})
+	default:
+		h.logger.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"analytics-app/middleware"
 	"analytics-app/models"
 	"analytics-app/services"
+	"analytics-app/utils"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
@@ -48,6 +50,26 @@ func (h *EventHandler) TrackEvent(c *gin.Context) {
 		return
 	}
 
+	if err := validateRevenueEvent(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid revenue event",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := h.service.NormalizeRevenue(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid revenue event",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Without an explicit event ID, derive one from the Idempotency-Key header
+	if key := c.GetHeader("Idempotency-Key"); key != "" && event.ID == uuid.Nil {
+		event.ID = services.IdempotentEventID(event.WebsiteID, key, 0)
+	}
+
 	response, err := h.service.TrackEvent(c.Request.Context(), &event)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to track event")
@@ -59,7 +81,7 @@ func (h *EventHandler) TrackEvent(c *gin.Context) {
 
 	// Increment event usage counter after successful tracking
 	userID := c.GetHeader("x-user-id")
-	if userID != "" {
+	if userID != "" && !response.Duplicate {
 		if err := h.subscriptionMiddleware.IncrementEventUsage(userID, 1); err != nil {
 			h.logger.Error().Err(err).Str("user_id", userID).Msg("Failed to increment event usage")
 			// Don't fail the request if usage increment fails
@@ -116,6 +138,25 @@ func (h *EventHandler) TrackBatchEvents(c *gin.Context) {
 			})
 			return
 		}
+		if err := validateRevenueEvent(&req.Events[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Event at index %d is an invalid revenue event", i),
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := h.service.NormalizeRevenue(&req.Events[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("Event at index %d is an invalid revenue event", i),
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// Without explicit event IDs, derive them from the Idempotency-Key header
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		services.AssignIdempotentIDs(&req, key)
 	}
 
 	response, err := h.service.TrackBatchEvents(c.Request.Context(), &req)
@@ -129,8 +170,8 @@ func (h *EventHandler) TrackBatchEvents(c *gin.Context) {
 
 	// Increment event usage counter after successful batch tracking
 	userID := c.GetHeader("x-user-id")
-	if userID != "" {
-		eventCount := len(req.Events)
+	if userID != "" && response.EventsCount > 0 {
+		eventCount := response.EventsCount
 		if err := h.subscriptionMiddleware.IncrementEventUsage(userID, eventCount); err != nil {
 			h.logger.Error().Err(err).Str("user_id", userID).Int("event_count", eventCount).Msg("Failed to increment batch event usage")
 			// Don't fail the request if usage increment fails
@@ -140,6 +181,15 @@ func (h *EventHandler) TrackBatchEvents(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// validateRevenueEvent runs utils.ValidateEvent on purchases before their revenue is
+// converted. Other events are not passed to it, as it rewrites unknown event types.
+func validateRevenueEvent(event *models.Event) error {
+	if event.EventType != models.RevenueEventType {
+		return nil
+	}
+	return utils.ValidateEvent(event)
+}
+
 // optimizeEventBatch processes events to reduce redundant data and parse user agents server-side
 func (h *EventHandler) optimizeEventBatch(req *models.BatchEventRequest) {
 	var sessionUserAgent string
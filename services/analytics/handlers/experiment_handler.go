@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+type ExperimentHandler struct {
+	service *services.ExperimentService
+	logger  zerolog.Logger
+}
+
+func NewExperimentHandler(service *services.ExperimentService, logger zerolog.Logger) *ExperimentHandler {
+	return &ExperimentHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *ExperimentHandler) CreateExperiment(c *gin.Context) {
+	var req models.CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind experiment data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid experiment data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	experiment, err := h.service.CreateExperiment(c.Request.Context(), &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to create experiment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    experiment,
+	})
+}
+
+func (h *ExperimentHandler) GetExperiments(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	experiments, err := h.service.GetExperiments(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get experiments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get experiments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    experiments,
+	})
+}
+
+func (h *ExperimentHandler) GetExperiment(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	experiment, err := h.service.GetExperiment(c.Request.Context(), experimentID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment})
+}
+
+func (h *ExperimentHandler) UpdateExperiment(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	var req models.UpdateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind experiment update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid experiment data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	experiment, err := h.service.UpdateExperiment(c.Request.Context(), experimentID, &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to update experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment})
+}
+
+func (h *ExperimentHandler) DeleteExperiment(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	if err := h.service.DeleteExperiment(c.Request.Context(), experimentID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete experiment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Experiment deleted successfully",
+	})
+}
+
+// GetResults reports each variant's conversion rate and significance against the control
+func (h *ExperimentHandler) GetResults(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	report, err := h.service.GetResults(c.Request.Context(), experimentID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get experiment results")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// writeError maps invalid experiments to 400 and missing experiments to 404
+func (h *ExperimentHandler) writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidExperiment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment data", "details": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+	default:
+		h.logger.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
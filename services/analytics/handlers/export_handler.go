@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+type ExportHandler struct {
+	service *services.ExportService
+	logger  zerolog.Logger
+}
+
+func NewExportHandler(service *services.ExportService, logger zerolog.Logger) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// CreateExport queues an export job; its status is polled with GetExport
+func (h *ExportHandler) CreateExport(c *gin.Context) {
+	var req models.CreateExportJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind export data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	job, err := h.service.CreateExport(c.Request.Context(), &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to create export")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+func (h *ExportHandler) GetExports(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	jobs, err := h.service.GetExports(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get exports")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get exports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    jobs,
+	})
+}
+
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	job, err := h.service.GetExport(c.Request.Context(), jobID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get export")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"export": job})
+}
+
+// DownloadExport serves a completed export's file
+func (h *ExportHandler) DownloadExport(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	job, path, err := h.service.OpenExport(c.Request.Context(), jobID)
+	if err != nil {
+		h.writeError(c, err, "Failed to download export")
+		return
+	}
+
+	// Large files take longer to send than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to clear write deadline for export download")
+	}
+
+	c.Header("Content-Type", job.Format.ContentType())
+	c.FileAttachment(path, job.FileName())
+}
+
+func (h *ExportHandler) DeleteExport(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	if err := h.service.DeleteExport(c.Request.Context(), jobID); err != nil {
+		h.writeError(c, err, "Failed to delete export")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Export deleted successfully",
+	})
+}
+
+// writeError maps invalid exports to 400, missing ones to 404, unfinished ones to 409
+// and expired ones to 410
+func (h *ExportHandler) writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidExport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export data", "details": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+	case errors.Is(err, services.ErrExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is not ready"})
+	case errors.Is(err, services.ErrExportExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Export has expired"})
+	default:
+		h.logger.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"analytics-app/models"
 	"analytics-app/services"
 	"analytics-app/utils"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog"
 )
 
@@ -38,6 +40,10 @@ func (h *FunnelHandler) CreateFunnel(c *gin.Context) {
 	}
 
 	funnel, err := h.service.CreateFunnel(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidFunnel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid funnel data", "details": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to create funnel")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create funnel"})
@@ -116,6 +122,9 @@ func (h *FunnelHandler) GetActiveFunnels(c *gin.Context) {
 				IsActive:    funnel.IsActive,
 				CreatedAt:   funnel.CreatedAt,
 				UpdatedAt:   funnel.UpdatedAt,
+
+				ConversionWindowSeconds: funnel.ConversionWindowSeconds,
+				Ordering:                funnel.Ordering,
 				// Don't include UserID in public response
 			}
 			activeFunnels = append(activeFunnels, publicFunnel)
@@ -163,6 +172,10 @@ func (h *FunnelHandler) UpdateFunnel(c *gin.Context) {
 	}
 
 	funnel, err := h.service.UpdateFunnel(c.Request.Context(), funnelID, &req)
+	if errors.Is(err, services.ErrInvalidFunnel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid funnel data", "details": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to update funnel")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update funnel"})
@@ -328,14 +341,27 @@ func (h *FunnelHandler) GetDetailedFunnelAnalytics(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
+	days, _ := strconv.Atoi(c.Query("days"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	q, err := models.NewFunnelQuery(days, c.Query("mode"), c.Query("per"), c.Query("breakdown"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid funnel query", "details": err.Error()})
+		return
+	}
+	if raw := c.Query("segment"); raw != "" {
+		segmentID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+			return
 		}
+		q.SegmentID = &segmentID
 	}
 
-	analytics, err := h.service.GetDetailedFunnelAnalytics(c.Request.Context(), funnelID, days)
+	analytics, err := h.service.GetDetailedFunnelAnalytics(c.Request.Context(), funnelID, q)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel or segment not found"})
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get detailed funnel analytics")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get detailed funnel analytics"})
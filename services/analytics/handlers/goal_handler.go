@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+type GoalHandler struct {
+	service *services.GoalService
+	logger  zerolog.Logger
+}
+
+func NewGoalHandler(service *services.GoalService, logger zerolog.Logger) *GoalHandler {
+	return &GoalHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *GoalHandler) CreateGoal(c *gin.Context) {
+	var req models.CreateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind goal data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid goal data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	goal, err := h.service.CreateGoal(c.Request.Context(), &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to create goal")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    goal,
+	})
+}
+
+func (h *GoalHandler) GetGoals(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	goals, err := h.service.GetGoals(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get goals")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    goals,
+	})
+}
+
+func (h *GoalHandler) GetGoal(c *gin.Context) {
+	goalID, err := uuid.Parse(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	goal, err := h.service.GetGoal(c.Request.Context(), goalID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get goal")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goal": goal})
+}
+
+func (h *GoalHandler) UpdateGoal(c *gin.Context) {
+	goalID, err := uuid.Parse(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	var req models.UpdateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind goal update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid goal data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	goal, err := h.service.UpdateGoal(c.Request.Context(), goalID, &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to update goal")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goal": goal})
+}
+
+func (h *GoalHandler) DeleteGoal(c *gin.Context) {
+	goalID, err := uuid.Parse(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	if err := h.service.DeleteGoal(c.Request.Context(), goalID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete goal")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Goal deleted successfully",
+	})
+}
+
+// writeError maps invalid goals to 400 and missing goals to 404
+func (h *GoalHandler) writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidGoal):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal data", "details": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+	default:
+		h.logger.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
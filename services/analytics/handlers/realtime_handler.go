@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"analytics-app/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+type RealtimeHandler struct {
+	service *services.RealtimeService
+	logger  zerolog.Logger
+}
+
+// NewRealtimeHandler creates the realtime handler. service is nil when realtime
+// streams are disabled.
+func NewRealtimeHandler(service *services.RealtimeService, logger zerolog.Logger) *RealtimeHandler {
+	return &RealtimeHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// StreamRealtime streams a website's live visitors, top pages and recent events as
+// Server-Sent Events, one "snapshot" event per update, until the client disconnects
+func (h *RealtimeHandler) StreamRealtime(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+	if h.service == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Realtime streams are disabled"})
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to clear write deadline for realtime stream")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start realtime stream"})
+		return
+	}
+
+	updates, unsubscribe := h.service.Subscribe(websiteID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Keep nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snapshot, ok := <-updates:
+			if !ok {
+				return
+			}
+			c.SSEvent("snapshot", snapshot)
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+type SegmentHandler struct {
+	service *services.SegmentService
+	logger  zerolog.Logger
+}
+
+func NewSegmentHandler(service *services.SegmentService, logger zerolog.Logger) *SegmentHandler {
+	return &SegmentHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *SegmentHandler) CreateSegment(c *gin.Context) {
+	var req models.CreateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind segment data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid segment data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	segment, err := h.service.CreateSegment(c.Request.Context(), &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to create segment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    segment,
+	})
+}
+
+func (h *SegmentHandler) GetSegments(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	segments, err := h.service.GetSegments(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get segments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get segments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    segments,
+	})
+}
+
+func (h *SegmentHandler) GetSegment(c *gin.Context) {
+	segmentID, err := uuid.Parse(c.Param("segment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	segment, err := h.service.GetSegment(c.Request.Context(), segmentID)
+	if err != nil {
+		h.writeError(c, err, "Failed to get segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segment": segment})
+}
+
+func (h *SegmentHandler) UpdateSegment(c *gin.Context) {
+	segmentID, err := uuid.Parse(c.Param("segment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	var req models.UpdateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind segment update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid segment data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	segment, err := h.service.UpdateSegment(c.Request.Context(), segmentID, &req)
+	if err != nil {
+		h.writeError(c, err, "Failed to update segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segment": segment})
+}
+
+func (h *SegmentHandler) DeleteSegment(c *gin.Context) {
+	segmentID, err := uuid.Parse(c.Param("segment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	if err := h.service.DeleteSegment(c.Request.Context(), segmentID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete segment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete segment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Segment deleted successfully",
+	})
+}
+
+// writeError maps invalid segments to 400 and missing segments to 404
+func (h *SegmentHandler) writeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSegment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment data", "details": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+	default:
+		h.logger.Error().Err(err).Msg(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
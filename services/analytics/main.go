@@ -9,6 +9,7 @@ import (
 	"analytics-app/repository"
 	"analytics-app/repository/privacy"
 	"analytics-app/services"
+	"analytics-app/utils"
 	"context"
 	"log"
 	"net/http"
@@ -80,26 +81,127 @@ func main() {
 
 	// Initialize repositories
 	eventRepo := repository.NewEventRepository(db, logger)
+	deadLetterRepo := repository.NewDeadLetterRepository(db, logger)
 	funnelRepo := repository.NewFunnelRepository(db)
-	analyticsRepo := repository.NewMainAnalyticsRepository(db)
+	goalRepo := repository.NewGoalRepository(db)
+	channelRuleRepo := repository.NewChannelRuleRepository(db)
+	experimentRepo := repository.NewExperimentRepository(db)
+	segmentRepo := repository.NewSegmentRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	digestRepo := repository.NewDigestRepository(db)
+	alertRepo := repository.NewAlertRepository(db)
+
+	// Hourly rollups, kept current by the compactor and read for reports over closed hours
+	var rollupRepo *repository.RollupRepository
+	if cfg.RollupsEnabled {
+		rollupRepo = repository.NewRollupRepository(db)
+	}
+	analyticsRepo := repository.NewMainAnalyticsRepository(db, rollupRepo)
 	privacyRepo := privacy.NewPrivacyRepository(db)
 
+	// Open the event spool so accepted events survive restarts
+	var eventSpool *services.EventSpool
+	if cfg.EventSpoolEnabled {
+		eventSpool, err = services.NewEventSpool(cfg.EventSpoolDir, cfg.EventSpoolSegmentBytes, logger)
+		if err != nil {
+			logger.Fatal().Err(err).Str("dir", cfg.EventSpoolDir).Msg("Failed to open event spool")
+		}
+		logger.Info().Str("dir", cfg.EventSpoolDir).Msg("Event spool enabled")
+	}
+
+	// Remember client-supplied event IDs so retried requests are not stored twice
+	var eventDedupe *services.EventDeduplicator
+	if cfg.EventDedupeEnabled {
+		eventDedupe = services.NewEventDeduplicator(redisClient, cfg.EventDedupeWindow, logger)
+	}
+
+	// Purchase amounts are converted into the reporting currency when events are accepted
+	currencyRates, err := utils.ParseCurrencyRates(cfg.CurrencyRates)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid CURRENCY_RATES")
+	}
+	currencies := utils.NewCurrencyConverter(cfg.RevenueCurrency, currencyRates)
+
+	// Accepted events are published to Redis and streamed to realtime subscribers
+	var realtimeService *services.RealtimeService
+	if cfg.RealtimeEnabled {
+		realtimeService = services.NewRealtimeService(redisClient, analyticsRepo, cfg.RealtimeInterval, logger)
+	}
+
 	// Initialize services
-	eventService := services.NewEventService(eventRepo, db, logger)
-	funnelService := services.NewFunnelService(funnelRepo, logger, redisClient)
-	analyticsService := services.NewAnalyticsService(analyticsRepo, logger)
+	eventService := services.NewEventService(eventRepo, deadLetterRepo, rollupRepo, eventDedupe, currencies, db, eventSpool, realtimeService, logger)
+	funnelService := services.NewFunnelService(funnelRepo, segmentRepo, channelRuleRepo, logger, redisClient)
+	goalService := services.NewGoalService(goalRepo, logger)
+	channelService := services.NewChannelService(channelRuleRepo, logger)
+	experimentService := services.NewExperimentService(experimentRepo, goalRepo, funnelRepo, logger)
+	segmentService := services.NewSegmentService(segmentRepo, logger)
+	analyticsService := services.NewAnalyticsService(analyticsRepo, goalRepo, channelRuleRepo, segmentRepo, currencies.Base(), logger)
+	anomalyService := services.NewAnomalyService(analyticsRepo, logger)
 	privacyService := services.NewPrivacyService(privacyRepo, logger)
 
+	exportService, err := services.NewExportService(exportRepo, analyticsService, cfg.ExportDir, cfg.ExportWorkers, cfg.ExportTTL, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Str("dir", cfg.ExportDir).Msg("Failed to open export store")
+	}
+	exportService.Start()
+	logger.Info().Str("dir", cfg.ExportDir).Int("workers", cfg.ExportWorkers).Msg("Export workers started")
+
+	// Digests are emailed by the scheduler only when SMTP is configured
+	var emailSender services.EmailSender
+	if cfg.SMTPHost != "" {
+		smtpSender, err := services.NewSMTPSender(services.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Invalid SMTP configuration")
+		}
+		emailSender = smtpSender
+	}
+	digestService := services.NewDigestService(digestRepo, analyticsService, emailSender, cfg.DashboardURL, logger)
+	if emailSender != nil {
+		digestService.Start()
+		logger.Info().Str("smtp_host", cfg.SMTPHost).Msg("Digest scheduler started")
+	} else {
+		logger.Warn().Msg("SMTP_HOST is not set, digests will not be emailed")
+	}
+
+	// Alert rules can be managed either way; they are only evaluated when enabled
+	webhookSender := services.NewWebhookSender(cfg.AlertWebhookTimeout, cfg.AlertWebhookAllowPrivate)
+	alertService := services.NewAlertService(alertRepo, analyticsRepo, goalRepo, webhookSender, cfg.AlertInterval, logger)
+	if cfg.AlertsEnabled {
+		alertService.Start()
+		logger.Info().Dur("interval", cfg.AlertInterval).Msg("Alert evaluator started")
+	}
+
+	var rollupCompactor *services.RollupCompactor
+	if rollupRepo != nil {
+		rollupCompactor = services.NewRollupCompactor(rollupRepo, cfg.RollupCompactInterval, logger)
+		rollupCompactor.Start()
+		logger.Info().Dur("interval", cfg.RollupCompactInterval).Msg("Hourly rollup compactor started")
+	}
+
 	// Initialize handlers
 	eventHandler := handlers.NewEventHandler(eventService, logger)
 	funnelHandler := handlers.NewFunnelHandler(funnelService, logger)
-	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, logger)
+	goalHandler := handlers.NewGoalHandler(goalService, logger)
+	channelHandler := handlers.NewChannelHandler(channelService, logger)
+	experimentHandler := handlers.NewExperimentHandler(experimentService, logger)
+	segmentHandler := handlers.NewSegmentHandler(segmentService, logger)
+	exportHandler := handlers.NewExportHandler(exportService, logger)
+	digestHandler := handlers.NewDigestHandler(digestService, logger)
+	alertHandler := handlers.NewAlertHandler(alertService, logger)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, anomalyService, logger)
+	realtimeHandler := handlers.NewRealtimeHandler(realtimeService, logger)
 	privacyHandler := handlers.NewPrivacyHandler(privacyService, logger)
 	healthHandler := handlers.NewHealthHandler(db, logger)
-	adminHandler := handlers.NewAdminHandler(funnelRepo, eventRepo, logger)
+	adminHandler := handlers.NewAdminHandler(funnelRepo, eventRepo, eventService, logger)
 
 	// Setup router
-	router := setupRouter(cfg, eventService, eventHandler, funnelHandler, analyticsHandler, privacyHandler, healthHandler, adminHandler, logger)
+	router := setupRouter(cfg, eventService, eventHandler, funnelHandler, goalHandler, channelHandler, experimentHandler, segmentHandler, exportHandler, digestHandler, alertHandler, analyticsHandler, realtimeHandler, privacyHandler, healthHandler, adminHandler, logger)
 
 	// Start server
 	server := &http.Server{
@@ -134,6 +236,17 @@ func main() {
 	if err := eventService.Shutdown(10 * time.Second); err != nil {
 		logger.Error().Err(err).Msg("Failed to shutdown event service gracefully")
 	}
+	if rollupCompactor != nil {
+		rollupCompactor.Stop()
+	}
+	exportService.Stop()
+	digestService.Stop()
+	alertService.Stop()
+
+	// End realtime streams, which would otherwise hold the server open
+	if realtimeService != nil {
+		realtimeService.Close()
+	}
 
 	// Then shutdown HTTP server
 	if err := server.Shutdown(shutdownCtx); err != nil {
@@ -190,7 +303,15 @@ func setupRouter(
 	eventService *services.EventService,
 	eventHandler *handlers.EventHandler,
 	funnelHandler *handlers.FunnelHandler,
+	goalHandler *handlers.GoalHandler,
+	channelHandler *handlers.ChannelHandler,
+	experimentHandler *handlers.ExperimentHandler,
+	segmentHandler *handlers.SegmentHandler,
+	exportHandler *handlers.ExportHandler,
+	digestHandler *handlers.DigestHandler,
+	alertHandler *handlers.AlertHandler,
 	analyticsHandler *handlers.AnalyticsHandler,
+	realtimeHandler *handlers.RealtimeHandler,
 	privacyHandler *handlers.PrivacyHandler,
 	healthHandler *handlers.HealthHandler,
 	adminHandler *handlers.AdminHandler,
@@ -245,6 +366,8 @@ func setupRouter(
 			analytics.GET("/dashboard/:website_id", analyticsHandler.GetDashboard)
 
 			analytics.GET("/top-pages/:website_id", analyticsHandler.GetTopPages)
+			analytics.GET("/entry-pages/:website_id", analyticsHandler.GetEntryPages)
+			analytics.GET("/exit-pages/:website_id", analyticsHandler.GetExitPages)
 			analytics.GET("/page-utm-breakdown/:website_id", analyticsHandler.GetPageUTMBreakdown)
 			analytics.GET("/top-referrers/:website_id", analyticsHandler.GetTopReferrers)
 			analytics.GET("/top-sources/:website_id", analyticsHandler.GetTopSources)
@@ -258,7 +381,15 @@ func setupRouter(
 			analytics.GET("/hourly-stats/:website_id", analyticsHandler.GetHourlyStats)
 			analytics.GET("/custom-events/:website_id", analyticsHandler.GetCustomEvents)
 			analytics.GET("/live-visitors/:website_id", analyticsHandler.GetLiveVisitors)
+			analytics.GET("/realtime/:website_id/stream", realtimeHandler.StreamRealtime)
+			analytics.GET("/visitors/:website_id/:visitor_id", analyticsHandler.GetVisitorProfile)
 			analytics.GET("/geolocation-breakdown/:website_id", analyticsHandler.GetGeolocationBreakdown)
+			analytics.GET("/retention/:website_id", analyticsHandler.GetRetention)
+			analytics.GET("/paths/:website_id", analyticsHandler.GetPaths)
+			analytics.GET("/goals/:website_id", analyticsHandler.GetGoalStats)
+			analytics.GET("/goals/:website_id/breakdown", analyticsHandler.GetGoalBreakdown)
+			analytics.GET("/attribution/:website_id", analyticsHandler.GetAttribution)
+			analytics.GET("/channels/:website_id", analyticsHandler.GetChannels)
 		}
 
 		// Public funnel routes (no auth required) - must be before parameterized routes
@@ -278,6 +409,79 @@ func setupRouter(
 			funnels.POST("/compare", funnelHandler.CompareFunnels)
 		}
 
+		// Goal routes
+		goals := v1.Group("/goals")
+		{
+			goals.POST("/", goalHandler.CreateGoal)
+			goals.GET("/", goalHandler.GetGoals)
+			goals.GET("/:goal_id", goalHandler.GetGoal)
+			goals.PUT("/:goal_id", goalHandler.UpdateGoal)
+			goals.DELETE("/:goal_id", goalHandler.DeleteGoal)
+		}
+
+		// Channel rule routes
+		channels := v1.Group("/channels")
+		{
+			channels.GET("/:website_id/rules", channelHandler.GetChannelRules)
+			channels.PUT("/:website_id/rules", channelHandler.UpdateChannelRules)
+			channels.DELETE("/:website_id/rules", channelHandler.ResetChannelRules)
+		}
+
+		// Experiment routes
+		experiments := v1.Group("/experiments")
+		{
+			experiments.POST("/", experimentHandler.CreateExperiment)
+			experiments.GET("/", experimentHandler.GetExperiments)
+			experiments.GET("/:experiment_id", experimentHandler.GetExperiment)
+			experiments.PUT("/:experiment_id", experimentHandler.UpdateExperiment)
+			experiments.DELETE("/:experiment_id", experimentHandler.DeleteExperiment)
+			experiments.GET("/:experiment_id/results", experimentHandler.GetResults)
+		}
+
+		// Segment routes
+		segments := v1.Group("/segments")
+		{
+			segments.POST("/", segmentHandler.CreateSegment)
+			segments.GET("/", segmentHandler.GetSegments)
+			segments.GET("/:segment_id", segmentHandler.GetSegment)
+			segments.PUT("/:segment_id", segmentHandler.UpdateSegment)
+			segments.DELETE("/:segment_id", segmentHandler.DeleteSegment)
+		}
+
+		// Export routes
+		exports := v1.Group("/exports")
+		{
+			exports.POST("/", exportHandler.CreateExport)
+			exports.GET("/", exportHandler.GetExports)
+			exports.GET("/:export_id", exportHandler.GetExport)
+			exports.GET("/:export_id/download", exportHandler.DownloadExport)
+			exports.DELETE("/:export_id", exportHandler.DeleteExport)
+		}
+
+		// Digest routes
+		digests := v1.Group("/digests")
+		{
+			digests.POST("/", digestHandler.CreateDigest)
+			digests.GET("/", digestHandler.GetDigests)
+			digests.GET("/:digest_id", digestHandler.GetDigest)
+			digests.PUT("/:digest_id", digestHandler.UpdateDigest)
+			digests.DELETE("/:digest_id", digestHandler.DeleteDigest)
+			digests.GET("/:digest_id/preview", digestHandler.PreviewDigest)
+			digests.POST("/:digest_id/send", digestHandler.SendDigest)
+		}
+
+		// Alert routes
+		alerts := v1.Group("/alerts")
+		{
+			alerts.POST("/", alertHandler.CreateAlert)
+			alerts.GET("/", alertHandler.GetAlerts)
+			alerts.GET("/:alert_id", alertHandler.GetAlert)
+			alerts.PUT("/:alert_id", alertHandler.UpdateAlert)
+			alerts.DELETE("/:alert_id", alertHandler.DeleteAlert)
+			alerts.GET("/:alert_id/deliveries", alertHandler.GetDeliveries)
+			alerts.POST("/:alert_id/test", alertHandler.TestAlert)
+		}
+
 		// Privacy routes
 		privacy := v1.Group("/privacy")
 		{
@@ -295,6 +499,9 @@ func setupRouter(
 			admin.GET("/funnels/stats", adminHandler.GetFunnelStats)
 			admin.GET("/analytics/stats", adminHandler.GetAnalyticsStats)
 			admin.GET("/funnels", adminHandler.GetFunnelsList)
+			admin.GET("/dead-letter", adminHandler.GetDeadLetters)
+			admin.POST("/dead-letter/replay", adminHandler.ReplayDeadLetters)
+			admin.DELETE("/dead-letter", adminHandler.PurgeDeadLetters)
 		}
 	}
 
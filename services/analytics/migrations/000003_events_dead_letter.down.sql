@@ -0,0 +1,5 @@
+-- Rollback events dead-letter table
+
+DROP INDEX IF EXISTS idx_events_dead_letter_failed_at;
+DROP INDEX IF EXISTS idx_events_dead_letter_website;
+DROP TABLE IF EXISTS events_dead_letter;
@@ -0,0 +1,16 @@
+-- Dead-letter table for events that could not be stored after all retries
+
+CREATE TABLE IF NOT EXISTS events_dead_letter (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL,
+    website_id VARCHAR(24) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_dead_letter_website ON events_dead_letter(website_id, failed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_events_dead_letter_failed_at ON events_dead_letter(failed_at);
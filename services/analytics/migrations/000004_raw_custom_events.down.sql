@@ -0,0 +1,8 @@
+-- Rollback raw custom event storage
+
+DROP INDEX IF EXISTS idx_custom_events_aggregated_bucket;
+ALTER TABLE custom_events_aggregated DROP COLUMN IF EXISTS bucket;
+
+DROP INDEX IF EXISTS idx_events_properties;
+DROP INDEX IF EXISTS idx_events_website_type_timestamp;
+
@@ -0,0 +1,34 @@
+-- Custom events are now stored individually in the events table.
+-- custom_events_aggregated becomes an hourly rollup derived from them.
+
+-- Indexes for querying custom events and their properties
+CREATE INDEX IF NOT EXISTS idx_events_website_type_timestamp ON events(website_id, event_type, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_events_properties ON events USING GIN (properties jsonb_path_ops) WHERE properties IS NOT NULL;
+
+-- Give every rollup row an hour bucket
+ALTER TABLE custom_events_aggregated ADD COLUMN IF NOT EXISTS bucket TIMESTAMPTZ;
+UPDATE custom_events_aggregated SET bucket = date_trunc('hour', first_seen AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' WHERE bucket IS NULL;
+
+-- Merge rows that now fall into the same bucket into the most recent one
+UPDATE custom_events_aggregated c
+SET count = m.total_count, first_seen = m.first_seen
+FROM (
+    SELECT website_id, event_signature, bucket, SUM(count) AS total_count, MIN(first_seen) AS first_seen, MAX(last_seen) AS last_seen
+    FROM custom_events_aggregated
+    GROUP BY website_id, event_signature, bucket
+    HAVING COUNT(*) > 1
+) m
+WHERE c.website_id = m.website_id
+AND c.event_signature = m.event_signature
+AND c.bucket = m.bucket
+AND c.last_seen = m.last_seen;
+
+DELETE FROM custom_events_aggregated c
+USING custom_events_aggregated newer
+WHERE c.website_id = newer.website_id
+AND c.event_signature = newer.event_signature
+AND c.bucket = newer.bucket
+AND (c.last_seen, c.id) < (newer.last_seen, newer.id);
+
+ALTER TABLE custom_events_aggregated ALTER COLUMN bucket SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_events_aggregated_bucket ON custom_events_aggregated(website_id, event_signature, bucket);
@@ -0,0 +1,6 @@
+-- Rollback hourly rollups
+
+DROP TABLE IF EXISTS analytics_rollup_dirty;
+DROP TABLE IF EXISTS analytics_hourly_dimensions;
+DROP TABLE IF EXISTS analytics_hourly_visitors;
+DROP TABLE IF EXISTS analytics_hourly;
@@ -0,0 +1,56 @@
+-- Hourly rollups of pageview traffic, so dashboards over closed hours do not scan raw events
+
+CREATE TABLE IF NOT EXISTS analytics_hourly (
+    website_id VARCHAR(24) NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    pageviews INTEGER NOT NULL DEFAULT 0,
+    visitors INTEGER NOT NULL DEFAULT 0,
+    -- Sessions are counted in the hour of their first pageview
+    sessions INTEGER NOT NULL DEFAULT 0,
+    bounces INTEGER NOT NULL DEFAULT 0,
+    -- Session duration summed once per pageview, matching how the raw dashboard averages it
+    total_duration DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, hour)
+);
+
+-- Distinct visitors per hour, so unique visitors in the totals and time series stay exact
+CREATE TABLE IF NOT EXISTS analytics_hourly_visitors (
+    website_id VARCHAR(24) NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    visitor_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (website_id, hour, visitor_id)
+);
+
+-- Per-hour traffic for each value of the top-list dimensions (page, country, browser, device, os)
+CREATE TABLE IF NOT EXISTS analytics_hourly_dimensions (
+    website_id VARCHAR(24) NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    dimension VARCHAR(32) NOT NULL,
+    value TEXT NOT NULL,
+    pageviews INTEGER NOT NULL DEFAULT 0,
+    visitors INTEGER NOT NULL DEFAULT 0,
+    sessions INTEGER NOT NULL DEFAULT 0,
+    bounces INTEGER NOT NULL DEFAULT 0,
+    entries INTEGER NOT NULL DEFAULT 0,
+    time_on_page_total BIGINT NOT NULL DEFAULT 0,
+    time_on_page_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (website_id, dimension, hour, value)
+);
+
+-- Hours whose events changed since they were last rolled up
+CREATE TABLE IF NOT EXISTS analytics_rollup_dirty (
+    website_id VARCHAR(24) NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    marked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_rollup_dirty_marked_at ON analytics_rollup_dirty(marked_at);
+
+-- Backfill: queue every hour that already has pageviews for the compactor
+INSERT INTO analytics_rollup_dirty (website_id, hour)
+SELECT DISTINCT website_id, date_trunc('hour', timestamp AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+FROM events
+WHERE event_type = 'pageview'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+-- Goals: conversions a website tracks, completed by a matching pageview or custom event
+CREATE TABLE IF NOT EXISTS goals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    user_id VARCHAR(24),
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('pageview', 'event')),
+    page_pattern TEXT,
+    event_name VARCHAR(255),
+    properties JSONB NOT NULL DEFAULT '[]',
+    value NUMERIC(14, 2),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_website_id ON goals(website_id);
@@ -0,0 +1,3 @@
+-- Rollback channel rules table
+
+DROP TABLE IF EXISTS channel_rules;
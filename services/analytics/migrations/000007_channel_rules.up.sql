@@ -0,0 +1,6 @@
+-- Channel rules a website adds in front of the built-in channel grouping
+CREATE TABLE IF NOT EXISTS channel_rules (
+    website_id VARCHAR(24) PRIMARY KEY,
+    rules JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+-- Rollback funnel conversion window and ordering columns
+
+ALTER TABLE funnels
+DROP COLUMN IF EXISTS ordering,
+DROP COLUMN IF EXISTS conversion_window_seconds;
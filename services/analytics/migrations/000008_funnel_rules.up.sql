@@ -0,0 +1,4 @@
+-- Funnel-wide conversion window and step ordering
+ALTER TABLE funnels
+ADD COLUMN IF NOT EXISTS conversion_window_seconds INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS ordering VARCHAR(10) NOT NULL DEFAULT 'loose';
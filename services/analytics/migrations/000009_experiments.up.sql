@@ -0,0 +1,22 @@
+-- Experiments: A/B tests whose variants come from event properties
+CREATE TABLE IF NOT EXISTS experiments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    user_id VARCHAR(24),
+    name VARCHAR(255) NOT NULL,
+    experiment_key VARCHAR(255) NOT NULL,
+    experiment_property VARCHAR(255) NOT NULL DEFAULT 'experiment',
+    variant_property VARCHAR(255) NOT NULL DEFAULT 'variant',
+    control_variant VARCHAR(255) NOT NULL,
+    goal_id UUID,
+    funnel_id UUID,
+    confidence DOUBLE PRECISION NOT NULL DEFAULT 0.95,
+    sample_size INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((goal_id IS NULL) <> (funnel_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_website_id ON experiments(website_id);
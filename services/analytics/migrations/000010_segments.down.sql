@@ -0,0 +1,4 @@
+-- Rollback segments table
+
+DROP INDEX IF EXISTS idx_segments_website_id;
+DROP TABLE IF EXISTS segments;
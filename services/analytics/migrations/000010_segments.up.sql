@@ -0,0 +1,14 @@
+-- Segments: saved filter sets selecting sessions or visitors for any report
+CREATE TABLE IF NOT EXISTS segments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    user_id VARCHAR(24),
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    scope VARCHAR(10) NOT NULL DEFAULT 'session' CHECK (scope IN ('session', 'visitor')),
+    filters JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_segments_website_id ON segments(website_id);
@@ -0,0 +1,5 @@
+-- Rollback export jobs table
+
+DROP INDEX IF EXISTS idx_export_jobs_status;
+DROP INDEX IF EXISTS idx_export_jobs_website_id;
+DROP TABLE IF EXISTS export_jobs;
@@ -0,0 +1,24 @@
+-- Export jobs: reports and raw events written to downloadable files in the background
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    user_id VARCHAR(24),
+    source VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'ndjson', 'parquet')),
+    start_date TIMESTAMPTZ NOT NULL,
+    end_date TIMESTAMPTZ NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    filters JSONB NOT NULL DEFAULT '[]',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'expired')),
+    rows_exported BIGINT NOT NULL DEFAULT 0,
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_website_id ON export_jobs(website_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_export_jobs_status ON export_jobs(status, created_at);
@@ -0,0 +1,5 @@
+-- Rollback digest schedules table
+
+DROP INDEX IF EXISTS idx_digest_schedules_due;
+DROP INDEX IF EXISTS idx_digest_schedules_website_id;
+DROP TABLE IF EXISTS digest_schedules;
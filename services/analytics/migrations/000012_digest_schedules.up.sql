@@ -0,0 +1,21 @@
+-- Digest schedules: weekly and monthly email reports of a website's numbers
+CREATE TABLE IF NOT EXISTS digest_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    user_id VARCHAR(24),
+    name VARCHAR(255) NOT NULL,
+    frequency VARCHAR(10) NOT NULL CHECK (frequency IN ('weekly', 'monthly')),
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    send_hour SMALLINT NOT NULL DEFAULT 8 CHECK (send_hour BETWEEN 0 AND 23),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_sent_at TIMESTAMPTZ,
+    last_error TEXT,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_schedules_website_id ON digest_schedules(website_id);
+CREATE INDEX IF NOT EXISTS idx_digest_schedules_due ON digest_schedules(next_run_at) WHERE enabled;
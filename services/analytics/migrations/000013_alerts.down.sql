@@ -0,0 +1,9 @@
+-- Rollback alert rules and deliveries tables
+
+DROP INDEX IF EXISTS idx_alert_deliveries_pending;
+DROP INDEX IF EXISTS idx_alert_deliveries_rule_id;
+DROP TABLE IF EXISTS alert_deliveries;
+
+DROP INDEX IF EXISTS idx_alert_rules_due;
+DROP INDEX IF EXISTS idx_alert_rules_website_id;
+DROP TABLE IF EXISTS alert_rules;
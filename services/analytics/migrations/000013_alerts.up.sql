@@ -0,0 +1,46 @@
+-- Alert rules: thresholds on a website's recent traffic, notified through webhooks
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    user_id VARCHAR(24),
+    name VARCHAR(255) NOT NULL,
+    metric VARCHAR(20) NOT NULL CHECK (metric IN ('visitors', 'pageviews', 'events', 'goal_conversions')),
+    goal_id UUID,
+    operator VARCHAR(2) NOT NULL CHECK (operator IN ('gt', 'lt')),
+    threshold DOUBLE PRECISION NOT NULL,
+    baseline VARCHAR(10) NOT NULL DEFAULT 'fixed' CHECK (baseline IN ('fixed', 'last_week')),
+    window_minutes INTEGER NOT NULL,
+    webhook_url TEXT NOT NULL,
+    webhook_secret VARCHAR(100) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    state VARCHAR(10) NOT NULL DEFAULT 'ok' CHECK (state IN ('ok', 'firing')),
+    last_value DOUBLE PRECISION,
+    last_evaluated_at TIMESTAMPTZ,
+    fired_at TIMESTAMPTZ,
+    resolved_at TIMESTAMPTZ,
+    next_evaluation_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_website_id ON alert_rules(website_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_due ON alert_rules(next_evaluation_at) WHERE enabled;
+
+-- Alert deliveries: webhook notifications of alert state changes, retried until accepted
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+    id UUID PRIMARY KEY,
+    rule_id UUID NOT NULL,
+    website_id VARCHAR(24) NOT NULL,
+    event VARCHAR(20) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    response_status INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_rule_id ON alert_deliveries(rule_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_pending ON alert_deliveries(next_attempt_at) WHERE status = 'pending';
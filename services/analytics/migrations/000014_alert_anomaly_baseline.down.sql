@@ -0,0 +1,8 @@
+-- Rollback anomaly alert baseline
+
+DELETE FROM alert_deliveries WHERE rule_id IN (SELECT id FROM alert_rules WHERE baseline = 'anomaly');
+DELETE FROM alert_rules WHERE baseline = 'anomaly';
+
+ALTER TABLE alert_rules
+DROP CONSTRAINT IF EXISTS alert_rules_baseline_check,
+ADD CONSTRAINT alert_rules_baseline_check CHECK (baseline IN ('fixed', 'last_week'));
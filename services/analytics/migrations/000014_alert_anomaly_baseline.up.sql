@@ -0,0 +1,4 @@
+-- Alert rules can compare with a seasonal anomaly baseline
+ALTER TABLE alert_rules
+DROP CONSTRAINT IF EXISTS alert_rules_baseline_check,
+ADD CONSTRAINT alert_rules_baseline_check CHECK (baseline IN ('fixed', 'last_week', 'anomaly'));
@@ -0,0 +1,3 @@
+-- Rollback hourly dimension visitors
+
+DROP TABLE IF EXISTS analytics_hourly_dimension_visitors;
@@ -0,0 +1,18 @@
+-- Distinct visitors per hour for each dimension value, so the top lists read from the
+-- rollups count unique visitors over a range exactly instead of summing hourly counts
+
+CREATE TABLE IF NOT EXISTS analytics_hourly_dimension_visitors (
+    website_id VARCHAR(24) NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    dimension VARCHAR(32) NOT NULL,
+    value TEXT NOT NULL,
+    visitor_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (website_id, dimension, hour, value, visitor_id)
+);
+
+-- Pages are now rolled up normalized: queue every rolled-up hour for the compactor, so
+-- existing hours get normalized pages and dimension visitors
+INSERT INTO analytics_rollup_dirty (website_id, hour)
+SELECT website_id, hour
+FROM analytics_hourly
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,349 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlertMetric is what an alert rule measures over its window
+type AlertMetric string
+
+const (
+	AlertVisitors  AlertMetric = "visitors"
+	AlertPageviews AlertMetric = "pageviews"
+	// AlertEvents counts events of every type, so it also catches broken custom tracking
+	AlertEvents AlertMetric = "events"
+	// AlertGoalConversions counts sessions converting on the rule's goal
+	AlertGoalConversions AlertMetric = "goal_conversions"
+)
+
+// AlertOperator is how an alert rule compares its metric with its limit
+type AlertOperator string
+
+const (
+	AlertAbove AlertOperator = "gt"
+	AlertBelow AlertOperator = "lt"
+)
+
+// AlertBaseline is what an alert rule's threshold is relative to
+type AlertBaseline string
+
+const (
+	// AlertFixed compares the metric with the threshold itself
+	AlertFixed AlertBaseline = "fixed"
+	// AlertLastWeek compares the metric with threshold percent of the same window a
+	// week earlier
+	AlertLastWeek AlertBaseline = "last_week"
+	// AlertAnomaly compares the metric with the same window in each of the previous
+	// AnomalyAlertSeasons weeks, and the threshold is the anomaly score to alert at
+	AlertAnomaly AlertBaseline = "anomaly"
+)
+
+// AnomalyAlertSeasons is how many earlier weeks anomaly alerts compare with
+const AnomalyAlertSeasons = 4
+
+// AlertState is whether an alert rule's condition currently holds
+type AlertState string
+
+const (
+	AlertOK     AlertState = "ok"
+	AlertFiring AlertState = "firing"
+)
+
+// AlertEvent is what a webhook delivery notifies of
+type AlertEvent string
+
+const (
+	AlertEventFiring   AlertEvent = "alert.firing"
+	AlertEventResolved AlertEvent = "alert.resolved"
+	// AlertEventTest is sent on request to check a webhook
+	AlertEventTest AlertEvent = "alert.test"
+)
+
+// AlertDeliveryStatus is where a webhook delivery is in its retries
+type AlertDeliveryStatus string
+
+const (
+	AlertDeliveryPending   AlertDeliveryStatus = "pending"
+	AlertDeliveryDelivered AlertDeliveryStatus = "delivered"
+	AlertDeliveryFailed    AlertDeliveryStatus = "failed"
+)
+
+const (
+	MinAlertWindowMinutes = 5
+	MaxAlertWindowMinutes = 24 * 60
+)
+
+// AlertRule watches a metric of a website over a trailing window and notifies its
+// webhook when the metric crosses the rule's limit and when it recovers. Only these
+// transitions are notified, so a rule that keeps firing notifies once.
+type AlertRule struct {
+	ID            uuid.UUID     `json:"id" db:"id"`
+	WebsiteID     string        `json:"website_id" db:"website_id"`
+	UserID        *string       `json:"user_id,omitempty" db:"user_id"`
+	Name          string        `json:"name" db:"name"`
+	Metric        AlertMetric   `json:"metric" db:"metric"`
+	GoalID        *uuid.UUID    `json:"goal_id,omitempty" db:"goal_id"`
+	Operator      AlertOperator `json:"operator" db:"operator"`
+	Threshold     float64       `json:"threshold" db:"threshold"`
+	Baseline      AlertBaseline `json:"baseline" db:"baseline"`
+	WindowMinutes int           `json:"window_minutes" db:"window_minutes"`
+	WebhookURL    string        `json:"webhook_url" db:"webhook_url"`
+	// WebhookSecret signs the rule's webhook deliveries
+	WebhookSecret string `json:"webhook_secret" db:"webhook_secret"`
+	Enabled       bool   `json:"enabled" db:"enabled"`
+
+	State           AlertState `json:"state" db:"state"`
+	LastValue       *float64   `json:"last_value,omitempty" db:"last_value"`
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at,omitempty" db:"last_evaluated_at"`
+	FiredAt         *time.Time `json:"fired_at,omitempty" db:"fired_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateAlertRuleRequest struct {
+	WebsiteID     string        `json:"website_id" binding:"required"`
+	UserID        *string       `json:"user_id,omitempty"`
+	Name          string        `json:"name" binding:"required"`
+	Metric        AlertMetric   `json:"metric" binding:"required"`
+	GoalID        *uuid.UUID    `json:"goal_id"`
+	Operator      AlertOperator `json:"operator" binding:"required"`
+	Threshold     float64       `json:"threshold"`
+	Baseline      AlertBaseline `json:"baseline"`
+	WindowMinutes int           `json:"window_minutes"`
+	WebhookURL    string        `json:"webhook_url" binding:"required"`
+	Enabled       *bool         `json:"enabled"`
+}
+
+type UpdateAlertRuleRequest struct {
+	Name          *string        `json:"name"`
+	Metric        *AlertMetric   `json:"metric"`
+	GoalID        *uuid.UUID     `json:"goal_id"`
+	Operator      *AlertOperator `json:"operator"`
+	Threshold     *float64       `json:"threshold"`
+	Baseline      *AlertBaseline `json:"baseline"`
+	WindowMinutes *int           `json:"window_minutes"`
+	WebhookURL    *string        `json:"webhook_url"`
+	Enabled       *bool          `json:"enabled"`
+	// RotateSecret replaces the webhook secret
+	RotateSecret bool `json:"rotate_secret"`
+}
+
+// Validate checks the metric, comparison, window and webhook URL
+func (r *AlertRule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("alert name is required")
+	}
+
+	switch r.Metric {
+	case AlertVisitors, AlertPageviews, AlertEvents:
+		if r.GoalID != nil {
+			return fmt.Errorf("goal_id is only allowed for goal_conversions alerts")
+		}
+	case AlertGoalConversions:
+		if r.GoalID == nil {
+			return fmt.Errorf("goal_id is required for goal_conversions alerts")
+		}
+	default:
+		return fmt.Errorf("unknown alert metric %q", r.Metric)
+	}
+
+	switch r.Operator {
+	case AlertAbove, AlertBelow:
+	default:
+		return fmt.Errorf("alert operator must be gt or lt, got %q", r.Operator)
+	}
+
+	switch r.Baseline {
+	case AlertFixed:
+		if r.Threshold < 0 {
+			return fmt.Errorf("threshold cannot be negative")
+		}
+	case AlertLastWeek:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive percentage of last week")
+		}
+	case AlertAnomaly:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be a positive anomaly score")
+		}
+	default:
+		return fmt.Errorf("alert baseline must be fixed, last_week or anomaly, got %q", r.Baseline)
+	}
+
+	if r.WindowMinutes < MinAlertWindowMinutes || r.WindowMinutes > MaxAlertWindowMinutes {
+		return fmt.Errorf("window_minutes must be between %d and %d", MinAlertWindowMinutes, MaxAlertWindowMinutes)
+	}
+
+	u, err := url.Parse(r.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an http or https URL")
+	}
+
+	return nil
+}
+
+// Window returns the rule's window ending at the given time
+func (r *AlertRule) Window(end time.Time) DateRange {
+	return DateRange{
+		Start:    end.Add(-time.Duration(r.WindowMinutes) * time.Minute),
+		End:      end,
+		Timezone: "UTC",
+	}
+}
+
+// Limit returns the value the metric is compared with: the threshold itself, or
+// threshold percent of the baseline value. It is not ok when a baseline of zero leaves
+// nothing to compare with.
+func (r *AlertRule) Limit(baseline float64) (float64, bool) {
+	if r.Baseline != AlertLastWeek {
+		return r.Threshold, true
+	}
+	if baseline <= 0 {
+		return 0, false
+	}
+	return r.Threshold / 100 * baseline, true
+}
+
+// AnomalyLimit returns the value the metric is compared with for anomaly rules: the
+// bound of the expected range on the operator's side. Like Limit, it is not ok when the
+// earlier weeks had no traffic.
+func (r *AlertRule) AnomalyLimit(score *AnomalyScore) (float64, bool) {
+	if score.Expected <= 0 {
+		return 0, false
+	}
+	if r.Operator == AlertAbove {
+		return score.Upper, true
+	}
+	return score.Lower, true
+}
+
+// Breached reports whether a value crosses the limit
+func (r *AlertRule) Breached(value, limit float64) bool {
+	if r.Operator == AlertAbove {
+		return value > limit
+	}
+	return value < limit
+}
+
+// Transition returns the rule's state after an evaluation and the event to notify of,
+// which is empty unless the state changed. Inconclusive evaluations keep the state.
+func (r *AlertRule) Transition(evaluation *AlertEvaluation) (AlertState, AlertEvent) {
+	if !evaluation.Conclusive {
+		return r.State, ""
+	}
+	switch {
+	case evaluation.Breached && r.State != AlertFiring:
+		return AlertFiring, AlertEventFiring
+	case !evaluation.Breached && r.State == AlertFiring:
+		return AlertOK, AlertEventResolved
+	}
+	return r.State, ""
+}
+
+// AlertMetrics are the counts alert rules measure over a window
+type AlertMetrics struct {
+	Visitors  int `json:"visitors"`
+	Pageviews int `json:"pageviews"`
+	Events    int `json:"events"`
+}
+
+// AlertEvaluation is the outcome of checking a rule over one window
+type AlertEvaluation struct {
+	Value float64 `json:"value"`
+	// BaselineValue is the metric over the same window a week earlier, for rules
+	// relative to last week, or its expected value for anomaly rules
+	BaselineValue *float64 `json:"baseline_value,omitempty"`
+	// Score is the anomaly score of the value, for anomaly rules
+	Score       *float64  `json:"score,omitempty"`
+	Limit       float64   `json:"limit"`
+	Breached    bool      `json:"breached"`
+	Conclusive  bool      `json:"-"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+// AlertDelivery is a webhook notification, retried until the webhook accepts it
+type AlertDelivery struct {
+	ID             uuid.UUID           `json:"id" db:"id"`
+	RuleID         uuid.UUID           `json:"alert_id" db:"rule_id"`
+	WebsiteID      string              `json:"website_id" db:"website_id"`
+	Event          AlertEvent          `json:"event" db:"event"`
+	Payload        json.RawMessage     `json:"payload" db:"payload"`
+	Status         AlertDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                 `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time           `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseStatus *int                `json:"response_status,omitempty" db:"response_status"`
+	LastError      *string             `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time          `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// AlertPayload is the JSON body of a webhook delivery. ID is the delivery's, so
+// receivers can ignore retries of a delivery they already processed.
+type AlertPayload struct {
+	ID         uuid.UUID        `json:"id"`
+	Event      AlertEvent       `json:"event"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Alert      AlertPayloadRule `json:"alert"`
+	Evaluation *AlertEvaluation `json:"evaluation,omitempty"`
+}
+
+// AlertPayloadRule is the rule a webhook delivery is about, without its secret
+type AlertPayloadRule struct {
+	ID            uuid.UUID     `json:"id"`
+	WebsiteID     string        `json:"website_id"`
+	Name          string        `json:"name"`
+	Metric        AlertMetric   `json:"metric"`
+	GoalID        *uuid.UUID    `json:"goal_id,omitempty"`
+	Operator      AlertOperator `json:"operator"`
+	Threshold     float64       `json:"threshold"`
+	Baseline      AlertBaseline `json:"baseline"`
+	WindowMinutes int           `json:"window_minutes"`
+	State         AlertState    `json:"state"`
+}
+
+// NewAlertDelivery builds a pending delivery of an event about the rule, with its
+// payload
+func NewAlertDelivery(rule *AlertRule, event AlertEvent, evaluation *AlertEvaluation, now time.Time) (*AlertDelivery, error) {
+	delivery := &AlertDelivery{
+		ID:            uuid.New(),
+		RuleID:        rule.ID,
+		WebsiteID:     rule.WebsiteID,
+		Event:         event,
+		Status:        AlertDeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	payload, err := json.Marshal(AlertPayload{
+		ID:        delivery.ID,
+		Event:     event,
+		CreatedAt: now,
+		Alert: AlertPayloadRule{
+			ID:            rule.ID,
+			WebsiteID:     rule.WebsiteID,
+			Name:          rule.Name,
+			Metric:        rule.Metric,
+			GoalID:        rule.GoalID,
+			Operator:      rule.Operator,
+			Threshold:     rule.Threshold,
+			Baseline:      rule.Baseline,
+			WindowMinutes: rule.WindowMinutes,
+			State:         rule.State,
+		},
+		Evaluation: evaluation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal alert payload: %w", err)
+	}
+	delivery.Payload = payload
+
+	return delivery, nil
+}
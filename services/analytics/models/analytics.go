@@ -56,6 +56,19 @@ type PageStat struct {
 	ExitRate      *float64 `json:"exit_rate" db:"exit_rate"`
 }
 
+// EntryExitPageStat - USED in top_pages_analytics.go for the entry and exit page reports.
+// BounceRate is single-page sessions per entry; ExitRate is exits per pageview.
+type EntryExitPageStat struct {
+	Page       string   `json:"page" db:"page"`
+	Entries    int      `json:"entries" db:"entries"`
+	Exits      int      `json:"exits" db:"exits"`
+	Views      int      `json:"views" db:"views"`
+	Unique     int      `json:"unique" db:"unique"`
+	BounceRate *float64 `json:"bounce_rate" db:"bounce_rate"`
+	ExitRate   *float64 `json:"exit_rate" db:"exit_rate"`
+	AvgTime    *float64 `json:"avg_time" db:"avg_time"`
+}
+
 // ReferrerStat - USED in top_referrers_analytics.go
 type ReferrerStat struct {
 	Referrer   string   `json:"referrer" db:"referrer"`
@@ -70,6 +83,8 @@ type SourceStat struct {
 	Views          int      `json:"views" db:"views"`
 	UniqueVisitors int      `json:"unique_visitors" db:"unique_visitors"`
 	BounceRate     *float64 `json:"bounce_rate" db:"bounce_rate"`
+	Orders         int      `json:"orders" db:"orders"`
+	Revenue        float64  `json:"revenue" db:"revenue"`
 }
 
 // CountryStat - USED in top_countries_analytics.go
@@ -164,6 +179,8 @@ type DashboardData struct {
 	TopSources      []SourceStat         `json:"top_sources"`
 	TopCountries    []CountryStat        `json:"top_countries"`
 	Geolocation     GeolocationBreakdown `json:"geolocation"`
+	Goals           []GoalStat           `json:"goals,omitempty"`
+	Revenue         *RevenueMetrics      `json:"revenue,omitempty"`
 }
 
 // LEGACY MODELS - Keep these for compatibility but they might not be actively used
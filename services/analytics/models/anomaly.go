@@ -0,0 +1,260 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// madScale turns a median absolute deviation into an estimate of the standard deviation
+// of normally distributed values
+const madScale = 1.4826
+
+// SeasonalBaseline expects each point of a series to be like the points one season
+// earlier, e.g. the same weekday in previous weeks. The expected value is the median of
+// those points and the spread their median absolute deviation, so earlier anomalies
+// barely move the baseline.
+type SeasonalBaseline struct {
+	// Period is the number of points in a season
+	Period int
+	// Seasons is how many seasons back points are compared with
+	Seasons int
+	// MinSamples is how many earlier points are needed to score a point
+	MinSamples int
+	// Threshold is the score beyond which a point is an anomaly
+	Threshold float64
+}
+
+var (
+	// DailyAnomalyBaseline compares each day with the same weekday of the previous 8 weeks
+	DailyAnomalyBaseline = SeasonalBaseline{Period: 7, Seasons: 8, MinSamples: 3, Threshold: 3.5}
+	// HourlyAnomalyBaseline compares each hour with the same hour of the week in the
+	// previous 4 weeks
+	HourlyAnomalyBaseline = SeasonalBaseline{Period: 7 * 24, Seasons: 4, MinSamples: 3, Threshold: 3.5}
+)
+
+// History is how far back points are compared with
+func (b SeasonalBaseline) History() int {
+	return b.Period * b.Seasons
+}
+
+// Score scores the point at index i of a series against the same point of earlier
+// seasons. Points that are not observed, such as those before the website had any
+// traffic, are left out of the baseline. It returns nil when fewer than MinSamples
+// earlier points were observed.
+func (b SeasonalBaseline) Score(values []float64, observed []bool, i int) *AnomalyScore {
+	var samples []float64
+	for season := 1; season <= b.Seasons; season++ {
+		j := i - season*b.Period
+		if j < 0 {
+			break
+		}
+		if observed[j] {
+			samples = append(samples, values[j])
+		}
+	}
+	if len(samples) < b.MinSamples {
+		return nil
+	}
+	return ScoreAnomaly(samples, values[i], b.Threshold)
+}
+
+// AnomalyScore is how far a value is from what earlier values led to expect
+type AnomalyScore struct {
+	Expected float64 `json:"expected"`
+	// Lower and Upper bound the range of values that are not anomalies
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	// Score is the distance from the expected value in robust standard deviations,
+	// negative below it
+	Score   float64 `json:"score"`
+	Anomaly bool    `json:"anomaly"`
+}
+
+// ScoreAnomaly scores a count against earlier samples of it. The spread is at least
+// the square root of the expected count, the noise of counting random visits, and at
+// least one, so that steady or tiny series do not flag every change.
+func ScoreAnomaly(samples []float64, value, threshold float64) *AnomalyScore {
+	expected := median(samples)
+
+	deviations := make([]float64, len(samples))
+	for i, sample := range samples {
+		deviations[i] = math.Abs(sample - expected)
+	}
+	spread := math.Max(madScale*median(deviations), math.Max(math.Sqrt(expected), 1))
+
+	score := (value - expected) / spread
+	return &AnomalyScore{
+		Expected: expected,
+		Lower:    math.Max(expected-threshold*spread, 0),
+		Upper:    expected + threshold*spread,
+		Score:    math.Round(score*100) / 100,
+		Anomaly:  math.Abs(score) > threshold,
+	}
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// AnomalyPoint is a scored point of a daily or hourly series
+type AnomalyPoint struct {
+	Date      string     `json:"date,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Value     float64    `json:"value"`
+	AnomalyScore
+}
+
+// TimeSeriesAnomalies are the scored points of a website's pageviews and unique
+// visitors series. Days or hours without traffic are included, and those without
+// enough history or not over yet are not.
+type TimeSeriesAnomalies struct {
+	Views  []AnomalyPoint `json:"views"`
+	Unique []AnomalyPoint `json:"unique"`
+	// Count is the number of anomalies in both series
+	Count int `json:"count"`
+}
+
+func (a *TimeSeriesAnomalies) add(point AnomalyPoint, views bool) {
+	if views {
+		a.Views = append(a.Views, point)
+	} else {
+		a.Unique = append(a.Unique, point)
+	}
+	if point.Anomaly {
+		a.Count++
+	}
+}
+
+// DailyAnomalyHistory is the range before a range whose days are compared with its
+// days, from the start of the day DailyAnomalyBaseline.History() days earlier
+func DailyAnomalyHistory(dateRange DateRange) DateRange {
+	loc := dateRange.Location()
+	start := dateRange.Start.In(loc)
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	return DateRange{
+		Start:    first.AddDate(0, 0, -DailyAnomalyBaseline.History()),
+		End:      dateRange.Start,
+		Timezone: dateRange.Timezone,
+	}
+}
+
+// HourlyAnomalyHistory is the range before a range whose hours are compared with its
+// hours, from the start of the hour HourlyAnomalyBaseline.History() hours earlier
+func HourlyAnomalyHistory(dateRange DateRange) DateRange {
+	loc := dateRange.Location()
+	start := dateRange.Start.In(loc)
+	first := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, loc)
+	return DateRange{
+		Start:    first.Add(-time.Duration(HourlyAnomalyBaseline.History()) * time.Hour),
+		End:      dateRange.Start,
+		Timezone: dateRange.Timezone,
+	}
+}
+
+// DetectDailyAnomalies scores the days of a range. History holds the days of
+// DailyAnomalyHistory(dateRange) and stats those of the range. Days ending after now
+// are not scored.
+func DetectDailyAnomalies(history, stats []DailyStat, dateRange DateRange, now time.Time) *TimeSeriesAnomalies {
+	first := DailyAnomalyHistory(dateRange).Start
+
+	var days []time.Time
+	for day := first; day.Before(dateRange.End); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	// The day the range starts on can be split between history and stats
+	index := make(map[string]int, len(days))
+	for i, day := range days {
+		index[day.Format("2006-01-02")] = i
+	}
+	views := make([]float64, len(days))
+	unique := make([]float64, len(days))
+	for _, stat := range append(append([]DailyStat(nil), history...), stats...) {
+		if i, ok := index[stat.Date]; ok {
+			views[i] += float64(stat.Views)
+			unique[i] += float64(stat.Unique)
+		}
+	}
+	observed := observedSince(views)
+
+	anomalies := &TimeSeriesAnomalies{Views: []AnomalyPoint{}, Unique: []AnomalyPoint{}}
+	for i, day := range days {
+		if i < DailyAnomalyBaseline.History() || day.AddDate(0, 0, 1).After(now) {
+			continue
+		}
+		date := day.Format("2006-01-02")
+		if score := DailyAnomalyBaseline.Score(views, observed, i); score != nil {
+			anomalies.add(AnomalyPoint{Date: date, Value: views[i], AnomalyScore: *score}, true)
+		}
+		if score := DailyAnomalyBaseline.Score(unique, observed, i); score != nil {
+			anomalies.add(AnomalyPoint{Date: date, Value: unique[i], AnomalyScore: *score}, false)
+		}
+	}
+
+	return anomalies
+}
+
+// DetectHourlyAnomalies scores the hours of a range. History holds the hours of
+// HourlyAnomalyHistory(dateRange) and stats those of the range. Hours ending after now
+// are not scored.
+func DetectHourlyAnomalies(history, stats []HourlyStat, dateRange DateRange, now time.Time) *TimeSeriesAnomalies {
+	loc := dateRange.Location()
+	first := HourlyAnomalyHistory(dateRange).Start
+
+	var hours []time.Time
+	for hour := first; hour.Before(dateRange.End); hour = hour.Add(time.Hour) {
+		hours = append(hours, hour)
+	}
+
+	// Stats are placed by their offset from the first hour, which also buckets
+	// rollup hours of timezones not offset by whole hours
+	views := make([]float64, len(hours))
+	unique := make([]float64, len(hours))
+	for _, stat := range append(append([]HourlyStat(nil), history...), stats...) {
+		i := int(stat.Timestamp.Sub(first) / time.Hour)
+		if i < 0 || i >= len(hours) {
+			continue
+		}
+		views[i] += float64(stat.Views)
+		unique[i] += float64(stat.Unique)
+	}
+	observed := observedSince(views)
+
+	anomalies := &TimeSeriesAnomalies{Views: []AnomalyPoint{}, Unique: []AnomalyPoint{}}
+	for i, hour := range hours {
+		if i < HourlyAnomalyBaseline.History() || hour.Add(time.Hour).After(now) {
+			continue
+		}
+		timestamp := hour.In(loc)
+		if score := HourlyAnomalyBaseline.Score(views, observed, i); score != nil {
+			anomalies.add(AnomalyPoint{Timestamp: &timestamp, Value: views[i], AnomalyScore: *score}, true)
+		}
+		if score := HourlyAnomalyBaseline.Score(unique, observed, i); score != nil {
+			anomalies.add(AnomalyPoint{Timestamp: &timestamp, Value: unique[i], AnomalyScore: *score}, false)
+		}
+	}
+
+	return anomalies
+}
+
+// observedSince marks the points from the first one with traffic on, since a website
+// has no history before it was tracked
+func observedSince(values []float64) []bool {
+	observed := make([]bool, len(values))
+	seen := false
+	for i, value := range values {
+		seen = seen || value > 0
+		observed[i] = seen
+	}
+	return observed
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AttributionModel is how credit for a conversion is split between a visitor's touches
+type AttributionModel string
+
+const (
+	AttributionFirstTouch    AttributionModel = "first_touch"
+	AttributionLastTouch     AttributionModel = "last_touch"
+	AttributionLinear        AttributionModel = "linear"
+	AttributionTimeDecay     AttributionModel = "time_decay"
+	AttributionPositionBased AttributionModel = "position_based"
+)
+
+const (
+	DefaultAttributionLookbackDays = 30
+	MaxAttributionLookbackDays     = 90
+
+	// AttributionHalfLife is how much older a touch must be to get half the
+	// time-decay credit of a touch at the moment of conversion
+	AttributionHalfLife = 7 * 24 * time.Hour
+
+	// AttributionEndpointShare is the credit the first and last touch each get in
+	// the position-based model; the rest is split between the touches in between
+	AttributionEndpointShare = 0.4
+)
+
+// AttributionDimensions maps each attribution dimension to the SQL expression giving a
+// session's value for it, evaluated on the session's first pageview
+var AttributionDimensions = map[string]string{
+	"source":   "COALESCE(NULLIF(utm_source, ''), substring(referrer from '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?([^/?#]+)'))",
+	"medium":   "utm_medium",
+	"campaign": "utm_campaign",
+	"referrer": "substring(referrer from '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?([^/?#]+)')",
+}
+
+// AttributionEmptyValues is the value reported for sessions without a dimension value
+var AttributionEmptyValues = map[string]string{
+	"source":   "direct",
+	"medium":   "none",
+	"campaign": "none",
+	"referrer": "direct",
+}
+
+// AttributionQuery describes an attribution report. Conversions are goal completions
+// (GoalID) or funnel completions (FunnelID); touches are the visitor's sessions that
+// started within LookbackDays before converting.
+type AttributionQuery struct {
+	Model        AttributionModel `json:"model"`
+	Dimension    string           `json:"dimension"`
+	LookbackDays int              `json:"lookback_days"`
+	GoalID       *uuid.UUID       `json:"goal_id,omitempty"`
+	FunnelID     *uuid.UUID       `json:"funnel_id,omitempty"`
+}
+
+// NewAttributionQuery builds an attribution query; exactly one of goalID and funnelID
+// must be set. The model defaults to last touch and the dimension to source.
+func NewAttributionQuery(model, dimension string, lookbackDays int, goalID, funnelID string) (AttributionQuery, error) {
+	q := AttributionQuery{
+		Model:        AttributionModel(model),
+		Dimension:    dimension,
+		LookbackDays: lookbackDays,
+	}
+
+	switch q.Model {
+	case "":
+		q.Model = AttributionLastTouch
+	case AttributionFirstTouch, AttributionLastTouch, AttributionLinear, AttributionTimeDecay, AttributionPositionBased:
+	default:
+		return AttributionQuery{}, fmt.Errorf("unknown attribution model %q", model)
+	}
+
+	if q.Dimension == "" {
+		q.Dimension = "source"
+	}
+	if _, ok := AttributionDimensions[q.Dimension]; !ok {
+		return AttributionQuery{}, fmt.Errorf("unknown attribution dimension %q", dimension)
+	}
+
+	if q.LookbackDays <= 0 {
+		q.LookbackDays = DefaultAttributionLookbackDays
+	}
+	if q.LookbackDays > MaxAttributionLookbackDays {
+		return AttributionQuery{}, fmt.Errorf("lookback_days cannot exceed %d", MaxAttributionLookbackDays)
+	}
+
+	switch {
+	case goalID != "" && funnelID != "":
+		return AttributionQuery{}, fmt.Errorf("only one of goal_id and funnel_id can be set")
+	case goalID != "":
+		id, err := uuid.Parse(goalID)
+		if err != nil {
+			return AttributionQuery{}, fmt.Errorf("invalid goal_id")
+		}
+		q.GoalID = &id
+	case funnelID != "":
+		id, err := uuid.Parse(funnelID)
+		if err != nil {
+			return AttributionQuery{}, fmt.Errorf("invalid funnel_id")
+		}
+		q.FunnelID = &id
+	default:
+		return AttributionQuery{}, fmt.Errorf("goal_id or funnel_id is required")
+	}
+
+	return q, nil
+}
+
+// Lookback returns the lookback window as a duration
+func (q AttributionQuery) Lookback() time.Duration {
+	return time.Duration(q.LookbackDays) * 24 * time.Hour
+}
+
+// AttributionWeights splits one conversion at convertedAt between touches, given in
+// chronological order. The weights sum to 1 unless there are no touches.
+func AttributionWeights(model AttributionModel, touches []time.Time, convertedAt time.Time) []float64 {
+	n := len(touches)
+	weights := make([]float64, n)
+	if n == 0 {
+		return weights
+	}
+	if n == 1 {
+		weights[0] = 1
+		return weights
+	}
+
+	switch model {
+	case AttributionFirstTouch:
+		weights[0] = 1
+	case AttributionLinear:
+		for i := range weights {
+			weights[i] = 1 / float64(n)
+		}
+	case AttributionTimeDecay:
+		total := 0.0
+		for i, touch := range touches {
+			age := convertedAt.Sub(touch)
+			if age < 0 {
+				age = 0
+			}
+			weights[i] = math.Exp2(-float64(age) / float64(AttributionHalfLife))
+			total += weights[i]
+		}
+		for i := range weights {
+			weights[i] /= total
+		}
+	case AttributionPositionBased:
+		if n == 2 {
+			weights[0], weights[1] = 0.5, 0.5
+			break
+		}
+		weights[0] = AttributionEndpointShare
+		weights[n-1] = AttributionEndpointShare
+		middle := (1 - 2*AttributionEndpointShare) / float64(n-2)
+		for i := 1; i < n-1; i++ {
+			weights[i] = middle
+		}
+	default:
+		weights[n-1] = 1
+	}
+
+	return weights
+}
+
+// AttributionRow is the conversion credit one dimension value received. Conversions are
+// fractional when a model splits credit; Value is credited goal value, if the goal has one.
+type AttributionRow struct {
+	Value       string   `json:"value"`
+	Conversions float64  `json:"conversions"`
+	Share       float64  `json:"share"`
+	GoalValue   *float64 `json:"goal_value,omitempty"`
+}
+
+// AttributionReport is conversions credited to the values of a dimension
+type AttributionReport struct {
+	WebsiteID    string           `json:"website_id"`
+	DateRange    string           `json:"date_range"`
+	Model        AttributionModel `json:"model"`
+	Dimension    string           `json:"dimension"`
+	LookbackDays int              `json:"lookback_days"`
+	GoalID       *uuid.UUID       `json:"goal_id,omitempty"`
+	FunnelID     *uuid.UUID       `json:"funnel_id,omitempty"`
+	Conversions  int              `json:"conversions"`
+	Rows         []AttributionRow `json:"rows"`
+}
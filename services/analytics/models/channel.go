@@ -0,0 +1,254 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ChannelDimension is the report and filter dimension for the marketing channel a
+// session arrived through, classified from its first pageview
+const ChannelDimension = "channel"
+
+// Built-in channels
+const (
+	ChannelDirect        = "Direct"
+	ChannelOrganicSearch = "Organic Search"
+	ChannelPaidSearch    = "Paid Search"
+	ChannelOrganicSocial = "Organic Social"
+	ChannelPaidSocial    = "Paid Social"
+	ChannelPaidOther     = "Paid Other"
+	ChannelDisplay       = "Display"
+	ChannelEmail         = "Email"
+	ChannelAffiliate     = "Affiliate"
+	ChannelReferral      = "Referral"
+
+	// ChannelOther is the channel of sessions no rule matches
+	ChannelOther = "Other"
+)
+
+// ChannelField is a session attribute channel rules match on
+type ChannelField string
+
+const (
+	ChannelFieldSource   ChannelField = "source"   // utm_source
+	ChannelFieldMedium   ChannelField = "medium"   // utm_medium
+	ChannelFieldCampaign ChannelField = "campaign" // utm_campaign
+	ChannelFieldReferrer ChannelField = "referrer" // referrer host, without "www."
+)
+
+// MaxChannelRules limits how many rules a website can add
+const MaxChannelRules = 100
+
+// ChannelCondition compares a session field with a value. Comparisons are
+// case-insensitive and a missing field is the empty string.
+type ChannelCondition struct {
+	Field    ChannelField   `json:"field"`
+	Operator FilterOperator `json:"operator"`
+	Value    string         `json:"value"`
+}
+
+// ChannelRule assigns Channel to sessions matching all of its conditions
+type ChannelRule struct {
+	Channel    string             `json:"channel"`
+	Conditions []ChannelCondition `json:"conditions"`
+}
+
+// ChannelRules are tried in order; the first matching rule decides the channel
+type ChannelRules []ChannelRule
+
+// Validate checks the rule's channel name and conditions
+func (r ChannelRule) Validate() error {
+	if strings.TrimSpace(r.Channel) == "" {
+		return fmt.Errorf("channel is required")
+	}
+	if len(r.Channel) > 100 {
+		return fmt.Errorf("channel name is too long")
+	}
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("channel %q needs at least one condition", r.Channel)
+	}
+
+	for _, condition := range r.Conditions {
+		switch condition.Field {
+		case ChannelFieldSource, ChannelFieldMedium, ChannelFieldCampaign, ChannelFieldReferrer:
+		default:
+			return fmt.Errorf("unknown channel field %q", condition.Field)
+		}
+
+		switch condition.Operator {
+		case FilterEquals, FilterNotEquals, FilterContains, FilterNotContains:
+		case FilterRegex:
+			if len(condition.Value) > 1024 {
+				return fmt.Errorf("channel regex is too long")
+			}
+			if err := ValidateRegex(condition.Value); err != nil {
+				return fmt.Errorf("invalid channel regex %q: %w", condition.Value, err)
+			}
+		default:
+			return fmt.Errorf("unknown channel operator %q", condition.Operator)
+		}
+	}
+	return nil
+}
+
+// Validate checks every rule
+func (rules ChannelRules) Validate() error {
+	if len(rules) > MaxChannelRules {
+		return fmt.Errorf("at most %d channel rules are allowed", MaxChannelRules)
+	}
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Known traffic sources used by the built-in rules. Domains ending in "." match any
+// top-level domain, e.g. "google." matches google.com and google.co.uk.
+var (
+	SearchEngineDomains = []string{
+		"google.", "bing.com", "yahoo.", "duckduckgo.com", "baidu.com", "yandex.",
+		"ecosia.org", "naver.com", "seznam.cz", "qwant.com", "search.brave.com",
+		"startpage.com", "ask.com", "aol.com",
+	}
+	SocialNetworkDomains = []string{
+		"facebook.", "fb.com", "instagram.com", "twitter.com", "x.com", "t.co",
+		"linkedin.com", "lnkd.in", "youtube.com", "youtu.be", "tiktok.com",
+		"pinterest.", "reddit.com", "snapchat.com", "threads.net", "bsky.app",
+		"mastodon.social", "news.ycombinator.com", "quora.com", "vk.com",
+		"discord.com", "telegram.org", "whatsapp.com",
+	}
+	EmailDomains = []string{
+		"mail.google.com", "outlook.live.com", "outlook.office.com",
+		"outlook.office365.com", "mail.yahoo.com", "mail.proton.me",
+	}
+
+	SearchEngineSources = []string{
+		"google", "bing", "yahoo", "duckduckgo", "baidu", "yandex", "ecosia",
+		"naver", "seznam", "qwant", "brave", "startpage",
+	}
+	SocialNetworkSources = []string{
+		"facebook", "fb", "instagram", "ig", "twitter", "x", "linkedin", "youtube",
+		"tiktok", "pinterest", "reddit", "snapchat", "threads", "bluesky",
+		"mastodon", "hackernews", "quora", "vk", "discord", "telegram", "whatsapp",
+	}
+)
+
+// domainsRegex matches a referrer host belonging to one of domains
+func domainsRegex(domains []string) string {
+	parts := make([]string, len(domains))
+	for i, domain := range domains {
+		parts[i] = regexp.QuoteMeta(domain)
+		if !strings.HasSuffix(domain, ".") {
+			parts[i] += "$"
+		}
+	}
+	return `(^|\.)(` + strings.Join(parts, "|") + `)`
+}
+
+// sourcesRegex matches a utm_source naming one of sources, optionally as a domain
+func sourcesRegex(sources []string) string {
+	return `^(` + strings.Join(sources, "|") + `)(\.[a-z.]+)?$`
+}
+
+const paidMediumRegex = `^(cpc|ppc|cpv|cpa|paid|paid[-_ ]?(search|ads?|media)|ads?)$`
+
+func condition(field ChannelField, operator FilterOperator, value string) ChannelCondition {
+	return ChannelCondition{Field: field, Operator: operator, Value: value}
+}
+
+// DefaultChannelRules classifies sessions from utm_medium conventions and lists of known
+// search engines, social networks and webmail hosts. Website rules are tried first.
+var DefaultChannelRules = ChannelRules{
+	{ChannelPaidSearch, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, paidMediumRegex),
+		condition(ChannelFieldSource, FilterRegex, sourcesRegex(SearchEngineSources)),
+	}},
+	{ChannelPaidSearch, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, paidMediumRegex),
+		condition(ChannelFieldReferrer, FilterRegex, domainsRegex(SearchEngineDomains)),
+	}},
+	{ChannelPaidSocial, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, `^(paid[-_ ]?social|social[-_ ]?paid)$`),
+	}},
+	{ChannelPaidSocial, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, paidMediumRegex),
+		condition(ChannelFieldSource, FilterRegex, sourcesRegex(SocialNetworkSources)),
+	}},
+	{ChannelPaidSocial, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, paidMediumRegex),
+		condition(ChannelFieldReferrer, FilterRegex, domainsRegex(SocialNetworkDomains)),
+	}},
+	{ChannelDisplay, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, `^(display|banner|cpm|expandable|interstitial)$`),
+	}},
+	{ChannelPaidOther, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, paidMediumRegex),
+	}},
+	{ChannelEmail, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, `^(e[-_ ]?mail|newsletter)$`),
+	}},
+	{ChannelEmail, []ChannelCondition{
+		condition(ChannelFieldSource, FilterRegex, `^(e[-_ ]?mail|newsletter|mailchimp|sendgrid|klaviyo)$`),
+	}},
+	{ChannelEmail, []ChannelCondition{
+		condition(ChannelFieldReferrer, FilterRegex, domainsRegex(EmailDomains)),
+	}},
+	{ChannelAffiliate, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, `^(affiliates?|partners?)$`),
+	}},
+	{ChannelOrganicSocial, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterRegex, `^(social|social[-_ ]?(network|media)|sm)$`),
+	}},
+	{ChannelOrganicSocial, []ChannelCondition{
+		condition(ChannelFieldSource, FilterRegex, sourcesRegex(SocialNetworkSources)),
+	}},
+	{ChannelOrganicSocial, []ChannelCondition{
+		condition(ChannelFieldReferrer, FilterRegex, domainsRegex(SocialNetworkDomains)),
+	}},
+	{ChannelOrganicSearch, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterEquals, "organic"),
+	}},
+	{ChannelOrganicSearch, []ChannelCondition{
+		condition(ChannelFieldSource, FilterRegex, sourcesRegex(SearchEngineSources)),
+	}},
+	{ChannelOrganicSearch, []ChannelCondition{
+		condition(ChannelFieldReferrer, FilterRegex, domainsRegex(SearchEngineDomains)),
+	}},
+	{ChannelReferral, []ChannelCondition{
+		condition(ChannelFieldMedium, FilterEquals, "referral"),
+	}},
+	{ChannelDirect, []ChannelCondition{
+		condition(ChannelFieldSource, FilterRegex, `^(|\(?direct\)?)$`),
+		condition(ChannelFieldMedium, FilterRegex, `^(|\(?none\)?)$`),
+		condition(ChannelFieldReferrer, FilterRegex, `^(|direct|none|null)$`),
+	}},
+	{ChannelReferral, []ChannelCondition{
+		condition(ChannelFieldReferrer, FilterNotEquals, ""),
+	}},
+}
+
+// WebsiteChannelRules are the rules a website adds in front of the built-in rules
+type WebsiteChannelRules struct {
+	WebsiteID string       `json:"website_id"`
+	Rules     ChannelRules `json:"rules"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+}
+
+type UpdateChannelRulesRequest struct {
+	Rules ChannelRules `json:"rules"`
+}
+
+// ChannelStat is the traffic and revenue of sessions that arrived through a channel
+type ChannelStat struct {
+	Channel    string  `json:"channel"`
+	Visitors   int     `json:"visitors"`
+	Sessions   int     `json:"sessions"`
+	Views      int     `json:"views"`
+	BounceRate float64 `json:"bounce_rate"`
+	Orders     int     `json:"orders"`
+	Revenue    float64 `json:"revenue"`
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// MaxDateRangeDays caps how long a single reporting period can be
+	MaxDateRangeDays = 732
+
+	// MaxHourlyRangeDays caps how long a period broken down by hour can be
+	MaxHourlyRangeDays = 31
+)
+
+// DateRange is the reporting period for analytics queries. Start is inclusive and End
+// is exclusive. When the range was given as calendar dates, both are midnight in Timezone.
+type DateRange struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Timezone string    `json:"timezone"`
+
+	// days is set when the range is relative ("last N days") rather than explicit dates
+	days int
+}
+
+// NewDateRange builds a reporting period from request parameters. start and end are
+// ISO dates (YYYY-MM-DD) or RFC3339 timestamps in the given IANA timezone; end dates
+// are inclusive. Without start, the period is the last `days` days up to now.
+func NewDateRange(start, end string, days int, timezone string, now time.Time) (DateRange, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("invalid timezone %q", timezone)
+	}
+	if days <= 0 {
+		days = 7
+	}
+
+	r := DateRange{Timezone: timezone}
+
+	if end != "" {
+		r.End, err = parseRangeBound(end, loc, true)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid end: %w", err)
+		}
+	} else {
+		r.End = now
+	}
+
+	if start != "" {
+		r.Start, err = parseRangeBound(start, loc, false)
+		if err != nil {
+			return DateRange{}, fmt.Errorf("invalid start: %w", err)
+		}
+	} else {
+		r.Start = r.End.In(loc).AddDate(0, 0, -days)
+		if end == "" {
+			r.days = days
+		}
+	}
+
+	if !r.Start.Before(r.End) {
+		return DateRange{}, fmt.Errorf("start must be before end")
+	}
+	if r.End.Sub(r.Start) > MaxDateRangeDays*24*time.Hour {
+		return DateRange{}, fmt.Errorf("date range cannot exceed %d days", MaxDateRangeDays)
+	}
+
+	return r, nil
+}
+
+// ValidateHourly checks that the range is short enough to be broken down by hour
+func (r DateRange) ValidateHourly() error {
+	if r.End.Sub(r.Start) > MaxHourlyRangeDays*24*time.Hour {
+		return fmt.Errorf("hourly stats cannot exceed %d days", MaxHourlyRangeDays)
+	}
+	return nil
+}
+
+// LastDays is the rolling "last N days" period ending now, in UTC
+func LastDays(days int) DateRange {
+	r, _ := NewDateRange("", "", days, "UTC", time.Now())
+	return r
+}
+
+func parseRangeBound(value string, loc *time.Location, isEnd bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateLayout, value, loc); err == nil {
+		if isEnd {
+			// End dates are inclusive, so the range runs to the following midnight
+			return t.AddDate(0, 0, 1), nil
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC3339, got %q", value)
+}
+
+// Location returns the range's timezone, falling back to UTC
+func (r DateRange) Location() *time.Location {
+	if loc, err := time.LoadLocation(r.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Days returns the length of the range in whole days, rounded up
+func (r DateRange) Days() int {
+	if r.days > 0 {
+		return r.days
+	}
+	days := int((r.End.Sub(r.Start) + 24*time.Hour - 1) / (24 * time.Hour))
+	if days < 1 {
+		return 1
+	}
+	return days
+}
+
+// Previous returns the period of the same length immediately before this one
+func (r DateRange) Previous() DateRange {
+	return DateRange{
+		Start:    r.Start.Add(-r.End.Sub(r.Start)),
+		End:      r.Start,
+		Timezone: r.Timezone,
+		days:     r.days,
+	}
+}
+
+// Label describes the range for API responses
+func (r DateRange) Label() string {
+	if r.days > 0 {
+		return fmt.Sprintf("%d days", r.days)
+	}
+	loc := r.Location()
+	return fmt.Sprintf("%s to %s", r.Start.In(loc).Format(dateLayout), r.End.Add(-time.Nanosecond).In(loc).Format(dateLayout))
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetterEvent is an event that could not be stored after all retries
+type DeadLetterEvent struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	EventID   uuid.UUID `json:"event_id" db:"event_id"`
+	WebsiteID string    `json:"website_id" db:"website_id"`
+	EventType string    `json:"event_type" db:"event_type"`
+	Event     Event     `json:"event" db:"payload"`
+	Error     string    `json:"error" db:"error"`
+	Attempts  int       `json:"attempts" db:"attempts"`
+	FailedAt  time.Time `json:"failed_at" db:"failed_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// DeadLetterReplayRequest selects dead-lettered events to replay.
+// Either explicit IDs or a website filter may be given.
+type DeadLetterReplayRequest struct {
+	IDs       []string `json:"ids"`
+	WebsiteID string   `json:"website_id"`
+	Limit     int      `json:"limit"`
+}
+
+// DeadLetterPurgeRequest selects dead-lettered events to purge: explicit IDs, or those
+// that failed before Before (default now), optionally for one website. Purging every
+// dead-lettered event has to be asked for with All.
+type DeadLetterPurgeRequest struct {
+	IDs       []string
+	WebsiteID string
+	Before    *time.Time
+	All       bool
+}
+
+// Validate rejects malformed IDs and a purge that would empty the table without All
+func (r *DeadLetterPurgeRequest) Validate() error {
+	if len(r.IDs) == 0 && r.WebsiteID == "" && r.Before == nil && !r.All {
+		return errors.New("specify id, website_id or before, or all=true to purge every dead-letter event")
+	}
+	_, err := ParseDeadLetterIDs(r.IDs)
+	return err
+}
+
+// ParseDeadLetterIDs parses dead-letter event IDs
+func ParseDeadLetterIDs(ids []string) ([]uuid.UUID, error) {
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		value, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dead-letter id %q", id)
+		}
+		parsed = append(parsed, value)
+	}
+	return parsed, nil
+}
+
+// DeadLetterReplayResult summarises a replay run
+type DeadLetterReplayResult struct {
+	Requested int `json:"requested"`
+	Replayed  int `json:"replayed"`
+	Failed    int `json:"failed"`
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DigestFrequency is how often a digest is emailed
+type DigestFrequency string
+
+const (
+	// DigestWeekly is sent on Mondays and covers the previous Monday to Sunday
+	DigestWeekly DigestFrequency = "weekly"
+	// DigestMonthly is sent on the 1st and covers the previous calendar month
+	DigestMonthly DigestFrequency = "monthly"
+)
+
+// MaxDigestRecipients bounds how many addresses a digest is sent to
+const MaxDigestRecipients = 20
+
+// DigestSchedule emails a website's numbers for the last week or month to a list of
+// recipients. It is sent at Hour in Timezone on the first day of each period.
+type DigestSchedule struct {
+	ID         uuid.UUID       `json:"id" db:"id"`
+	WebsiteID  string          `json:"website_id" db:"website_id"`
+	UserID     *string         `json:"user_id,omitempty" db:"user_id"`
+	Name       string          `json:"name" db:"name"`
+	Frequency  DigestFrequency `json:"frequency" db:"frequency"`
+	Recipients []string        `json:"recipients" db:"recipients"`
+	Timezone   string          `json:"timezone" db:"timezone"`
+	Hour       int             `json:"hour" db:"send_hour"`
+	Enabled    bool            `json:"enabled" db:"enabled"`
+	NextRunAt  time.Time       `json:"next_run_at" db:"next_run_at"`
+	LastSentAt *time.Time      `json:"last_sent_at,omitempty" db:"last_sent_at"`
+	LastError  *string         `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at" db:"updated_at"`
+
+	// FailedAttempts counts failed sends of the current period
+	FailedAttempts int `json:"-" db:"failed_attempts"`
+}
+
+type CreateDigestScheduleRequest struct {
+	WebsiteID  string          `json:"website_id" binding:"required"`
+	UserID     *string         `json:"user_id,omitempty"`
+	Name       string          `json:"name" binding:"required"`
+	Frequency  DigestFrequency `json:"frequency" binding:"required"`
+	Recipients []string        `json:"recipients" binding:"required"`
+	Timezone   string          `json:"timezone"`
+	Hour       *int            `json:"hour"`
+	Enabled    *bool           `json:"enabled"`
+}
+
+type UpdateDigestScheduleRequest struct {
+	Name       *string          `json:"name"`
+	Frequency  *DigestFrequency `json:"frequency"`
+	Recipients *[]string        `json:"recipients"`
+	Timezone   *string          `json:"timezone"`
+	Hour       *int             `json:"hour"`
+	Enabled    *bool            `json:"enabled"`
+}
+
+// Validate checks the frequency, timezone, hour and recipient addresses
+func (d *DigestSchedule) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("digest name is required")
+	}
+
+	switch d.Frequency {
+	case DigestWeekly, DigestMonthly:
+	default:
+		return fmt.Errorf("digest frequency must be weekly or monthly, got %q", d.Frequency)
+	}
+
+	if _, err := time.LoadLocation(d.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", d.Timezone)
+	}
+	if d.Hour < 0 || d.Hour > 23 {
+		return fmt.Errorf("hour must be between 0 and 23, got %d", d.Hour)
+	}
+
+	if len(d.Recipients) == 0 {
+		return fmt.Errorf("digest needs at least one recipient")
+	}
+	if len(d.Recipients) > MaxDigestRecipients {
+		return fmt.Errorf("digest can have at most %d recipients", MaxDigestRecipients)
+	}
+	for i, recipient := range d.Recipients {
+		// Only bare addresses, as they are used for the SMTP envelope as well
+		addr, err := mail.ParseAddress(recipient)
+		if err != nil || addr.Address != recipient {
+			return fmt.Errorf("recipient %d: invalid email address %q", i+1, recipient)
+		}
+	}
+
+	return nil
+}
+
+// Location returns the schedule's timezone, falling back to UTC
+func (d *DigestSchedule) Location() *time.Location {
+	if loc, err := time.LoadLocation(d.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Period returns the last complete week (Monday to Sunday) or calendar month before
+// now, in the schedule's timezone
+func (d *DigestSchedule) Period(now time.Time) (DateRange, error) {
+	local := now.In(d.Location())
+	var start, end time.Time
+	if d.Frequency == DigestMonthly {
+		end = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, local.Location())
+		start = end.AddDate(0, -1, 0)
+	} else {
+		end = startOfWeek(local)
+		start = end.AddDate(0, 0, -7)
+	}
+
+	// End dates are inclusive
+	return NewDateRange(start.Format(dateLayout), end.AddDate(0, 0, -1).Format(dateLayout), 0, d.Timezone, now)
+}
+
+// NextRun returns the first send time after the given time: Monday for weekly
+// digests and the 1st for monthly ones, at the schedule's hour
+func (d *DigestSchedule) NextRun(after time.Time) time.Time {
+	local := after.In(d.Location())
+	if d.Frequency == DigestMonthly {
+		next := time.Date(local.Year(), local.Month(), 1, d.Hour, 0, 0, 0, local.Location())
+		if !next.After(after) {
+			next = time.Date(local.Year(), local.Month()+1, 1, d.Hour, 0, 0, 0, local.Location())
+		}
+		return next
+	}
+
+	monday := startOfWeek(local)
+	next := time.Date(monday.Year(), monday.Month(), monday.Day(), d.Hour, 0, 0, 0, local.Location())
+	if !next.After(after) {
+		next = time.Date(monday.Year(), monday.Month(), monday.Day()+7, d.Hour, 0, 0, 0, local.Location())
+	}
+	return next
+}
+
+// startOfWeek returns midnight of the Monday on or before t, in t's location
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// DigestReport is the content of a digest email: the period's dashboard with its
+// comparison to the period before, top pages and top sources
+type DigestReport struct {
+	WebsiteID string
+	Name      string
+	Frequency DigestFrequency
+	Period    DateRange
+	Dashboard *DashboardData
+	// DashboardURL links to the website's dashboard; empty when unknown
+	DashboardURL string
+}
+
+// PeriodLabel describes the period for the email, e.g. "Mar 3 – Mar 9, 2025" or
+// "March 2025"
+func (r *DigestReport) PeriodLabel() string {
+	loc := r.Period.Location()
+	start := r.Period.Start.In(loc)
+	last := r.Period.End.Add(-time.Nanosecond).In(loc)
+
+	if r.Frequency == DigestMonthly && start.Day() == 1 && last.AddDate(0, 0, 1).Day() == 1 && start.Month() == last.Month() {
+		return start.Format("January 2006")
+	}
+	if start.Year() != last.Year() {
+		return start.Format("Jan 2, 2006") + " – " + last.Format("Jan 2, 2006")
+	}
+	return start.Format("Jan 2") + " – " + last.Format("Jan 2, 2006")
+}
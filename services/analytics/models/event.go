@@ -68,15 +68,26 @@ type BatchEventRequest struct {
 	Events []Event `json:"events"`
 }
 
+// EventWebsiteID returns the website of the event at index i: its own website_id,
+// or the batch's siteId when it has none
+func (r *BatchEventRequest) EventWebsiteID(i int) string {
+	if r.Events[i].WebsiteID != "" {
+		return r.Events[i].WebsiteID
+	}
+	return r.SiteID
+}
+
 type EventResponse struct {
 	Status    string `json:"status"`
 	EventID   string `json:"event_id"`
 	VisitorID string `json:"visitor_id"`
 	SessionID string `json:"session_id"`
+	Duplicate bool   `json:"duplicate,omitempty"`
 }
 
 type BatchEventResponse struct {
-	Status      string `json:"status"`
-	EventsCount int    `json:"events_count"`
-	ProcessedAt int64  `json:"processed_at"`
+	Status          string `json:"status"`
+	EventsCount     int    `json:"events_count"`
+	DuplicatesCount int    `json:"duplicates_count"`
+	ProcessedAt     int64  `json:"processed_at"`
 }
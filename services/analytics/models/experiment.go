@@ -0,0 +1,369 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultExperimentProperty = "experiment"
+	DefaultVariantProperty    = "variant"
+	DefaultExperimentLevel    = 0.95
+
+	// MinExperimentVisitors and MinExperimentConversions are the smallest variant for
+	// which a significant result is reported; below them normal approximations break down
+	MinExperimentVisitors    = 100
+	MinExperimentConversions = 10
+)
+
+// ExperimentZScores are the supported confidence levels and their two-sided z scores
+var ExperimentZScores = map[float64]float64{
+	0.90: 1.6449,
+	0.95: 1.9600,
+	0.99: 2.5758,
+}
+
+// Experiment is an A/B test whose variants come from event properties. A visitor is
+// exposed by their first event between StartedAt and EndedAt whose ExperimentProperty
+// is Key, and is in the variant named by that event's VariantProperty. A visitor
+// converts by completing the goal or funnel after being exposed.
+type Experiment struct {
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	WebsiteID          string     `json:"website_id" db:"website_id"`
+	UserID             *string    `json:"user_id,omitempty" db:"user_id"`
+	Name               string     `json:"name" db:"name"`
+	Key                string     `json:"key" db:"experiment_key"`
+	ExperimentProperty string     `json:"experiment_property" db:"experiment_property"`
+	VariantProperty    string     `json:"variant_property" db:"variant_property"`
+	ControlVariant     string     `json:"control_variant" db:"control_variant"`
+	GoalID             *uuid.UUID `json:"goal_id,omitempty" db:"goal_id"`
+	FunnelID           *uuid.UUID `json:"funnel_id,omitempty" db:"funnel_id"`
+	Confidence         float64    `json:"confidence" db:"confidence"`
+	// SampleSize is the planned number of visitors per variant; 0 means none was planned
+	SampleSize int        `json:"sample_size" db:"sample_size"`
+	StartedAt  time.Time  `json:"started_at" db:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateExperimentRequest struct {
+	WebsiteID          string     `json:"website_id" binding:"required"`
+	UserID             *string    `json:"user_id,omitempty"`
+	Name               string     `json:"name" binding:"required"`
+	Key                string     `json:"key" binding:"required"`
+	ExperimentProperty string     `json:"experiment_property"`
+	VariantProperty    string     `json:"variant_property"`
+	ControlVariant     string     `json:"control_variant" binding:"required"`
+	GoalID             *uuid.UUID `json:"goal_id"`
+	FunnelID           *uuid.UUID `json:"funnel_id"`
+	Confidence         float64    `json:"confidence"`
+	SampleSize         int        `json:"sample_size"`
+	StartedAt          *time.Time `json:"started_at"`
+	EndedAt            *time.Time `json:"ended_at"`
+}
+
+// UpdateExperimentRequest changes an experiment's presentation and schedule. Its key,
+// properties and metric are fixed once it has started collecting data.
+type UpdateExperimentRequest struct {
+	Name           *string    `json:"name"`
+	ControlVariant *string    `json:"control_variant"`
+	Confidence     *float64   `json:"confidence"`
+	SampleSize     *int       `json:"sample_size"`
+	StartedAt      *time.Time `json:"started_at"`
+	EndedAt        *time.Time `json:"ended_at"`
+}
+
+// Validate checks that the experiment identifies its variants and has one metric
+func (e *Experiment) Validate() error {
+	if strings.TrimSpace(e.Name) == "" {
+		return fmt.Errorf("experiment name is required")
+	}
+	if e.Key == "" {
+		return fmt.Errorf("experiment key is required")
+	}
+	if e.ExperimentProperty == "" || e.VariantProperty == "" {
+		return fmt.Errorf("experiment and variant properties are required")
+	}
+	if e.ExperimentProperty == e.VariantProperty {
+		return fmt.Errorf("experiment and variant properties must differ")
+	}
+	if e.ControlVariant == "" {
+		return fmt.Errorf("control_variant is required")
+	}
+	if (e.GoalID == nil) == (e.FunnelID == nil) {
+		return fmt.Errorf("exactly one of goal_id and funnel_id is required")
+	}
+	if _, ok := ExperimentZScores[e.Confidence]; !ok {
+		return fmt.Errorf("confidence must be 0.90, 0.95 or 0.99")
+	}
+	if e.SampleSize < 0 {
+		return fmt.Errorf("sample_size cannot be negative")
+	}
+	if e.EndedAt != nil && !e.EndedAt.After(e.StartedAt) {
+		return fmt.Errorf("ended_at must be after started_at")
+	}
+	return nil
+}
+
+// ExperimentCounts are the exposed visitors of a variant and how many converted
+type ExperimentCounts struct {
+	Variant     string `json:"variant"`
+	Visitors    int    `json:"visitors"`
+	Conversions int    `json:"conversions"`
+}
+
+// ExperimentExposure is when a visitor first saw the experiment and in which variant
+type ExperimentExposure struct {
+	VisitorID string
+	Variant   string
+	ExposedAt time.Time
+}
+
+// ExperimentVariantResult is a variant's conversion rate with its confidence interval
+// and, for variants other than the control, how it compares with the control. Rates,
+// bounds and uplift are percentages.
+type ExperimentVariantResult struct {
+	Variant             string   `json:"variant"`
+	Control             bool     `json:"control"`
+	Visitors            int      `json:"visitors"`
+	Conversions         int      `json:"conversions"`
+	ConversionRate      float64  `json:"conversion_rate"`
+	ConfidenceLow       float64  `json:"confidence_low"`
+	ConfidenceHigh      float64  `json:"confidence_high"`
+	Uplift              *float64 `json:"uplift,omitempty"`
+	ZScore              *float64 `json:"z_score,omitempty"`
+	PValue              *float64 `json:"p_value,omitempty"`
+	Significant         bool     `json:"significant"`
+	ProbabilityToBeBest float64  `json:"probability_to_be_best"`
+}
+
+// ExperimentReport is an experiment's results. Alpha is the p-value threshold after the
+// sequential-testing adjustment for how much of the planned sample has been collected.
+type ExperimentReport struct {
+	Experiment          Experiment                `json:"experiment"`
+	Confidence          float64                   `json:"confidence"`
+	Alpha               float64                   `json:"alpha"`
+	InformationFraction *float64                  `json:"information_fraction,omitempty"`
+	Variants            []ExperimentVariantResult `json:"variants"`
+	Winner              *string                   `json:"winner,omitempty"`
+	Warnings            []string                  `json:"warnings"`
+}
+
+// AnalyzeExperiment compares every variant with the control using a two-proportion
+// z-test and ranks all variants by their Bayesian probability to be best.
+//
+// Looking at results before the planned sample size is reached inflates false
+// positives, so with a sample_size the significance threshold follows an
+// O'Brien-Fleming alpha-spending boundary: very strict early on and the nominal alpha
+// once every variant has its planned visitors. Without a sample_size the nominal alpha
+// is used and a warning says the result is not protected against peeking.
+func AnalyzeExperiment(experiment Experiment, counts []ExperimentCounts) ExperimentReport {
+	z := ExperimentZScores[experiment.Confidence]
+	alpha := 1 - experiment.Confidence
+
+	report := ExperimentReport{
+		Experiment: experiment,
+		Confidence: experiment.Confidence,
+		Alpha:      alpha,
+		Warnings:   []string{},
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		ci, cj := counts[i].Variant == experiment.ControlVariant, counts[j].Variant == experiment.ControlVariant
+		if ci != cj {
+			return ci
+		}
+		return counts[i].Variant < counts[j].Variant
+	})
+
+	var control *ExperimentCounts
+	minVisitors := -1
+	for i := range counts {
+		if counts[i].Variant == experiment.ControlVariant {
+			control = &counts[i]
+		}
+		if minVisitors < 0 || counts[i].Visitors < minVisitors {
+			minVisitors = counts[i].Visitors
+		}
+	}
+	if control == nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("control variant %q has no visitors yet", experiment.ControlVariant))
+	}
+	if len(counts) < 2 {
+		report.Warnings = append(report.Warnings, "at least two variants need visitors to compare")
+	}
+
+	if experiment.SampleSize > 0 {
+		fraction := math.Min(1, float64(max(minVisitors, 0))/float64(experiment.SampleSize))
+		report.InformationFraction = &fraction
+		report.Alpha = obrienFlemingAlpha(z, fraction)
+		if fraction < 1 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"%.0f%% of the planned %d visitors per variant collected; the significance threshold is stricter until the sample is complete",
+				fraction*100, experiment.SampleSize))
+		}
+	} else {
+		report.Warnings = append(report.Warnings, "no sample_size planned; checking results repeatedly inflates the false positive rate")
+	}
+
+	for _, c := range counts {
+		if c.Visitors < MinExperimentVisitors || c.Conversions < MinExperimentConversions {
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"variant %q has %d visitors and %d conversions; at least %d and %d are needed for a reliable result",
+				c.Variant, c.Visitors, c.Conversions, MinExperimentVisitors, MinExperimentConversions))
+		}
+	}
+
+	best := probabilityToBeBest(counts)
+	var winner *ExperimentVariantResult
+	for i, c := range counts {
+		result := ExperimentVariantResult{
+			Variant:             c.Variant,
+			Control:             c.Variant == experiment.ControlVariant,
+			Visitors:            c.Visitors,
+			Conversions:         c.Conversions,
+			ProbabilityToBeBest: best[i],
+		}
+		if c.Visitors > 0 {
+			result.ConversionRate = float64(c.Conversions) / float64(c.Visitors) * 100
+			low, high := wilsonInterval(c.Conversions, c.Visitors, z)
+			result.ConfidenceLow, result.ConfidenceHigh = low*100, high*100
+		}
+
+		if !result.Control && control != nil && control.Visitors > 0 && c.Visitors > 0 {
+			zScore, pValue := twoProportionZTest(control.Conversions, control.Visitors, c.Conversions, c.Visitors)
+			result.ZScore, result.PValue = &zScore, &pValue
+			if control.Conversions > 0 {
+				controlRate := float64(control.Conversions) / float64(control.Visitors) * 100
+				uplift := (result.ConversionRate - controlRate) / controlRate * 100
+				result.Uplift = &uplift
+			}
+			result.Significant = pValue < report.Alpha && enoughData(*control) && enoughData(c)
+		}
+
+		report.Variants = append(report.Variants, result)
+	}
+
+	for i := range report.Variants {
+		v := &report.Variants[i]
+		if v.Significant && *v.ZScore > 0 && (winner == nil || v.ConversionRate > winner.ConversionRate) {
+			winner = v
+		}
+	}
+	if winner != nil {
+		report.Winner = &winner.Variant
+	} else if control != nil && len(report.Variants) > 1 {
+		// The control wins when every other variant is significantly worse
+		allWorse := true
+		for _, v := range report.Variants[1:] {
+			if !v.Significant || *v.ZScore >= 0 {
+				allWorse = false
+			}
+		}
+		if allWorse {
+			report.Winner = &control.Variant
+		}
+	}
+
+	return report
+}
+
+func enoughData(c ExperimentCounts) bool {
+	return c.Visitors >= MinExperimentVisitors && c.Conversions >= MinExperimentConversions
+}
+
+// normalCDF is the standard normal cumulative distribution function
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// obrienFlemingAlpha is the alpha spent by information fraction t under the
+// O'Brien-Fleming spending function, for the two-sided critical value z
+func obrienFlemingAlpha(z, t float64) float64 {
+	if t <= 0 {
+		return 0
+	}
+	return 2 - 2*normalCDF(z/math.Sqrt(t))
+}
+
+// wilsonInterval is the Wilson score interval of a binomial proportion
+func wilsonInterval(successes, trials int, z float64) (float64, float64) {
+	n := float64(trials)
+	p := float64(successes) / n
+	denominator := 1 + z*z/n
+	center := (p + z*z/(2*n)) / denominator
+	margin := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denominator
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// twoProportionZTest compares variant b with control a using the pooled proportion and
+// returns the z score and two-sided p-value
+func twoProportionZTest(conversionsA, visitorsA, conversionsB, visitorsB int) (float64, float64) {
+	nA, nB := float64(visitorsA), float64(visitorsB)
+	pA, pB := float64(conversionsA)/nA, float64(conversionsB)/nB
+	pooled := float64(conversionsA+conversionsB) / (nA + nB)
+
+	se := math.Sqrt(pooled * (1 - pooled) * (1/nA + 1/nB))
+	if se == 0 {
+		return 0, 1
+	}
+	z := (pB - pA) / se
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// probabilityToBeBest approximates each variant's chance of having the highest true
+// conversion rate. Rates get a uniform Beta(1, 1) prior; the Beta posteriors are
+// approximated as normal and P(best) integrated numerically.
+func probabilityToBeBest(counts []ExperimentCounts) []float64 {
+	result := make([]float64, len(counts))
+	if len(counts) == 0 {
+		return result
+	}
+
+	means := make([]float64, len(counts))
+	sds := make([]float64, len(counts))
+	low, high := math.Inf(1), math.Inf(-1)
+	for i, c := range counts {
+		alpha := float64(c.Conversions) + 1
+		beta := float64(c.Visitors-c.Conversions) + 1
+		means[i] = alpha / (alpha + beta)
+		sds[i] = math.Sqrt(alpha * beta / ((alpha + beta) * (alpha + beta) * (alpha + beta + 1)))
+		low = math.Min(low, means[i]-8*sds[i])
+		high = math.Max(high, means[i]+8*sds[i])
+	}
+
+	const steps = 2000
+	width := (high - low) / steps
+	total := 0.0
+	for i := range counts {
+		sum := 0.0
+		for s := 0; s <= steps; s++ {
+			x := low + float64(s)*width
+			density := math.Exp(-0.5*math.Pow((x-means[i])/sds[i], 2)) / (sds[i] * math.Sqrt(2*math.Pi))
+			for j := range counts {
+				if j != i {
+					density *= normalCDF((x - means[j]) / sds[j])
+				}
+			}
+			if s == 0 || s == steps {
+				density /= 2
+			}
+			sum += density
+		}
+		result[i] = sum * width
+		total += result[i]
+	}
+
+	if total > 0 {
+		for i := range result {
+			result[i] /= total
+		}
+	}
+	return result
+}
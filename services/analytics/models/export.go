@@ -0,0 +1,295 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportFormat is the file format of an export
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportNDJSON  ExportFormat = "ndjson"
+	ExportParquet ExportFormat = "parquet"
+)
+
+// Extension returns the file extension of the format
+func (f ExportFormat) Extension() string {
+	return string(f)
+}
+
+// ContentType returns the MIME type downloads of the format are served with
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportCSV:
+		return "text/csv"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// ExportStatus is where an export job is in its lifecycle
+type ExportStatus string
+
+const (
+	ExportPending   ExportStatus = "pending"
+	ExportRunning   ExportStatus = "running"
+	ExportCompleted ExportStatus = "completed"
+	ExportFailed    ExportStatus = "failed"
+	// ExportExpired jobs completed, but their file has since been deleted
+	ExportExpired ExportStatus = "expired"
+)
+
+// ExportEventsSource exports raw events; any other source is the name of a report
+const ExportEventsSource = "events"
+
+// ExportReports are the reports that can be exported, by source name
+var ExportReports = []string{
+	"pages", "entry-pages", "exit-pages", "referrers", "sources", "countries",
+	"browsers", "devices", "os", "channels", "daily", "hourly", "custom-events",
+}
+
+// MaxExportReportRows bounds how many rows a report export contains
+const MaxExportReportRows = 10000
+
+// ExportColumnType is the type of an export column, which decides its Parquet type
+type ExportColumnType string
+
+const (
+	ExportString    ExportColumnType = "string"
+	ExportInt       ExportColumnType = "int"
+	ExportFloat     ExportColumnType = "float"
+	ExportTimestamp ExportColumnType = "timestamp"
+)
+
+// ExportColumn is a column of an export. Values of a column are nil, or a string,
+// int64, float64 or time.Time according to its type.
+type ExportColumn struct {
+	Name string
+	Type ExportColumnType
+}
+
+// ExportEventColumns are the columns of a raw events export. IP addresses are never
+// exported; properties are a JSON object.
+var ExportEventColumns = []ExportColumn{
+	{"id", ExportString},
+	{"timestamp", ExportTimestamp},
+	{"visitor_id", ExportString},
+	{"session_id", ExportString},
+	{"event_type", ExportString},
+	{"page", ExportString},
+	{"referrer", ExportString},
+	{"utm_source", ExportString},
+	{"utm_medium", ExportString},
+	{"utm_campaign", ExportString},
+	{"utm_term", ExportString},
+	{"utm_content", ExportString},
+	{"country", ExportString},
+	{"region", ExportString},
+	{"city", ExportString},
+	{"continent", ExportString},
+	{"browser", ExportString},
+	{"device", ExportString},
+	{"os", ExportString},
+	{"time_on_page", ExportInt},
+	{"properties", ExportString},
+}
+
+// ExportJob is a request to write a report or the raw events of a period to a file,
+// and the progress of doing so. Completed files can be downloaded until ExpiresAt.
+type ExportJob struct {
+	ID           uuid.UUID    `json:"id" db:"id"`
+	WebsiteID    string       `json:"website_id" db:"website_id"`
+	UserID       *string      `json:"user_id,omitempty" db:"user_id"`
+	Source       string       `json:"source" db:"source"`
+	Format       ExportFormat `json:"format" db:"format"`
+	StartDate    time.Time    `json:"start_date" db:"start_date"`
+	EndDate      time.Time    `json:"end_date" db:"end_date"`
+	Timezone     string       `json:"timezone" db:"timezone"`
+	Filters      Filters      `json:"filters" db:"filters"`
+	Status       ExportStatus `json:"status" db:"status"`
+	RowsExported int64        `json:"rows_exported" db:"rows_exported"`
+	FileSize     int64        `json:"file_size" db:"file_size"`
+	Error        *string      `json:"error,omitempty" db:"error"`
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at" db:"updated_at"`
+	StartedAt    *time.Time   `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt  *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt    *time.Time   `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+// CreateExportJobRequest describes an export. The period is given like a report's:
+// start and end dates, or the last Days days.
+type CreateExportJobRequest struct {
+	WebsiteID string       `json:"website_id" binding:"required"`
+	UserID    *string      `json:"user_id,omitempty"`
+	Source    string       `json:"source" binding:"required"`
+	Format    ExportFormat `json:"format" binding:"required"`
+	Start     string       `json:"start"`
+	End       string       `json:"end"`
+	Days      int          `json:"days"`
+	Timezone  string       `json:"timezone"`
+	Filters   Filters      `json:"filters"`
+}
+
+// DateRange returns the period the job exports
+func (j *ExportJob) DateRange() DateRange {
+	return DateRange{Start: j.StartDate, End: j.EndDate, Timezone: j.Timezone}
+}
+
+// FileName is the name a completed export is downloaded as
+func (j *ExportJob) FileName() string {
+	loc, err := time.LoadLocation(j.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	// The end date is exclusive
+	last := j.EndDate.Add(-time.Nanosecond)
+	return fmt.Sprintf("%s-%s-%s-%s.%s", j.WebsiteID, j.Source,
+		j.StartDate.In(loc).Format(dateLayout), last.In(loc).Format(dateLayout), j.Format.Extension())
+}
+
+// IsReport reports whether the job exports a report rather than raw events
+func (j *ExportJob) IsReport() bool {
+	return j.Source != ExportEventsSource
+}
+
+// Validate checks the source, format and filters, and that hourly exports are short
+// enough to be broken down by hour
+func (j *ExportJob) Validate() error {
+	if j.Source != ExportEventsSource && !isExportReport(j.Source) {
+		return fmt.Errorf("source must be %s or one of %s, got %q", ExportEventsSource, strings.Join(ExportReports, ", "), j.Source)
+	}
+	if j.Source == "hourly" {
+		if err := j.DateRange().ValidateHourly(); err != nil {
+			return err
+		}
+	}
+
+	switch j.Format {
+	case ExportCSV, ExportNDJSON, ExportParquet:
+	default:
+		return fmt.Errorf("format must be csv, ndjson or parquet, got %q", j.Format)
+	}
+
+	for i, filter := range j.Filters {
+		if err := filter.Validate(); err != nil {
+			return fmt.Errorf("filter %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func isExportReport(source string) bool {
+	for _, report := range ExportReports {
+		if report == source {
+			return true
+		}
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ExportTable returns the columns and rows of a report, a slice of structs. Columns are
+// the struct's JSON fields in order; maps such as properties are exported as JSON.
+func ExportTable(report interface{}) ([]ExportColumn, [][]interface{}, error) {
+	items := reflect.ValueOf(report)
+	if items.Kind() != reflect.Slice || items.Type().Elem().Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("report must be a slice of structs, got %T", report)
+	}
+
+	itemType := items.Type().Elem()
+	var columns []ExportColumn
+	var fields []int
+	for i := 0; i < itemType.NumField(); i++ {
+		field := itemType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columnType, err := exportColumnType(field.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		columns = append(columns, ExportColumn{Name: name, Type: columnType})
+		fields = append(fields, i)
+	}
+
+	rows := make([][]interface{}, items.Len())
+	for i := range rows {
+		item := items.Index(i)
+		row := make([]interface{}, len(fields))
+		for j, field := range fields {
+			value, err := exportValue(item.Field(field))
+			if err != nil {
+				return nil, nil, fmt.Errorf("column %s: %w", columns[j].Name, err)
+			}
+			row[j] = value
+		}
+		rows[i] = row
+	}
+
+	return columns, rows, nil
+}
+
+func exportColumnType(t reflect.Type) (ExportColumnType, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return ExportTimestamp, nil
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Struct, reflect.Bool:
+		return ExportString, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ExportInt, nil
+	case reflect.Float32, reflect.Float64:
+		return ExportFloat, nil
+	}
+	return "", fmt.Errorf("unsupported type %s", t)
+}
+
+func exportValue(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Bool:
+		return fmt.Sprint(v.Bool()), nil
+	case reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	encoded, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,230 @@
+package models
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+)
+
+// FilterOperator is how a filter compares an event value
+type FilterOperator string
+
+const (
+	FilterEquals      FilterOperator = "eq"
+	FilterNotEquals   FilterOperator = "neq"
+	FilterContains    FilterOperator = "contains"
+	FilterNotContains FilterOperator = "not_contains"
+	FilterRegex       FilterOperator = "regex"
+)
+
+// PropertyDimensionPrefix selects a key inside an event's properties, e.g. "properties.plan"
+const PropertyDimensionPrefix = "properties."
+
+// FilterColumns maps filterable dimensions to their events table columns. The IP address
+// is left out on purpose: it is personal data and should not be queryable from reports.
+var FilterColumns = map[string]string{
+	"visitor_id":   "visitor_id",
+	"session_id":   "session_id",
+	"event_type":   "event_type",
+	"page":         "page",
+	"referrer":     "referrer",
+	"user_agent":   "user_agent",
+	"country":      "country",
+	"city":         "city",
+	"region":       "region",
+	"continent":    "continent",
+	"browser":      "browser",
+	"device":       "device",
+	"os":           "os",
+	"utm_source":   "utm_source",
+	"utm_medium":   "utm_medium",
+	"utm_campaign": "utm_campaign",
+	"utm_term":     "utm_term",
+	"utm_content":  "utm_content",
+	"time_on_page": "time_on_page",
+}
+
+// Filter restricts an analytics report to events matching a condition
+type Filter struct {
+	Dimension string         `json:"dimension"`
+	Operator  FilterOperator `json:"operator"`
+	Value     string         `json:"value"`
+
+	// Channels classifies sessions for a channel filter. The built-in rules are used
+	// when it is nil.
+	Channels ChannelRules `json:"-"`
+
+	// Segment is the saved segment a segment filter restricts to
+	Segment *Segment `json:"-"`
+}
+
+// Filters are combined with AND
+type Filters []Filter
+
+// HasChannel reports whether any filter, or any segment's filter, is on the channel
+// dimension
+func (filters Filters) HasChannel() bool {
+	for _, filter := range filters {
+		if filter.Dimension == ChannelDimension {
+			return true
+		}
+		if filter.Segment != nil && filter.Segment.Filters.HasChannel() {
+			return true
+		}
+	}
+	return false
+}
+
+// WithChannels returns a copy of the filters where channel filters, including those
+// of segments, use rules
+func (filters Filters) WithChannels(rules ChannelRules) Filters {
+	result := make(Filters, len(filters))
+	for i, filter := range filters {
+		if filter.Dimension == ChannelDimension {
+			filter.Channels = rules
+		}
+		if filter.Segment != nil {
+			segment := *filter.Segment
+			segment.Filters = segment.Filters.WithChannels(rules)
+			filter.Segment = &segment
+		}
+		result[i] = filter
+	}
+	return result
+}
+
+// ParseFilter parses the "dimension:operator:value" query parameter form,
+// e.g. "country:eq:Germany" or "properties.plan:neq:free"
+func ParseFilter(raw string) (Filter, error) {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 {
+		return Filter{}, fmt.Errorf("filter %q must have the form dimension:operator:value", raw)
+	}
+
+	filter := Filter{
+		Dimension: parts[0],
+		Operator:  FilterOperator(parts[1]),
+		Value:     parts[2],
+	}
+	if err := filter.Validate(); err != nil {
+		return Filter{}, err
+	}
+	return filter, nil
+}
+
+// ParseFilters parses every raw filter, stopping at the first invalid one
+func ParseFilters(raw []string) (Filters, error) {
+	filters := make(Filters, 0, len(raw))
+	for _, r := range raw {
+		filter, err := ParseFilter(r)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// PropertyKey returns the properties key for a property filter
+func (f Filter) PropertyKey() (string, bool) {
+	if !strings.HasPrefix(f.Dimension, PropertyDimensionPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(f.Dimension, PropertyDimensionPrefix), true
+}
+
+// Validate checks the dimension and operator, and that regex values are valid for ValidateRegex
+func (f Filter) Validate() error {
+	if key, ok := f.PropertyKey(); ok {
+		if key == "" {
+			return fmt.Errorf("filter property key is required")
+		}
+	} else if _, ok := FilterColumns[f.Dimension]; !ok && f.Dimension != ChannelDimension {
+		return fmt.Errorf("unknown filter dimension %q", f.Dimension)
+	}
+
+	switch f.Operator {
+	case FilterEquals, FilterNotEquals, FilterContains, FilterNotContains:
+	case FilterRegex:
+		if len(f.Value) > 256 {
+			return fmt.Errorf("filter regex is too long")
+		}
+		if err := ValidateRegex(f.Value); err != nil {
+			return fmt.Errorf("invalid filter regex %q: %w", f.Value, err)
+		}
+	default:
+		return fmt.Errorf("unknown filter operator %q", f.Operator)
+	}
+
+	return nil
+}
+
+// maxRegexRepeat is the largest {n,m} bound Postgres accepts
+const maxRegexRepeat = 255
+
+// ValidateRegex checks a pattern that Postgres will match with ~*. Postgres regular
+// expressions read some syntax differently from Go's, so besides compiling, a pattern
+// may only use plain and (?:) groups, and only escape punctuation or one of \d, \s, \w,
+// \t, \n, \r, \f and \v (\D, \S and \W outside brackets). Flags, named groups, \b,
+// \p{..}, \Q..\E and \z are rejected, as are repeat counts above 255.
+func ValidateRegex(pattern string) error {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	if err := checkRegexRepeats(re); err != nil {
+		return err
+	}
+
+	inBracket := false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			next := pattern[i]
+			switch {
+			case !isRegexWordChar(next):
+			case strings.IndexByte("dswtnrfv", next) >= 0:
+			case strings.IndexByte("DSW", next) >= 0 && !inBracket:
+			default:
+				return fmt.Errorf("unsupported escape \\%c", next)
+			}
+		case inBracket && c == '[' && strings.HasPrefix(pattern[i:], "[:"):
+			// A character class such as [:alpha:]
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				i += end + 3
+			}
+		case inBracket && c == ']':
+			inBracket = false
+		case !inBracket && c == '[':
+			inBracket = true
+			// A ] first in a bracket expression is a literal
+			if strings.HasPrefix(pattern[i+1:], "^") {
+				i++
+			}
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				i++
+			}
+		case !inBracket && c == '(' && strings.HasPrefix(pattern[i+1:], "?") && !strings.HasPrefix(pattern[i+1:], "?:"):
+			return fmt.Errorf("flags and named groups are not supported")
+		}
+	}
+
+	return nil
+}
+
+func checkRegexRepeats(re *syntax.Regexp) error {
+	if re.Op == syntax.OpRepeat && (re.Min > maxRegexRepeat || re.Max > maxRegexRepeat) {
+		return fmt.Errorf("repeat counts cannot exceed %d", maxRegexRepeat)
+	}
+	for _, sub := range re.Sub {
+		if err := checkRegexRepeats(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isRegexWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -16,6 +16,10 @@ type Funnel struct {
 	IsActive    bool        `json:"is_active" db:"is_active"`
 	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+
+	// ConversionWindowSeconds limits the time from the first to the last step; 0 is no limit
+	ConversionWindowSeconds int            `json:"conversion_window_seconds" db:"conversion_window_seconds"`
+	Ordering                FunnelOrdering `json:"ordering" db:"ordering"`
 }
 
 type FunnelStep struct {
@@ -24,12 +28,20 @@ type FunnelStep struct {
 	Type      string          `json:"type"` // page, event, custom
 	Condition FunnelCondition `json:"condition"`
 	Order     int             `json:"order"`
+
+	// WindowSeconds limits the time since the previous step; 0 is no limit
+	WindowSeconds int `json:"window_seconds,omitempty"`
 }
 
+// FunnelCondition is what completes a step. Operator applies to the page or custom
+// event name; without one, pages are matched as patterns ("/product/*") and names
+// exactly. Properties are predicates on the event's properties.
 type FunnelCondition struct {
-	Page   *string `json:"page,omitempty"`
-	Event  *string `json:"event,omitempty"`
-	Custom *string `json:"custom,omitempty"`
+	Page       *string                 `json:"page,omitempty"`
+	Event      *string                 `json:"event,omitempty"`
+	Custom     *string                 `json:"custom,omitempty"`
+	Operator   FilterOperator          `json:"operator,omitempty"`
+	Properties []GoalPropertyCondition `json:"properties,omitempty"`
 }
 
 type FunnelSteps []FunnelStep
@@ -65,21 +77,25 @@ type FunnelAnalytics struct {
 }
 
 type CreateFunnelRequest struct {
-	Name        string      `json:"name" binding:"required"`
-	Description *string     `json:"description"`
-	WebsiteID   string      `json:"website_id" binding:"required"`
-	UserID      *string     `json:"user_id,omitempty"`
-	Steps       FunnelSteps `json:"steps" binding:"required"`
-	IsActive    bool        `json:"is_active"`
+	Name                    string         `json:"name" binding:"required"`
+	Description             *string        `json:"description"`
+	WebsiteID               string         `json:"website_id" binding:"required"`
+	UserID                  *string        `json:"user_id,omitempty"`
+	Steps                   FunnelSteps    `json:"steps" binding:"required"`
+	IsActive                bool           `json:"is_active"`
+	ConversionWindowSeconds int            `json:"conversion_window_seconds"`
+	Ordering                FunnelOrdering `json:"ordering"`
 }
 
 type UpdateFunnelRequest struct {
-	Name        *string      `json:"name"`
-	Description *string      `json:"description"`
-	WebsiteID   *string      `json:"website_id"`
-	UserID      *string      `json:"user_id"`
-	Steps       *FunnelSteps `json:"steps"`
-	IsActive    *bool        `json:"is_active"`
+	Name                    *string         `json:"name"`
+	Description             *string         `json:"description"`
+	WebsiteID               *string         `json:"website_id"`
+	UserID                  *string         `json:"user_id"`
+	Steps                   *FunnelSteps    `json:"steps"`
+	IsActive                *bool           `json:"is_active"`
+	ConversionWindowSeconds *int            `json:"conversion_window_seconds"`
+	Ordering                *FunnelOrdering `json:"ordering"`
 }
 
 // Advanced Analytics Models
@@ -115,6 +131,42 @@ type DetailedFunnelAnalytics struct {
 	DailyPerformance []DailyFunnelPerformance `json:"daily_performance" db:"daily_performance"`
 	CohortData       []FunnelCohortData       `json:"cohort_data" db:"cohort_data"`
 	DateRange        int                      `json:"date_range" db:"date_range"`
+	Mode             FunnelMode               `json:"mode" db:"mode"`
+	Per              FunnelUnit               `json:"per" db:"per"`
+	StepTimings      []FunnelStepTiming       `json:"step_timings" db:"step_timings"`
+	TimeToConvert    *FunnelStepTiming        `json:"time_to_convert,omitempty" db:"time_to_convert"`
+	Breakdown        string                   `json:"breakdown,omitempty" db:"breakdown"`
+	Segments         []FunnelSegment          `json:"segments,omitempty" db:"segments"`
+}
+
+// FunnelSegment is the funnel for the paths started with one breakdown value
+type FunnelSegment struct {
+	Value          string                `json:"value"`
+	Entries        int                   `json:"entries"`
+	Conversions    int                   `json:"conversions"`
+	ConversionRate float64               `json:"conversion_rate"`
+	StepAnalytics  []FunnelStepAnalytics `json:"step_analytics"`
+}
+
+// FunnelStepTiming is how long paths took from one step to a later one (1-based), as
+// percentiles and a histogram, in seconds
+type FunnelStepTiming struct {
+	FromStep  int                  `json:"from_step"`
+	ToStep    int                  `json:"to_step"`
+	Count     int                  `json:"count"`
+	P25       float64              `json:"p25"`
+	Median    float64              `json:"median"`
+	P75       float64              `json:"p75"`
+	P90       float64              `json:"p90"`
+	Histogram []FunnelTimingBucket `json:"histogram"`
+}
+
+// FunnelTimingBucket counts timings from MinSeconds up to, not including, MaxSeconds.
+// The last bucket has no upper bound.
+type FunnelTimingBucket struct {
+	MinSeconds int  `json:"min_seconds"`
+	MaxSeconds *int `json:"max_seconds,omitempty"`
+	Count      int  `json:"count"`
 }
 
 // Funnel Comparison Models
@@ -0,0 +1,453 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Funnel step types
+const (
+	FunnelStepPage   = "page"
+	FunnelStepEvent  = "event"
+	FunnelStepCustom = "custom"
+)
+
+// FunnelOrdering is how strictly funnel steps must follow each other
+type FunnelOrdering string
+
+const (
+	// FunnelOrderLoose allows other events between steps as long as the steps happen in order
+	FunnelOrderLoose FunnelOrdering = "loose"
+	// FunnelOrderStrict requires each step to be the very next event after the previous one
+	FunnelOrderStrict FunnelOrdering = "strict"
+)
+
+// FunnelMode is where detailed funnel analytics find the visitors to evaluate
+type FunnelMode string
+
+const (
+	// FunnelModeTracked evaluates visitors the tracker reported entering the funnel
+	FunnelModeTracked FunnelMode = "tracked"
+	// FunnelModeRetroactive evaluates every visitor's raw events, including those from
+	// before the funnel existed
+	FunnelModeRetroactive FunnelMode = "retroactive"
+)
+
+// FunnelUnit is whose events make up one path through the funnel
+type FunnelUnit string
+
+const (
+	FunnelPerVisitor FunnelUnit = "visitor"
+	FunnelPerSession FunnelUnit = "session"
+)
+
+const (
+	DefaultFunnelDays            = 7
+	DefaultRetroactiveFunnelDays = 90
+	// MaxRetroactiveFunnelDays bounds how much raw history a retroactive funnel scans
+	MaxRetroactiveFunnelDays = 90
+
+	DefaultFunnelBreakdownLimit = 10
+	MaxFunnelBreakdownLimit     = 50
+)
+
+// FunnelSourceDimension breaks a funnel down by the UTM source, or else the referrer's host
+const FunnelSourceDimension = "source"
+
+// FunnelQuery describes a detailed funnel analytics request. Breakdown, when set, is
+// the dimension to split paths by, taken from the event that started each path.
+// SegmentID restricts the paths to a saved segment's sessions or visitors; Segment is
+// that segment once loaded.
+type FunnelQuery struct {
+	Days      int        `json:"days"`
+	Mode      FunnelMode `json:"mode"`
+	Per       FunnelUnit `json:"per"`
+	Breakdown string     `json:"breakdown,omitempty"`
+	Limit     int        `json:"limit,omitempty"`
+	SegmentID *uuid.UUID `json:"segment_id,omitempty"`
+	Segment   *Segment   `json:"-"`
+}
+
+// Filters returns the report filters the query restricts paths with
+func (q FunnelQuery) Filters() Filters {
+	if q.Segment == nil {
+		return nil
+	}
+	return Filters{q.Segment.Filter()}
+}
+
+// NewFunnelQuery builds a funnel query. The mode defaults to tracked and paths are per
+// visitor; without days, tracked funnels cover 7 days and retroactive ones 90. A
+// breakdown is any filter column or "source", and reports its top limit values.
+func NewFunnelQuery(days int, mode, per, breakdown string, limit int) (FunnelQuery, error) {
+	q := FunnelQuery{Days: days, Mode: FunnelMode(mode), Per: FunnelUnit(per), Breakdown: breakdown}
+
+	switch q.Mode {
+	case "":
+		q.Mode = FunnelModeTracked
+	case FunnelModeTracked, FunnelModeRetroactive:
+	default:
+		return FunnelQuery{}, fmt.Errorf("funnel mode must be tracked or retroactive, got %q", mode)
+	}
+
+	switch q.Per {
+	case "":
+		q.Per = FunnelPerVisitor
+	case FunnelPerVisitor, FunnelPerSession:
+	default:
+		return FunnelQuery{}, fmt.Errorf("funnel per must be visitor or session, got %q", per)
+	}
+
+	if q.Days <= 0 {
+		q.Days = DefaultFunnelDays
+		if q.Mode == FunnelModeRetroactive {
+			q.Days = DefaultRetroactiveFunnelDays
+		}
+	}
+	if q.Mode == FunnelModeRetroactive && q.Days > MaxRetroactiveFunnelDays {
+		return FunnelQuery{}, fmt.Errorf("retroactive funnels cannot cover more than %d days", MaxRetroactiveFunnelDays)
+	}
+
+	if q.Breakdown != "" {
+		if _, ok := FilterColumns[q.Breakdown]; !ok && q.Breakdown != FunnelSourceDimension {
+			return FunnelQuery{}, fmt.Errorf("unknown breakdown dimension %q", breakdown)
+		}
+		q.Limit = limit
+		if q.Limit <= 0 {
+			q.Limit = DefaultFunnelBreakdownLimit
+		}
+		if q.Limit > MaxFunnelBreakdownLimit {
+			q.Limit = MaxFunnelBreakdownLimit
+		}
+	}
+	return q, nil
+}
+
+// Tracked reports whether the step is only seen through the tracker's funnel events.
+// Event steps match CSS selectors on clicks, which are not stored as raw events.
+func (s FunnelStep) Tracked() bool {
+	return s.Type == FunnelStepEvent
+}
+
+// Filters returns the conditions a raw event must meet to complete the step. Tracked
+// steps have none.
+func (s FunnelStep) Filters() Filters {
+	var filters Filters
+	switch s.Type {
+	case FunnelStepPage:
+		page := ""
+		if s.Condition.Page != nil {
+			page = *s.Condition.Page
+		}
+		filters = Filters{{Dimension: "event_type", Operator: FilterEquals, Value: "pageview"}}
+		if s.Condition.Operator == "" {
+			filters = append(filters, Filter{Dimension: "page", Operator: FilterRegex, Value: PagePatternRegex(page)})
+		} else {
+			filters = append(filters, Filter{Dimension: "page", Operator: s.Condition.Operator, Value: page})
+		}
+	case FunnelStepCustom:
+		name := ""
+		if s.Condition.Custom != nil {
+			name = *s.Condition.Custom
+		}
+		operator := s.Condition.Operator
+		if operator == "" {
+			operator = FilterEquals
+		}
+		filters = Filters{{Dimension: "event_type", Operator: operator, Value: name}}
+	default:
+		return nil
+	}
+
+	for _, p := range s.Condition.Properties {
+		filters = append(filters, Filter{Dimension: PropertyDimensionPrefix + p.Key, Operator: p.Operator, Value: p.Value})
+	}
+	return filters
+}
+
+// FunnelTouch is one event in a visitor's timeline with the 0-based indexes of the
+// funnel steps it matches. Events matching no step only matter for strict ordering.
+type FunnelTouch struct {
+	Timestamp time.Time
+	Steps     []int
+
+	// Segment is the event's breakdown dimension value
+	Segment string
+}
+
+func (t FunnelTouch) matches(step int) bool {
+	for _, s := range t.Steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// FunnelPath is how far one visitor (or session) got through a funnel. StepTimes holds
+// when each completed step was first reached, so its length is the number of steps
+// completed. Segment is the breakdown value of the event that started the path, and
+// Unit the visitor or session ID the path belongs to.
+type FunnelPath struct {
+	StepTimes []time.Time
+	Segment   string
+	Unit      string
+}
+
+// Reached returns the number of steps completed
+func (p FunnelPath) Reached() int {
+	return len(p.StepTimes)
+}
+
+// Evaluate finds the furthest a visitor got through the funnel from their timeline,
+// which must be sorted by time. Every first-step event is tried as a start and the
+// earliest of the longest paths wins.
+func (f *Funnel) Evaluate(touches []FunnelTouch) FunnelPath {
+	var best FunnelPath
+	if len(f.Steps) == 0 {
+		return best
+	}
+
+	// Without windows or strict ordering a later start can never get further
+	unconstrained := f.Ordering != FunnelOrderStrict && f.ConversionWindowSeconds == 0
+	for _, step := range f.Steps {
+		if step.WindowSeconds > 0 {
+			unconstrained = false
+		}
+	}
+
+	for start, touch := range touches {
+		if !touch.matches(0) {
+			continue
+		}
+		path := f.evaluateFrom(touches, start)
+		if path.Reached() > best.Reached() {
+			best = path
+		}
+		if unconstrained || best.Reached() == len(f.Steps) {
+			break
+		}
+	}
+	return best
+}
+
+// EvaluateSince is Evaluate on the touches at or after since, so that a path taken
+// before since cannot hide a later one
+func (f *Funnel) EvaluateSince(touches []FunnelTouch, since time.Time) FunnelPath {
+	start := sort.Search(len(touches), func(i int) bool { return !touches[i].Timestamp.Before(since) })
+	return f.Evaluate(touches[start:])
+}
+
+// evaluateFrom follows the funnel from the first step at touches[start]. For each step
+// it keeps the latest time a valid path reached it, which leaves the most room for the
+// next step's window.
+func (f *Funnel) evaluateFrom(touches []FunnelTouch, start int) FunnelPath {
+	steps := len(f.Steps)
+	started := touches[start].Timestamp
+	path := FunnelPath{StepTimes: []time.Time{started}, Segment: touches[start].Segment}
+
+	reached := make([]bool, steps)
+	latest := make([]time.Time, steps)
+	reached[0], latest[0] = true, started
+
+	// previous marks the steps reached by the previous touch, for strict ordering
+	previous := make([]bool, steps)
+	previous[0] = true
+
+	for i := start + 1; i < len(touches) && path.Reached() < steps; i++ {
+		touch := touches[i]
+		if f.ConversionWindowSeconds > 0 && touch.Timestamp.Sub(started) > time.Duration(f.ConversionWindowSeconds)*time.Second {
+			break
+		}
+
+		current := make([]bool, steps)
+		// Later steps first, so one event cannot complete two consecutive steps
+		for k := steps - 1; k >= 1; k-- {
+			if !touch.matches(k) {
+				continue
+			}
+
+			var ok bool
+			var prior time.Time
+			if f.Ordering == FunnelOrderStrict {
+				ok, prior = previous[k-1], touches[i-1].Timestamp
+			} else {
+				ok, prior = reached[k-1], latest[k-1]
+			}
+			if !ok {
+				continue
+			}
+			if window := f.Steps[k].WindowSeconds; window > 0 && touch.Timestamp.Sub(prior) > time.Duration(window)*time.Second {
+				continue
+			}
+
+			reached[k], latest[k], current[k] = true, touch.Timestamp, true
+			if k == path.Reached() {
+				path.StepTimes = append(path.StepTimes, touch.Timestamp)
+			}
+		}
+		previous = current
+	}
+	return path
+}
+
+// StepAnalytics summarises evaluated paths per step: how many visitors reached it, the
+// share of them that went on to the next step and their average seconds to do so
+func (f *Funnel) StepAnalytics(paths []FunnelPath) []FunnelStepAnalytics {
+	steps := len(f.Steps)
+	reached := make([]int, steps)
+	seconds := make([]float64, steps)
+
+	for _, path := range paths {
+		for k := 0; k < path.Reached() && k < steps; k++ {
+			reached[k]++
+			if k+1 < path.Reached() {
+				seconds[k] += path.StepTimes[k+1].Sub(path.StepTimes[k]).Seconds()
+			}
+		}
+	}
+
+	analytics := make([]FunnelStepAnalytics, steps)
+	for k, step := range f.Steps {
+		var conversion float64
+		var avgTime *float64
+		if reached[k] > 0 {
+			if k+1 < steps {
+				conversion = float64(reached[k+1]) / float64(reached[k]) * 100
+				if reached[k+1] > 0 {
+					avg := seconds[k] / float64(reached[k+1])
+					avgTime = &avg
+				}
+			} else {
+				conversion = 100
+			}
+		}
+
+		analytics[k] = FunnelStepAnalytics{
+			StepID:          step.ID,
+			StepName:        step.Name,
+			StepOrder:       step.Order,
+			VisitorsReached: reached[k],
+			ConversionRate:  conversion,
+			DropOffRate:     100 - conversion,
+			AvgTimeOnStep:   avgTime,
+		}
+	}
+	return analytics
+}
+
+// Breakdown splits paths by segment and summarises each one's steps, keeping the limit
+// segments with the most paths
+func (f *Funnel) Breakdown(paths []FunnelPath, limit int) []FunnelSegment {
+	bySegment := map[string][]FunnelPath{}
+	for _, path := range paths {
+		bySegment[path.Segment] = append(bySegment[path.Segment], path)
+	}
+
+	values := make([]string, 0, len(bySegment))
+	for value := range bySegment {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := len(bySegment[values[i]]), len(bySegment[values[j]])
+		if a != b {
+			return a > b
+		}
+		return values[i] < values[j]
+	})
+	if limit > 0 && len(values) > limit {
+		values = values[:limit]
+	}
+
+	segments := make([]FunnelSegment, len(values))
+	for i, value := range values {
+		segmentPaths := bySegment[value]
+		conversions := 0
+		for _, path := range segmentPaths {
+			if path.Reached() == len(f.Steps) {
+				conversions++
+			}
+		}
+		segments[i] = FunnelSegment{
+			Value:          value,
+			Entries:        len(segmentPaths),
+			Conversions:    conversions,
+			ConversionRate: float64(conversions) / float64(len(segmentPaths)) * 100,
+			StepAnalytics:  f.StepAnalytics(segmentPaths),
+		}
+	}
+	return segments
+}
+
+// FunnelTimingBuckets are the upper bounds in seconds of the timing histogram buckets:
+// 10s, 30s, 1m, 5m, 15m, 1h, 6h, 1d and 7d. A last bucket holds everything slower.
+var FunnelTimingBuckets = []int{10, 30, 60, 300, 900, 3600, 21600, 86400, 604800}
+
+// StepTimings returns the distribution of times between each pair of consecutive steps
+func (f *Funnel) StepTimings(paths []FunnelPath) []FunnelStepTiming {
+	var timings []FunnelStepTiming
+	for k := 1; k < len(f.Steps); k++ {
+		timings = append(timings, stepTiming(paths, k-1, k))
+	}
+	return timings
+}
+
+// TimeToConvert returns the distribution of times from the first to the last step of
+// converted paths, or nil for a single-step funnel
+func (f *Funnel) TimeToConvert(paths []FunnelPath) *FunnelStepTiming {
+	if len(f.Steps) < 2 {
+		return nil
+	}
+	timing := stepTiming(paths, 0, len(f.Steps)-1)
+	return &timing
+}
+
+func stepTiming(paths []FunnelPath, from, to int) FunnelStepTiming {
+	var seconds []float64
+	for _, path := range paths {
+		if path.Reached() > to {
+			seconds = append(seconds, path.StepTimes[to].Sub(path.StepTimes[from]).Seconds())
+		}
+	}
+	sort.Float64s(seconds)
+
+	timing := FunnelStepTiming{
+		FromStep:  from + 1,
+		ToStep:    to + 1,
+		Count:     len(seconds),
+		Histogram: make([]FunnelTimingBucket, len(FunnelTimingBuckets)+1),
+	}
+
+	lower := 0
+	for i, upper := range FunnelTimingBuckets {
+		bound := upper
+		timing.Histogram[i] = FunnelTimingBucket{MinSeconds: lower, MaxSeconds: &bound}
+		lower = upper
+	}
+	timing.Histogram[len(FunnelTimingBuckets)] = FunnelTimingBucket{MinSeconds: lower}
+
+	for _, s := range seconds {
+		bucket := sort.SearchInts(FunnelTimingBuckets, int(math.Floor(s))+1)
+		timing.Histogram[bucket].Count++
+	}
+
+	if len(seconds) > 0 {
+		timing.P25 = percentile(seconds, 25)
+		timing.Median = percentile(seconds, 50)
+		timing.P75 = percentile(seconds, 75)
+		timing.P90 = percentile(seconds, 90)
+	}
+	return timing
+}
+
+// percentile interpolates linearly between the closest ranks of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GoalType is what completes a goal
+type GoalType string
+
+const (
+	// GoalPageview is completed by a pageview whose path matches PagePattern
+	GoalPageview GoalType = "pageview"
+	// GoalEvent is completed by a custom event named EventName whose properties match
+	GoalEvent GoalType = "event"
+)
+
+// GoalPropertyCondition is a predicate on a custom event property
+type GoalPropertyCondition struct {
+	Key      string         `json:"key"`
+	Operator FilterOperator `json:"operator"`
+	Value    string         `json:"value"`
+}
+
+// Goal is a conversion a website tracks. Value is an optional monetary value credited
+// for every completion.
+type Goal struct {
+	ID          uuid.UUID               `json:"id" db:"id"`
+	WebsiteID   string                  `json:"website_id" db:"website_id"`
+	UserID      *string                 `json:"user_id,omitempty" db:"user_id"`
+	Name        string                  `json:"name" db:"name"`
+	Type        GoalType                `json:"type" db:"type"`
+	PagePattern *string                 `json:"page_pattern,omitempty" db:"page_pattern"`
+	EventName   *string                 `json:"event_name,omitempty" db:"event_name"`
+	Properties  []GoalPropertyCondition `json:"properties,omitempty" db:"properties"`
+	Value       *float64                `json:"value,omitempty" db:"value"`
+	IsActive    bool                    `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time               `json:"updated_at" db:"updated_at"`
+}
+
+type CreateGoalRequest struct {
+	WebsiteID   string                  `json:"website_id" binding:"required"`
+	UserID      *string                 `json:"user_id,omitempty"`
+	Name        string                  `json:"name" binding:"required"`
+	Type        GoalType                `json:"type" binding:"required"`
+	PagePattern *string                 `json:"page_pattern"`
+	EventName   *string                 `json:"event_name"`
+	Properties  []GoalPropertyCondition `json:"properties"`
+	Value       *float64                `json:"value"`
+	IsActive    *bool                   `json:"is_active"`
+}
+
+type UpdateGoalRequest struct {
+	Name        *string                  `json:"name"`
+	Type        *GoalType                `json:"type"`
+	PagePattern *string                  `json:"page_pattern"`
+	EventName   *string                  `json:"event_name"`
+	Properties  *[]GoalPropertyCondition `json:"properties"`
+	Value       *float64                 `json:"value"`
+	IsActive    *bool                    `json:"is_active"`
+}
+
+// Validate checks that the goal has what its type needs
+func (g *Goal) Validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return fmt.Errorf("goal name is required")
+	}
+	if g.Value != nil && *g.Value < 0 {
+		return fmt.Errorf("goal value cannot be negative")
+	}
+
+	switch g.Type {
+	case GoalPageview:
+		if g.PagePattern == nil || *g.PagePattern == "" {
+			return fmt.Errorf("page_pattern is required for pageview goals")
+		}
+		if len(g.Properties) > 0 {
+			return fmt.Errorf("property conditions are only supported on event goals")
+		}
+	case GoalEvent:
+		if g.EventName == nil || *g.EventName == "" {
+			return fmt.Errorf("event_name is required for event goals")
+		}
+	default:
+		return fmt.Errorf("goal type must be pageview or event, got %q", g.Type)
+	}
+
+	for _, filter := range g.Filters() {
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filters returns the conditions an event must meet to complete the goal
+func (g *Goal) Filters() Filters {
+	switch g.Type {
+	case GoalPageview:
+		pattern := ""
+		if g.PagePattern != nil {
+			pattern = *g.PagePattern
+		}
+		return Filters{
+			{Dimension: "event_type", Operator: FilterEquals, Value: "pageview"},
+			{Dimension: "page", Operator: FilterRegex, Value: PagePatternRegex(pattern)},
+		}
+	default:
+		name := ""
+		if g.EventName != nil {
+			name = *g.EventName
+		}
+		filters := Filters{{Dimension: "event_type", Operator: FilterEquals, Value: name}}
+		for _, p := range g.Properties {
+			filters = append(filters, Filter{Dimension: PropertyDimensionPrefix + p.Key, Operator: p.Operator, Value: p.Value})
+		}
+		return filters
+	}
+}
+
+// PagePatternRegex turns a path pattern into an anchored regex. "*" matches any run of
+// characters, so "/blog/*" matches every post and "/pricing" only the pricing page.
+// A trailing slash, query string or fragment on the visited path is ignored.
+func PagePatternRegex(pattern string) string {
+	parts := strings.Split(strings.TrimRight(pattern, "/"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return "^" + strings.Join(parts, ".*") + `/?([?#].*)?$`
+}
+
+// GoalStat is how often a goal was completed in a reporting period. Conversions are
+// unique visitors who completed it; the rate is against all visitors in the period.
+type GoalStat struct {
+	GoalID         uuid.UUID `json:"goal_id"`
+	Name           string    `json:"name"`
+	Type           GoalType  `json:"type"`
+	Completions    int       `json:"completions"`
+	Conversions    int       `json:"conversions"`
+	ConversionRate float64   `json:"conversion_rate"`
+	Value          *float64  `json:"value,omitempty"`
+}
+
+// GoalConversion is one goal's conversions within a breakdown row
+type GoalConversion struct {
+	GoalID         uuid.UUID `json:"goal_id"`
+	Name           string    `json:"name"`
+	Conversions    int       `json:"conversions"`
+	ConversionRate float64   `json:"conversion_rate"`
+}
+
+// GoalBreakdownRow is a dimension value with its visitors and their goal conversions
+type GoalBreakdownRow struct {
+	Value    string           `json:"value"`
+	Visitors int              `json:"visitors"`
+	Goals    []GoalConversion `json:"goals"`
+}
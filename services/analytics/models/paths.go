@@ -0,0 +1,94 @@
+package models
+
+import "fmt"
+
+// PathDirection is which way a path report walks from its anchor page
+type PathDirection string
+
+const (
+	PathForward  PathDirection = "forward"
+	PathBackward PathDirection = "backward"
+)
+
+const (
+	// PathOtherPage is the node that pages outside a step's top pages are folded into
+	PathOtherPage = "other"
+
+	DefaultPathSteps = 3
+	MaxPathSteps     = 10
+
+	DefaultPathPagesPerStep = 10
+	MaxPathPagesPerStep     = 50
+)
+
+// PathQuery describes a path exploration: sessions are anchored at the first visit to Page
+// (forward) or the last visit to it (backward), and followed for Steps pageviews
+type PathQuery struct {
+	Page         string        `json:"page"`
+	Direction    PathDirection `json:"direction"`
+	Steps        int           `json:"steps"`
+	PagesPerStep int           `json:"pages_per_step"`
+}
+
+// NewPathQuery builds a path query from a start page or an end page; exactly one must be set
+func NewPathQuery(startPage, endPage string, steps, pagesPerStep int) (PathQuery, error) {
+	q := PathQuery{Steps: steps, PagesPerStep: pagesPerStep}
+	switch {
+	case startPage != "" && endPage != "":
+		return PathQuery{}, fmt.Errorf("only one of start_page and end_page can be set")
+	case startPage != "":
+		q.Page, q.Direction = startPage, PathForward
+	case endPage != "":
+		q.Page, q.Direction = endPage, PathBackward
+	default:
+		return PathQuery{}, fmt.Errorf("start_page or end_page is required")
+	}
+
+	if q.Steps <= 0 {
+		q.Steps = DefaultPathSteps
+	}
+	if q.Steps > MaxPathSteps {
+		return PathQuery{}, fmt.Errorf("steps cannot exceed %d", MaxPathSteps)
+	}
+	if q.PagesPerStep <= 0 {
+		q.PagesPerStep = DefaultPathPagesPerStep
+	}
+	if q.PagesPerStep > MaxPathPagesPerStep {
+		q.PagesPerStep = MaxPathPagesPerStep
+	}
+
+	return q, nil
+}
+
+// PathNodeID identifies a page at a step. Steps count from the anchor page (step 0) and are
+// negative when walking backward, so links always point from step n to step n+1.
+func PathNodeID(step int, page string) string {
+	return fmt.Sprintf("%d:%s", step, page)
+}
+
+// PathNode is a page at one step of the explored paths
+type PathNode struct {
+	ID       string `json:"id"`
+	Step     int    `json:"step"`
+	Page     string `json:"page"`
+	Sessions int    `json:"sessions"`
+}
+
+// PathLink is the number of sessions that went from one node to the next
+type PathLink struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Sessions int    `json:"sessions"`
+}
+
+// PathReport is Sankey-ready path data around an anchor page
+type PathReport struct {
+	WebsiteID string        `json:"website_id"`
+	DateRange string        `json:"date_range"`
+	Page      string        `json:"page"`
+	Direction PathDirection `json:"direction"`
+	Steps     int           `json:"steps"`
+	Sessions  int           `json:"sessions"`
+	Nodes     []PathNode    `json:"nodes"`
+	Links     []PathLink    `json:"links"`
+}
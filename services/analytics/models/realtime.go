@@ -0,0 +1,160 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RealtimeWindow is how long after their last pageview a visitor still counts as live
+	RealtimeWindow = 5 * time.Minute
+	// RealtimeTopPages bounds how many pages a realtime snapshot ranks
+	RealtimeTopPages = 10
+	// RealtimeRecentEvents bounds the recent-events feed of a realtime snapshot
+	RealtimeRecentEvents = 20
+)
+
+// RealtimeEvent is an accepted event as published to realtime subscribers. The visitor
+// ID is only used to count live visitors and is never sent to clients.
+type RealtimeEvent struct {
+	ID        uuid.UUID `json:"id"`
+	WebsiteID string    `json:"website_id"`
+	VisitorID string    `json:"visitor_id,omitempty"`
+	EventType string    `json:"event_type"`
+	Page      string    `json:"page,omitempty"`
+	Referrer  string    `json:"referrer,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	Browser   string    `json:"browser,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewRealtimeEvent returns the realtime form of an event
+func NewRealtimeEvent(event *Event) RealtimeEvent {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return RealtimeEvent{
+		ID:        event.ID,
+		WebsiteID: event.WebsiteID,
+		VisitorID: event.VisitorID,
+		EventType: event.EventType,
+		Page:      event.Page,
+		Referrer:  deref(event.Referrer),
+		Country:   deref(event.Country),
+		Device:    deref(event.Device),
+		Browser:   deref(event.Browser),
+		Timestamp: event.Timestamp,
+	}
+}
+
+// RealtimePage is a page and how many live visitors are currently on it
+type RealtimePage struct {
+	Page     string `json:"page"`
+	Visitors int    `json:"visitors"`
+}
+
+// RealtimeSnapshot is one update of a website's realtime stream
+type RealtimeSnapshot struct {
+	WebsiteID    string          `json:"website_id"`
+	LiveVisitors int             `json:"live_visitors"`
+	TopPages     []RealtimePage  `json:"top_pages"`
+	RecentEvents []RealtimeEvent `json:"recent_events"`
+	Timestamp    time.Time       `json:"timestamp"`
+}
+
+// realtimeVisitor is where a live visitor was last seen
+type realtimeVisitor struct {
+	page     string
+	lastSeen time.Time
+}
+
+// RealtimeState follows a website's live visitors and recent events as they are
+// published. Visitors are live for RealtimeWindow after their last pageview, which
+// matches GetLiveVisitors. It is not safe for concurrent use.
+type RealtimeState struct {
+	visitors map[string]realtimeVisitor
+	recent   []RealtimeEvent
+}
+
+func NewRealtimeState() *RealtimeState {
+	return &RealtimeState{visitors: make(map[string]realtimeVisitor)}
+}
+
+// Add records an event. Events are expected roughly in time order; an older pageview
+// does not move a visitor back to a previous page, and an event already in the feed
+// is not added again.
+func (s *RealtimeState) Add(event RealtimeEvent) {
+	if event.EventType == "pageview" && event.VisitorID != "" {
+		if seen, ok := s.visitors[event.VisitorID]; !ok || !event.Timestamp.Before(seen.lastSeen) {
+			s.visitors[event.VisitorID] = realtimeVisitor{page: event.Page, lastSeen: event.Timestamp}
+		}
+	}
+
+	for _, recent := range s.recent {
+		if recent.ID == event.ID {
+			return
+		}
+	}
+
+	// The feed is kept newest first
+	i := sort.Search(len(s.recent), func(i int) bool {
+		return !s.recent[i].Timestamp.After(event.Timestamp)
+	})
+	if i >= RealtimeRecentEvents {
+		return
+	}
+	s.recent = append(s.recent, RealtimeEvent{})
+	copy(s.recent[i+1:], s.recent[i:])
+	s.recent[i] = event
+	if len(s.recent) > RealtimeRecentEvents {
+		s.recent = s.recent[:RealtimeRecentEvents]
+	}
+}
+
+// Snapshot forgets visitors that are no longer live at now and returns the current
+// state. Top pages are ranked by the live visitors last seen on them.
+func (s *RealtimeState) Snapshot(websiteID string, now time.Time) RealtimeSnapshot {
+	cutoff := now.Add(-RealtimeWindow)
+	pages := make(map[string]int)
+	for id, visitor := range s.visitors {
+		if visitor.lastSeen.Before(cutoff) {
+			delete(s.visitors, id)
+			continue
+		}
+		pages[visitor.page]++
+	}
+
+	topPages := make([]RealtimePage, 0, len(pages))
+	for page, visitors := range pages {
+		topPages = append(topPages, RealtimePage{Page: page, Visitors: visitors})
+	}
+	sort.Slice(topPages, func(i, j int) bool {
+		if topPages[i].Visitors != topPages[j].Visitors {
+			return topPages[i].Visitors > topPages[j].Visitors
+		}
+		return topPages[i].Page < topPages[j].Page
+	})
+	if len(topPages) > RealtimeTopPages {
+		topPages = topPages[:RealtimeTopPages]
+	}
+
+	recent := make([]RealtimeEvent, len(s.recent))
+	for i, event := range s.recent {
+		event.VisitorID = ""
+		recent[i] = event
+	}
+
+	return RealtimeSnapshot{
+		WebsiteID:    websiteID,
+		LiveVisitors: len(s.visitors),
+		TopPages:     topPages,
+		RecentEvents: recent,
+		Timestamp:    now,
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetentionPeriod is the size of a retention cohort and of each retention step
+type RetentionPeriod string
+
+const (
+	RetentionDay   RetentionPeriod = "day"
+	RetentionWeek  RetentionPeriod = "week"
+	RetentionMonth RetentionPeriod = "month"
+)
+
+// ParseRetentionPeriod validates a period query parameter, defaulting to weekly cohorts
+func ParseRetentionPeriod(raw string) (RetentionPeriod, error) {
+	switch period := RetentionPeriod(raw); period {
+	case "":
+		return RetentionWeek, nil
+	case RetentionDay, RetentionWeek, RetentionMonth:
+		return period, nil
+	default:
+		return "", fmt.Errorf("period must be day, week or month, got %q", raw)
+	}
+}
+
+// Truncate returns the start of the period containing t, in t's location. Weeks start on Monday.
+func (p RetentionPeriod) Truncate(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch p {
+	case RetentionWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case RetentionMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+// Between returns how many whole periods separate the period starts from and to
+func (p RetentionPeriod) Between(from, to time.Time) int {
+	switch p {
+	case RetentionWeek:
+		return daysBetween(from, to) / 7
+	case RetentionMonth:
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	default:
+		return daysBetween(from, to)
+	}
+}
+
+// daysBetween counts calendar days, so DST changes do not shorten a day
+func daysBetween(from, to time.Time) int {
+	f := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	t := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(t.Sub(f).Hours() / 24)
+}
+
+// RetentionCohort is the visitors first seen in one period, and how many of them were
+// active again in each following period. Retained[0] is the first period itself.
+type RetentionCohort struct {
+	Cohort   string    `json:"cohort"`
+	Size     int       `json:"size"`
+	Retained []int     `json:"retained"`
+	Rates    []float64 `json:"rates"`
+}
+
+// RetentionReport is the retention matrix for a website
+type RetentionReport struct {
+	WebsiteID string            `json:"website_id"`
+	DateRange string            `json:"date_range"`
+	Timezone  string            `json:"timezone"`
+	Period    RetentionPeriod   `json:"period"`
+	EventType string            `json:"event_type"`
+	Cohorts   []RetentionCohort `json:"cohorts"`
+}
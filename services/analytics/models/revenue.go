@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RevenueEventType is the event type of a completed order
+const RevenueEventType = "purchase"
+
+// Properties of a purchase event. RevenueProperty is not sent by clients: it is the
+// order amount converted into the reporting currency when the event is accepted.
+const (
+	RevenueOrderIDProperty  = "order_id"
+	RevenueAmountProperty   = "amount"
+	RevenueCurrencyProperty = "currency"
+	RevenueItemsProperty    = "items"
+	RevenueProperty         = "revenue"
+)
+
+// OrderItem is a line item of an order
+type OrderItem struct {
+	ID       string  `json:"id,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// Order is the typed view of a purchase event's properties
+type Order struct {
+	OrderID  string      `json:"order_id"`
+	Amount   float64     `json:"amount"`
+	Currency string      `json:"currency"`
+	Items    []OrderItem `json:"items,omitempty"`
+}
+
+// ParseOrder reads and validates the order in a purchase event's properties.
+// The currency is returned upper-cased.
+func ParseOrder(properties Properties) (*Order, error) {
+	var order Order
+
+	orderID, _ := properties[RevenueOrderIDProperty].(string)
+	if strings.TrimSpace(orderID) == "" {
+		return nil, fmt.Errorf("order_id is required")
+	}
+	order.OrderID = orderID
+
+	amount, ok := properties[RevenueAmountProperty].(float64)
+	if !ok || amount < 0 {
+		return nil, fmt.Errorf("amount must be a non-negative number")
+	}
+	order.Amount = amount
+
+	currency, _ := properties[RevenueCurrencyProperty].(string)
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !isCurrencyCode(currency) {
+		return nil, fmt.Errorf("currency must be a 3-letter ISO 4217 code")
+	}
+	order.Currency = currency
+
+	if raw, present := properties[RevenueItemsProperty]; present {
+		items, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("items must be an array")
+		}
+		for i, rawItem := range items {
+			item, err := parseOrderItem(rawItem)
+			if err != nil {
+				return nil, fmt.Errorf("items[%d]: %w", i, err)
+			}
+			order.Items = append(order.Items, item)
+		}
+	}
+
+	return &order, nil
+}
+
+func parseOrderItem(raw interface{}) (OrderItem, error) {
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return OrderItem{}, fmt.Errorf("must be an object")
+	}
+
+	item := OrderItem{Quantity: 1}
+	item.ID, _ = fields["id"].(string)
+	item.Name, _ = fields["name"].(string)
+
+	price, ok := fields["price"].(float64)
+	if !ok || price < 0 {
+		return OrderItem{}, fmt.Errorf("price must be a non-negative number")
+	}
+	item.Price = price
+
+	if rawQuantity, present := fields["quantity"]; present {
+		quantity, ok := rawQuantity.(float64)
+		if !ok || quantity <= 0 {
+			return OrderItem{}, fmt.Errorf("quantity must be a positive number")
+		}
+		item.Quantity = quantity
+	}
+
+	return item, nil
+}
+
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// RevenueMetrics summarizes the orders in a reporting period. Amounts are in the
+// reporting currency and each order ID is counted once.
+type RevenueMetrics struct {
+	Currency          string  `json:"currency"`
+	Revenue           float64 `json:"revenue"`
+	Orders            int     `json:"orders"`
+	Customers         int     `json:"customers"`
+	AverageOrderValue float64 `json:"average_order_value"`
+	RevenuePerVisitor float64 `json:"revenue_per_visitor"`
+}
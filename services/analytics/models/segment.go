@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SegmentScope is the unit a segment selects
+type SegmentScope string
+
+const (
+	// SegmentSession selects sessions with an event matching the segment's filters
+	SegmentSession SegmentScope = "session"
+	// SegmentVisitor selects visitors with an event matching the segment's filters, so
+	// all of their sessions in the period
+	SegmentVisitor SegmentScope = "visitor"
+)
+
+// SegmentDimension is the filter dimension restricting a report to a saved segment
+const SegmentDimension = "segment"
+
+// MaxSegmentFilters bounds how many filters a segment combines
+const MaxSegmentFilters = 20
+
+// Segment is a named set of filters a website reuses across reports. A session or
+// visitor belongs to it when one of its events in the report's period matches every
+// filter.
+type Segment struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	WebsiteID   string       `json:"website_id" db:"website_id"`
+	UserID      *string      `json:"user_id,omitempty" db:"user_id"`
+	Name        string       `json:"name" db:"name"`
+	Description *string      `json:"description,omitempty" db:"description"`
+	Scope       SegmentScope `json:"scope" db:"scope"`
+	Filters     Filters      `json:"filters" db:"filters"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+type CreateSegmentRequest struct {
+	WebsiteID   string       `json:"website_id" binding:"required"`
+	UserID      *string      `json:"user_id,omitempty"`
+	Name        string       `json:"name" binding:"required"`
+	Description *string      `json:"description"`
+	Scope       SegmentScope `json:"scope"`
+	Filters     Filters      `json:"filters" binding:"required"`
+}
+
+type UpdateSegmentRequest struct {
+	Name        *string       `json:"name"`
+	Description *string       `json:"description"`
+	Scope       *SegmentScope `json:"scope"`
+	Filters     *Filters      `json:"filters"`
+}
+
+// Validate checks the scope and every filter
+func (s *Segment) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("segment name is required")
+	}
+
+	switch s.Scope {
+	case SegmentSession, SegmentVisitor:
+	default:
+		return fmt.Errorf("segment scope must be session or visitor, got %q", s.Scope)
+	}
+
+	if len(s.Filters) == 0 {
+		return fmt.Errorf("segment needs at least one filter")
+	}
+	if len(s.Filters) > MaxSegmentFilters {
+		return fmt.Errorf("segment can have at most %d filters", MaxSegmentFilters)
+	}
+	for i, filter := range s.Filters {
+		if err := filter.Validate(); err != nil {
+			return fmt.Errorf("filter %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Column returns the events column identifying the segment's unit
+func (s *Segment) Column() string {
+	if s.Scope == SegmentVisitor {
+		return "visitor_id"
+	}
+	return "session_id"
+}
+
+// Filter returns the report filter restricting a report to the segment
+func (s *Segment) Filter() Filter {
+	return Filter{
+		Dimension: SegmentDimension,
+		Operator:  FilterEquals,
+		Value:     s.ID.String(),
+		Segment:   s,
+	}
+}
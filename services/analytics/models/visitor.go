@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnonymizedVisitorPrefix starts the visitor IDs the privacy anonymization rewrites
+// events to ('anon_' and a hash of the original ID)
+const AnonymizedVisitorPrefix = "anon_"
+
+// MaxVisitorEvents bounds how many of a visitor's most recent events a profile lists
+const MaxVisitorEvents = 2000
+
+// IsAnonymizedVisitor reports whether a visitor ID was rewritten by anonymization
+func IsAnonymizedVisitor(visitorID string) bool {
+	return strings.HasPrefix(visitorID, AnonymizedVisitorPrefix)
+}
+
+// VisitorEvent is one event in a visitor's timeline. Goals are the names of the goals
+// it completed.
+type VisitorEvent struct {
+	Timestamp  time.Time  `json:"timestamp"`
+	EventType  string     `json:"event_type"`
+	Page       string     `json:"page,omitempty"`
+	Referrer   string     `json:"referrer,omitempty"`
+	TimeOnPage *int       `json:"time_on_page,omitempty"`
+	Properties Properties `json:"properties,omitempty"`
+	Goals      []string   `json:"goals,omitempty"`
+}
+
+// VisitorSession is a session in a visitor's timeline. Its traffic source, device and
+// location are those of its first event.
+type VisitorSession struct {
+	SessionID       string         `json:"session_id"`
+	StartedAt       time.Time      `json:"started_at"`
+	EndedAt         time.Time      `json:"ended_at"`
+	DurationSeconds int            `json:"duration_seconds"`
+	Pageviews       int            `json:"pageviews"`
+	EntryPage       string         `json:"entry_page,omitempty"`
+	Referrer        string         `json:"referrer,omitempty"`
+	UTMSource       string         `json:"utm_source,omitempty"`
+	UTMMedium       string         `json:"utm_medium,omitempty"`
+	UTMCampaign     string         `json:"utm_campaign,omitempty"`
+	UTMTerm         string         `json:"utm_term,omitempty"`
+	UTMContent      string         `json:"utm_content,omitempty"`
+	Device          string         `json:"device,omitempty"`
+	Browser         string         `json:"browser,omitempty"`
+	OS              string         `json:"os,omitempty"`
+	UserAgent       string         `json:"user_agent,omitempty"`
+	Country         string         `json:"country,omitempty"`
+	Region          string         `json:"region,omitempty"`
+	City            string         `json:"city,omitempty"`
+	Continent       string         `json:"continent,omitempty"`
+	Events          []VisitorEvent `json:"events"`
+}
+
+// VisitorGoal is how often a visitor completed a goal
+type VisitorGoal struct {
+	GoalID      uuid.UUID `json:"goal_id"`
+	Name        string    `json:"name"`
+	Completions int       `json:"completions"`
+}
+
+// VisitorProfile is a single visitor's history on a website. The summary covers all of
+// their events; Sessions only the most recent MaxVisitorEvents, in time order, and
+// Truncated says when older ones were left out. IP addresses are never included, and
+// anonymized visitors are shown without their city, region or user agent.
+type VisitorProfile struct {
+	VisitorID     string           `json:"visitor_id"`
+	WebsiteID     string           `json:"website_id"`
+	Anonymized    bool             `json:"anonymized"`
+	FirstSeen     time.Time        `json:"first_seen"`
+	LastSeen      time.Time        `json:"last_seen"`
+	TotalSessions int              `json:"total_sessions"`
+	Pageviews     int              `json:"pageviews"`
+	Events        int              `json:"events"`
+	Conversions   int              `json:"conversions"`
+	Goals         []VisitorGoal    `json:"goals"`
+	Sessions      []VisitorSession `json:"sessions"`
+	Truncated     bool             `json:"truncated"`
+}
+
+// AddEvent appends an event to the timeline. An event from another session than the
+// last one starts a new session, described by session.
+func (p *VisitorProfile) AddEvent(session VisitorSession, event VisitorEvent) {
+	if len(p.Sessions) == 0 || p.Sessions[len(p.Sessions)-1].SessionID != session.SessionID {
+		session.StartedAt = event.Timestamp
+		session.EntryPage = ""
+		session.Events = nil
+		p.Sessions = append(p.Sessions, session)
+	}
+
+	current := &p.Sessions[len(p.Sessions)-1]
+	current.Events = append(current.Events, event)
+	current.EndedAt = event.Timestamp
+	current.DurationSeconds = int(current.EndedAt.Sub(current.StartedAt).Seconds())
+	if event.EventType == "pageview" {
+		current.Pageviews++
+		if current.EntryPage == "" {
+			current.EntryPage = event.Page
+		}
+	}
+}
+
+// Anonymize removes what could identify an anonymized visitor: their city, region and
+// user agent
+func (p *VisitorProfile) Anonymize() {
+	p.Anonymized = true
+	for i := range p.Sessions {
+		p.Sessions[i].City = ""
+		p.Sessions[i].Region = ""
+		p.Sessions[i].UserAgent = ""
+	}
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AlertAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewAlertAnalytics(db *pgxpool.Pool) *AlertAnalytics {
+	return &AlertAnalytics{db: db}
+}
+
+// GetAlertMetrics counts a website's visitors, pageviews and events of every type in
+// the period. Alert windows end at the current minute, so this always reads the
+// events table.
+func (aa *AlertAnalytics) GetAlertMetrics(ctx context.Context, websiteID string, dateRange models.DateRange) (*models.AlertMetrics, error) {
+	query := `
+		SELECT
+			COUNT(DISTINCT visitor_id) FILTER (WHERE event_type = 'pageview'),
+			COUNT(*) FILTER (WHERE event_type = 'pageview'),
+			COUNT(*)
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $2 AND timestamp < $3`
+
+	var metrics models.AlertMetrics
+	err := aa.db.QueryRow(ctx, query, websiteID, dateRange.Start, dateRange.End).Scan(
+		&metrics.Visitors, &metrics.Pageviews, &metrics.Events,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics, nil
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AlertRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAlertRepository(db *pgxpool.Pool) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+const alertRuleColumns = `id, website_id, user_id, name, metric, goal_id, operator, threshold, baseline, window_minutes,
+	webhook_url, webhook_secret, enabled, state, last_value, last_evaluated_at, fired_at, resolved_at, created_at, updated_at`
+
+const alertDeliveryColumns = `id, rule_id, website_id, event, payload, status, attempts, next_attempt_at,
+	response_status, last_error, created_at, delivered_at`
+
+// Create stores a rule, to be evaluated right away
+func (r *AlertRepository) Create(ctx context.Context, rule *models.AlertRule) error {
+	rule.ID = uuid.New()
+	rule.State = models.AlertOK
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+
+	query := `
+		INSERT INTO alert_rules (id, website_id, user_id, name, metric, goal_id, operator, threshold, baseline,
+			window_minutes, webhook_url, webhook_secret, enabled, state, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+
+	_, err := r.db.Exec(ctx, query,
+		rule.ID, rule.WebsiteID, rule.UserID, rule.Name, rule.Metric, rule.GoalID, rule.Operator, rule.Threshold,
+		rule.Baseline, rule.WindowMinutes, rule.WebhookURL, rule.WebhookSecret, rule.Enabled, rule.State,
+		rule.CreatedAt, rule.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByWebsiteID returns a website's alert rules in name order
+func (r *AlertRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.AlertRule, error) {
+	query := `
+		SELECT ` + alertRuleColumns + `
+		FROM alert_rules
+		WHERE website_id = $1
+		ORDER BY name ASC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (r *AlertRepository) GetByID(ctx context.Context, ruleID uuid.UUID) (*models.AlertRule, error) {
+	query := `
+		SELECT ` + alertRuleColumns + `
+		FROM alert_rules
+		WHERE id = $1`
+
+	return scanAlertRule(r.db.QueryRow(ctx, query, ruleID))
+}
+
+// Update stores a rule's settings and state, and evaluates it right away
+func (r *AlertRepository) Update(ctx context.Context, ruleID uuid.UUID, rule *models.AlertRule) error {
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE alert_rules
+		SET name = $2, metric = $3, goal_id = $4, operator = $5, threshold = $6, baseline = $7,
+			window_minutes = $8, webhook_url = $9, webhook_secret = $10, enabled = $11, state = $12,
+			next_evaluation_at = NOW(), updated_at = $13
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		ruleID, rule.Name, rule.Metric, rule.GoalID, rule.Operator, rule.Threshold, rule.Baseline,
+		rule.WindowMinutes, rule.WebhookURL, rule.WebhookSecret, rule.Enabled, rule.State, rule.UpdatedAt,
+	)
+
+	return err
+}
+
+// Delete removes a rule and its deliveries
+func (r *AlertRepository) Delete(ctx context.Context, ruleID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM alert_deliveries WHERE rule_id = $1`, ruleID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM alert_rules WHERE id = $1`, ruleID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ClaimDue returns an enabled rule due for evaluation, or pgx.ErrNoRows when none is.
+// Its next evaluation is moved to next first, so concurrent callers never claim the
+// same rule.
+func (r *AlertRepository) ClaimDue(ctx context.Context, now, next time.Time) (*models.AlertRule, error) {
+	query := `
+		UPDATE alert_rules
+		SET next_evaluation_at = $2
+		WHERE id = (
+			SELECT id FROM alert_rules
+			WHERE enabled AND next_evaluation_at <= $1
+			ORDER BY next_evaluation_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + alertRuleColumns
+
+	return scanAlertRule(r.db.QueryRow(ctx, query, now, next))
+}
+
+// RecordEvaluation stores a rule's state after an evaluation and, when the state
+// changed, the delivery notifying of it, both or neither
+func (r *AlertRepository) RecordEvaluation(ctx context.Context, rule *models.AlertRule, delivery *models.AlertDelivery) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE alert_rules
+		SET state = $2, last_value = $3, last_evaluated_at = $4, fired_at = $5, resolved_at = $6
+		WHERE id = $1`
+
+	if _, err := tx.Exec(ctx, query,
+		rule.ID, rule.State, rule.LastValue, rule.LastEvaluatedAt, rule.FiredAt, rule.ResolvedAt,
+	); err != nil {
+		return fmt.Errorf("failed to update alert state: %w", err)
+	}
+
+	if delivery != nil {
+		if err := insertAlertDelivery(ctx, tx, delivery); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CreateDelivery queues a delivery outside of an evaluation
+func (r *AlertRepository) CreateDelivery(ctx context.Context, delivery *models.AlertDelivery) error {
+	return insertAlertDelivery(ctx, r.db, delivery)
+}
+
+// execer is a pool or a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+func insertAlertDelivery(ctx context.Context, db execer, delivery *models.AlertDelivery) error {
+	query := `
+		INSERT INTO alert_deliveries (id, rule_id, website_id, event, payload, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := db.Exec(ctx, query,
+		delivery.ID, delivery.RuleID, delivery.WebsiteID, delivery.Event, []byte(delivery.Payload),
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to queue alert delivery: %w", err)
+	}
+	return nil
+}
+
+// GetDeliveries returns a rule's most recent deliveries, newest first
+func (r *AlertRepository) GetDeliveries(ctx context.Context, ruleID uuid.UUID, limit int) ([]models.AlertDelivery, error) {
+	query := `
+		SELECT ` + alertDeliveryColumns + `
+		FROM alert_deliveries
+		WHERE rule_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, ruleID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.AlertDelivery{}
+	for rows.Next() {
+		delivery, err := scanAlertDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimDelivery returns a pending delivery that is due, or pgx.ErrNoRows when none is.
+// A rule's deliveries are claimed in the order they were created. The attempt is
+// counted and the next one moved to retryAt first, so concurrent callers never claim
+// the same delivery and it is retried then if the attempt never completes.
+func (r *AlertRepository) ClaimDelivery(ctx context.Context, now, retryAt time.Time) (*models.AlertDelivery, error) {
+	query := `
+		UPDATE alert_deliveries
+		SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id = (
+			SELECT d.id FROM alert_deliveries d
+			WHERE d.status = 'pending' AND d.next_attempt_at <= $1
+			AND NOT EXISTS (
+				SELECT 1 FROM alert_deliveries earlier
+				WHERE earlier.rule_id = d.rule_id
+				AND earlier.status = 'pending'
+				AND earlier.created_at < d.created_at
+			)
+			ORDER BY d.next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + alertDeliveryColumns
+
+	return scanAlertDelivery(r.db.QueryRow(ctx, query, now, retryAt))
+}
+
+// MarkDelivered records that the webhook accepted a delivery
+func (r *AlertRepository) MarkDelivered(ctx context.Context, deliveryID uuid.UUID, responseStatus int) error {
+	query := `
+		UPDATE alert_deliveries
+		SET status = 'delivered', response_status = $2, last_error = NULL, delivered_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, deliveryID, responseStatus)
+	return err
+}
+
+// MarkAttemptFailed records a failed attempt. The delivery is retried at nextAttemptAt,
+// or failed for good when that is nil.
+func (r *AlertRepository) MarkAttemptFailed(ctx context.Context, deliveryID uuid.UUID, responseStatus *int, message string, nextAttemptAt *time.Time) error {
+	query := `
+		UPDATE alert_deliveries
+		SET response_status = $2, last_error = $3,
+			status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+			next_attempt_at = COALESCE($4, next_attempt_at)
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, deliveryID, responseStatus, message, nextAttemptAt)
+	return err
+}
+
+func scanAlertRule(row pgx.Row) (*models.AlertRule, error) {
+	var rule models.AlertRule
+
+	err := row.Scan(
+		&rule.ID, &rule.WebsiteID, &rule.UserID, &rule.Name, &rule.Metric, &rule.GoalID, &rule.Operator,
+		&rule.Threshold, &rule.Baseline, &rule.WindowMinutes, &rule.WebhookURL, &rule.WebhookSecret,
+		&rule.Enabled, &rule.State, &rule.LastValue, &rule.LastEvaluatedAt, &rule.FiredAt, &rule.ResolvedAt,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func scanAlertDelivery(row pgx.Row) (*models.AlertDelivery, error) {
+	var delivery models.AlertDelivery
+	var payload []byte
+
+	err := row.Scan(
+		&delivery.ID, &delivery.RuleID, &delivery.WebsiteID, &delivery.Event, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.ResponseStatus, &delivery.LastError,
+		&delivery.CreatedAt, &delivery.DeliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+
+	return &delivery, nil
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AttributionAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewAttributionAnalytics(db *pgxpool.Pool) *AttributionAnalytics {
+	return &AttributionAnalytics{db: db}
+}
+
+// attributionConversions builds a query part listing (visitor_id, converted_at) for each
+// visitor's first completion of the goal, or of the funnel when goal is nil
+func attributionConversions(qb *QueryBuilder, q models.AttributionQuery, goal *models.Goal) string {
+	if goal != nil {
+		return `SELECT visitor_id, MIN(timestamp) AS converted_at
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND ` + qb.Match(goal.Filters(), "") + `{{filters}}
+			GROUP BY visitor_id`
+	}
+
+	conversions := `SELECT visitor_id, MIN(COALESCE(last_activity, created_at)) AS converted_at
+			FROM funnel_events
+			WHERE funnel_id = ` + qb.Arg(*q.FunnelID) + `
+			AND website_id = $1
+			AND converted = true
+			AND created_at >= $2 AND created_at < $3`
+	if qb.HasFilters() {
+		// Funnel events carry no event columns, so filters select the visitors instead
+		conversions += `
+			AND visitor_id IN (
+				SELECT visitor_id
+				FROM events
+				WHERE website_id = $1
+				AND timestamp >= $2 AND timestamp < $3{{filters}}
+			)`
+	}
+	return conversions + `
+			GROUP BY visitor_id`
+}
+
+// GetAttribution credits each conversion in the range to the sessions the visitor started
+// within the lookback window before converting, split by the query's model. A conversion
+// with no such session is credited to the dimension's empty value. It returns the number
+// of conversions and the credit per dimension value, largest first.
+func (aa *AttributionAnalytics) GetAttribution(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, q models.AttributionQuery, goal *models.Goal) (int, []models.AttributionRow, error) {
+	expression, ok := models.AttributionDimensions[q.Dimension]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown attribution dimension %q", q.Dimension)
+	}
+
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	lookbackArg := qb.Arg(q.Lookback().Seconds())
+	emptyArg := qb.Arg(models.AttributionEmptyValues[q.Dimension])
+
+	// Touches are sessions, valued by their first pageview
+	query := `
+		WITH conversions AS (
+			` + attributionConversions(qb, q, goal) + `
+		),
+		sessions AS (
+			SELECT DISTINCT ON (session_id)
+				visitor_id,
+				timestamp AS started_at,
+				` + expression + ` AS value
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2::timestamptz - make_interval(secs => ` + lookbackArg + `) AND timestamp < $3
+			AND event_type = 'pageview'
+			AND visitor_id IN (SELECT visitor_id FROM conversions)
+			ORDER BY session_id, timestamp
+		)
+		SELECT c.visitor_id, c.converted_at, s.started_at, COALESCE(NULLIF(s.value, ''), ` + emptyArg + `)
+		FROM conversions c
+		LEFT JOIN sessions s ON s.visitor_id = c.visitor_id
+			AND s.started_at <= c.converted_at
+			AND s.started_at >= c.converted_at - make_interval(secs => ` + lookbackArg + `)
+		ORDER BY c.visitor_id, s.started_at`
+
+	rows, err := aa.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	credit := make(map[string]float64)
+	conversions := 0
+
+	var visitor string
+	var convertedAt time.Time
+	var touches []time.Time
+	var values []string
+	flush := func() {
+		if visitor == "" {
+			return
+		}
+		conversions++
+		for i, weight := range models.AttributionWeights(q.Model, touches, convertedAt) {
+			credit[values[i]] += weight
+		}
+	}
+
+	for rows.Next() {
+		var visitorID, value string
+		var converted time.Time
+		var startedAt *time.Time
+		if err := rows.Scan(&visitorID, &converted, &startedAt, &value); err != nil {
+			return 0, nil, fmt.Errorf("scan failed: %w", err)
+		}
+
+		if visitorID != visitor {
+			flush()
+			visitor, convertedAt = visitorID, converted
+			touches, values = touches[:0], values[:0]
+		}
+		if startedAt == nil {
+			// No session in the window: credit the conversion itself
+			startedAt = &converted
+		}
+		touches = append(touches, *startedAt)
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	flush()
+
+	result := make([]models.AttributionRow, 0, len(credit))
+	for value, conversionCredit := range credit {
+		row := models.AttributionRow{Value: value, Conversions: conversionCredit}
+		if conversions > 0 {
+			row.Share = conversionCredit * 100.0 / float64(conversions)
+		}
+		if goal != nil && goal.Value != nil {
+			goalValue := conversionCredit * *goal.Value
+			row.GoalValue = &goalValue
+		}
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Conversions != result[j].Conversions {
+			return result[i].Conversions > result[j].Conversions
+		}
+		return result[i].Value < result[j].Value
+	})
+
+	return conversions, result, nil
+}
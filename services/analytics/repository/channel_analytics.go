@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// channelFields are the lower-cased SQL values channel rules compare, with "%s" in place
+// of the column alias. Missing values are empty strings.
+var channelFields = map[models.ChannelField]string{
+	models.ChannelFieldSource:   "COALESCE(lower(%sutm_source), '')",
+	models.ChannelFieldMedium:   "COALESCE(lower(%sutm_medium), '')",
+	models.ChannelFieldCampaign: "COALESCE(lower(%sutm_campaign), '')",
+	models.ChannelFieldReferrer: `COALESCE(lower(substring(%sreferrer from '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?(?:www\.)?([^/?#:]+)')), '')`,
+}
+
+// channelExpression compiles channel rules into a CASE expression on an unaliased events row
+func channelExpression(qb *QueryBuilder, rules models.ChannelRules) string {
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	for _, rule := range rules {
+		conditions := make([]string, len(rule.Conditions))
+		for i, condition := range rule.Conditions {
+			conditions[i] = channelCondition(qb, condition)
+		}
+		sb.WriteString("\n\t\t\t\tWHEN " + strings.Join(conditions, " AND ") + " THEN " + qb.Arg(rule.Channel))
+	}
+	sb.WriteString("\n\t\t\t\tELSE " + qb.Arg(models.ChannelOther) + "\n\t\t\tEND")
+	return sb.String()
+}
+
+func channelCondition(qb *QueryBuilder, condition models.ChannelCondition) string {
+	field := strings.ReplaceAll(channelFields[condition.Field], "%s", "")
+	value := strings.ToLower(condition.Value)
+
+	switch condition.Operator {
+	case models.FilterNotEquals:
+		return fmt.Sprintf("%s <> %s", field, qb.Arg(value))
+	case models.FilterContains:
+		return fmt.Sprintf("%s LIKE %s", field, qb.Arg(likePattern(value)))
+	case models.FilterNotContains:
+		return fmt.Sprintf("%s NOT LIKE %s", field, qb.Arg(likePattern(value)))
+	case models.FilterRegex:
+		return fmt.Sprintf("%s ~* %s", field, qb.Arg(condition.Value))
+	default:
+		return fmt.Sprintf("%s = %s", field, qb.Arg(value))
+	}
+}
+
+// sessionChannels builds a query listing each session in the range with the channel of
+// its first pageview there
+func sessionChannels(qb *QueryBuilder, rules models.ChannelRules, filtered bool) string {
+	filters := ""
+	if filtered {
+		filters = FiltersToken
+	}
+	return `SELECT DISTINCT ON (session_id)
+				session_id,
+				visitor_id,
+				` + channelExpression(qb, rules) + ` AS channel
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'pageview'` + filters + `
+			ORDER BY session_id, timestamp`
+}
+
+// compileChannel compiles a channel filter: events match when their session arrived
+// through a matching channel
+func (qb *QueryBuilder) compileChannel(filter models.Filter) string {
+	rules := filter.Channels
+	if rules == nil {
+		rules = models.DefaultChannelRules
+	}
+	return `%ssession_id IN (
+			SELECT landing.session_id FROM (
+			` + sessionChannels(qb, rules, false) + `
+			) landing
+			WHERE ` + qb.compare("landing.channel", filter.Operator, filter.Value) + `
+		)`
+}
+
+type ChannelAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewChannelAnalytics(db *pgxpool.Pool) *ChannelAnalytics {
+	return &ChannelAnalytics{db: db}
+}
+
+// GetChannels returns traffic and revenue per channel. Sessions, with their pageviews and
+// orders, are grouped by the channel of their first pageview.
+func (ca *ChannelAnalytics) GetChannels(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, rules models.ChannelRules) ([]models.ChannelStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
+	query := `
+		WITH landing AS (
+			` + sessionChannels(qb, rules, true) + `
+		),
+		session_stats AS (
+			SELECT session_id, COUNT(*) AS page_count
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
+			GROUP BY session_id
+		),
+		` + ordersCTE + `,
+		session_orders AS (
+			SELECT session_id, COUNT(*) AS orders, SUM(revenue) AS revenue
+			FROM orders
+			GROUP BY session_id
+		)
+		SELECT
+			l.channel,
+			COUNT(DISTINCT l.visitor_id) AS visitors,
+			COUNT(*) AS sessions,
+			COALESCE(SUM(s.page_count), 0)::bigint AS views,
+			COALESCE(COUNT(*) FILTER (WHERE s.page_count = 1) * 100.0 / NULLIF(COUNT(*), 0), 0)::float8 AS bounce_rate,
+			COALESCE(SUM(o.orders), 0)::bigint AS orders,
+			COALESCE(SUM(o.revenue), 0)::float8 AS revenue
+		FROM landing l
+		JOIN session_stats s ON s.session_id = l.session_id
+		LEFT JOIN session_orders o ON o.session_id = l.session_id
+		GROUP BY l.channel
+		ORDER BY visitors DESC, sessions DESC`
+
+	rows, err := ca.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	channels := []models.ChannelStat{}
+	for rows.Next() {
+		var stat models.ChannelStat
+		if err := rows.Scan(&stat.Channel, &stat.Visitors, &stat.Sessions, &stat.Views, &stat.BounceRate, &stat.Orders, &stat.Revenue); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		channels = append(channels, stat)
+	}
+
+	return channels, rows.Err()
+}
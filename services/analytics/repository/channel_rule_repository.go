@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ChannelRuleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewChannelRuleRepository(db *pgxpool.Pool) *ChannelRuleRepository {
+	return &ChannelRuleRepository{db: db}
+}
+
+// Get returns a website's own channel rules, with no rules if it has none
+func (r *ChannelRuleRepository) Get(ctx context.Context, websiteID string) (*models.WebsiteChannelRules, error) {
+	result := &models.WebsiteChannelRules{WebsiteID: websiteID, Rules: models.ChannelRules{}}
+
+	var rulesJSON []byte
+	var updatedAt time.Time
+	err := r.db.QueryRow(ctx, `SELECT rules, updated_at FROM channel_rules WHERE website_id = $1`, websiteID).Scan(&rulesJSON, &updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(rulesJSON, &result.Rules); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel rules: %w", err)
+	}
+	result.UpdatedAt = &updatedAt
+
+	return result, nil
+}
+
+// Save replaces a website's channel rules
+func (r *ChannelRuleRepository) Save(ctx context.Context, websiteID string, rules models.ChannelRules) error {
+	if rules == nil {
+		rules = models.ChannelRules{}
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel rules: %w", err)
+	}
+
+	query := `
+		INSERT INTO channel_rules (website_id, rules, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (website_id) DO UPDATE SET rules = EXCLUDED.rules, updated_at = EXCLUDED.updated_at`
+
+	_, err = r.db.Exec(ctx, query, websiteID, rulesJSON)
+	return err
+}
+
+// Delete removes a website's channel rules, leaving only the built-in rules
+func (r *ChannelRuleRepository) Delete(ctx context.Context, websiteID string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM channel_rules WHERE website_id = $1`, websiteID)
+	return err
+}
@@ -26,86 +26,88 @@ func NewCustomEventsAggregatedRepository(db *pgxpool.Pool, logger zerolog.Logger
 	}
 }
 
-// UpsertCustomEvent creates or updates a custom event aggregation
-func (r *CustomEventsAggregatedRepository) UpsertCustomEvent(ctx context.Context, event *models.Event) error {
-	if event.EventType == "pageview" || event.EventType == "session_start" || event.EventType == "session_end" {
-		// Don't aggregate system events
-		return nil
-	}
-
-	// Create event signature from properties
-	signature := r.createEventSignature(event.EventType, event.Properties)
+// CustomEventBucket identifies one hourly row of the custom event rollup
+type CustomEventBucket struct {
+	WebsiteID string
+	Signature string
+	EventType string
+	Hour      time.Time
+}
 
-	// Prepare properties JSON
-	var propertiesJSON []byte
-	if event.Properties != nil {
-		var err error
-		propertiesJSON, err = json.Marshal(event.Properties)
-		if err != nil {
-			r.logger.Error().Err(err).Msg("Failed to marshal properties for aggregation")
-			return err
-		}
+// CustomEventBuckets returns the rollup rows touched by the given events, once each in
+// the order first touched. System events are not aggregated, and event types differing
+// only in case share a row.
+func CustomEventBuckets(events []models.Event) []CustomEventBucket {
+	type key struct {
+		websiteID string
+		signature string
+		hour      time.Time
 	}
 
-	// Use a different approach for PostgreSQL - check if exists and update or insert
-	// First, try to find existing record within recent time window
-	checkQuery := `
-		SELECT id, count, first_seen FROM custom_events_aggregated 
-		WHERE website_id = $1 AND event_signature = $2 
-		AND last_seen >= $3
-		ORDER BY last_seen DESC LIMIT 1
-	`
-	
-	now := time.Now()
-	oneHourAgo := now.Add(-time.Hour)
-	var existingID string
-	var existingCount int
-	var firstSeen time.Time
-	
-	err := r.db.QueryRow(ctx, checkQuery, event.WebsiteID, signature, oneHourAgo).Scan(&existingID, &existingCount, &firstSeen)
-	
-	var query string
-	var args []interface{}
-	
-	if err != nil {
-		// No existing record found, insert new one
-		query = `
-			INSERT INTO custom_events_aggregated (
-				website_id, event_type, event_signature, count, sample_properties, 
-				first_seen, last_seen, created_at, updated_at
-			) VALUES ($1, $2, $3, 1, $4, $5, $5, $5, $5)
-		`
-		args = []interface{}{event.WebsiteID, event.EventType, signature, propertiesJSON, now}
-	} else {
-		// Update existing record
-		query = `
-			UPDATE custom_events_aggregated 
-			SET count = $3, last_seen = $4, updated_at = $4,
-				sample_properties = CASE 
-					WHEN last_seen < $4 THEN $5
-					ELSE sample_properties
-				END
-			WHERE id = $1 AND website_id = $2
-		`
-		args = []interface{}{existingID, event.WebsiteID, existingCount + 1, now, propertiesJSON}
+	var buckets []CustomEventBucket
+	seen := make(map[key]bool)
+	for _, event := range events {
+		if IsSystemEvent(event.EventType) {
+			continue
+		}
+		bucket := CustomEventBucket{
+			WebsiteID: event.WebsiteID,
+			Signature: EventSignature(event.EventType),
+			EventType: event.EventType,
+			Hour:      event.Timestamp.UTC().Truncate(time.Hour),
+		}
+		k := key{bucket.WebsiteID, bucket.Signature, bucket.Hour}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		buckets = append(buckets, bucket)
 	}
+	return buckets
+}
 
-	_, err = r.db.Exec(ctx, query, args...)
+// RefreshRollup recomputes the hourly rollup rows touched by the given events from the
+// raw events table. Counts are recalculated rather than incremented, so refreshing the
+// same bucket twice (e.g. after a redelivered batch) never double counts.
+func (r *CustomEventsAggregatedRepository) RefreshRollup(ctx context.Context, events []models.Event) error {
+	buckets := CustomEventBuckets(events)
 
-	if err != nil {
-		r.logger.Error().Err(err).
-			Str("website_id", event.WebsiteID).
-			Str("event_type", event.EventType).
-			Str("signature", signature).
-			Msg("Failed to upsert custom event aggregation")
-		return err
+	query := `
+		INSERT INTO custom_events_aggregated (
+			website_id, event_type, event_signature, count, sample_properties,
+			first_seen, last_seen, bucket, created_at, updated_at
+		)
+		SELECT
+			$1, $2, $3, COUNT(*),
+			(ARRAY_AGG(properties ORDER BY timestamp DESC))[1],
+			MIN(timestamp), MAX(timestamp), $4, NOW(), NOW()
+		FROM events
+		WHERE website_id = $1
+		AND LOWER(event_type) = LOWER($2)
+		AND timestamp >= $4 AND timestamp < $4 + INTERVAL '1 hour'
+		HAVING COUNT(*) > 0
+		ON CONFLICT (website_id, event_signature, bucket) DO UPDATE SET
+			count = EXCLUDED.count,
+			sample_properties = EXCLUDED.sample_properties,
+			first_seen = EXCLUDED.first_seen,
+			last_seen = EXCLUDED.last_seen,
+			updated_at = NOW()`
+
+	for _, bucket := range buckets {
+		_, err := r.db.Exec(ctx, query, bucket.WebsiteID, bucket.EventType, bucket.Signature, bucket.Hour)
+		if err != nil {
+			r.logger.Error().Err(err).
+				Str("website_id", bucket.WebsiteID).
+				Str("event_type", bucket.EventType).
+				Time("bucket", bucket.Hour).
+				Msg("Failed to refresh custom event rollup")
+			return err
+		}
 	}
 
 	r.logger.Debug().
-		Str("website_id", event.WebsiteID).
-		Str("event_type", event.EventType).
-		Str("signature", signature).
-		Msg("Custom event aggregated successfully")
+		Int("buckets", len(buckets)).
+		Msg("Custom event rollup refreshed")
 
 	return nil
 }
@@ -172,9 +174,9 @@ func (r *CustomEventsAggregatedRepository) GetCustomEventStats(ctx context.Conte
 	return events, rows.Err()
 }
 
-// createEventSignature creates a unique signature for an event based on its type only
+// EventSignature creates a unique signature for an event based on its type only
 // This ensures proper aggregation of events of the same type regardless of property variations
-func (r *CustomEventsAggregatedRepository) createEventSignature(eventType string, properties models.Properties) string {
+func EventSignature(eventType string) string {
 	// Use only event type for signature to enable proper aggregation
 	// Properties are stored separately as sample_properties for analysis
 	signatureData := strings.ToLower(eventType)
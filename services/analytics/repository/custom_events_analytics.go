@@ -17,21 +17,69 @@ func NewCustomEventsAnalytics(db *pgxpool.Pool) *CustomEventsAnalytics {
 }
 
 // GetCustomEventStats returns custom event statistics for a website
-func (ce *CustomEventsAnalytics) GetCustomEventStats(ctx context.Context, websiteID string, days int) ([]models.CustomEventStat, error) {
-	// Get aggregated event counts from the custom_events_aggregated table
+func (ce *CustomEventsAnalytics) GetCustomEventStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.CustomEventStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
+	// Get event counts from the hourly custom_events_aggregated rollup, with the
+	// properties of the most recent event of each type as a sample
 	query := `
+		WITH event_totals AS (
+			SELECT 
+				event_type,
+				SUM(count) AS total_count
+			FROM custom_events_aggregated
+			WHERE website_id = $1 
+			AND last_seen >= $2 AND first_seen < $3
+			GROUP BY event_type
+		), recent_samples AS (
+			SELECT DISTINCT ON (event_type)
+				event_type,
+				sample_properties
+			FROM custom_events_aggregated
+			WHERE website_id = $1 
+			AND last_seen >= $2 AND first_seen < $3
+			ORDER BY event_type, last_seen DESC
+		)
 		SELECT 
-			event_type,
-			SUM(count) as total_count,
-			sample_properties
-		FROM custom_events_aggregated
-		WHERE website_id = $1 
-		AND last_seen >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY event_type, sample_properties
-		ORDER BY total_count DESC
+			et.event_type,
+			et.total_count,
+			rs.sample_properties
+		FROM event_totals et
+		LEFT JOIN recent_samples rs USING (event_type)
+		ORDER BY et.total_count DESC
 		LIMIT 50`
 
-	rows, err := ce.db.Query(ctx, query, websiteID, days)
+	// The rollup has no per-event dimensions, so filtered reports count raw events
+	if qb.HasFilters() {
+		query = `
+			WITH matching_events AS (
+				SELECT event_type, properties, timestamp
+				FROM events
+				WHERE website_id = $1 
+				AND timestamp >= $2 AND timestamp < $3
+				AND event_type NOT IN ('pageview', 'session_start', 'session_end'){{filters}}
+			), event_totals AS (
+				SELECT event_type, COUNT(*) AS total_count
+				FROM matching_events
+				GROUP BY event_type
+			), recent_samples AS (
+				SELECT DISTINCT ON (event_type)
+					event_type,
+					properties AS sample_properties
+				FROM matching_events
+				ORDER BY event_type, timestamp DESC
+			)
+			SELECT 
+				et.event_type,
+				et.total_count,
+				rs.sample_properties
+			FROM event_totals et
+			LEFT JOIN recent_samples rs USING (event_type)
+			ORDER BY et.total_count DESC
+			LIMIT 50`
+	}
+
+	rows, err := ce.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -16,7 +16,9 @@ func NewDashboardAnalytics(db *pgxpool.Pool) *DashboardAnalytics {
 }
 
 // GetDashboardMetrics returns the main dashboard metrics for a website
-func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID string, days int) (*models.DashboardMetrics, error) {
+func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.DashboardMetrics, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -33,7 +35,7 @@ func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID
 				END as session_duration
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -58,11 +60,11 @@ func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID
 		FROM events e
 		INNER JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'`
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}`
 
 	var metrics models.DashboardMetrics
-	err := da.db.QueryRow(ctx, query, websiteID, days).Scan(
+	err := da.db.QueryRow(ctx, qb.Build(query), qb.Args()...).Scan(
 		&metrics.PageViews, &metrics.TotalVisitors, &metrics.UniqueVisitors, &metrics.Sessions,
 		&metrics.BounceRate, &metrics.AvgSessionTime, &metrics.PagesPerSession,
 	)
@@ -83,7 +85,9 @@ func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID
 }
 
 // GetComparisonMetrics returns comparison metrics between current and previous periods
-func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteID string, days int) (*models.ComparisonMetrics, error) {
+func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.ComparisonMetrics, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
 	// Get current period metrics
 	currentQuery := `
 		WITH current_session_stats AS (
@@ -100,7 +104,7 @@ func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteI
 				END as session_duration
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -117,8 +121,8 @@ func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteI
 		FROM events e
 		INNER JOIN current_session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'`
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}`
 
 	// Get previous period metrics
 	previousQuery := `
@@ -136,8 +140,8 @@ func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteI
 				END as session_duration
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND timestamp < NOW() - INTERVAL '1 day' * $3
+			AND timestamp >= $2
+			AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -154,21 +158,13 @@ func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteI
 		FROM events e
 		INNER JOIN previous_session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.timestamp < NOW() - INTERVAL '1 day' * $3
-		AND event_type = 'pageview'`
+		AND e.timestamp >= $2
+		AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}`
 
 	// Execute current period query
-	var current struct {
-		PageViews      int     `db:"page_views"`
-		TotalVisitors  int     `db:"total_visitors"`
-		UniqueVisitors int     `db:"unique_visitors"`
-		Sessions       int     `db:"sessions"`
-		BounceRate     float64 `db:"bounce_rate"`
-		AvgSessionTime float64 `db:"avg_session_time"`
-	}
-
-	err := da.db.QueryRow(ctx, currentQuery, websiteID, days).Scan(
+	var current models.DashboardMetrics
+	err := da.db.QueryRow(ctx, qb.Build(currentQuery), qb.Args()...).Scan(
 		&current.PageViews, &current.TotalVisitors, &current.UniqueVisitors, &current.Sessions,
 		&current.BounceRate, &current.AvgSessionTime,
 	)
@@ -177,32 +173,22 @@ func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteI
 	}
 
 	// Execute previous period query
-	var previous struct {
-		PageViews      int     `db:"page_views"`
-		TotalVisitors  int     `db:"total_visitors"`
-		UniqueVisitors int     `db:"unique_visitors"`
-		Sessions       int     `db:"sessions"`
-		BounceRate     float64 `db:"bounce_rate"`
-		AvgSessionTime float64 `db:"avg_session_time"`
-	}
-
-	err = da.db.QueryRow(ctx, previousQuery, websiteID, days*2, days).Scan(
+	var previous models.DashboardMetrics
+	previousQB := NewQueryBuilder(websiteID, dateRange.Previous(), filters)
+	err = da.db.QueryRow(ctx, previousQB.Build(previousQuery), previousQB.Args()...).Scan(
 		&previous.PageViews, &previous.TotalVisitors, &previous.UniqueVisitors, &previous.Sessions,
 		&previous.BounceRate, &previous.AvgSessionTime,
 	)
 	if err != nil {
 		// If no previous data, return zeros for safe calculation
-		previous = struct {
-			PageViews      int     `db:"page_views"`
-			TotalVisitors  int     `db:"total_visitors"`
-			UniqueVisitors int     `db:"unique_visitors"`
-			Sessions       int     `db:"sessions"`
-			BounceRate     float64 `db:"bounce_rate"`
-			AvgSessionTime float64 `db:"avg_session_time"`
-		}{}
+		previous = models.DashboardMetrics{}
 	}
 
-	// Calculate percentage changes with clamping and N/A handling
+	return compareDashboardMetrics(current, previous), nil
+}
+
+// compareDashboardMetrics calculates percentage changes with clamping and N/A handling
+func compareDashboardMetrics(current, previous models.DashboardMetrics) *models.ComparisonMetrics {
 	minPrevCount := 10
 	clamp := func(v float64) float64 {
 		if v > 500 {
@@ -238,5 +224,5 @@ func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteI
 		SessionChange:      calcInt(current.Sessions, previous.Sessions),
 		BounceChange:       calcFloat(current.BounceRate, previous.BounceRate, previous.Sessions),
 		DurationChange:     calcFloat(current.AvgSessionTime, previous.AvgSessionTime, previous.Sessions),
-	}, nil
+	}
 }
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// DeadLetterRepository stores events that could not be written to the events table
+type DeadLetterRepository struct {
+	db     *pgxpool.Pool
+	logger zerolog.Logger
+}
+
+func NewDeadLetterRepository(db *pgxpool.Pool, logger zerolog.Logger) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// InsertBatch writes failed events to the dead-letter table
+func (r *DeadLetterRepository) InsertBatch(ctx context.Context, entries []models.DeadLetterEvent) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO events_dead_letter (id, event_id, website_id, event_type, payload, error, attempts, failed_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	now := time.Now()
+	batch := &pgx.Batch{}
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ID == uuid.Nil {
+			entry.ID = uuid.New()
+		}
+		if entry.FailedAt.IsZero() {
+			entry.FailedAt = now
+		}
+		entry.CreatedAt = now
+
+		payload, err := json.Marshal(entry.Event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dead-letter payload: %w", err)
+		}
+
+		batch.Queue(query,
+			entry.ID, entry.Event.ID, entry.Event.WebsiteID, entry.Event.EventType,
+			payload, entry.Error, entry.Attempts, entry.FailedAt, entry.CreatedAt,
+		)
+	}
+
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for range entries {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("failed to insert dead-letter event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// List returns dead-lettered events, newest first, optionally filtered by website
+func (r *DeadLetterRepository) List(ctx context.Context, websiteID string, limit, offset int) ([]models.DeadLetterEvent, int, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int
+	err := r.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM events_dead_letter WHERE ($1 = '' OR website_id = $1)`,
+		websiteID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count failed: %w", err)
+	}
+
+	query := `
+		SELECT id, event_id, website_id, event_type, payload, error, attempts, failed_at, created_at
+		FROM events_dead_letter
+		WHERE ($1 = '' OR website_id = $1)
+		ORDER BY failed_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(ctx, query, websiteID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	entries, err := r.scanEntries(rows)
+	return entries, total, err
+}
+
+// GetForReplay loads dead-lettered events by ID, or the oldest ones for a website when no IDs are given
+func (r *DeadLetterRepository) GetForReplay(ctx context.Context, ids []uuid.UUID, websiteID string, limit int) ([]models.DeadLetterEvent, error) {
+	if limit <= 0 || limit > MaxBatchSize {
+		limit = MaxBatchSize
+	}
+
+	query := `
+		SELECT id, event_id, website_id, event_type, payload, error, attempts, failed_at, created_at
+		FROM events_dead_letter
+		WHERE (cardinality($1::uuid[]) = 0 OR id = ANY($1))
+		AND ($2 = '' OR website_id = $2)
+		ORDER BY failed_at ASC
+		LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, ids, websiteID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanEntries(rows)
+}
+
+// MarkFailed records another unsuccessful replay attempt
+func (r *DeadLetterRepository) MarkFailed(ctx context.Context, id uuid.UUID, reason string) error {
+	_, err := r.db.Exec(ctx,
+		`UPDATE events_dead_letter SET attempts = attempts + 1, error = $2, failed_at = NOW() WHERE id = $1`,
+		id, reason)
+	return err
+}
+
+// Delete removes dead-lettered events by ID
+func (r *DeadLetterRepository) Delete(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result, err := r.db.Exec(ctx, `DELETE FROM events_dead_letter WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return 0, fmt.Errorf("delete failed: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// Purge removes dead-lettered events for a website (or all websites) that failed before the cutoff
+func (r *DeadLetterRepository) Purge(ctx context.Context, websiteID string, before time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx,
+		`DELETE FROM events_dead_letter WHERE ($1 = '' OR website_id = $1) AND failed_at < $2`,
+		websiteID, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge failed: %w", err)
+	}
+
+	r.logger.Info().
+		Str("website_id", websiteID).
+		Time("before", before).
+		Int64("rows_deleted", result.RowsAffected()).
+		Msg("Purged dead-letter events")
+
+	return result.RowsAffected(), nil
+}
+
+// GetCount returns the number of dead-lettered events
+func (r *DeadLetterRepository) GetCount(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM events_dead_letter`).Scan(&count)
+	return count, err
+}
+
+func (r *DeadLetterRepository) scanEntries(rows pgx.Rows) ([]models.DeadLetterEvent, error) {
+	var entries []models.DeadLetterEvent
+	for rows.Next() {
+		var entry models.DeadLetterEvent
+		var payload []byte
+
+		err := rows.Scan(
+			&entry.ID, &entry.EventID, &entry.WebsiteID, &entry.EventType,
+			&payload, &entry.Error, &entry.Attempts, &entry.FailedAt, &entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+
+		if err := json.Unmarshal(payload, &entry.Event); err != nil {
+			r.logger.Warn().Err(err).Str("id", entry.ID.String()).Msg("Failed to parse dead-letter payload")
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type DigestRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDigestRepository(db *pgxpool.Pool) *DigestRepository {
+	return &DigestRepository{db: db}
+}
+
+const digestScheduleColumns = `id, website_id, user_id, name, frequency, recipients, timezone, send_hour, enabled,
+	next_run_at, last_sent_at, last_error, failed_attempts, created_at, updated_at`
+
+func (r *DigestRepository) Create(ctx context.Context, schedule *models.DigestSchedule) error {
+	schedule.ID = uuid.New()
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = schedule.CreatedAt
+
+	query := `
+		INSERT INTO digest_schedules (id, website_id, user_id, name, frequency, recipients, timezone, send_hour, enabled, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := r.db.Exec(ctx, query,
+		schedule.ID, schedule.WebsiteID, schedule.UserID, schedule.Name, schedule.Frequency, schedule.Recipients,
+		schedule.Timezone, schedule.Hour, schedule.Enabled, schedule.NextRunAt, schedule.CreatedAt, schedule.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByWebsiteID returns a website's digest schedules in name order
+func (r *DigestRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.DigestSchedule, error) {
+	query := `
+		SELECT ` + digestScheduleColumns + `
+		FROM digest_schedules
+		WHERE website_id = $1
+		ORDER BY name ASC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.DigestSchedule{}
+	for rows.Next() {
+		schedule, err := scanDigestSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+func (r *DigestRepository) GetByID(ctx context.Context, scheduleID uuid.UUID) (*models.DigestSchedule, error) {
+	query := `
+		SELECT ` + digestScheduleColumns + `
+		FROM digest_schedules
+		WHERE id = $1`
+
+	return scanDigestSchedule(r.db.QueryRow(ctx, query, scheduleID))
+}
+
+// Update stores a schedule's settings and its recomputed next run, which starts its
+// failed attempts over
+func (r *DigestRepository) Update(ctx context.Context, scheduleID uuid.UUID, schedule *models.DigestSchedule) error {
+	schedule.UpdatedAt = time.Now()
+	schedule.FailedAttempts = 0
+
+	query := `
+		UPDATE digest_schedules
+		SET name = $2, frequency = $3, recipients = $4, timezone = $5, send_hour = $6, enabled = $7,
+			next_run_at = $8, failed_attempts = 0, updated_at = $9
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		scheduleID, schedule.Name, schedule.Frequency, schedule.Recipients, schedule.Timezone,
+		schedule.Hour, schedule.Enabled, schedule.NextRunAt, schedule.UpdatedAt,
+	)
+
+	return err
+}
+
+// ClaimDue returns the enabled schedule that has been due the longest, or pgx.ErrNoRows
+// when none is due. Its next run is moved to retryAt first, so concurrent callers never
+// claim the same schedule and it is retried then if the send never completes.
+func (r *DigestRepository) ClaimDue(ctx context.Context, now, retryAt time.Time) (*models.DigestSchedule, error) {
+	query := `
+		UPDATE digest_schedules
+		SET next_run_at = $2, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM digest_schedules
+			WHERE enabled AND next_run_at <= $1
+			ORDER BY next_run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + digestScheduleColumns
+
+	return scanDigestSchedule(r.db.QueryRow(ctx, query, now, retryAt))
+}
+
+// MarkSent records a successful send and schedules the next one
+func (r *DigestRepository) MarkSent(ctx context.Context, scheduleID uuid.UUID, sentAt, nextRunAt time.Time) error {
+	query := `
+		UPDATE digest_schedules
+		SET last_sent_at = $2, next_run_at = $3, last_error = NULL, failed_attempts = 0, updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, scheduleID, sentAt, nextRunAt)
+	return err
+}
+
+// MarkFailed records a failed send, the attempts made for the period so far and when
+// to run next
+func (r *DigestRepository) MarkFailed(ctx context.Context, scheduleID uuid.UUID, message string, attempts int, nextRunAt time.Time) error {
+	query := `
+		UPDATE digest_schedules
+		SET last_error = $2, failed_attempts = $3, next_run_at = $4, updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, scheduleID, message, attempts, nextRunAt)
+	return err
+}
+
+func (r *DigestRepository) Delete(ctx context.Context, scheduleID uuid.UUID) error {
+	query := `DELETE FROM digest_schedules WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, scheduleID)
+	return err
+}
+
+func scanDigestSchedule(row pgx.Row) (*models.DigestSchedule, error) {
+	var schedule models.DigestSchedule
+
+	err := row.Scan(
+		&schedule.ID, &schedule.WebsiteID, &schedule.UserID, &schedule.Name, &schedule.Frequency,
+		&schedule.Recipients, &schedule.Timezone, &schedule.Hour, &schedule.Enabled,
+		&schedule.NextRunAt, &schedule.LastSentAt, &schedule.LastError, &schedule.FailedAttempts,
+		&schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
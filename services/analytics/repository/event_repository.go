@@ -4,11 +4,13 @@ import (
 	"analytics-app/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
@@ -29,6 +31,15 @@ type BatchResult struct {
 	Processed int     `json:"processed"`
 	Failed    int     `json:"failed"`
 	Errors    []error `json:"errors,omitempty"`
+
+	// Failures identifies which events (by index into the input slice) were not stored
+	Failures []EventFailure `json:"-"`
+}
+
+// EventFailure records why a single event in a batch could not be stored
+type EventFailure struct {
+	Index int
+	Err   error
 }
 
 func NewEventRepository(db *pgxpool.Pool, logger zerolog.Logger) *EventRepository {
@@ -74,31 +85,30 @@ func (r *EventRepository) GetTotalPageviews(ctx context.Context) (int64, error)
 func (r *EventRepository) Create(ctx context.Context, event *models.Event) error {
 	r.prepareEvent(event)
 
-	// Handle custom events with aggregation
-	if event.EventType != "pageview" && event.EventType != "session_start" && event.EventType != "session_end" {
-		// For custom events, aggregate them instead of storing individually
-		if err := r.customEventsAggregated.UpsertCustomEvent(ctx, event); err != nil {
-			r.logger.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to aggregate custom event")
-			return err
-		}
-		return nil
-	}
-
-	// For system events (pageview, session_start, session_end), store normally
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	// All events, including custom events, are stored individually
+	insertCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	query := `INSERT INTO events (
 		id, website_id, visitor_id, session_id, event_type, page, referrer, user_agent, ip_address,
 		country, city, browser, device, os, utm_source, utm_medium, utm_campaign, utm_term, utm_content,
 		time_on_page, properties, timestamp, created_at
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+	ON CONFLICT (id, timestamp) DO NOTHING`
 
-	_, err := r.db.Exec(ctx, query, r.eventArgs(event)...)
+	_, err := r.db.Exec(insertCtx, query, r.eventArgs(event)...)
 	if err != nil {
 		r.logger.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to insert event")
+		return err
 	}
-	return err
+
+	// The custom event rollup is derived from the stored events
+	if !IsSystemEvent(event.EventType) {
+		if err := r.customEventsAggregated.RefreshRollup(ctx, []models.Event{*event}); err != nil {
+			r.logger.Warn().Err(err).Str("event_id", event.ID.String()).Msg("Failed to refresh custom event rollup")
+		}
+	}
+	return nil
 }
 
 func (r *EventRepository) CreateBatch(ctx context.Context, events []models.Event) (*BatchResult, error) {
@@ -113,43 +123,39 @@ func (r *EventRepository) CreateBatch(ctx context.Context, events []models.Event
 	result := &BatchResult{Total: len(events)}
 	start := time.Now()
 
-	// Separate system events from custom events
-	var systemEvents []models.Event
-	var customEvents []models.Event
-
-	for _, event := range events {
-		if event.EventType == "pageview" || event.EventType == "session_start" || event.EventType == "session_end" {
-			systemEvents = append(systemEvents, event)
-		} else {
-			customEvents = append(customEvents, event)
+	// Store all events in chunks; custom events keep their full properties
+	failed := make(map[int]bool)
+	for i := 0; i < len(events); i += MaxBatchSize {
+		end := i + MaxBatchSize
+		if end > len(events) {
+			end = len(events)
 		}
-	}
-
-	// Process system events in chunks (normal storage)
-	if len(systemEvents) > 0 {
-		for i := 0; i < len(systemEvents); i += MaxBatchSize {
-			end := i + MaxBatchSize
-			if end > len(systemEvents) {
-				end = len(systemEvents)
-			}
 
-			chunkResult, err := r.processChunk(ctx, systemEvents[i:end])
-			result.Processed += chunkResult.Processed
-			result.Failed += chunkResult.Failed
+		chunkResult, err := r.processChunk(ctx, events[i:end])
+		result.Processed += chunkResult.Processed
+		result.Failed += chunkResult.Failed
+		result.Errors = append(result.Errors, chunkResult.Errors...)
+		for _, failure := range chunkResult.Failures {
+			result.Failures = append(result.Failures, EventFailure{Index: i + failure.Index, Err: failure.Err})
+			failed[i+failure.Index] = true
+		}
 
-			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("system events chunk %d-%d: %w", i, end-1, err))
-			}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("events chunk %d-%d: %w", i, end-1, err))
 		}
 	}
 
-	// Process custom events with aggregation
-	for _, event := range customEvents {
-		if err := r.customEventsAggregated.UpsertCustomEvent(ctx, &event); err != nil {
-			result.Failed++
-			result.Errors = append(result.Errors, fmt.Errorf("custom event aggregation failed: %w", err))
-		} else {
-			result.Processed++
+	// Refresh the custom event rollup for the events that were stored. The raw events are
+	// the source of truth, so a rollup failure is logged rather than failing the batch.
+	var customEvents []models.Event
+	for i, event := range events {
+		if !failed[i] && !IsSystemEvent(event.EventType) {
+			customEvents = append(customEvents, event)
+		}
+	}
+	if len(customEvents) > 0 {
+		if err := r.customEventsAggregated.RefreshRollup(ctx, customEvents); err != nil {
+			r.logger.Warn().Err(err).Int("custom_events", len(customEvents)).Msg("Failed to refresh custom event rollup")
 		}
 	}
 
@@ -157,7 +163,6 @@ func (r *EventRepository) CreateBatch(ctx context.Context, events []models.Event
 		Int("total", result.Total).
 		Int("processed", result.Processed).
 		Int("failed", result.Failed).
-		Int("system_events", len(systemEvents)).
 		Int("custom_events", len(customEvents)).
 		Dur("duration", time.Since(start)).
 		Msg("Batch insert completed")
@@ -195,9 +200,21 @@ func (r *EventRepository) copyBatch(ctx context.Context, events []models.Event)
 
 	rowsAffected, err := r.db.CopyFrom(ctx, pgx.Identifier{"events"}, columns, pgx.CopyFromRows(rows))
 
+	// COPY cannot skip rows that already exist (e.g. redelivered events), so fall back to
+	// inserting row by row, which ignores duplicates
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		r.logger.Debug().Int("events_count", len(events)).Msg("Duplicate events in COPY, falling back to batch insert")
+		return r.regularBatch(ctx, events)
+	}
+
 	result := &BatchResult{Total: len(events)}
 	if err != nil {
+		// COPY is atomic, so every event in the chunk failed
 		result.Failed = len(events)
+		for i := range events {
+			result.Failures = append(result.Failures, EventFailure{Index: i, Err: err})
+		}
 		return result, fmt.Errorf("copy failed: %w", err)
 	}
 
@@ -212,7 +229,8 @@ func (r *EventRepository) regularBatch(ctx context.Context, events []models.Even
 		id, website_id, visitor_id, session_id, event_type, page, referrer, user_agent, ip_address,
 		country, city, browser, device, os, utm_source, utm_medium, utm_campaign, utm_term, utm_content,
 		time_on_page, properties, timestamp, created_at
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+	ON CONFLICT (id, timestamp) DO NOTHING`
 
 	// Prepare events and queue them
 	for i := range events {
@@ -230,6 +248,7 @@ func (r *EventRepository) regularBatch(ctx context.Context, events []models.Even
 		if _, err := br.Exec(); err != nil {
 			result.Failed++
 			result.Errors = append(result.Errors, fmt.Errorf("event %d: %w", i, err))
+			result.Failures = append(result.Failures, EventFailure{Index: i, Err: err})
 		} else {
 			result.Processed++
 		}
@@ -303,6 +322,12 @@ func (r *EventRepository) HealthCheck(ctx context.Context) error {
 
 // Helper methods
 
+// IsSystemEvent reports whether the event type is one of the built-in pageview/session events.
+// Every other type is a custom event and refreshes the custom event rollup.
+func IsSystemEvent(eventType string) bool {
+	return eventType == "pageview" || eventType == "session_start" || eventType == "session_end"
+}
+
 func (r *EventRepository) prepareEvent(event *models.Event) {
 	if event.ID == uuid.Nil {
 		event.ID = uuid.New()
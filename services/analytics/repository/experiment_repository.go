@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExperimentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExperimentRepository(db *pgxpool.Pool) *ExperimentRepository {
+	return &ExperimentRepository{db: db}
+}
+
+const experimentColumns = `id, website_id, user_id, name, experiment_key, experiment_property, variant_property, control_variant,
+	goal_id, funnel_id, confidence, sample_size, started_at, ended_at, created_at, updated_at`
+
+func (r *ExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	experiment.ID = uuid.New()
+	experiment.CreatedAt = time.Now()
+	experiment.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO experiments (` + experimentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+
+	_, err := r.db.Exec(ctx, query,
+		experiment.ID, experiment.WebsiteID, experiment.UserID, experiment.Name, experiment.Key,
+		experiment.ExperimentProperty, experiment.VariantProperty, experiment.ControlVariant,
+		experiment.GoalID, experiment.FunnelID, experiment.Confidence, experiment.SampleSize,
+		experiment.StartedAt, experiment.EndedAt, experiment.CreatedAt, experiment.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByWebsiteID returns a website's experiments, most recently started first
+func (r *ExperimentRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.Experiment, error) {
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE website_id = $1
+		ORDER BY started_at DESC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []models.Experiment{}
+	for rows.Next() {
+		experiment, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, *experiment)
+	}
+
+	return experiments, rows.Err()
+}
+
+func (r *ExperimentRepository) GetByID(ctx context.Context, experimentID uuid.UUID) (*models.Experiment, error) {
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE id = $1`
+
+	return scanExperiment(r.db.QueryRow(ctx, query, experimentID))
+}
+
+func (r *ExperimentRepository) Update(ctx context.Context, experimentID uuid.UUID, experiment *models.Experiment) error {
+	experiment.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE experiments
+		SET name = $2, control_variant = $3, confidence = $4, sample_size = $5, started_at = $6, ended_at = $7, updated_at = $8
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		experimentID, experiment.Name, experiment.ControlVariant, experiment.Confidence,
+		experiment.SampleSize, experiment.StartedAt, experiment.EndedAt, experiment.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *ExperimentRepository) Delete(ctx context.Context, experimentID uuid.UUID) error {
+	query := `DELETE FROM experiments WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, experimentID)
+	return err
+}
+
+// exposuresCTE is each exposed visitor's first exposure in the experiment's period.
+// $1 website, $2 start, $3 end, $4 experiment property, $5 variant property, $6 key.
+const exposuresCTE = `
+	exposures AS (
+		SELECT DISTINCT ON (visitor_id)
+			visitor_id, properties->>$5 AS variant, timestamp AS exposed_at
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $2 AND timestamp < $3
+		AND properties->>$4 = $6
+		AND COALESCE(properties->>$5, '') <> ''
+		ORDER BY visitor_id, timestamp
+	)`
+
+// GetExposures returns every exposed visitor with their variant, up to end
+func (r *ExperimentRepository) GetExposures(ctx context.Context, experiment *models.Experiment, end time.Time) ([]models.ExperimentExposure, error) {
+	query := `
+		WITH ` + exposuresCTE + `
+		SELECT visitor_id, variant, exposed_at
+		FROM exposures`
+
+	rows, err := r.db.Query(ctx, query,
+		experiment.WebsiteID, experiment.StartedAt, end,
+		experiment.ExperimentProperty, experiment.VariantProperty, experiment.Key,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exposures []models.ExperimentExposure
+	for rows.Next() {
+		var exposure models.ExperimentExposure
+		if err := rows.Scan(&exposure.VisitorID, &exposure.Variant, &exposure.ExposedAt); err != nil {
+			return nil, err
+		}
+		exposures = append(exposures, exposure)
+	}
+
+	return exposures, rows.Err()
+}
+
+// GetGoalCounts returns each variant's exposed visitors and how many of them completed
+// the goal after their exposure, up to end
+func (r *ExperimentRepository) GetGoalCounts(ctx context.Context, experiment *models.Experiment, goal *models.Goal, end time.Time) ([]models.ExperimentCounts, error) {
+	qb := NewQueryBuilder(experiment.WebsiteID, models.DateRange{Start: experiment.StartedAt, End: end}, nil)
+	qb.Arg(experiment.ExperimentProperty)
+	qb.Arg(experiment.VariantProperty)
+	qb.Arg(experiment.Key)
+
+	query := `
+		WITH ` + exposuresCTE + `
+		SELECT x.variant,
+			COUNT(*)::bigint AS visitors,
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1
+				FROM events e
+				WHERE e.website_id = $1
+				AND e.visitor_id = x.visitor_id
+				AND e.timestamp >= x.exposed_at AND e.timestamp < $3
+				AND ` + qb.Match(goal.Filters(), "e.") + `
+			))::bigint AS conversions
+		FROM exposures x
+		GROUP BY x.variant`
+
+	rows, err := r.db.Query(ctx, query, qb.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.ExperimentCounts
+	for rows.Next() {
+		var c models.ExperimentCounts
+		if err := rows.Scan(&c.Variant, &c.Visitors, &c.Conversions); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+func scanExperiment(row pgx.Row) (*models.Experiment, error) {
+	var experiment models.Experiment
+
+	err := row.Scan(
+		&experiment.ID, &experiment.WebsiteID, &experiment.UserID, &experiment.Name, &experiment.Key,
+		&experiment.ExperimentProperty, &experiment.VariantProperty, &experiment.ControlVariant,
+		&experiment.GoalID, &experiment.FunnelID, &experiment.Confidence, &experiment.SampleSize,
+		&experiment.StartedAt, &experiment.EndedAt, &experiment.CreatedAt, &experiment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &experiment, nil
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExportRepository(db *pgxpool.Pool) *ExportRepository {
+	return &ExportRepository{db: db}
+}
+
+const exportJobColumns = `id, website_id, user_id, source, format, start_date, end_date, timezone, filters,
+	status, rows_exported, file_size, error, created_at, updated_at, started_at, completed_at, expires_at`
+
+func (r *ExportRepository) Create(ctx context.Context, job *models.ExportJob) error {
+	job.ID = uuid.New()
+	job.Status = models.ExportPending
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+
+	filters, err := json.Marshal(job.Filters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal export filters: %w", err)
+	}
+
+	query := `
+		INSERT INTO export_jobs (id, website_id, user_id, source, format, start_date, end_date, timezone, filters, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err = r.db.Exec(ctx, query,
+		job.ID, job.WebsiteID, job.UserID, job.Source, job.Format, job.StartDate, job.EndDate,
+		job.Timezone, filters, job.Status, job.CreatedAt, job.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByWebsiteID returns a website's export jobs, newest first
+func (r *ExportRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.ExportJob, error) {
+	query := `
+		SELECT ` + exportJobColumns + `
+		FROM export_jobs
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (r *ExportRepository) GetByID(ctx context.Context, jobID uuid.UUID) (*models.ExportJob, error) {
+	query := `
+		SELECT ` + exportJobColumns + `
+		FROM export_jobs
+		WHERE id = $1`
+
+	return scanExportJob(r.db.QueryRow(ctx, query, jobID))
+}
+
+// ClaimNext marks the oldest pending job as running and returns it, or pgx.ErrNoRows
+// when there is none. Concurrent callers never claim the same job.
+func (r *ExportRepository) ClaimNext(ctx context.Context) (*models.ExportJob, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = 'running', started_at = NOW(), updated_at = NOW(), rows_exported = 0, error = NULL
+		WHERE id = (
+			SELECT id FROM export_jobs
+			WHERE status = 'pending'
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + exportJobColumns
+
+	return scanExportJob(r.db.QueryRow(ctx, query))
+}
+
+// UpdateProgress records how many rows a running job has written so far
+func (r *ExportRepository) UpdateProgress(ctx context.Context, jobID uuid.UUID, rowsExported int64) error {
+	query := `
+		UPDATE export_jobs
+		SET rows_exported = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	_, err := r.db.Exec(ctx, query, jobID, rowsExported)
+	return err
+}
+
+func (r *ExportRepository) Complete(ctx context.Context, jobID uuid.UUID, rowsExported, fileSize int64, expiresAt time.Time) error {
+	query := `
+		UPDATE export_jobs
+		SET status = 'completed', rows_exported = $2, file_size = $3, expires_at = $4,
+			completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, jobID, rowsExported, fileSize, expiresAt)
+	return err
+}
+
+func (r *ExportRepository) Fail(ctx context.Context, jobID uuid.UUID, message string) error {
+	query := `
+		UPDATE export_jobs
+		SET status = 'failed', error = $2, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, jobID, message)
+	return err
+}
+
+// Requeue makes a running job pending again, to be started over
+func (r *ExportRepository) Requeue(ctx context.Context, jobID uuid.UUID) error {
+	query := `
+		UPDATE export_jobs
+		SET status = 'pending', started_at = NULL, rows_exported = 0, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	_, err := r.db.Exec(ctx, query, jobID)
+	return err
+}
+
+// RequeueStale makes running jobs without progress since the given time pending again;
+// their worker is assumed to have died
+func (r *ExportRepository) RequeueStale(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = 'pending', started_at = NULL, rows_exported = 0, updated_at = NOW()
+		WHERE status = 'running' AND updated_at < $1`
+
+	tag, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Expire marks completed jobs past their expiry as expired and returns their IDs
+func (r *ExportRepository) Expire(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	query := `
+		UPDATE export_jobs
+		SET status = 'expired', updated_at = NOW()
+		WHERE status = 'completed' AND expires_at <= $1
+		RETURNING id`
+
+	rows, err := r.db.Query(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Live returns which of the given jobs still exist and are pending, running or
+// completed, so still need their file
+func (r *ExportRepository) Live(ctx context.Context, jobIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	query := `
+		SELECT id FROM export_jobs
+		WHERE id = ANY($1) AND status IN ('pending', 'running', 'completed')`
+
+	rows, err := r.db.Query(ctx, query, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	live := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		live[id] = true
+	}
+
+	return live, rows.Err()
+}
+
+func (r *ExportRepository) Delete(ctx context.Context, jobID uuid.UUID) error {
+	query := `DELETE FROM export_jobs WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, jobID)
+	return err
+}
+
+// StreamEvents reads a website's raw events in the period, oldest first, through a
+// server-side cursor and hands them to fn batchSize rows at a time, as values of
+// models.ExportEventColumns. The rows come from a single snapshot of the table.
+func (r *ExportRepository) StreamEvents(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, batchSize int, fn func(rows [][]interface{}) error) error {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	query := qb.Build(`
+		DECLARE export_events NO SCROLL CURSOR FOR
+		SELECT id, timestamp, visitor_id, session_id, event_type, page, referrer,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content,
+			country, region, city, continent, browser, device, os, time_on_page, properties::text
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $2 AND timestamp < $3{{filters}}
+		ORDER BY timestamp`)
+
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin export transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, query, qb.Args()...); err != nil {
+		return fmt.Errorf("failed to open export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_events", batchSize)
+	batch := make([][]interface{}, 0, batchSize)
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch export rows: %w", err)
+		}
+
+		batch = batch[:0]
+		for rows.Next() {
+			var id uuid.UUID
+			var timestamp time.Time
+			var visitorID, eventType string
+			var sessionID, page, referrer, utmSource, utmMedium, utmCampaign, utmTerm, utmContent,
+				country, region, city, continent, browser, device, os, properties *string
+			var timeOnPage *int
+			err := rows.Scan(
+				&id, &timestamp, &visitorID, &sessionID, &eventType, &page, &referrer,
+				&utmSource, &utmMedium, &utmCampaign, &utmTerm, &utmContent,
+				&country, &region, &city, &continent, &browser, &device, &os, &timeOnPage, &properties,
+			)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan export row: %w", err)
+			}
+
+			var onPage interface{}
+			if timeOnPage != nil {
+				onPage = int64(*timeOnPage)
+			}
+			batch = append(batch, []interface{}{
+				id.String(), timestamp, visitorID, exportString(sessionID), eventType, exportString(page),
+				exportString(referrer), exportString(utmSource), exportString(utmMedium),
+				exportString(utmCampaign), exportString(utmTerm), exportString(utmContent),
+				exportString(country), exportString(region), exportString(city), exportString(continent),
+				exportString(browser), exportString(device), exportString(os), onPage, exportString(properties),
+			})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to fetch export rows: %w", err)
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+// exportString returns a nullable column's value as an export value
+func exportString(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func scanExportJob(row pgx.Row) (*models.ExportJob, error) {
+	var job models.ExportJob
+	var filtersJSON []byte
+
+	err := row.Scan(
+		&job.ID, &job.WebsiteID, &job.UserID, &job.Source, &job.Format, &job.StartDate, &job.EndDate,
+		&job.Timezone, &filtersJSON, &job.Status, &job.RowsExported, &job.FileSize, &job.Error,
+		&job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.CompletedAt, &job.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(filtersJSON, &job.Filters); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
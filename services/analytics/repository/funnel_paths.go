@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GetFunnelPaths evaluates the funnel for each visitor or session in the period, taking
+// their events in time order. Tracked mode only looks at visitors with funnel events;
+// retroactive mode at everyone. Page and custom steps are matched on raw events; event
+// steps are click selectors only the tracker can see, so they come from its funnel
+// events in both modes, without a breakdown value. A segment restricts both to its
+// sessions or visitors.
+func (r *FunnelRepository) GetFunnelPaths(ctx context.Context, funnel *models.Funnel, dateRange models.DateRange, q models.FunnelQuery) ([]models.FunnelPath, error) {
+	return r.GetFunnelPathsSince(ctx, funnel, dateRange, q, nil)
+}
+
+// GetFunnelPathsSince is GetFunnelPaths for the visitors or sessions in since only, each
+// evaluated on their events from the given time on. A nil since evaluates everyone on
+// the whole period.
+func (r *FunnelRepository) GetFunnelPathsSince(ctx context.Context, funnel *models.Funnel, dateRange models.DateRange, q models.FunnelQuery, since map[string]time.Time) ([]models.FunnelPath, error) {
+	qb := NewQueryBuilder(funnel.WebsiteID, dateRange, q.Filters())
+	funnelArg := qb.Arg(funnel.ID)
+
+	var matches []string
+	var tracked []int
+	for i, step := range funnel.Steps {
+		if step.Tracked() {
+			tracked = append(tracked, i+1)
+			continue
+		}
+		matches = append(matches, fmt.Sprintf("CASE WHEN %s THEN %d END", qb.Match(step.Filters(), "e."), i))
+	}
+	trackedArg := qb.Arg(tracked)
+
+	stepsExpr := "'{}'::int[]"
+	if len(matches) > 0 {
+		stepsExpr = "array_remove(ARRAY[" + strings.Join(matches, ", ") + "]::int[], NULL)"
+	}
+
+	unit := "visitor_id"
+	if q.Per == models.FunnelPerSession {
+		unit = "session_id"
+	}
+
+	segment := "''"
+	if q.Breakdown == models.FunnelSourceDimension {
+		segment = "COALESCE(NULLIF(" + models.AttributionDimensions[models.FunnelSourceDimension] + ", ''), '" + models.AttributionEmptyValues[models.FunnelSourceDimension] + "')"
+	} else if column, ok := models.FilterColumns[q.Breakdown]; ok {
+		segment = "COALESCE((e." + column + ")::text, '')"
+	}
+
+	participants := ""
+	if q.Mode != models.FunnelModeRetroactive {
+		participants = `
+			AND e.visitor_id IN (
+				SELECT visitor_id
+				FROM funnel_events
+				WHERE funnel_id = ` + funnelArg + `
+				AND created_at >= $2 AND created_at < $3
+			)`
+	}
+
+	trackedUnits := ""
+	if since != nil {
+		units := make([]string, 0, len(since))
+		for unitID := range since {
+			units = append(units, unitID)
+		}
+		unitsArg := qb.Arg(units)
+		participants += `
+			AND e.` + unit + ` = ANY(` + unitsArg + `::text[])`
+		trackedUnits = `
+			AND ` + unit + ` = ANY(` + unitsArg + `::text[])`
+	}
+
+	touchFilters := []string{"unit_id IS NOT NULL"}
+	// Loose ordering ignores events between steps, so only matching ones are needed
+	if funnel.Ordering != models.FunnelOrderStrict {
+		touchFilters = append(touchFilters, "cardinality(steps) > 0")
+	}
+
+	query := `
+		WITH touches AS (
+			SELECT e.` + unit + ` AS unit_id, e.timestamp, ` + stepsExpr + ` AS steps, ` + segment + ` AS segment
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2 AND e.timestamp < $3` + participants + FiltersAliasedToken + `
+			UNION ALL
+			SELECT ` + unit + `, created_at, ARRAY[current_step - 1], ''
+			FROM funnel_events
+			WHERE funnel_id = ` + funnelArg + `
+			AND created_at >= $2 AND created_at < $3
+			AND current_step = ANY(` + trackedArg + `::int[])` + trackedUnits + FiltersToken + `
+		)
+		SELECT unit_id, timestamp, steps, segment
+		FROM touches
+		WHERE ` + strings.Join(touchFilters, " AND ") + `
+		ORDER BY unit_id, timestamp`
+
+	rows, err := r.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []models.FunnelPath
+	var current string
+	var touches []models.FunnelTouch
+	evaluate := func() {
+		if len(touches) == 0 {
+			return
+		}
+		var path models.FunnelPath
+		if since != nil {
+			path = funnel.EvaluateSince(touches, since[current])
+		} else {
+			path = funnel.Evaluate(touches)
+		}
+		if path.Reached() > 0 {
+			path.Unit = current
+			paths = append(paths, path)
+		}
+		touches = touches[:0]
+	}
+
+	for rows.Next() {
+		var unitID string
+		var touch models.FunnelTouch
+		if err := rows.Scan(&unitID, &touch.Timestamp, &touch.Steps, &touch.Segment); err != nil {
+			return nil, err
+		}
+		if unitID != current {
+			evaluate()
+			current = unitID
+		}
+		touches = append(touches, touch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	evaluate()
+
+	return paths, nil
+}
+
+// summarizeFunnelDays groups paths by the UTC day they entered the funnel, newest first
+func summarizeFunnelDays(paths []models.FunnelPath, steps int) ([]models.DailyFunnelPerformance, []models.FunnelCohortData) {
+	type day struct {
+		starts, conversions int
+		convertSeconds      float64
+	}
+
+	days := map[string]*day{}
+	for _, path := range paths {
+		date := path.StepTimes[0].UTC().Format("2006-01-02")
+		d, ok := days[date]
+		if !ok {
+			d = &day{}
+			days[date] = d
+		}
+		d.starts++
+		if path.Reached() == steps {
+			d.conversions++
+			d.convertSeconds += path.StepTimes[steps-1].Sub(path.StepTimes[0]).Seconds()
+		}
+	}
+
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+
+	var dailyPerformance []models.DailyFunnelPerformance
+	var cohortData []models.FunnelCohortData
+	for _, date := range dates {
+		d := days[date]
+		rate := float64(d.conversions) / float64(d.starts) * 100
+
+		var avgTimeToConvert int
+		if d.conversions > 0 {
+			avgTimeToConvert = int(d.convertSeconds / float64(d.conversions))
+		}
+
+		dailyPerformance = append(dailyPerformance, models.DailyFunnelPerformance{
+			Date:           date,
+			TotalStarts:    d.starts,
+			Conversions:    d.conversions,
+			ConversionRate: rate,
+		})
+		cohortData = append(cohortData, models.FunnelCohortData{
+			CohortDate:       date,
+			CohortSize:       d.starts,
+			Conversions:      d.conversions,
+			ConversionRate:   rate,
+			AvgTimeToConvert: avgTimeToConvert,
+		})
+	}
+	return dailyPerformance, cohortData
+}
@@ -26,12 +26,13 @@ func (r *FunnelRepository) Create(ctx context.Context, funnel *models.Funnel) er
 	funnel.UpdatedAt = time.Now()
 
 	query := `
-		INSERT INTO funnels (id, name, description, website_id, user_id, steps, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+		INSERT INTO funnels (id, name, description, website_id, user_id, steps, is_active, created_at, updated_at, conversion_window_seconds, ordering)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
 	_, err := r.db.Exec(ctx, query,
 		funnel.ID, funnel.Name, funnel.Description, funnel.WebsiteID,
 		funnel.UserID, funnel.Steps, funnel.IsActive, funnel.CreatedAt, funnel.UpdatedAt,
+		funnel.ConversionWindowSeconds, funnel.Ordering,
 	)
 
 	return err
@@ -39,7 +40,7 @@ func (r *FunnelRepository) Create(ctx context.Context, funnel *models.Funnel) er
 
 func (r *FunnelRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.Funnel, error) {
 	query := `
-		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at
+		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at, conversion_window_seconds, ordering
 		FROM funnels
 		WHERE website_id = $1
 		ORDER BY created_at DESC`
@@ -58,6 +59,7 @@ func (r *FunnelRepository) GetByWebsiteID(ctx context.Context, websiteID string)
 		err := rows.Scan(
 			&funnel.ID, &funnel.Name, &funnel.Description, &funnel.WebsiteID,
 			&funnel.UserID, &stepsJSON, &funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
+			&funnel.ConversionWindowSeconds, &funnel.Ordering,
 		)
 		if err != nil {
 			return nil, err
@@ -79,7 +81,7 @@ func (r *FunnelRepository) GetByWebsiteID(ctx context.Context, websiteID string)
 
 func (r *FunnelRepository) GetByID(ctx context.Context, funnelID uuid.UUID) (*models.Funnel, error) {
 	query := `
-		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at
+		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at, conversion_window_seconds, ordering
 		FROM funnels
 		WHERE id = $1`
 
@@ -89,6 +91,7 @@ func (r *FunnelRepository) GetByID(ctx context.Context, funnelID uuid.UUID) (*mo
 	err := r.db.QueryRow(ctx, query, funnelID).Scan(
 		&funnel.ID, &funnel.Name, &funnel.Description, &funnel.WebsiteID,
 		&funnel.UserID, &stepsJSON, &funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
+		&funnel.ConversionWindowSeconds, &funnel.Ordering,
 	)
 
 	if err != nil {
@@ -111,11 +114,13 @@ func (r *FunnelRepository) Update(ctx context.Context, funnelID uuid.UUID, funne
 
 	query := `
 		UPDATE funnels 
-		SET name = $2, description = $3, steps = $4, is_active = $5, updated_at = $6
+		SET name = $2, description = $3, steps = $4, is_active = $5, updated_at = $6,
+			conversion_window_seconds = $7, ordering = $8
 		WHERE id = $1`
 
 	_, err := r.db.Exec(ctx, query,
 		funnelID, funnel.Name, funnel.Description, funnel.Steps, funnel.IsActive, funnel.UpdatedAt,
+		funnel.ConversionWindowSeconds, funnel.Ordering,
 	)
 
 	return err
@@ -267,6 +272,36 @@ func (r *FunnelRepository) GetFunnelAnalytics(ctx context.Context, funnelID uuid
 		conversionRateValue = *conversionRate
 	}
 
+	// Value is the revenue of orders placed by converted visitors, each order counted once
+	valueQuery := `
+		WITH converted AS (
+			SELECT DISTINCT website_id, visitor_id
+			FROM funnel_events
+			WHERE funnel_id = $1
+			AND converted = true
+			AND created_at >= NOW() - INTERVAL '1 day' * $2
+		),
+		orders AS (
+			SELECT DISTINCT ON (e.properties->>'order_id')
+				(e.properties->>'revenue')::numeric AS revenue
+			FROM events e
+			JOIN converted c ON c.website_id = e.website_id AND c.visitor_id = e.visitor_id
+			WHERE e.event_type = 'purchase'
+			AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND e.properties->>'revenue' IS NOT NULL
+			ORDER BY e.properties->>'order_id', e.timestamp
+		)
+		SELECT SUM(revenue)::float8 FROM orders`
+
+	var totalValue, avgValue *float64
+	if err := r.db.QueryRow(ctx, valueQuery, funnelID, days).Scan(&totalValue); err != nil {
+		return nil, err
+	}
+	if totalValue != nil && totalConversions > 0 {
+		avg := *totalValue / float64(totalConversions)
+		avgValue = &avg
+	}
+
 	analytics := &models.FunnelAnalytics{
 		FunnelID:         funnelID,
 		WebsiteID:        websiteID,
@@ -274,6 +309,8 @@ func (r *FunnelRepository) GetFunnelAnalytics(ctx context.Context, funnelID uuid
 		TotalStarts:      totalStarts,
 		TotalConversions: totalConversions,
 		ConversionRate:   conversionRateValue,
+		AvgValue:         avgValue,
+		TotalValue:       totalValue,
 		AvgTimeToConvert: avgTimeToConvertInt,
 		AvgTimeToAbandon: avgTimeToAbandonInt,
 		DropOffRate:      dropOffRate,
@@ -283,174 +320,44 @@ func (r *FunnelRepository) GetFunnelAnalytics(ctx context.Context, funnelID uuid
 	return analytics, nil
 }
 
-// GetDetailedFunnelAnalytics provides step-by-step funnel analysis
-func (r *FunnelRepository) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, days int) (*models.DetailedFunnelAnalytics, error) {
+// GetDetailedFunnelAnalytics provides step-by-step funnel analysis. The funnel's steps,
+// window and ordering are evaluated against raw events: in tracked mode those of the
+// visitors the tracker saw entering the funnel, in retroactive mode everyone's.
+func (r *FunnelRepository) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, q models.FunnelQuery) (*models.DetailedFunnelAnalytics, error) {
 	// Get the funnel definition first
 	funnel, err := r.GetByID(ctx, funnelID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get step-by-step analytics
-	stepAnalytics := make([]models.FunnelStepAnalytics, len(funnel.Steps))
-
-	for i, step := range funnel.Steps {
-		stepIndex := i + 1
-
-		// Count visitors who reached this EXACT step (not beyond)
-		stepQuery := `
-			SELECT 
-				COUNT(DISTINCT visitor_id) as visitors_reached,
-				AVG(EXTRACT(EPOCH FROM (last_activity - started_at))) as avg_time_on_step
-			FROM funnel_events
-			WHERE funnel_id = $1 
-			AND current_step = $2
-			AND created_at >= NOW() - INTERVAL '1 day' * $3`
-
-		var visitorsReached int
-		var avgTimeOnStep *float64
-
-		err := r.db.QueryRow(ctx, stepQuery, funnelID, stepIndex, days).Scan(
-			&visitorsReached, &avgTimeOnStep,
-		)
-
-		if err != nil {
-			visitorsReached = 0
-			avgTimeOnStep = nil
-		}
-
-		// Calculate conversion rate to next step
-		var conversionToNext float64
-		if i < len(funnel.Steps)-1 {
-			// Count visitors who reached the NEXT step (converted from current step)
-			nextStepQuery := `
-				SELECT COUNT(DISTINCT visitor_id) as visitors_to_next
-				FROM funnel_events
-				WHERE funnel_id = $1 
-				AND current_step = $2
-				AND created_at >= NOW() - INTERVAL '1 day' * $3`
-
-			var visitorsToNext int
-			err := r.db.QueryRow(ctx, nextStepQuery, funnelID, stepIndex+1, days).Scan(&visitorsToNext)
-			if err == nil && visitorsReached > 0 {
-				conversionToNext = (float64(visitorsToNext) / float64(visitorsReached)) * 100
-			}
-		} else {
-			// Last step - check for conversions
-			conversionQuery := `
-				SELECT COUNT(DISTINCT visitor_id) as conversions
-				FROM funnel_events
-				WHERE funnel_id = $1 
-				AND converted = true
-				AND current_step = $2
-				AND created_at >= NOW() - INTERVAL '1 day' * $3`
-
-			var conversions int
-			err := r.db.QueryRow(ctx, conversionQuery, funnelID, stepIndex, days).Scan(&conversions)
-			if err == nil && visitorsReached > 0 {
-				conversionToNext = (float64(conversions) / float64(visitorsReached)) * 100
-			}
-		}
-
-		stepAnalytics[i] = models.FunnelStepAnalytics{
-			StepID:          step.ID,
-			StepName:        step.Name,
-			StepOrder:       step.Order,
-			VisitorsReached: visitorsReached,
-			ConversionRate:  conversionToNext,
-			DropOffRate:     100 - conversionToNext,
-			AvgTimeOnStep:   avgTimeOnStep,
-		}
-	}
-
-	// Get daily performance data
-	dailyQuery := `
-		SELECT 
-			DATE(created_at) as date,
-			COUNT(DISTINCT visitor_id) as total_starts,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) as conversions,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) * 100.0 / NULLIF(COUNT(DISTINCT visitor_id), 0) as conversion_rate
-		FROM funnel_events
-		WHERE funnel_id = $1 
-		AND created_at >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY DATE(created_at)
-		ORDER BY date DESC`
-
-	rows, err := r.db.Query(ctx, dailyQuery, funnelID, days)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var dailyPerformance []models.DailyFunnelPerformance
-	for rows.Next() {
-		var dp models.DailyFunnelPerformance
-		var date time.Time
-		var conversionRate *float64
-
-		err := rows.Scan(&date, &dp.TotalStarts, &dp.Conversions, &conversionRate)
-		if err != nil {
-			continue
-		}
-
-		dp.Date = date.Format("2006-01-02")
-		if conversionRate != nil {
-			dp.ConversionRate = *conversionRate
-		}
-
-		dailyPerformance = append(dailyPerformance, dp)
-	}
-
-	// Get cohort analysis data
-	cohortQuery := `
-		SELECT 
-			DATE(started_at) as cohort_date,
-			COUNT(DISTINCT visitor_id) as cohort_size,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) as conversions,
-			AVG(EXTRACT(EPOCH FROM (last_activity - started_at))) as avg_time_to_convert
-		FROM funnel_events
-		WHERE funnel_id = $1 
-		AND started_at >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY DATE(started_at)
-		ORDER BY cohort_date DESC`
+	now := time.Now()
+	dateRange := models.DateRange{Start: now.AddDate(0, 0, -q.Days), End: now, Timezone: "UTC"}
 
-	cohortRows, err := r.db.Query(ctx, cohortQuery, funnelID, days)
+	paths, err := r.GetFunnelPaths(ctx, funnel, dateRange, q)
 	if err != nil {
 		return nil, err
 	}
-	defer cohortRows.Close()
-
-	var cohortData []models.FunnelCohortData
-	for cohortRows.Next() {
-		var cd models.FunnelCohortData
-		var cohortDate time.Time
-		var avgTimeToConvert *float64
-
-		err := cohortRows.Scan(&cohortDate, &cd.CohortSize, &cd.Conversions, &avgTimeToConvert)
-		if err != nil {
-			continue
-		}
-
-		cd.CohortDate = cohortDate.Format("2006-01-02")
-		cd.ConversionRate = 0
-		if cd.CohortSize > 0 {
-			cd.ConversionRate = (float64(cd.Conversions) / float64(cd.CohortSize)) * 100
-		}
-		if avgTimeToConvert != nil {
-			cd.AvgTimeToConvert = int(*avgTimeToConvert)
-		}
 
-		cohortData = append(cohortData, cd)
-	}
+	dailyPerformance, cohortData := summarizeFunnelDays(paths, len(funnel.Steps))
 
-	return &models.DetailedFunnelAnalytics{
+	analytics := &models.DetailedFunnelAnalytics{
 		FunnelID:         funnelID,
 		WebsiteID:        funnel.WebsiteID,
-		StepAnalytics:    stepAnalytics,
+		StepAnalytics:    funnel.StepAnalytics(paths),
 		DailyPerformance: dailyPerformance,
 		CohortData:       cohortData,
-		DateRange:        days,
-	}, nil
+		DateRange:        q.Days,
+		Mode:             q.Mode,
+		Per:              q.Per,
+		StepTimings:      funnel.StepTimings(paths),
+		TimeToConvert:    funnel.TimeToConvert(paths),
+	}
+	if q.Breakdown != "" {
+		analytics.Breakdown = q.Breakdown
+		analytics.Segments = funnel.Breakdown(paths, q.Limit)
+	}
+
+	return analytics, nil
 }
 
 // GetTotalCount returns the total number of funnels
@@ -464,7 +371,7 @@ func (r *FunnelRepository) GetTotalCount(ctx context.Context) (int, error) {
 // GetRecentFunnels returns the most recently created funnels
 func (r *FunnelRepository) GetRecentFunnels(ctx context.Context, limit int) ([]models.Funnel, error) {
 	query := `
-		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at
+		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at, conversion_window_seconds, ordering
 		FROM funnels
 		ORDER BY created_at DESC
 		LIMIT $1`
@@ -483,6 +390,7 @@ func (r *FunnelRepository) GetRecentFunnels(ctx context.Context, limit int) ([]m
 		err := rows.Scan(
 			&funnel.ID, &funnel.Name, &funnel.Description, &funnel.WebsiteID,
 			&funnel.UserID, &stepsJSON, &funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
+			&funnel.ConversionWindowSeconds, &funnel.Ordering,
 		)
 		if err != nil {
 			return nil, err
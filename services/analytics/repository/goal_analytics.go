@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GoalAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewGoalAnalytics(db *pgxpool.Pool) *GoalAnalytics {
+	return &GoalAnalytics{db: db}
+}
+
+// goalCompletions builds a query part listing (goal_id, visitor_id, session_id) for every
+// event that completes one of the goals. With distinct, each visitor is listed once per
+// goal and session.
+func goalCompletions(qb *QueryBuilder, goals []models.Goal, distinct bool) string {
+	selectClause := "SELECT "
+	if distinct {
+		selectClause = "SELECT DISTINCT "
+	}
+
+	parts := make([]string, len(goals))
+	for i, goal := range goals {
+		parts[i] = selectClause + qb.Arg(goal.ID.String()) + `::uuid AS goal_id, visitor_id, session_id
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND ` + qb.Match(goal.Filters(), "") + `{{filters}}`
+	}
+	return strings.Join(parts, "\n\t\t\tUNION ALL\n\t\t\t")
+}
+
+// GetGoalStats returns completions and unique-visitor conversions for each goal. The
+// conversion rate is against every visitor with a pageview in the range.
+func (ga *GoalAnalytics) GetGoalStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, goals []models.Goal) ([]models.GoalStat, error) {
+	stats := make([]models.GoalStat, len(goals))
+	index := make(map[uuid.UUID]int, len(goals))
+	for i, goal := range goals {
+		stats[i] = models.GoalStat{GoalID: goal.ID, Name: goal.Name, Type: goal.Type}
+		index[goal.ID] = i
+	}
+	if len(goals) == 0 {
+		return stats, nil
+	}
+
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
+	// The row without a goal carries the number of visitors in the range
+	query := `
+		WITH completions AS (
+			` + goalCompletions(qb, goals, false) + `
+		)
+		SELECT goal_id, COUNT(*), COUNT(DISTINCT visitor_id)
+		FROM completions
+		GROUP BY goal_id
+		UNION ALL
+		SELECT NULL, 0, COUNT(DISTINCT visitor_id)
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}`
+
+	rows, err := ga.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	visitors := 0
+	for rows.Next() {
+		var goalID *uuid.UUID
+		var completions, conversions int
+		if err := rows.Scan(&goalID, &completions, &conversions); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		if goalID == nil {
+			visitors = conversions
+			continue
+		}
+		if i, ok := index[*goalID]; ok {
+			stats[i].Completions = completions
+			stats[i].Conversions = conversions
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, goal := range goals {
+		if visitors > 0 {
+			stats[i].ConversionRate = float64(stats[i].Conversions) * 100.0 / float64(visitors)
+		}
+		if goal.Value != nil {
+			value := *goal.Value * float64(stats[i].Completions)
+			stats[i].Value = &value
+		}
+	}
+
+	return stats, nil
+}
+
+// GetGoalBreakdown returns the top values of a dimension by visitors, with how many of
+// each value's visitors converted on each goal. A session takes the value of its first
+// pageview, and each completion counts for the value of the session it happened in.
+func (ga *GoalAnalytics) GetGoalBreakdown(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, goals []models.Goal, dimension string, limit int) ([]models.GoalBreakdownRow, error) {
+	column, ok := models.FilterColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown breakdown dimension %q", dimension)
+	}
+
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	limitArg := qb.Arg(limit)
+
+	completions := `SELECT NULL::uuid AS goal_id, NULL::varchar AS visitor_id, NULL::varchar AS session_id WHERE FALSE`
+	if len(goals) > 0 {
+		completions = goalCompletions(qb, goals, true)
+	}
+
+	query := `
+		WITH sessions AS (
+			SELECT DISTINCT ON (e.session_id)
+				e.session_id, e.visitor_id, COALESCE((e.` + column + `)::text, '') AS value
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'{{filters:e}}
+			ORDER BY e.session_id, e.timestamp, e.id
+		), top_values AS (
+			SELECT value, COUNT(DISTINCT visitor_id) AS visitors
+			FROM sessions
+			GROUP BY value
+			ORDER BY visitors DESC, value
+			LIMIT ` + limitArg + `
+		), completions AS (
+			` + completions + `
+		), converted AS (
+			SELECT s.value, c.goal_id, c.visitor_id
+			FROM completions c
+			JOIN sessions s ON s.session_id = c.session_id
+		)
+		SELECT t.value, t.visitors, c.goal_id, COUNT(DISTINCT c.visitor_id)
+		FROM top_values t
+		LEFT JOIN converted c ON c.value = t.value
+		GROUP BY t.value, t.visitors, c.goal_id
+		ORDER BY t.visitors DESC, t.value`
+
+	rows, err := ga.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	goalIndex := make(map[uuid.UUID]int, len(goals))
+	for i, goal := range goals {
+		goalIndex[goal.ID] = i
+	}
+
+	breakdown := []models.GoalBreakdownRow{}
+	for rows.Next() {
+		var value string
+		var visitors, conversions int
+		var goalID *uuid.UUID
+		if err := rows.Scan(&value, &visitors, &goalID, &conversions); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+
+		if len(breakdown) == 0 || breakdown[len(breakdown)-1].Value != value {
+			row := models.GoalBreakdownRow{Value: value, Visitors: visitors, Goals: make([]models.GoalConversion, len(goals))}
+			for i, goal := range goals {
+				row.Goals[i] = models.GoalConversion{GoalID: goal.ID, Name: goal.Name}
+			}
+			breakdown = append(breakdown, row)
+		}
+		if goalID == nil {
+			continue
+		}
+
+		row := &breakdown[len(breakdown)-1]
+		if i, ok := goalIndex[*goalID]; ok {
+			row.Goals[i].Conversions = conversions
+			if visitors > 0 {
+				row.Goals[i].ConversionRate = float64(conversions) * 100.0 / float64(visitors)
+			}
+		}
+	}
+
+	return breakdown, rows.Err()
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GoalRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewGoalRepository(db *pgxpool.Pool) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+const goalColumns = `id, website_id, user_id, name, type, page_pattern, event_name, properties, value::float8, is_active, created_at, updated_at`
+
+func (r *GoalRepository) Create(ctx context.Context, goal *models.Goal) error {
+	goal.ID = uuid.New()
+	goal.CreatedAt = time.Now()
+	goal.UpdatedAt = time.Now()
+
+	properties, err := json.Marshal(goalProperties(goal.Properties))
+	if err != nil {
+		return fmt.Errorf("failed to marshal goal properties: %w", err)
+	}
+
+	query := `
+		INSERT INTO goals (id, website_id, user_id, name, type, page_pattern, event_name, properties, value, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err = r.db.Exec(ctx, query,
+		goal.ID, goal.WebsiteID, goal.UserID, goal.Name, goal.Type, goal.PagePattern,
+		goal.EventName, properties, goal.Value, goal.IsActive, goal.CreatedAt, goal.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByWebsiteID returns a website's goals, oldest first so report columns stay stable
+func (r *GoalRepository) GetByWebsiteID(ctx context.Context, websiteID string, activeOnly bool) ([]models.Goal, error) {
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE website_id = $1
+		AND (is_active OR NOT $2)
+		ORDER BY created_at ASC`
+
+	rows, err := r.db.Query(ctx, query, websiteID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []models.Goal{}
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, *goal)
+	}
+
+	return goals, rows.Err()
+}
+
+func (r *GoalRepository) GetByID(ctx context.Context, goalID uuid.UUID) (*models.Goal, error) {
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE id = $1`
+
+	return scanGoal(r.db.QueryRow(ctx, query, goalID))
+}
+
+func (r *GoalRepository) Update(ctx context.Context, goalID uuid.UUID, goal *models.Goal) error {
+	goal.UpdatedAt = time.Now()
+
+	properties, err := json.Marshal(goalProperties(goal.Properties))
+	if err != nil {
+		return fmt.Errorf("failed to marshal goal properties: %w", err)
+	}
+
+	query := `
+		UPDATE goals
+		SET name = $2, type = $3, page_pattern = $4, event_name = $5, properties = $6, value = $7, is_active = $8, updated_at = $9
+		WHERE id = $1`
+
+	_, err = r.db.Exec(ctx, query,
+		goalID, goal.Name, goal.Type, goal.PagePattern, goal.EventName, properties, goal.Value, goal.IsActive, goal.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *GoalRepository) Delete(ctx context.Context, goalID uuid.UUID) error {
+	query := `DELETE FROM goals WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, goalID)
+	return err
+}
+
+func scanGoal(row pgx.Row) (*models.Goal, error) {
+	var goal models.Goal
+	var propertiesJSON []byte
+
+	err := row.Scan(
+		&goal.ID, &goal.WebsiteID, &goal.UserID, &goal.Name, &goal.Type, &goal.PagePattern,
+		&goal.EventName, &propertiesJSON, &goal.Value, &goal.IsActive, &goal.CreatedAt, &goal.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if propertiesJSON != nil {
+		if err := json.Unmarshal(propertiesJSON, &goal.Properties); err != nil {
+			return nil, err
+		}
+	}
+
+	return &goal, nil
+}
+
+// goalProperties stores goals without property conditions as an empty array, not null
+func goalProperties(properties []models.GoalPropertyCondition) []models.GoalPropertyCondition {
+	if properties == nil {
+		return []models.GoalPropertyCondition{}
+	}
+	return properties
+}
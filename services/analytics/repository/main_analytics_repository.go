@@ -3,7 +3,6 @@ package repository
 import (
 	"analytics-app/models"
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -23,10 +22,21 @@ type MainAnalyticsRepository struct {
 	trafficSummary *TrafficSummaryAnalytics
 	timeSeries     *TimeSeriesAnalytics
 	customEvents   *CustomEventsAnalytics
+	retention      *RetentionAnalytics
+	paths          *PathAnalytics
+	goals          *GoalAnalytics
+	revenue        *RevenueAnalytics
+	attribution    *AttributionAnalytics
+	channels       *ChannelAnalytics
+	visitors       *VisitorAnalytics
+	realtime       *RealtimeAnalytics
+	alerts         *AlertAnalytics
+	rollups        *RollupRepository
 }
 
-// NewMainAnalyticsRepository creates a new main analytics repository
-func NewMainAnalyticsRepository(db *pgxpool.Pool) *MainAnalyticsRepository {
+// NewMainAnalyticsRepository creates a new main analytics repository. The closed hours of
+// unfiltered reports are served from the hourly rollups when rollups is not nil.
+func NewMainAnalyticsRepository(db *pgxpool.Pool, rollups *RollupRepository) *MainAnalyticsRepository {
 	return &MainAnalyticsRepository{
 		dashboard:      NewDashboardAnalytics(db),
 		topPages:       NewTopPagesAnalytics(db),
@@ -40,85 +50,253 @@ func NewMainAnalyticsRepository(db *pgxpool.Pool) *MainAnalyticsRepository {
 		trafficSummary: NewTrafficSummaryAnalytics(db),
 		timeSeries:     NewTimeSeriesAnalytics(db),
 		customEvents:   NewCustomEventsAnalytics(db),
+		retention:      NewRetentionAnalytics(db),
+		paths:          NewPathAnalytics(db),
+		goals:          NewGoalAnalytics(db),
+		revenue:        NewRevenueAnalytics(db),
+		attribution:    NewAttributionAnalytics(db),
+		channels:       NewChannelAnalytics(db),
+		visitors:       NewVisitorAnalytics(db),
+		realtime:       NewRealtimeAnalytics(db),
+		alerts:         NewAlertAnalytics(db),
+		rollups:        rollups,
 	}
 }
 
+// useRollups reports whether a report can be read from the hourly rollups: it must be
+// unfiltered, hold a closed hour, and have no hours still waiting to be rolled up
+func (r *MainAnalyticsRepository) useRollups(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) bool {
+	now := time.Now()
+	if r.rollups == nil || len(filters) > 0 || !r.rollups.Covers(dateRange, now) {
+		return false
+	}
+	pending, err := r.rollups.HasPending(ctx, websiteID, dateRange, now)
+	return err == nil && !pending
+}
+
 // Dashboard Analytics Methods
-func (r *MainAnalyticsRepository) GetDashboardMetrics(ctx context.Context, websiteID string, days int) (*models.DashboardMetrics, error) {
-	return r.dashboard.GetDashboardMetrics(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetDashboardMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.DashboardMetrics, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		return r.rollups.GetDashboardMetrics(ctx, websiteID, dateRange)
+	}
+	return r.dashboard.GetDashboardMetrics(ctx, websiteID, dateRange, filters)
 }
 
-func (r *MainAnalyticsRepository) GetComparisonMetrics(ctx context.Context, websiteID string, days int) (*models.ComparisonMetrics, error) {
-	return r.dashboard.GetComparisonMetrics(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetComparisonMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.ComparisonMetrics, error) {
+	previousRange := dateRange.Previous()
+	if r.useRollups(ctx, websiteID, dateRange, filters) && r.useRollups(ctx, websiteID, previousRange, filters) {
+		current, err := r.rollups.GetDashboardMetrics(ctx, websiteID, dateRange)
+		if err != nil {
+			return nil, err
+		}
+		previous, err := r.rollups.GetDashboardMetrics(ctx, websiteID, previousRange)
+		if err != nil {
+			previous = &models.DashboardMetrics{}
+		}
+		return compareDashboardMetrics(*current, *previous), nil
+	}
+	return r.dashboard.GetComparisonMetrics(ctx, websiteID, dateRange, filters)
 }
 
-func (r *MainAnalyticsRepository) GetUTMAnalytics(ctx context.Context, websiteID string, days int) (map[string]interface{}, error) {
-	return r.dashboard.GetUTMAnalytics(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetUTMAnalytics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (map[string]interface{}, error) {
+	return r.dashboard.GetUTMAnalytics(ctx, websiteID, dateRange, filters)
 }
 
 // Top Pages Analytics Methods
-func (r *MainAnalyticsRepository) GetTopPages(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
-	return r.topPages.GetTopPages(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.PageStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		return r.getTopPagesFromRollups(ctx, websiteID, dateRange, limit)
+	}
+	return r.topPages.GetTopPages(ctx, websiteID, dateRange, filters, limit)
+}
+
+func (r *MainAnalyticsRepository) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.PageStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		return r.getTopPagesFromRollups(ctx, websiteID, dateRange, limit)
+	}
+	return r.topPages.GetTopPagesWithTimeBucket(ctx, websiteID, dateRange, filters, limit)
+}
+
+func (r *MainAnalyticsRepository) GetEntryPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.EntryExitPageStat, error) {
+	return r.topPages.GetEntryPages(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (r *MainAnalyticsRepository) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
-	return r.topPages.GetTopPagesWithTimeBucket(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetExitPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.EntryExitPageStat, error) {
+	return r.topPages.GetExitPages(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (r *MainAnalyticsRepository) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, days int) (map[string]interface{}, error) {
-	return r.topPages.GetPageUTMBreakdown(ctx, websiteID, pagePath, days)
+func (r *MainAnalyticsRepository) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, dateRange models.DateRange, filters models.Filters) (map[string]interface{}, error) {
+	return r.topPages.GetPageUTMBreakdown(ctx, websiteID, pagePath, dateRange, filters)
+}
+
+// getTopPagesFromRollups reads top pages from the rollups, which hold pages normalized
+func (r *MainAnalyticsRepository) getTopPagesFromRollups(ctx context.Context, websiteID string, dateRange models.DateRange, limit int) ([]models.PageStat, error) {
+	rows, err := r.rollups.GetTopDimension(ctx, websiteID, "page", dateRange, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]models.PageStat, 0, len(rows))
+	for _, row := range rows {
+		pages = append(pages, models.PageStat{
+			Page:       row.Value,
+			Views:      row.Views,
+			Unique:     row.Visitors,
+			BounceRate: row.BounceRate(),
+			AvgTime:    row.AvgTime(),
+			EntryRate:  row.EntryRate(),
+		})
+	}
+
+	return pages, nil
 }
 
 // Top Referrers Analytics Methods
-func (r *MainAnalyticsRepository) GetTopReferrers(ctx context.Context, websiteID string, days int, limit int) ([]models.ReferrerStat, error) {
-	return r.topReferrers.GetTopReferrers(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopReferrers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.ReferrerStat, error) {
+	return r.topReferrers.GetTopReferrers(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Sources Analytics Methods
-func (r *MainAnalyticsRepository) GetTopSources(ctx context.Context, websiteID string, days int, limit int) ([]models.SourceStat, error) {
-	return r.topSources.GetTopSources(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopSources(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.SourceStat, error) {
+	return r.topSources.GetTopSources(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Countries Analytics Methods
-func (r *MainAnalyticsRepository) GetTopCountries(ctx context.Context, websiteID string, days int, limit int) ([]models.CountryStat, error) {
-	return r.topCountries.GetTopCountries(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.CountryStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		rows, err := r.rollups.GetTopDimension(ctx, websiteID, "country", dateRange, limit)
+		if err != nil {
+			return nil, err
+		}
+		stats := make([]models.CountryStat, 0, len(rows))
+		for _, row := range rows {
+			stats = append(stats, models.CountryStat{Country: row.Value, Views: row.Views, Unique: row.Visitors, Visitors: row.Visitors, BounceRate: row.BounceRate()})
+		}
+		return stats, nil
+	}
+	return r.topCountries.GetTopCountries(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Browsers Analytics Methods
-func (r *MainAnalyticsRepository) GetTopBrowsers(ctx context.Context, websiteID string, days int, limit int) ([]models.BrowserStat, error) {
-	return r.topBrowsers.GetTopBrowsers(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopBrowsers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.BrowserStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		rows, err := r.rollups.GetTopDimension(ctx, websiteID, "browser", dateRange, limit)
+		if err != nil {
+			return nil, err
+		}
+		stats := make([]models.BrowserStat, 0, len(rows))
+		for _, row := range rows {
+			stats = append(stats, models.BrowserStat{Browser: row.Value, Views: row.Views, Unique: row.Visitors, Visitors: row.Visitors, BounceRate: row.BounceRate()})
+		}
+		return stats, nil
+	}
+	return r.topBrowsers.GetTopBrowsers(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Devices Analytics Methods
-func (r *MainAnalyticsRepository) GetTopDevices(ctx context.Context, websiteID string, days int, limit int) ([]models.DeviceStat, error) {
-	return r.topDevices.GetTopDevices(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopDevices(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.DeviceStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		rows, err := r.rollups.GetTopDimension(ctx, websiteID, "device", dateRange, limit)
+		if err != nil {
+			return nil, err
+		}
+		stats := make([]models.DeviceStat, 0, len(rows))
+		for _, row := range rows {
+			stats = append(stats, models.DeviceStat{Device: row.Value, Views: row.Views, Unique: row.Visitors, Visitors: row.Visitors, BounceRate: row.BounceRate()})
+		}
+		return stats, nil
+	}
+	return r.topDevices.GetTopDevices(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top OS Analytics Methods
-func (r *MainAnalyticsRepository) GetTopOS(ctx context.Context, websiteID string, days int, limit int) ([]models.OSStat, error) {
-	return r.topOS.GetTopOS(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopOS(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.OSStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		rows, err := r.rollups.GetTopDimension(ctx, websiteID, "os", dateRange, limit)
+		if err != nil {
+			return nil, err
+		}
+		stats := make([]models.OSStat, 0, len(rows))
+		for _, row := range rows {
+			stats = append(stats, models.OSStat{OS: row.Value, Views: row.Views, Unique: row.Visitors, Visitors: row.Visitors, BounceRate: row.BounceRate()})
+		}
+		return stats, nil
+	}
+	return r.topOS.GetTopOS(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Traffic Summary Analytics Methods
-func (r *MainAnalyticsRepository) GetTrafficSummary(ctx context.Context, websiteID string, days int) (*models.TrafficSummary, error) {
-	return r.trafficSummary.GetTrafficSummary(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetTrafficSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.TrafficSummary, error) {
+	return r.trafficSummary.GetTrafficSummary(ctx, websiteID, dateRange, filters)
 }
 
 // Time Series Analytics Methods
-func (r *MainAnalyticsRepository) GetDailyStats(ctx context.Context, websiteID string, days int) ([]models.DailyStat, error) {
-	fmt.Printf("DEBUG: MainAnalyticsRepository.GetDailyStats called\n")
-	result, err := r.timeSeries.GetDailyStats(ctx, websiteID, days)
-	fmt.Printf("DEBUG: MainAnalyticsRepository.GetDailyStats returning %d results, err: %v\n", len(result), err)
-	return result, err
+func (r *MainAnalyticsRepository) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.DailyStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		return r.rollups.GetDailyStats(ctx, websiteID, dateRange)
+	}
+	return r.timeSeries.GetDailyStats(ctx, websiteID, dateRange, filters)
 }
 
-func (r *MainAnalyticsRepository) GetHourlyStats(ctx context.Context, websiteID string, days int, timezone string) ([]models.HourlyStat, error) {
-	return r.timeSeries.GetHourlyStats(ctx, websiteID, days, timezone)
+func (r *MainAnalyticsRepository) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.HourlyStat, error) {
+	if r.useRollups(ctx, websiteID, dateRange, filters) {
+		return r.rollups.GetHourlyStats(ctx, websiteID, dateRange)
+	}
+	return r.timeSeries.GetHourlyStats(ctx, websiteID, dateRange, filters)
 }
 
 // Custom Events Analytics Methods
-func (r *MainAnalyticsRepository) GetCustomEventStats(ctx context.Context, websiteID string, days int) ([]models.CustomEventStat, error) {
-	return r.customEvents.GetCustomEventStats(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetCustomEventStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.CustomEventStat, error) {
+	return r.customEvents.GetCustomEventStats(ctx, websiteID, dateRange, filters)
+}
+
+// Retention Analytics Methods
+func (r *MainAnalyticsRepository) GetRetention(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, period models.RetentionPeriod, eventType string) ([]models.RetentionCohort, error) {
+	return r.retention.GetRetention(ctx, websiteID, dateRange, filters, period, eventType)
+}
+
+// Path Analytics Methods
+func (r *MainAnalyticsRepository) GetPaths(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, q models.PathQuery) ([]models.PathNode, []models.PathLink, error) {
+	return r.paths.GetPaths(ctx, websiteID, dateRange, filters, q)
+}
+
+// Goal Analytics Methods
+func (r *MainAnalyticsRepository) GetGoalStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, goals []models.Goal) ([]models.GoalStat, error) {
+	return r.goals.GetGoalStats(ctx, websiteID, dateRange, filters, goals)
+}
+
+func (r *MainAnalyticsRepository) GetGoalBreakdown(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, goals []models.Goal, dimension string, limit int) ([]models.GoalBreakdownRow, error) {
+	return r.goals.GetGoalBreakdown(ctx, websiteID, dateRange, filters, goals, dimension, limit)
+}
+
+// Revenue Analytics Methods
+func (r *MainAnalyticsRepository) GetRevenueMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.RevenueMetrics, error) {
+	return r.revenue.GetRevenueMetrics(ctx, websiteID, dateRange, filters)
+}
+
+// Attribution Analytics Methods
+func (r *MainAnalyticsRepository) GetAttribution(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, q models.AttributionQuery, goal *models.Goal) (int, []models.AttributionRow, error) {
+	return r.attribution.GetAttribution(ctx, websiteID, dateRange, filters, q, goal)
+}
+
+// Visitor Analytics Methods
+func (r *MainAnalyticsRepository) GetVisitorProfile(ctx context.Context, websiteID, visitorID string, goals []models.Goal) (*models.VisitorProfile, error) {
+	return r.visitors.GetVisitorProfile(ctx, websiteID, visitorID, goals)
+}
+
+// Realtime Analytics Methods
+func (r *MainAnalyticsRepository) GetRealtimeEvents(ctx context.Context, websiteID string, since time.Time) ([]models.RealtimeEvent, error) {
+	return r.realtime.GetRealtimeEvents(ctx, websiteID, since)
+}
+
+// Alert Analytics Methods
+func (r *MainAnalyticsRepository) GetAlertMetrics(ctx context.Context, websiteID string, dateRange models.DateRange) (*models.AlertMetrics, error) {
+	return r.alerts.GetAlertMetrics(ctx, websiteID, dateRange)
+}
+
+// Channel Analytics Methods
+func (r *MainAnalyticsRepository) GetChannels(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, rules models.ChannelRules) ([]models.ChannelStat, error) {
+	return r.channels.GetChannels(ctx, websiteID, dateRange, filters, rules)
 }
 
 // GetLiveVisitors returns the number of currently active visitors
@@ -140,14 +318,14 @@ func (r *MainAnalyticsRepository) GetLiveVisitors(ctx context.Context, websiteID
 }
 
 // Geolocation Analytics Methods
-func (r *MainAnalyticsRepository) GetTopContinents(ctx context.Context, websiteID string, startDate, endDate time.Time, limit int) ([]models.TopItem, error) {
-	return r.geolocation.GetTopContinents(ctx, websiteID, startDate, endDate, limit)
+func (r *MainAnalyticsRepository) GetTopContinents(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.TopItem, error) {
+	return r.geolocation.GetTopContinents(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (r *MainAnalyticsRepository) GetTopRegions(ctx context.Context, websiteID string, startDate, endDate time.Time, limit int) ([]models.TopItem, error) {
-	return r.geolocation.GetTopRegions(ctx, websiteID, startDate, endDate, limit)
+func (r *MainAnalyticsRepository) GetTopRegions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.TopItem, error) {
+	return r.geolocation.GetTopRegions(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (r *MainAnalyticsRepository) GetGeolocationBreakdown(ctx context.Context, websiteID string, startDate, endDate time.Time) (*models.GeolocationBreakdown, error) {
-	return r.geolocation.GetGeolocationBreakdown(ctx, websiteID, startDate, endDate)
+func (r *MainAnalyticsRepository) GetGeolocationBreakdown(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.GeolocationBreakdown, error) {
+	return r.geolocation.GetGeolocationBreakdown(ctx, websiteID, dateRange, filters)
 }
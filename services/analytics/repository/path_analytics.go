@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PathAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewPathAnalytics(db *pgxpool.Pool) *PathAnalytics {
+	return &PathAnalytics{db: db}
+}
+
+// GetPaths follows sessions from an anchor page, step by step, and returns the pages at
+// each step with the transitions between them. Pages outside a step's top PagesPerStep
+// (by sessions) are folded into a single "other" node. Filters select the sessions, whose
+// pageviews are then all followed, and pages are normalized like the top pages report.
+func (pa *PathAnalytics) GetPaths(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, q models.PathQuery) ([]models.PathNode, []models.PathLink, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	pageArg := qb.Arg(q.Page)
+	stepsArg := qb.Arg(q.Steps)
+	pagesPerStepArg := qb.Arg(q.PagesPerStep)
+	otherArg := qb.Arg(models.PathOtherPage)
+
+	// Forward paths start at the first visit to the page, backward paths end at the last
+	anchor := "MIN(seq)"
+	window := "o.seq BETWEEN a.anchor_seq AND a.anchor_seq + " + stepsArg
+	if q.Direction == models.PathBackward {
+		anchor = "MAX(seq)"
+		window = "o.seq BETWEEN a.anchor_seq - " + stepsArg + " AND a.anchor_seq"
+	}
+
+	query := `
+		WITH sessions AS (
+			SELECT DISTINCT session_id
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
+		), ordered AS (
+			SELECT
+				e.session_id,
+				` + normalizePageSQL("e.page") + ` AS page,
+				ROW_NUMBER() OVER (PARTITION BY e.session_id ORDER BY e.timestamp, e.id) AS seq
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'
+			AND e.session_id IN (SELECT session_id FROM sessions)
+		), anchors AS (
+			SELECT session_id, ` + anchor + ` AS anchor_seq
+			FROM ordered
+			WHERE page = ` + normalizePageSQL(pageArg+"::text") + `
+			GROUP BY session_id
+		), steps AS (
+			SELECT o.session_id, (o.seq - a.anchor_seq)::int AS step, o.page
+			FROM ordered o
+			JOIN anchors a ON a.session_id = o.session_id
+			WHERE ` + window + `
+		), ranked AS (
+			SELECT step, page, ROW_NUMBER() OVER (PARTITION BY step ORDER BY COUNT(*) DESC, page) AS rank
+			FROM steps
+			GROUP BY step, page
+		), folded AS (
+			SELECT s.session_id, s.step, CASE WHEN r.rank <= ` + pagesPerStepArg + ` THEN s.page ELSE ` + otherArg + ` END AS page
+			FROM steps s
+			JOIN ranked r ON r.step = s.step AND r.page = s.page
+		)
+		SELECT step, page, NULL::text AS target, COUNT(*)
+		FROM folded
+		GROUP BY step, page
+		UNION ALL
+		SELECT f1.step, f1.page, f2.page, COUNT(*)
+		FROM folded f1
+		JOIN folded f2 ON f2.session_id = f1.session_id AND f2.step = f1.step + 1
+		GROUP BY f1.step, f1.page, f2.page
+		ORDER BY 1, 4 DESC`
+
+	rows, err := pa.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := []models.PathNode{}
+	links := []models.PathLink{}
+	for rows.Next() {
+		var step, sessions int
+		var page string
+		var target *string
+		if err := rows.Scan(&step, &page, &target, &sessions); err != nil {
+			return nil, nil, fmt.Errorf("scan failed: %w", err)
+		}
+
+		if target == nil {
+			nodes = append(nodes, models.PathNode{
+				ID:       models.PathNodeID(step, page),
+				Step:     step,
+				Page:     page,
+				Sessions: sessions,
+			})
+			continue
+		}
+		links = append(links, models.PathLink{
+			Source:   models.PathNodeID(step, page),
+			Target:   models.PathNodeID(step+1, *target),
+			Sessions: sessions,
+		})
+	}
+
+	return nodes, links, rows.Err()
+}
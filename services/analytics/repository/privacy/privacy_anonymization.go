@@ -60,17 +60,10 @@ func (r *PrivacyRepository) AnonymizeEventsData(userID string) error {
 	userAgentsAnonymized := result.RowsAffected()
 
 	// Anonymize visitor IDs by hashing them (keeping them consistent for analytics)
-	anonymizeVisitorIDQuery := `
-		UPDATE events 
-		SET visitor_id = 'anon_' || SUBSTRING(MD5(visitor_id), 1, 8)
-		WHERE website_id = ANY($1) AND visitor_id IS NOT NULL
-	`
-
-	result, err = r.db.Exec(context.Background(), anonymizeVisitorIDQuery, websiteIDs)
+	visitorIDsAnonymized, err := r.anonymizeVisitorIDs(websiteIDs)
 	if err != nil {
-		return fmt.Errorf("failed to anonymize visitor IDs: %w", err)
+		return err
 	}
-	visitorIDsAnonymized := result.RowsAffected()
 
 	// Anonymize session IDs by hashing them
 	anonymizeSessionIDQuery := `
@@ -94,6 +87,53 @@ func (r *PrivacyRepository) AnonymizeEventsData(userID string) error {
 	return nil
 }
 
+// anonymizeVisitorIDs hashes the websites' visitor IDs in their events. The hourly rollups
+// keep visitor IDs for exact unique counts, so those rows are dropped in the same
+// transaction and their hours queued for the compactor to rebuild from the hashed events.
+// Reports read raw events for queued hours meanwhile.
+func (r *PrivacyRepository) anonymizeVisitorIDs(websiteIDs []string) (int64, error) {
+	ctx := context.Background()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	anonymizeVisitorIDQuery := `
+		UPDATE events 
+		SET visitor_id = 'anon_' || SUBSTRING(MD5(visitor_id), 1, 8)
+		WHERE website_id = ANY($1) AND visitor_id IS NOT NULL
+	`
+
+	result, err := tx.Exec(ctx, anonymizeVisitorIDQuery, websiteIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize visitor IDs: %w", err)
+	}
+
+	// Bumping marked_at keeps a compaction that read the old IDs from clearing the hour
+	requeueQuery := `
+		INSERT INTO analytics_rollup_dirty (website_id, hour, marked_at)
+		SELECT website_id, hour, NOW()
+		FROM analytics_hourly
+		WHERE website_id = ANY($1)
+		ON CONFLICT (website_id, hour) DO UPDATE SET marked_at = EXCLUDED.marked_at
+	`
+	if _, err := tx.Exec(ctx, requeueQuery, websiteIDs); err != nil {
+		return 0, fmt.Errorf("failed to queue rollups for anonymized visitors: %w", err)
+	}
+
+	for _, table := range []string{"analytics_hourly_visitors", "analytics_hourly_dimension_visitors"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+			return 0, fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit visitor anonymization: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
 // AnonymizeAnalyticsData anonymizes analytics data for a specific user
 func (r *PrivacyRepository) AnonymizeAnalyticsData(userID string) error {
 	// Get all websites owned by the user
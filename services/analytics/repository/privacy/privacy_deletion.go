@@ -30,6 +30,13 @@ func (r *PrivacyRepository) DeleteEventsData(userID string) error {
 	rowsAffected := result.RowsAffected()
 	fmt.Printf("Privacy operation: delete_events for user %s - Deleted %d events for %d websites\n", userID, rowsAffected, len(websiteIDs))
 
+	// Events that failed to store keep their full payload in the dead-letter table
+	result, err = r.db.Exec(context.Background(), `DELETE FROM events_dead_letter WHERE website_id = ANY($1)`, websiteIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead-letter events: %w", err)
+	}
+	fmt.Printf("Privacy operation: delete_events for user %s - Deleted %d dead-letter events\n", userID, result.RowsAffected())
+
 	return nil
 }
 
@@ -45,6 +52,13 @@ func (r *PrivacyRepository) DeleteEventsDataForWebsite(websiteID string) error {
 	rowsAffected := result.RowsAffected()
 	fmt.Printf("Privacy operation: delete_events for website %s - Deleted %d events\n", websiteID, rowsAffected)
 
+	// Events that failed to store keep their full payload in the dead-letter table
+	result, err = r.db.Exec(context.Background(), `DELETE FROM events_dead_letter WHERE website_id = $1`, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead-letter events for website %s: %w", websiteID, err)
+	}
+	fmt.Printf("Privacy operation: delete_events for website %s - Deleted %d dead-letter events\n", websiteID, result.RowsAffected())
+
 	return nil
 }
 
@@ -72,9 +86,19 @@ func (r *PrivacyRepository) DeleteAnalyticsData(userID string) error {
 	customEventsDeleted := result.RowsAffected()
 	fmt.Printf("Privacy operation: delete_analytics for user %s - Deleted %d custom events for %d websites\n", userID, customEventsDeleted, len(websiteIDs))
 
+	// Delete hourly rollups
+	for _, table := range rollupTables {
+		if _, err := r.db.Exec(context.Background(), `DELETE FROM `+table+` WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+	}
+
 	return nil
 }
 
+// rollupTables are the hourly rollup tables holding per-website analytics
+var rollupTables = []string{"analytics_hourly", "analytics_hourly_visitors", "analytics_hourly_dimensions", "analytics_hourly_dimension_visitors", "analytics_rollup_dirty"}
+
 // DeleteAnalyticsDataForWebsite deletes all analytics data for a specific website
 func (r *PrivacyRepository) DeleteAnalyticsDataForWebsite(websiteID string) error {
 	// Delete custom events aggregated data
@@ -87,6 +111,13 @@ func (r *PrivacyRepository) DeleteAnalyticsDataForWebsite(websiteID string) erro
 	customEventsDeleted := result.RowsAffected()
 	fmt.Printf("Privacy operation: delete_analytics for website %s - Deleted %d custom events\n", websiteID, customEventsDeleted)
 
+	// Delete hourly rollups
+	for _, table := range rollupTables {
+		if _, err := r.db.Exec(context.Background(), `DELETE FROM `+table+` WHERE website_id = $1`, websiteID); err != nil {
+			return fmt.Errorf("failed to delete %s for website %s: %w", table, websiteID, err)
+		}
+	}
+
 	return nil
 }
 
@@ -127,7 +158,47 @@ func (r *PrivacyRepository) DeleteFunnelData(userID string) error {
 	}
 	funnelsDeleted := result.RowsAffected()
 
-	fmt.Printf("Privacy operation: delete_funnels for user %s - Deleted %d funnels and %d funnel events for %d websites\n", userID, funnelsDeleted, funnelEventsDeleted, len(websiteIDs))
+	// Delete goals
+	result, err = r.db.Exec(context.Background(), `DELETE FROM goals WHERE website_id = ANY($1)`, websiteIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete goals: %w", err)
+	}
+	goalsDeleted := result.RowsAffected()
+
+	// Delete channel rules
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM channel_rules WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete channel rules: %w", err)
+	}
+
+	// Delete experiments
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM experiments WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete experiments: %w", err)
+	}
+
+	// Delete segments
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM segments WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete segments: %w", err)
+	}
+
+	// Delete export jobs; their files are removed by the export sweeper
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM export_jobs WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete export jobs: %w", err)
+	}
+
+	// Delete digest schedules, which hold recipients' email addresses
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM digest_schedules WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete digest schedules: %w", err)
+	}
+
+	// Delete alert rules and their webhook deliveries
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alert_deliveries WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete alert deliveries: %w", err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alert_rules WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete alert rules: %w", err)
+	}
+
+	fmt.Printf("Privacy operation: delete_funnels for user %s - Deleted %d funnels, %d funnel events and %d goals for %d websites\n", userID, funnelsDeleted, funnelEventsDeleted, goalsDeleted, len(websiteIDs))
 
 	return nil
 }
@@ -157,7 +228,47 @@ func (r *PrivacyRepository) DeleteFunnelDataForWebsite(websiteID string) error {
 	}
 	funnelsDeleted := result.RowsAffected()
 
-	fmt.Printf("Privacy operation: delete_funnels for website %s - Deleted %d funnels and %d funnel events\n", websiteID, funnelsDeleted, funnelEventsDeleted)
+	// Delete goals
+	result, err = r.db.Exec(context.Background(), `DELETE FROM goals WHERE website_id = $1`, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to delete goals for website %s: %w", websiteID, err)
+	}
+	goalsDeleted := result.RowsAffected()
+
+	// Delete channel rules
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM channel_rules WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete channel rules for website %s: %w", websiteID, err)
+	}
+
+	// Delete experiments
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM experiments WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete experiments for website %s: %w", websiteID, err)
+	}
+
+	// Delete segments
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM segments WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete segments for website %s: %w", websiteID, err)
+	}
+
+	// Delete export jobs; their files are removed by the export sweeper
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM export_jobs WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete export jobs for website %s: %w", websiteID, err)
+	}
+
+	// Delete digest schedules, which hold recipients' email addresses
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM digest_schedules WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete digest schedules for website %s: %w", websiteID, err)
+	}
+
+	// Delete alert rules and their webhook deliveries
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alert_deliveries WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete alert deliveries for website %s: %w", websiteID, err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alert_rules WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete alert rules for website %s: %w", websiteID, err)
+	}
+
+	fmt.Printf("Privacy operation: delete_funnels for website %s - Deleted %d funnels, %d funnel events and %d goals\n", websiteID, funnelsDeleted, funnelEventsDeleted, goalsDeleted)
 
 	return nil
 }
@@ -113,7 +113,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	// Get basic metrics
 	metricsQuery := `
 		SELECT 
-			COUNT(*) as page_views,
+			COUNT(*) FILTER (WHERE event_type = 'pageview') as page_views,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(DISTINCT session_id) as sessions,
 			ROUND(AVG(CASE WHEN time_on_page IS NOT NULL AND time_on_page > 0 THEN time_on_page END)) as avg_time_on_page
@@ -141,7 +141,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	topPagesQuery := `
 		SELECT page, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1) AND page IS NOT NULL
+		WHERE website_id = ANY($1) AND event_type = 'pageview' AND page IS NOT NULL
 		GROUP BY page 
 		ORDER BY views DESC 
 		LIMIT 50
@@ -172,7 +172,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	topReferrersQuery := `
 		SELECT COALESCE(referrer, 'Direct') as referrer, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(referrer, 'Direct')
 		ORDER BY views DESC 
 		LIMIT 20
@@ -203,7 +203,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	countriesQuery := `
 		SELECT COALESCE(country, 'Unknown') as country, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(country, 'Unknown')
 		ORDER BY views DESC 
 		LIMIT 20
@@ -234,7 +234,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	browsersQuery := `
 		SELECT COALESCE(browser, 'Unknown') as browser, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(browser, 'Unknown')
 		ORDER BY views DESC 
 		LIMIT 15
@@ -265,7 +265,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	devicesQuery := `
 		SELECT COALESCE(device, 'Unknown') as device, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(device, 'Unknown')
 		ORDER BY views DESC 
 		LIMIT 10
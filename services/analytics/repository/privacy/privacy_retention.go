@@ -20,6 +20,13 @@ func (r *PrivacyRepository) CleanupOldEvents() error {
 
 	rowsAffected := result.RowsAffected()
 
+	// The hourly rollups keep visitor IDs for exact unique counts; they go with the events
+	for _, table := range []string{"analytics_hourly_visitors", "analytics_hourly_dimension_visitors"} {
+		if _, err := r.db.Exec(context.Background(), `DELETE FROM `+table+` WHERE hour < $1`, cutoffDate); err != nil {
+			return fmt.Errorf("failed to cleanup old rollup visitors: %w", err)
+		}
+	}
+
 	// Log the cleanup operation
 	r.LogPrivacyOperation("cleanup_old_events", "system", fmt.Sprintf("Cleaned up %d events older than %s", rowsAffected, cutoffDate.Format(time.RFC3339)))
 
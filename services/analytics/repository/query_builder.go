@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"strings"
+)
+
+// Placeholders replaced by QueryBuilder.Build with the compiled report filters.
+// FiltersToken is for unaliased queries on events; FiltersAliasedToken for "events e".
+const (
+	FiltersToken        = "{{filters}}"
+	FiltersAliasedToken = "{{filters:e}}"
+)
+
+// castColumns are filterable columns that are not text and must be cast for comparison
+var castColumns = map[string]bool{
+	"time_on_page": true,
+}
+
+// QueryBuilder collects positional arguments for an analytics query. Every query starts
+// with $1 = website ID, $2 = range start and $3 = range end; extra arguments are added
+// with Arg before Build appends the filter values after them.
+type QueryBuilder struct {
+	args    []interface{}
+	filters models.Filters
+
+	// compiled holds each filter's condition with "%s" in place of the column alias
+	compiled []string
+}
+
+func NewQueryBuilder(websiteID string, dateRange models.DateRange, filters models.Filters) *QueryBuilder {
+	return &QueryBuilder{
+		args:    []interface{}{websiteID, dateRange.Start, dateRange.End},
+		filters: filters,
+	}
+}
+
+// Arg adds a query argument and returns its placeholder
+func (qb *QueryBuilder) Arg(value interface{}) string {
+	qb.args = append(qb.args, value)
+	return fmt.Sprintf("$%d", len(qb.args))
+}
+
+// Args returns the arguments to execute the built query with
+func (qb *QueryBuilder) Args() []interface{} {
+	return qb.args
+}
+
+// Build replaces the filter tokens in query with the compiled filter conditions.
+// Each filter value is bound once, however many times the tokens appear.
+func (qb *QueryBuilder) Build(query string) string {
+	if qb.compiled == nil {
+		qb.compiled = make([]string, 0, len(qb.filters))
+		for _, filter := range qb.filters {
+			qb.compiled = append(qb.compiled, qb.compile(filter))
+		}
+	}
+
+	return strings.NewReplacer(
+		FiltersToken, qb.conditions(""),
+		FiltersAliasedToken, qb.conditions("e."),
+	).Replace(query)
+}
+
+// Match compiles conditions other than the report filters, such as a goal's, into a
+// single boolean expression on the given column alias ("" or "e."). Empty filters match
+// every row.
+func (qb *QueryBuilder) Match(filters models.Filters, alias string) string {
+	if len(filters) == 0 {
+		return "TRUE"
+	}
+	conditions := make([]string, len(filters))
+	for i, filter := range filters {
+		conditions[i] = strings.ReplaceAll(qb.compile(filter), "%s", alias)
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// HasFilters reports whether the query is narrowed by any report filter
+func (qb *QueryBuilder) HasFilters() bool {
+	return len(qb.filters) > 0
+}
+
+func (qb *QueryBuilder) conditions(alias string) string {
+	var sb strings.Builder
+	for _, condition := range qb.compiled {
+		sb.WriteString("\n\t\tAND ")
+		sb.WriteString(strings.ReplaceAll(condition, "%s", alias))
+	}
+	return sb.String()
+}
+
+func (qb *QueryBuilder) compile(filter models.Filter) string {
+	if filter.Dimension == models.ChannelDimension {
+		return qb.compileChannel(filter)
+	}
+	if filter.Dimension == models.SegmentDimension {
+		return qb.compileSegment(filter)
+	}
+
+	var column string
+	if key, ok := filter.PropertyKey(); ok {
+		column = fmt.Sprintf("(%%sproperties->>%s)", qb.Arg(key))
+	} else {
+		name := models.FilterColumns[filter.Dimension]
+		column = "%s" + name
+		if castColumns[name] {
+			column = "(%s" + name + ")::text"
+		}
+	}
+
+	return qb.compare(column, filter.Operator, filter.Value)
+}
+
+// compare compiles a filter operator applied to column
+func (qb *QueryBuilder) compare(column string, operator models.FilterOperator, value string) string {
+	switch operator {
+	case models.FilterNotEquals:
+		return fmt.Sprintf("%s IS DISTINCT FROM %s", column, qb.Arg(value))
+	case models.FilterContains:
+		return fmt.Sprintf("%s ILIKE %s", column, qb.Arg(likePattern(value)))
+	case models.FilterNotContains:
+		return fmt.Sprintf("(%s IS NULL OR %s NOT ILIKE %s)", column, column, qb.Arg(likePattern(value)))
+	case models.FilterRegex:
+		return fmt.Sprintf("%s ~* %s", column, qb.Arg(value))
+	default:
+		return fmt.Sprintf("%s = %s", column, qb.Arg(value))
+	}
+}
+
+// likePattern escapes LIKE wildcards so "contains" matches the value literally
+func likePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
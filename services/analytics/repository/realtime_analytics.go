@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RealtimeAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewRealtimeAnalytics(db *pgxpool.Pool) *RealtimeAnalytics {
+	return &RealtimeAnalytics{db: db}
+}
+
+// GetRealtimeEvents returns what a realtime stream starts from: the last pageview of
+// every visitor seen since the given time and the most recent events, oldest first
+func (ra *RealtimeAnalytics) GetRealtimeEvents(ctx context.Context, websiteID string, since time.Time) ([]models.RealtimeEvent, error) {
+	query := `
+		SELECT id, visitor_id, event_type, COALESCE(page, ''), referrer, country, device, browser, timestamp
+		FROM (
+			(SELECT DISTINCT ON (visitor_id) id, visitor_id, event_type, page, referrer, country, device, browser, timestamp
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2
+			AND event_type = 'pageview'
+			ORDER BY visitor_id, timestamp DESC)
+			UNION ALL
+			(SELECT id, visitor_id, event_type, page, referrer, country, device, browser, timestamp
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2
+			ORDER BY timestamp DESC
+			LIMIT $3)
+		) recent
+		ORDER BY timestamp`
+
+	rows, err := ra.db.Query(ctx, query, websiteID, since, models.RealtimeRecentEvents)
+	if err != nil {
+		return nil, fmt.Errorf("realtime events query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.RealtimeEvent
+	for rows.Next() {
+		var event models.Event
+		err := rows.Scan(
+			&event.ID, &event.VisitorID, &event.EventType, &event.Page, &event.Referrer,
+			&event.Country, &event.Device, &event.Browser, &event.Timestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan realtime event: %w", err)
+		}
+		event.WebsiteID = websiteID
+		events = append(events, models.NewRealtimeEvent(&event))
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RetentionAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewRetentionAnalytics(db *pgxpool.Pool) *RetentionAnalytics {
+	return &RetentionAnalytics{db: db}
+}
+
+// GetRetention groups visitors by the period of their first pageview and counts how many
+// of them had an event of eventType in each period after that, up to the end of the range.
+// Only visitors first seen inside the range form cohorts.
+func (ra *RetentionAnalytics) GetRetention(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, period models.RetentionPeriod, eventType string) ([]models.RetentionCohort, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	periodArg := qb.Arg(string(period))
+	timezoneArg := qb.Arg(dateRange.Timezone)
+	eventTypeArg := qb.Arg(eventType)
+
+	query := `
+		WITH first_seen AS (
+			SELECT visitor_id, MIN(timestamp) AS first_seen
+			FROM events
+			WHERE website_id = $1
+			AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
+			GROUP BY visitor_id
+			HAVING MIN(timestamp) >= $2
+		), cohorts AS (
+			SELECT visitor_id, date_trunc(` + periodArg + `::text, first_seen AT TIME ZONE ` + timezoneArg + `) AS cohort
+			FROM first_seen
+		), activity AS (
+			SELECT DISTINCT e.visitor_id, date_trunc(` + periodArg + `::text, e.timestamp AT TIME ZONE ` + timezoneArg + `) AS period
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = ` + eventTypeArg + `{{filters:e}}
+		)
+		SELECT cohort, NULL::timestamp AS period, COUNT(*)
+		FROM cohorts
+		GROUP BY cohort
+		UNION ALL
+		SELECT c.cohort, a.period, COUNT(*)
+		FROM cohorts c
+		JOIN activity a ON a.visitor_id = c.visitor_id AND a.period >= c.cohort
+		GROUP BY c.cohort, a.period
+		ORDER BY 1, 2 NULLS FIRST`
+
+	rows, err := ra.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	// Local wall-clock times come back as UTC; reinterpret them in the range's timezone
+	loc := dateRange.Location()
+	local := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+	lastPeriod := period.Truncate(dateRange.End.Add(-time.Nanosecond).In(loc))
+
+	var cohorts []models.RetentionCohort
+	var cohortStart time.Time
+	for rows.Next() {
+		var cohort time.Time
+		var activePeriod *time.Time
+		var count int
+		if err := rows.Scan(&cohort, &activePeriod, &count); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		cohort = local(cohort)
+
+		if activePeriod == nil {
+			periods := period.Between(cohort, lastPeriod) + 1
+			if periods < 1 {
+				periods = 1
+			}
+			cohorts = append(cohorts, models.RetentionCohort{
+				Cohort:   cohort.Format("2006-01-02"),
+				Size:     count,
+				Retained: make([]int, periods),
+				Rates:    make([]float64, periods),
+			})
+			cohortStart = cohort
+			continue
+		}
+
+		if len(cohorts) == 0 || !cohort.Equal(cohortStart) {
+			continue
+		}
+		current := &cohorts[len(cohorts)-1]
+		index := period.Between(cohortStart, local(*activePeriod))
+		if index < 0 || index >= len(current.Retained) {
+			continue
+		}
+		current.Retained[index] = count
+		if current.Size > 0 {
+			current.Rates[index] = float64(count) * 100.0 / float64(current.Size)
+		}
+	}
+
+	return cohorts, rows.Err()
+}
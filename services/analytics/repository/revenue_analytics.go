@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ordersCTE selects every order in the date range once, by order ID, keeping the first
+// purchase event sent for it. revenue is the amount in the reporting currency.
+const ordersCTE = `orders AS (
+			SELECT DISTINCT ON (properties->>'order_id')
+				session_id,
+				visitor_id,
+				(properties->>'revenue')::numeric AS revenue
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'purchase'
+			AND properties->>'revenue' IS NOT NULL
+			ORDER BY properties->>'order_id', timestamp
+		)`
+
+// filteredOrderSessions limits "orders o" to sessions with a pageview matching the report
+// filters, so orders are filtered by the traffic that led to them
+const filteredOrderSessions = `
+		AND o.session_id IN (
+			SELECT session_id
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
+		)`
+
+type RevenueAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewRevenueAnalytics(db *pgxpool.Pool) *RevenueAnalytics {
+	return &RevenueAnalytics{db: db}
+}
+
+// GetRevenueMetrics returns revenue, orders, customers and average order value. Revenue
+// per visitor and the currency are left to the caller.
+func (ra *RevenueAnalytics) GetRevenueMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.RevenueMetrics, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
+	query := `
+		WITH ` + ordersCTE + `
+		SELECT
+			COALESCE(SUM(o.revenue), 0)::float8 AS revenue,
+			COUNT(*) AS orders,
+			COUNT(DISTINCT o.visitor_id) AS customers
+		FROM orders o
+		WHERE TRUE`
+	if qb.HasFilters() {
+		query += filteredOrderSessions
+	}
+
+	var metrics models.RevenueMetrics
+	err := ra.db.QueryRow(ctx, qb.Build(query), qb.Args()...).Scan(&metrics.Revenue, &metrics.Orders, &metrics.Customers)
+	if err != nil {
+		return nil, err
+	}
+
+	if metrics.Orders > 0 {
+		metrics.AverageOrderValue = metrics.Revenue / float64(metrics.Orders)
+	}
+
+	return &metrics, nil
+}
+
+// orderTotals is the revenue attributed to one value of a dimension
+type orderTotals struct {
+	Orders  int
+	Revenue float64
+}
+
+// revenueByTouch attributes each order to the value of column on the first pageview of
+// its session, with empty values reported as emptyLabel. column must be an events column.
+func revenueByTouch(ctx context.Context, db *pgxpool.Pool, websiteID string, dateRange models.DateRange, filters models.Filters, column, emptyLabel string) (map[string]orderTotals, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
+	query := `
+		WITH session_touch AS (
+			SELECT DISTINCT ON (session_id)
+				session_id,
+				COALESCE(NULLIF(` + column + `, ''), ` + qb.Arg(emptyLabel) + `) AS value
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
+			ORDER BY session_id, timestamp
+		),
+		` + ordersCTE + `
+		SELECT st.value, COUNT(*), SUM(o.revenue)::float8
+		FROM orders o
+		JOIN session_touch st ON st.session_id = o.session_id
+		GROUP BY st.value`
+
+	rows, err := db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]orderTotals)
+	for rows.Next() {
+		var value string
+		var t orderTotals
+		if err := rows.Scan(&value, &t.Orders, &t.Revenue); err != nil {
+			return nil, err
+		}
+		totals[value] = t
+	}
+
+	return totals, rows.Err()
+}
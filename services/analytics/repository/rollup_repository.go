@@ -0,0 +1,695 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RollupSessionWindow is how far before and after a changed hour the rollup looks for
+// the other pageviews of the sessions in that hour
+const RollupSessionWindow = 4 * time.Hour
+
+// hourSQL buckets an event timestamp into its UTC hour
+const hourSQL = `date_trunc('hour', timestamp AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'`
+
+// rollupPageSQL rolls up p.page normalized, so paths that differ only in query string,
+// trailing slash or origin are one page. Pageviews without a page keep an empty value,
+// which the reports leave out.
+var rollupPageSQL = `CASE WHEN COALESCE(p.page, '') = '' THEN '' ELSE ` + normalizePageSQL("p.page") + ` END`
+
+// rollupDimensionsSQL expands each pageview p into one row per rolled-up dimension
+var rollupDimensionsSQL = `CROSS JOIN LATERAL (VALUES
+	('page', ` + rollupPageSQL + `),
+	('country', COALESCE(NULLIF(p.country, ''), 'Unknown')),
+	('browser', COALESCE(p.browser, 'unknown')),
+	('device', COALESCE(p.device, 'unknown')),
+	('os', COALESCE(p.os, 'unknown'))
+) AS d(dimension, value)`
+
+// edgePageviewsSQL selects the pageviews in the partial hours at the edges of a range,
+// which the rollups do not cover. It expects the website ID in $1, the range in $2 and
+// $5, and its closed hours in $3 and $4.
+const edgePageviewsSQL = `
+	SELECT ` + hourSQL + ` AS hour, timestamp, visitor_id, session_id, time_on_page,
+		page, country, browser, device, os
+	FROM events
+	WHERE website_id = $1
+	AND timestamp >= $2 AND timestamp < $5
+	AND (timestamp < $3 OR timestamp >= $4)
+	AND event_type = 'pageview'`
+
+// edgeSessionsSQL measures the sessions of the edge pageviews the way the rollups do,
+// looking $6 seconds around the range for their other pageviews
+const edgeSessionsSQL = `
+	SELECT
+		session_id,
+		COUNT(*) AS page_count,
+		MIN(timestamp) AS started_at,
+		CASE
+			WHEN COUNT(*) > 1 THEN LEAST(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))), 1800)
+			ELSE COALESCE(MAX(time_on_page), 30)
+		END AS duration
+	FROM events
+	WHERE website_id = $1
+	AND timestamp >= $2 - make_interval(secs => $6) AND timestamp < $5 + make_interval(secs => $6)
+	AND event_type = 'pageview'
+	AND session_id IN (SELECT session_id FROM edge)
+	GROUP BY session_id`
+
+// DirtyHour is an hour of a website's events that changed since it was last rolled up
+type DirtyHour struct {
+	WebsiteID string
+	Hour      time.Time
+	MarkedAt  time.Time
+}
+
+// RollupDimensionStat is the rolled-up traffic for one value of a dimension
+type RollupDimensionStat struct {
+	Value           string
+	Views           int
+	Visitors        int
+	Sessions        int
+	Bounces         int
+	Entries         int
+	TimeOnPageTotal int64
+	TimeOnPageCount int
+}
+
+// BounceRate returns the share of this value's sessions that viewed a single page
+func (s RollupDimensionStat) BounceRate() *float64 {
+	rate := 0.0
+	if s.Sessions > 0 {
+		rate = float64(s.Bounces) * 100.0 / float64(s.Sessions)
+	}
+	if rate > 100.0 {
+		rate = 100.0
+	}
+	return &rate
+}
+
+// EntryRate returns the share of this value's sessions that started with it
+func (s RollupDimensionStat) EntryRate() *float64 {
+	rate := 0.0
+	if s.Sessions > 0 {
+		rate = float64(s.Entries) * 100.0 / float64(s.Sessions)
+	}
+	return &rate
+}
+
+// AvgTime returns the average reported time on page
+func (s RollupDimensionStat) AvgTime() *float64 {
+	avg := 0.0
+	if s.TimeOnPageCount > 0 {
+		avg = float64(s.TimeOnPageTotal) / float64(s.TimeOnPageCount)
+	}
+	return &avg
+}
+
+// RollupRepository maintains and reads the hourly pageview rollups
+type RollupRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRollupRepository(db *pgxpool.Pool) *RollupRepository {
+	return &RollupRepository{db: db}
+}
+
+// ClosedHours returns the whole hours of the range that are over by now. Reports read
+// them from the rollups and the partial hours around them from the raw events.
+func (r *RollupRepository) ClosedHours(dateRange models.DateRange, now time.Time) (time.Time, time.Time) {
+	start := dateRange.Start.Truncate(time.Hour)
+	if start.Before(dateRange.Start) {
+		start = start.Add(time.Hour)
+	}
+	end := dateRange.End.Truncate(time.Hour)
+	if current := now.Truncate(time.Hour); end.After(current) {
+		end = current
+	}
+	return start, end
+}
+
+// Covers reports whether the hourly rollups can answer a report for the range: it must
+// hold at least one closed hour
+func (r *RollupRepository) Covers(dateRange models.DateRange, now time.Time) bool {
+	start, end := r.ClosedHours(dateRange, now)
+	return start.Before(end)
+}
+
+// rangeArgs returns the query arguments for a range read from the rollups: the website
+// ID, the range's start, the start and end of its closed hours, and the range's end
+func (r *RollupRepository) rangeArgs(websiteID string, dateRange models.DateRange) []interface{} {
+	start, end := r.ClosedHours(dateRange, time.Now())
+	return []interface{}{websiteID, dateRange.Start, start, end, dateRange.End}
+}
+
+// MarkDirty queues the hours of the given pageviews for the compactor
+func (r *RollupRepository) MarkDirty(ctx context.Context, events []models.Event) error {
+	type key struct {
+		websiteID string
+		hour      time.Time
+	}
+	seen := make(map[key]bool)
+	var websiteIDs []string
+	var hours []time.Time
+	for _, event := range events {
+		if event.EventType != "pageview" {
+			continue
+		}
+		k := key{websiteID: event.WebsiteID, hour: event.Timestamp.UTC().Truncate(time.Hour)}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		websiteIDs = append(websiteIDs, k.websiteID)
+		hours = append(hours, k.hour)
+	}
+	if len(hours) == 0 {
+		return nil
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO analytics_rollup_dirty (website_id, hour, marked_at)
+		SELECT website_id, hour, NOW()
+		FROM unnest($1::varchar[], $2::timestamptz[]) AS t(website_id, hour)
+		ON CONFLICT (website_id, hour) DO UPDATE SET marked_at = EXCLUDED.marked_at`,
+		websiteIDs, hours)
+	if err != nil {
+		return fmt.Errorf("failed to mark rollup hours dirty: %w", err)
+	}
+	return nil
+}
+
+// TakeDirty returns up to limit hours waiting to be rolled up, oldest first
+func (r *RollupRepository) TakeDirty(ctx context.Context, limit int) ([]DirtyHour, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT website_id, hour, marked_at
+		FROM analytics_rollup_dirty
+		ORDER BY marked_at ASC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var hours []DirtyHour
+	for rows.Next() {
+		var h DirtyHour
+		if err := rows.Scan(&h.WebsiteID, &h.Hour, &h.MarkedAt); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
+
+// ClearDirty removes rolled-up hours from the queue, unless they were marked again since
+// they were taken
+func (r *RollupRepository) ClearDirty(ctx context.Context, hours []DirtyHour) error {
+	if len(hours) == 0 {
+		return nil
+	}
+
+	websiteIDs := make([]string, len(hours))
+	buckets := make([]time.Time, len(hours))
+	markedAt := make([]time.Time, len(hours))
+	for i, h := range hours {
+		websiteIDs[i], buckets[i], markedAt[i] = h.WebsiteID, h.Hour, h.MarkedAt
+	}
+
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM analytics_rollup_dirty d
+		USING unnest($1::varchar[], $2::timestamptz[], $3::timestamptz[]) AS t(website_id, hour, marked_at)
+		WHERE d.website_id = t.website_id
+		AND d.hour = t.hour
+		AND d.marked_at <= t.marked_at`,
+		websiteIDs, buckets, markedAt)
+	return err
+}
+
+// HasPending reports whether any hour that can affect the range's closed hours is still
+// waiting to be rolled up. The current hour is left out: it is queued whenever a
+// pageview arrives, and the next compaction updates the sessions it continues.
+func (r *RollupRepository) HasPending(ctx context.Context, websiteID string, dateRange models.DateRange, now time.Time) (bool, error) {
+	start, end := r.ClosedHours(dateRange, now)
+	end = end.Add(RollupSessionWindow)
+	if current := now.Truncate(time.Hour); end.After(current) {
+		end = current
+	}
+
+	var pending bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM analytics_rollup_dirty
+			WHERE website_id = $1
+			AND hour >= $2 AND hour < $3
+		)`,
+		websiteID, start.Add(-RollupSessionWindow), end).Scan(&pending)
+	return pending, err
+}
+
+// Refresh recomputes a website's rollups for the given hours from the raw events. Hours
+// holding other pageviews of the same sessions are recomputed too, so session-level
+// counts (bounces, entries, duration) stay consistent. Recomputing is idempotent.
+func (r *RollupRepository) Refresh(ctx context.Context, websiteID string, hours []time.Time) error {
+	if len(hours) == 0 {
+		return nil
+	}
+
+	hours, err := r.sessionHours(ctx, websiteID, hours)
+	if err != nil {
+		return fmt.Errorf("failed to expand rollup hours: %w", err)
+	}
+	from, to := hours[0], hours[len(hours)-1].Add(time.Hour)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"analytics_hourly", "analytics_hourly_visitors", "analytics_hourly_dimensions", "analytics_hourly_dimension_visitors"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE website_id = $1 AND hour = ANY($2)`, websiteID, hours); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	args := []interface{}{websiteID, hours, from, to, RollupSessionWindow.Seconds()}
+
+	hourlyQuery := `
+		WITH pageviews AS (
+			SELECT ` + hourSQL + ` AS hour, visitor_id, session_id
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $3 AND timestamp < $4
+			AND event_type = 'pageview'
+		), hour_stats AS (
+			SELECT hour, COUNT(*) AS pageviews, COUNT(DISTINCT visitor_id) AS visitors
+			FROM pageviews
+			WHERE hour = ANY($2)
+			GROUP BY hour
+		), session_stats AS (
+			SELECT
+				date_trunc('hour', MIN(timestamp) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS hour,
+				COUNT(*) AS page_count,
+				CASE
+					WHEN COUNT(*) > 1 THEN LEAST(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))), 1800)
+					ELSE COALESCE(MAX(time_on_page), 30)
+				END AS duration
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $3 - make_interval(secs => $5) AND timestamp < $4 + make_interval(secs => $5)
+			AND event_type = 'pageview'
+			AND session_id IN (SELECT session_id FROM pageviews)
+			GROUP BY session_id
+		)
+		INSERT INTO analytics_hourly (website_id, hour, pageviews, visitors, sessions, bounces, total_duration, updated_at)
+		SELECT $1, h.hour, h.pageviews, h.visitors, COALESCE(s.sessions, 0), COALESCE(s.bounces, 0), COALESCE(s.total_duration, 0), NOW()
+		FROM hour_stats h
+		LEFT JOIN (
+			SELECT hour, COUNT(*) AS sessions, COUNT(*) FILTER (WHERE page_count = 1) AS bounces, SUM(duration * page_count) AS total_duration
+			FROM session_stats
+			GROUP BY hour
+		) s ON s.hour = h.hour`
+
+	visitorsQuery := `
+		INSERT INTO analytics_hourly_visitors (website_id, hour, visitor_id)
+		SELECT DISTINCT $1, ` + hourSQL + `, visitor_id
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $3 AND timestamp < $4
+		AND event_type = 'pageview'
+		AND ` + hourSQL + ` = ANY($2)`
+
+	dimensionsQuery := `
+		WITH pageviews AS (
+			SELECT ` + hourSQL + ` AS hour, timestamp, visitor_id, session_id, time_on_page,
+				page, country, browser, device, os
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $3 AND timestamp < $4
+			AND event_type = 'pageview'
+		), sessions AS (
+			SELECT session_id, COUNT(*) AS page_count, MIN(timestamp) AS started_at
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $3 - make_interval(secs => $5) AND timestamp < $4 + make_interval(secs => $5)
+			AND event_type = 'pageview'
+			AND session_id IN (SELECT session_id FROM pageviews)
+			GROUP BY session_id
+		)
+		INSERT INTO analytics_hourly_dimensions (
+			website_id, hour, dimension, value, pageviews, visitors, sessions,
+			bounces, entries, time_on_page_total, time_on_page_count
+		)
+		SELECT
+			$1, p.hour, d.dimension, d.value,
+			COUNT(*),
+			COUNT(DISTINCT p.visitor_id),
+			COUNT(DISTINCT p.session_id),
+			COUNT(*) FILTER (WHERE s.page_count = 1),
+			COUNT(*) FILTER (WHERE p.timestamp = s.started_at),
+			COALESCE(SUM(p.time_on_page), 0),
+			COUNT(p.time_on_page)
+		FROM pageviews p
+		LEFT JOIN sessions s ON s.session_id = p.session_id
+		` + rollupDimensionsSQL + `
+		WHERE p.hour = ANY($2)
+		GROUP BY p.hour, d.dimension, d.value`
+
+	dimensionVisitorsQuery := `
+		INSERT INTO analytics_hourly_dimension_visitors (website_id, hour, dimension, value, visitor_id)
+		SELECT DISTINCT $1, p.hour, d.dimension, d.value, p.visitor_id
+		FROM (
+			SELECT ` + hourSQL + ` AS hour, visitor_id, page, country, browser, device, os
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $3 AND timestamp < $4
+			AND event_type = 'pageview'
+		) p
+		` + rollupDimensionsSQL + `
+		WHERE p.hour = ANY($2)`
+
+	if _, err := tx.Exec(ctx, hourlyQuery, args...); err != nil {
+		return fmt.Errorf("failed to refresh hourly rollup: %w", err)
+	}
+	if _, err := tx.Exec(ctx, visitorsQuery, args[:4]...); err != nil {
+		return fmt.Errorf("failed to refresh hourly visitors: %w", err)
+	}
+	if _, err := tx.Exec(ctx, dimensionsQuery, args...); err != nil {
+		return fmt.Errorf("failed to refresh dimension rollup: %w", err)
+	}
+	if _, err := tx.Exec(ctx, dimensionVisitorsQuery, args[:4]...); err != nil {
+		return fmt.Errorf("failed to refresh dimension visitors: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// sessionHours returns the given hours plus every hour holding a pageview of a session
+// that has a pageview in one of them, sorted
+func (r *RollupRepository) sessionHours(ctx context.Context, websiteID string, hours []time.Time) ([]time.Time, error) {
+	set := make(map[time.Time]bool, len(hours))
+	from, to := hours[0], hours[0]
+	for _, h := range hours {
+		h = h.UTC().Truncate(time.Hour)
+		set[h] = true
+		if h.Before(from) {
+			from = h
+		}
+		if h.After(to) {
+			to = h
+		}
+	}
+	to = to.Add(time.Hour)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT `+hourSQL+`
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $3 - make_interval(secs => $5) AND timestamp < $4 + make_interval(secs => $5)
+		AND event_type = 'pageview'
+		AND session_id IN (
+			SELECT session_id FROM events
+			WHERE website_id = $1
+			AND timestamp >= $3 AND timestamp < $4
+			AND event_type = 'pageview'
+			AND `+hourSQL+` = ANY($2)
+		)`,
+		websiteID, hours, from, to, RollupSessionWindow.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h time.Time
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		set[h.UTC()] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	expanded := make([]time.Time, 0, len(set))
+	for h := range set {
+		expanded = append(expanded, h)
+	}
+	sort.Slice(expanded, func(i, j int) bool { return expanded[i].Before(expanded[j]) })
+	return expanded, nil
+}
+
+// GetDashboardMetrics returns the main dashboard metrics from the rollups
+func (r *RollupRepository) GetDashboardMetrics(ctx context.Context, websiteID string, dateRange models.DateRange) (*models.DashboardMetrics, error) {
+	query := `
+		WITH edge AS (` + edgePageviewsSQL + `
+		), edge_sessions AS (` + edgeSessionsSQL + `
+		), totals AS (
+			SELECT pageviews, sessions, bounces, total_duration
+			FROM analytics_hourly
+			WHERE website_id = $1
+			AND hour >= $3 AND hour < $4
+			UNION ALL
+			SELECT (SELECT COUNT(*) FROM edge), 0, 0, 0
+			UNION ALL
+			-- Sessions count in the hour of their first pageview, as in the rollups
+			SELECT 0, COUNT(*), COUNT(*) FILTER (WHERE page_count = 1), COALESCE(SUM(duration * page_count), 0)
+			FROM edge_sessions
+			WHERE started_at >= $2 AND started_at < $5
+			AND (started_at < $3 OR started_at >= $4)
+		)
+		SELECT
+			COALESCE(SUM(pageviews), 0),
+			COALESCE(SUM(sessions), 0),
+			COALESCE(SUM(bounces), 0),
+			COALESCE(SUM(total_duration), 0),
+			(
+				SELECT COUNT(DISTINCT visitor_id)
+				FROM (
+					SELECT visitor_id
+					FROM analytics_hourly_visitors
+					WHERE website_id = $1
+					AND hour >= $3 AND hour < $4
+					UNION ALL
+					SELECT visitor_id FROM edge
+				) v
+			)
+		FROM totals`
+
+	var metrics models.DashboardMetrics
+	var bounces int
+	var totalDuration float64
+	args := append(r.rangeArgs(websiteID, dateRange), RollupSessionWindow.Seconds())
+	err := r.db.QueryRow(ctx, query, args...).Scan(
+		&metrics.PageViews, &metrics.Sessions, &bounces, &totalDuration, &metrics.UniqueVisitors,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.TotalVisitors = metrics.Sessions
+	if metrics.Sessions > 0 {
+		metrics.BounceRate = float64(bounces) * 100.0 / float64(metrics.Sessions)
+		metrics.PagesPerSession = float64(metrics.PageViews) / float64(metrics.Sessions)
+	}
+	if metrics.PageViews > 0 {
+		metrics.AvgSessionTime = totalDuration / float64(metrics.PageViews)
+	}
+	if metrics.BounceRate > 100.0 {
+		metrics.BounceRate = 100.0
+	}
+
+	return &metrics, nil
+}
+
+// GetDailyStats returns daily pageviews and unique visitors from the rollups, with days
+// bucketed in the range's timezone
+func (r *RollupRepository) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange) ([]models.DailyStat, error) {
+	query := `
+		WITH edge AS (` + edgePageviewsSQL + `
+		), views AS (
+			SELECT date, SUM(views) AS views
+			FROM (
+				SELECT DATE(hour AT TIME ZONE $6)::text AS date, SUM(pageviews) AS views
+				FROM analytics_hourly
+				WHERE website_id = $1
+				AND hour >= $3 AND hour < $4
+				GROUP BY 1
+				UNION ALL
+				SELECT DATE(timestamp AT TIME ZONE $6)::text, COUNT(*)
+				FROM edge
+				GROUP BY 1
+			) v
+			GROUP BY date
+		), visitors AS (
+			SELECT date, COUNT(DISTINCT visitor_id) AS unique_visitors
+			FROM (
+				SELECT DATE(hour AT TIME ZONE $6)::text AS date, visitor_id
+				FROM analytics_hourly_visitors
+				WHERE website_id = $1
+				AND hour >= $3 AND hour < $4
+				UNION ALL
+				SELECT DATE(timestamp AT TIME ZONE $6)::text, visitor_id
+				FROM edge
+			) v
+			GROUP BY date
+		)
+		SELECT v.date, v.views, COALESCE(u.unique_visitors, 0)
+		FROM views v
+		LEFT JOIN visitors u USING (date)
+		ORDER BY v.date DESC`
+
+	args := append(r.rangeArgs(websiteID, dateRange), dateRange.Timezone)
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.DailyStat
+	for rows.Next() {
+		var stat models.DailyStat
+		if err := rows.Scan(&stat.Date, &stat.Views, &stat.Unique); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetHourlyStats returns hourly pageviews and unique visitors from the rollups
+func (r *RollupRepository) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange) ([]models.HourlyStat, error) {
+	query := `
+		WITH edge AS (` + edgePageviewsSQL + `
+		)
+		SELECT hour, pageviews, visitors
+		FROM analytics_hourly
+		WHERE website_id = $1
+		AND hour >= $3 AND hour < $4
+		UNION ALL
+		SELECT hour, COUNT(*), COUNT(DISTINCT visitor_id)
+		FROM edge
+		GROUP BY hour
+		ORDER BY hour ASC`
+
+	rows, err := r.db.Query(ctx, query, r.rangeArgs(websiteID, dateRange)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loc := dateRange.Location()
+
+	var stats []models.HourlyStat
+	for rows.Next() {
+		var stat models.HourlyStat
+		var hour time.Time
+		if err := rows.Scan(&hour, &stat.Views, &stat.Unique); err != nil {
+			return nil, err
+		}
+
+		localTime := hour.In(loc)
+		stat.Timestamp = localTime
+		stat.Hour = fmt.Sprintf("%d", localTime.Hour())
+		stat.HourLabel = localTime.Format("15:04")
+		stats = append(stats, stat)
+	}
+
+	return stats, rows.Err()
+}
+
+// GetTopDimension returns the top values of a rolled-up dimension (page, country,
+// browser, device or os). Pages are ranked by views, everything else by visitors.
+// Visitors are counted distinct over the whole range.
+func (r *RollupRepository) GetTopDimension(ctx context.Context, websiteID, dimension string, dateRange models.DateRange, limit int) ([]RollupDimensionStat, error) {
+	query := `
+		WITH edge AS (` + edgePageviewsSQL + `
+		), edge_sessions AS (` + edgeSessionsSQL + `
+		), edge_values AS (
+			SELECT d.value, p.timestamp, p.visitor_id, p.session_id, p.time_on_page, s.page_count, s.started_at
+			FROM edge p
+			LEFT JOIN edge_sessions s ON s.session_id = p.session_id
+			` + rollupDimensionsSQL + `
+			WHERE d.dimension = $7
+		), counts AS (
+			SELECT
+				value,
+				SUM(pageviews) AS views,
+				SUM(sessions) AS sessions,
+				SUM(bounces) AS bounces,
+				SUM(entries) AS entries,
+				SUM(time_on_page_total)::bigint AS time_on_page_total,
+				SUM(time_on_page_count) AS time_on_page_count
+			FROM (
+				SELECT value, pageviews, sessions, bounces, entries, time_on_page_total, time_on_page_count
+				FROM analytics_hourly_dimensions
+				WHERE website_id = $1
+				AND hour >= $3 AND hour < $4
+				AND dimension = $7
+				UNION ALL
+				SELECT
+					value,
+					COUNT(*),
+					COUNT(DISTINCT session_id),
+					COUNT(*) FILTER (WHERE page_count = 1),
+					COUNT(*) FILTER (WHERE timestamp = started_at),
+					COALESCE(SUM(time_on_page), 0),
+					COUNT(time_on_page)
+				FROM edge_values
+				GROUP BY value
+			) c
+			GROUP BY value
+		), visitors AS (
+			SELECT value, COUNT(DISTINCT visitor_id) AS visitors
+			FROM (
+				SELECT value, visitor_id
+				FROM analytics_hourly_dimension_visitors
+				WHERE website_id = $1
+				AND hour >= $3 AND hour < $4
+				AND dimension = $7
+				UNION ALL
+				SELECT value, visitor_id FROM edge_values
+			) v
+			GROUP BY value
+		)
+		SELECT
+			c.value,
+			c.views,
+			COALESCE(v.visitors, 0),
+			c.sessions,
+			c.bounces,
+			c.entries,
+			c.time_on_page_total,
+			c.time_on_page_count
+		FROM counts c
+		LEFT JOIN visitors v USING (value)
+		WHERE c.value <> ''
+		ORDER BY CASE WHEN $7 = 'page' THEN c.views ELSE COALESCE(v.visitors, 0) END DESC
+		LIMIT $8`
+
+	args := append(r.rangeArgs(websiteID, dateRange), RollupSessionWindow.Seconds(), dimension, limit)
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []RollupDimensionStat
+	for rows.Next() {
+		var s RollupDimensionStat
+		err := rows.Scan(&s.Value, &s.Views, &s.Visitors, &s.Sessions, &s.Bounces, &s.Entries, &s.TimeOnPageTotal, &s.TimeOnPageCount)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SegmentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSegmentRepository(db *pgxpool.Pool) *SegmentRepository {
+	return &SegmentRepository{db: db}
+}
+
+const segmentColumns = `id, website_id, user_id, name, description, scope, filters, created_at, updated_at`
+
+func (r *SegmentRepository) Create(ctx context.Context, segment *models.Segment) error {
+	segment.ID = uuid.New()
+	segment.CreatedAt = time.Now()
+	segment.UpdatedAt = time.Now()
+
+	filters, err := json.Marshal(segment.Filters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal segment filters: %w", err)
+	}
+
+	query := `
+		INSERT INTO segments (` + segmentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = r.db.Exec(ctx, query,
+		segment.ID, segment.WebsiteID, segment.UserID, segment.Name, segment.Description,
+		segment.Scope, filters, segment.CreatedAt, segment.UpdatedAt,
+	)
+
+	return err
+}
+
+// GetByWebsiteID returns a website's segments in name order
+func (r *SegmentRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.Segment, error) {
+	query := `
+		SELECT ` + segmentColumns + `
+		FROM segments
+		WHERE website_id = $1
+		ORDER BY name ASC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []models.Segment{}
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, *segment)
+	}
+
+	return segments, rows.Err()
+}
+
+func (r *SegmentRepository) GetByID(ctx context.Context, segmentID uuid.UUID) (*models.Segment, error) {
+	query := `
+		SELECT ` + segmentColumns + `
+		FROM segments
+		WHERE id = $1`
+
+	return scanSegment(r.db.QueryRow(ctx, query, segmentID))
+}
+
+func (r *SegmentRepository) Update(ctx context.Context, segmentID uuid.UUID, segment *models.Segment) error {
+	segment.UpdatedAt = time.Now()
+
+	filters, err := json.Marshal(segment.Filters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal segment filters: %w", err)
+	}
+
+	query := `
+		UPDATE segments
+		SET name = $2, description = $3, scope = $4, filters = $5, updated_at = $6
+		WHERE id = $1`
+
+	_, err = r.db.Exec(ctx, query,
+		segmentID, segment.Name, segment.Description, segment.Scope, filters, segment.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *SegmentRepository) Delete(ctx context.Context, segmentID uuid.UUID) error {
+	query := `DELETE FROM segments WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, segmentID)
+	return err
+}
+
+func scanSegment(row pgx.Row) (*models.Segment, error) {
+	var segment models.Segment
+	var filtersJSON []byte
+
+	err := row.Scan(
+		&segment.ID, &segment.WebsiteID, &segment.UserID, &segment.Name, &segment.Description,
+		&segment.Scope, &filtersJSON, &segment.CreatedAt, &segment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(filtersJSON, &segment.Filters); err != nil {
+		return nil, err
+	}
+
+	return &segment, nil
+}
+
+// compileSegment compiles a segment filter: events match when their session or visitor
+// has an event in the report's period matching all of the segment's filters
+func (qb *QueryBuilder) compileSegment(filter models.Filter) string {
+	segment := filter.Segment
+	if segment == nil {
+		return "FALSE"
+	}
+
+	column := segment.Column()
+	return `%s` + column + ` IN (
+			SELECT ` + column + `
+			FROM events
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND ` + qb.Match(segment.Filters, "") + `
+		)`
+}
@@ -17,26 +17,24 @@ func NewTimeSeriesAnalytics(db *pgxpool.Pool) *TimeSeriesAnalytics {
 	return &TimeSeriesAnalytics{db: db}
 }
 
-// GetDailyStats returns daily statistics for a website
-func (ts *TimeSeriesAnalytics) GetDailyStats(ctx context.Context, websiteID string, days int) ([]models.DailyStat, error) {
-	// Use a working query pattern similar to hourly stats
+// GetDailyStats returns daily statistics for a website, with days bucketed in the range's timezone
+func (ts *TimeSeriesAnalytics) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.DailyStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(dateRange.Timezone)
+
 	query := `
 		SELECT 
-			DATE(timestamp)::text as date,
+			DATE(timestamp AT TIME ZONE $4)::text as date,
 			COUNT(*) as views,
 			COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
-		GROUP BY DATE(timestamp)
-		ORDER BY date DESC
-		LIMIT %d`
-
-	// Format the query with days parameter
-	formattedQuery := fmt.Sprintf(query, days, days)
-	
-	rows, err := ts.db.Query(ctx, formattedQuery, websiteID)
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
+		GROUP BY DATE(timestamp AT TIME ZONE $4)
+		ORDER BY date DESC`
+
+	rows, err := ts.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -62,46 +60,43 @@ func (ts *TimeSeriesAnalytics) GetDailyStats(ctx context.Context, websiteID stri
 	return stats, nil
 }
 
-// GetHourlyStats returns hourly statistics for a website
-func (ts *TimeSeriesAnalytics) GetHourlyStats(ctx context.Context, websiteID string, days int, timezone string) ([]models.HourlyStat, error) {
+// GetHourlyStats returns hourly statistics for a website, with hours bucketed in the range's timezone.
+// Callers keep the range within models.MaxHourlyRangeDays.
+func (ts *TimeSeriesAnalytics) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.HourlyStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(dateRange.Timezone)
+
 	query := `
 		SELECT 
-			EXTRACT(HOUR FROM timestamp)::integer as hour,
-			DATE_TRUNC('hour', timestamp) as timestamp,
+			DATE_TRUNC('hour', timestamp AT TIME ZONE $4) AT TIME ZONE $4 as hour_start,
 			COUNT(*) as views,
 			COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND event_type = 'pageview'
-		GROUP BY DATE_TRUNC('hour', timestamp), EXTRACT(HOUR FROM timestamp)
-		ORDER BY timestamp ASC
-		LIMIT LEAST($2 * 24, 24*30)`
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
+		GROUP BY hour_start
+		ORDER BY hour_start ASC`
 
-	rows, err := ts.db.Query(ctx, query, websiteID, days)
+	rows, err := ts.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Load timezone
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		// Fallback to UTC if timezone is invalid
-		loc = time.UTC
-	}
+	loc := dateRange.Location()
 
 	var stats []models.HourlyStat
 	for rows.Next() {
 		var stat models.HourlyStat
 		var timestamp time.Time
 		var uniqueVisitors int
-		err := rows.Scan(&stat.Hour, &timestamp, &stat.Views, &uniqueVisitors)
+		err := rows.Scan(&timestamp, &stat.Views, &uniqueVisitors)
 		if err != nil {
 			continue
 		}
 
-		// Convert UTC timestamp to user's timezone
+		// Present the bucket in the user's timezone
 		localTime := timestamp.In(loc)
 		stat.Timestamp = localTime
 		stat.Unique = uniqueVisitors
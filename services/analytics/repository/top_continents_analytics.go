@@ -4,7 +4,6 @@ import (
 	"analytics-app/models"
 	"context"
 	"database/sql"
-	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -20,7 +19,10 @@ func NewTopGeolocationAnalytics(db *pgxpool.Pool) *TopGeolocationAnalytics {
 }
 
 // GetTopContinents returns the top continents by visitor count for a website
-func (r *TopGeolocationAnalytics) GetTopContinents(ctx context.Context, websiteID string, startDate, endDate time.Time, limit int) ([]models.TopItem, error) {
+func (r *TopGeolocationAnalytics) GetTopContinents(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.TopItem, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		SELECT 
 			COALESCE(continent, 'Unknown') as name,
@@ -29,14 +31,14 @@ func (r *TopGeolocationAnalytics) GetTopContinents(ctx context.Context, websiteI
 		FROM events 
 		WHERE website_id = $1 
 			AND timestamp >= $2 
-			AND timestamp <= $3
-			AND event_type = 'pageview'
+			AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
 		GROUP BY continent
 		ORDER BY count DESC
 		LIMIT $4
 	`
 
-	rows, err := r.db.Query(ctx, query, websiteID, startDate, endDate, limit)
+	rows, err := r.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +65,10 @@ func (r *TopGeolocationAnalytics) GetTopContinents(ctx context.Context, websiteI
 }
 
 // GetTopRegions returns the top regions by visitor count for a website
-func (r *TopGeolocationAnalytics) GetTopRegions(ctx context.Context, websiteID string, startDate, endDate time.Time, limit int) ([]models.TopItem, error) {
+func (r *TopGeolocationAnalytics) GetTopRegions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.TopItem, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		SELECT 
 			COALESCE(region, 'Unknown') as name,
@@ -72,14 +77,14 @@ func (r *TopGeolocationAnalytics) GetTopRegions(ctx context.Context, websiteID s
 		FROM events 
 		WHERE website_id = $1 
 			AND timestamp >= $2 
-			AND timestamp <= $3
-			AND event_type = 'pageview'
+			AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
 		GROUP BY region
 		ORDER BY count DESC
 		LIMIT $4
 	`
 
-	rows, err := r.db.Query(ctx, query, websiteID, startDate, endDate, limit)
+	rows, err := r.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,7 +111,10 @@ func (r *TopGeolocationAnalytics) GetTopRegions(ctx context.Context, websiteID s
 }
 
 // GetTopCountries returns the top countries by visitor count for a website
-func (r *TopGeolocationAnalytics) GetTopCountries(ctx context.Context, websiteID string, startDate, endDate time.Time, limit int) ([]models.TopItem, error) {
+func (r *TopGeolocationAnalytics) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.TopItem, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		SELECT 
 			COALESCE(country, 'Unknown') as name,
@@ -115,14 +123,14 @@ func (r *TopGeolocationAnalytics) GetTopCountries(ctx context.Context, websiteID
 		FROM events 
 		WHERE website_id = $1 
 			AND timestamp >= $2 
-			AND timestamp <= $3
-			AND event_type = 'pageview'
+			AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
 		GROUP BY country
 		ORDER BY count DESC
 		LIMIT $4
 	`
 
-	rows, err := r.db.Query(ctx, query, websiteID, startDate, endDate, limit)
+	rows, err := r.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -149,27 +157,27 @@ func (r *TopGeolocationAnalytics) GetTopCountries(ctx context.Context, websiteID
 }
 
 // GetGeolocationBreakdown returns comprehensive geolocation analytics
-func (r *TopGeolocationAnalytics) GetGeolocationBreakdown(ctx context.Context, websiteID string, startDate, endDate time.Time) (*models.GeolocationBreakdown, error) {
+func (r *TopGeolocationAnalytics) GetGeolocationBreakdown(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.GeolocationBreakdown, error) {
 	// Get top countries
-	countries, err := r.GetTopCountries(ctx, websiteID, startDate, endDate, 10)
+	countries, err := r.GetTopCountries(ctx, websiteID, dateRange, filters, 10)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get top continents
-	continents, err := r.GetTopContinents(ctx, websiteID, startDate, endDate, 10)
+	continents, err := r.GetTopContinents(ctx, websiteID, dateRange, filters, 10)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get top regions
-	regions, err := r.GetTopRegions(ctx, websiteID, startDate, endDate, 10)
+	regions, err := r.GetTopRegions(ctx, websiteID, dateRange, filters, 10)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get top cities
-	cities, err := r.GetTopCities(ctx, websiteID, startDate, endDate, 10)
+	cities, err := r.GetTopCities(ctx, websiteID, dateRange, filters, 10)
 	if err != nil {
 		return nil, err
 	}
@@ -183,7 +191,10 @@ func (r *TopGeolocationAnalytics) GetGeolocationBreakdown(ctx context.Context, w
 }
 
 // GetTopCities returns the top cities by visitor count for a website
-func (r *TopGeolocationAnalytics) GetTopCities(ctx context.Context, websiteID string, startDate, endDate time.Time, limit int) ([]models.TopItem, error) {
+func (r *TopGeolocationAnalytics) GetTopCities(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.TopItem, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		SELECT 
 			COALESCE(city, 'Unknown') as name,
@@ -192,14 +203,14 @@ func (r *TopGeolocationAnalytics) GetTopCities(ctx context.Context, websiteID st
 		FROM events 
 		WHERE website_id = $1 
 			AND timestamp >= $2 
-			AND timestamp <= $3
-			AND event_type = 'pageview'
+			AND timestamp < $3
+			AND event_type = 'pageview'{{filters}}
 		GROUP BY city
 		ORDER BY count DESC
 		LIMIT $4
 	`
 
-	rows, err := r.db.Query(ctx, query, websiteID, startDate, endDate, limit)
+	rows, err := r.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
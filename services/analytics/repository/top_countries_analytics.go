@@ -16,7 +16,10 @@ func NewTopCountriesAnalytics(db *pgxpool.Pool) *TopCountriesAnalytics {
 }
 
 // GetTopCountries returns the top countries for a website with analytics
-func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID string, days int, limit int) ([]models.CountryStat, error) {
+func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.CountryStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -24,7 +27,7 @@ func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -39,13 +42,13 @@ func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}
 		GROUP BY e.country
 		ORDER BY unique_visitors DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tc.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tc.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -15,7 +15,10 @@ func NewTopDevicesAnalytics(db *pgxpool.Pool) *TopDevicesAnalytics {
 }
 
 // GetTopDevices returns the top devices for a website with analytics
-func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID string, days int, limit int) ([]models.DeviceStat, error) {
+func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.DeviceStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -23,7 +26,7 @@ func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID stri
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -38,13 +41,13 @@ func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID stri
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}
 		GROUP BY e.device
 		ORDER BY unique_visitors DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := td.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := td.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
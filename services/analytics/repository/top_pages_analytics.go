@@ -4,6 +4,7 @@ import (
 	"analytics-app/models"
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -17,6 +18,14 @@ func NewTopPagesAnalytics(db *pgxpool.Pool) *TopPagesAnalytics {
 	return &TopPagesAnalytics{db: db}
 }
 
+// normalizePageSQL is the SQL counterpart of normalizePage, for queries that must count
+// normalized pages before they are limited
+func normalizePageSQL(column string) string {
+	return `COALESCE(NULLIF(rtrim(regexp_replace(
+		regexp_replace(split_part(rtrim(btrim(` + column + `, E' \t\r\n'), '/'), '?', 1), '^https?://[^/]*', ''),
+		'^([^/]|$)', '/\1'), '/'), ''), '/')`
+}
+
 // normalizePage normalizes page paths to eliminate duplicates
 func (tp *TopPagesAnalytics) normalizePage(page string) string {
 	if page == "" {
@@ -62,7 +71,10 @@ func (tp *TopPagesAnalytics) normalizePage(page string) string {
 }
 
 // GetTopPages returns the top pages for a website with analytics
-func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
+func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.PageStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -70,7 +82,7 @@ func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string,
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -96,14 +108,14 @@ func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string,
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}
 		AND e.page IS NOT NULL
 		GROUP BY e.page
 		ORDER BY views DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tp.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tp.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -176,7 +188,10 @@ func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string,
 }
 
 // GetPageUTMBreakdown returns UTM parameter breakdown for a specific page
-func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, days int) (map[string]interface{}, error) {
+func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, dateRange models.DateRange, filters models.Filters) (map[string]interface{}, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(pagePath)
+
 	query := `
 		SELECT 
 			COALESCE(utm_source, 'direct') as source,
@@ -188,18 +203,18 @@ func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID,
 		WHERE website_id = $1 
 		AND (
 			CASE 
-				WHEN $2 LIKE '%?%' THEN 
-					page LIKE $2 || '%'
+				WHEN $4 LIKE '%?%' THEN 
+					page LIKE $4 || '%'
 				ELSE 
-					page = $2 OR page LIKE $2 || '?%'
+					page = $4 OR page LIKE $4 || '?%'
 				END
 		)
-		AND timestamp >= NOW() - INTERVAL '1 day' * $3
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
 		GROUP BY utm_source, utm_medium, utm_campaign
 		ORDER BY visits DESC`
 
-	rows, err := tp.db.Query(ctx, query, websiteID, pagePath, days)
+	rows, err := tp.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -275,7 +290,10 @@ func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID,
 }
 
 // GetTopPagesWithTimeBucket returns top pages with time-bucket aggregation for better performance
-func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
+func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.PageStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -283,7 +301,7 @@ func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, webs
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= time_bucket('1 day', NOW()) - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -309,14 +327,14 @@ func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, webs
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= time_bucket('1 day', NOW()) - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}
 		AND e.page IS NOT NULL
 		GROUP BY e.page
 		ORDER BY views DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tp.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tp.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -381,3 +399,155 @@ func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, webs
 
 	return pages, nil
 }
+
+// GetEntryPages returns the pages sessions started on, ranked by entries
+func (tp *TopPagesAnalytics) GetEntryPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.EntryExitPageStat, error) {
+	return tp.getEntryExitPages(ctx, websiteID, dateRange, filters, limit, "entries")
+}
+
+// GetExitPages returns the pages sessions ended on, ranked by exits
+func (tp *TopPagesAnalytics) GetExitPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.EntryExitPageStat, error) {
+	return tp.getEntryExitPages(ctx, websiteID, dateRange, filters, limit, "exits")
+}
+
+// getEntryExitPages ranks pages by entries or exits. Each session's first and last pageview
+// is found over all of its pageviews in the range; filters only select which pageviews
+// are counted. Pages are normalized before they are counted and limited.
+func (tp *TopPagesAnalytics) getEntryExitPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int, rankBy string) ([]models.EntryExitPageStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
+	rankCondition := "s.seq = 1"
+	if rankBy == "exits" {
+		rankCondition = "s.seq = s.page_count"
+	}
+
+	query := `
+		WITH session_pages AS (
+			SELECT 
+				id,
+				timestamp,
+				ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY timestamp, id) as seq,
+				COUNT(*) OVER (PARTITION BY session_id) as page_count
+			FROM events
+			WHERE website_id = $1 
+			AND timestamp >= $2 AND timestamp < $3
+			AND event_type = 'pageview'
+		)
+		SELECT 
+			` + normalizePageSQL("COALESCE(e.page, '')") + ` as page,
+			COUNT(*) FILTER (WHERE s.seq = 1) as entries,
+			COUNT(*) FILTER (WHERE s.seq = s.page_count) as exits,
+			COUNT(*) as views,
+			COUNT(DISTINCT e.visitor_id) as unique_visitors,
+			COUNT(*) FILTER (WHERE s.page_count = 1) as bounces,
+			COALESCE(SUM(e.time_on_page), 0)::bigint as time_on_page_total,
+			COUNT(e.time_on_page) as time_on_page_count
+		FROM events e
+		INNER JOIN session_pages s ON s.id = e.id AND s.timestamp = e.timestamp
+		WHERE e.website_id = $1 
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}
+		GROUP BY 1
+		HAVING COUNT(*) FILTER (WHERE ` + rankCondition + `) > 0
+		ORDER BY ` + rankBy + ` DESC
+		LIMIT $4`
+
+	rows, err := tp.db.Query(ctx, qb.Build(query), qb.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []EntryExitPageTotals
+	for rows.Next() {
+		var t EntryExitPageTotals
+		err := rows.Scan(&t.Page, &t.Entries, &t.Exits, &t.Views, &t.Unique,
+			&t.Bounces, &t.TimeOnPageTotal, &t.TimeOnPageCount)
+		if err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tp.SummarizeEntryExitPages(totals, rankBy), nil
+}
+
+// EntryExitPageTotals are the counts of one page in the entry and exit page reports
+type EntryExitPageTotals struct {
+	Page            string
+	Entries         int
+	Exits           int
+	Views           int
+	Unique          int
+	Bounces         int
+	TimeOnPageTotal int64
+	TimeOnPageCount int
+}
+
+// SummarizeEntryExitPages merges totals whose pages normalize the same, computes their
+// rates and ranks them by rankBy ("entries" or "exits"). Counts are summed before the
+// rates are computed, so a merged page's rates are weighted by its counts. Rows from
+// getEntryExitPages are already normalized and only merge where the SQL differs.
+func (tp *TopPagesAnalytics) SummarizeEntryExitPages(totals []EntryExitPageTotals, rankBy string) []models.EntryExitPageStat {
+	pageMap := make(map[string]*EntryExitPageTotals)
+	var order []string
+	for _, t := range totals {
+		t.Page = tp.normalizePage(t.Page)
+
+		existing, exists := pageMap[t.Page]
+		if !exists {
+			merged := t
+			pageMap[t.Page] = &merged
+			order = append(order, t.Page)
+			continue
+		}
+		existing.Entries += t.Entries
+		existing.Exits += t.Exits
+		existing.Views += t.Views
+		existing.Unique += t.Unique
+		existing.Bounces += t.Bounces
+		existing.TimeOnPageTotal += t.TimeOnPageTotal
+		existing.TimeOnPageCount += t.TimeOnPageCount
+	}
+
+	pages := make([]models.EntryExitPageStat, 0, len(order))
+	for _, page := range order {
+		t := pageMap[page]
+		bounceRate, exitRate, avgTime := 0.0, 0.0, 0.0
+		if t.Entries > 0 {
+			bounceRate = float64(t.Bounces) * 100.0 / float64(t.Entries)
+		}
+		if bounceRate > 100.0 {
+			bounceRate = 100.0
+		}
+		if t.Views > 0 {
+			exitRate = float64(t.Exits) * 100.0 / float64(t.Views)
+		}
+		if t.TimeOnPageCount > 0 {
+			avgTime = float64(t.TimeOnPageTotal) / float64(t.TimeOnPageCount)
+		}
+		pages = append(pages, models.EntryExitPageStat{
+			Page:       t.Page,
+			Entries:    t.Entries,
+			Exits:      t.Exits,
+			Views:      t.Views,
+			Unique:     t.Unique,
+			BounceRate: &bounceRate,
+			ExitRate:   &exitRate,
+			AvgTime:    &avgTime,
+		})
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		if rankBy == "exits" {
+			return pages[i].Exits > pages[j].Exits
+		}
+		return pages[i].Entries > pages[j].Entries
+	})
+
+	return pages
+}
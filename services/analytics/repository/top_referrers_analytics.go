@@ -16,7 +16,10 @@ func NewTopReferrersAnalytics(db *pgxpool.Pool) *TopReferrersAnalytics {
 }
 
 // GetTopReferrers returns the top referrers for a website with analytics
-func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID string, days int, limit int) ([]models.ReferrerStat, error) {
+func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.ReferrerStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -24,7 +27,7 @@ func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		),
@@ -64,8 +67,8 @@ func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID
 				e.timestamp
 			FROM events e
 			WHERE e.website_id = $1 
-			AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND e.event_type = 'pageview'
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'{{filters:e}}
 		)
 		SELECT 
 			nr.normalized_referrer as referrer,
@@ -80,9 +83,9 @@ func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID
 		WHERE nr.normalized_referrer IS NOT NULL AND nr.normalized_referrer != ''
 		GROUP BY nr.normalized_referrer
 		ORDER BY unique_visitors DESC, views DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tr.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tr.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
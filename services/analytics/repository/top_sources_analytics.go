@@ -16,7 +16,10 @@ func NewTopSourcesAnalytics(db *pgxpool.Pool) *TopSourcesAnalytics {
 }
 
 // GetTopSources returns the top traffic sources for a website with analytics
-func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID string, days int, limit int) ([]models.SourceStat, error) {
+func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.SourceStat, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+	qb.Arg(limit)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -24,7 +27,7 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		),
@@ -58,8 +61,20 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 				e.timestamp
 			FROM events e
 			WHERE e.website_id = $1 
-			AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND e.event_type = 'pageview'
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'{{filters:e}}
+		),
+		` + ordersCTE + `,
+		-- Orders are attributed to the source of the session's first pageview
+		source_revenue AS (
+			SELECT ss.source_category, COUNT(*) as orders, SUM(o.revenue) as revenue
+			FROM orders o
+			JOIN (
+				SELECT DISTINCT ON (session_id) session_id, source_category
+				FROM source_categorized
+				ORDER BY session_id, timestamp
+			) ss ON ss.session_id = o.session_id
+			GROUP BY ss.source_category
 		)
 		SELECT 
 			sc.source_category as source,
@@ -68,15 +83,18 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 			COALESCE(
 				(COUNT(*) FILTER (WHERE s.page_count = 1) * 100.0) / 
 				NULLIF(COUNT(DISTINCT sc.session_id), 0), 0
-			) as bounce_rate
+			) as bounce_rate,
+			COALESCE(MAX(r.orders), 0) as orders,
+			COALESCE(MAX(r.revenue), 0)::float8 as revenue
 		FROM source_categorized sc
 		LEFT JOIN session_stats s ON sc.session_id = s.session_id
+		LEFT JOIN source_revenue r ON r.source_category = sc.source_category
 		GROUP BY sc.source_category
 		ORDER BY unique_visitors DESC, views DESC
-		LIMIT $3
+		LIMIT $4
 	`
 
-	rows, err := ts.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := ts.db.Query(ctx, qb.Build(query), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +108,8 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 			&source.Views,
 			&source.UniqueVisitors,
 			&source.BounceRate,
+			&source.Orders,
+			&source.Revenue,
 		)
 		if err != nil {
 			return nil, err
@@ -16,7 +16,9 @@ func NewTrafficSummaryAnalytics(db *pgxpool.Pool) *TrafficSummaryAnalytics {
 }
 
 // GetTrafficSummary returns comprehensive traffic summary for a website
-func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websiteID string, days int) (*models.TrafficSummary, error) {
+func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.TrafficSummary, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -30,7 +32,7 @@ func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websit
 				END as session_duration
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -57,11 +59,11 @@ func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websit
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'`
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'{{filters:e}}`
 
 	var summary models.TrafficSummary
-	err := ts.db.QueryRow(ctx, query, websiteID, days).Scan(
+	err := ts.db.QueryRow(ctx, qb.Build(query), qb.Args()...).Scan(
 		&summary.TotalPageViews, &summary.TotalVisitors, &summary.UniqueVisitors, &summary.TotalSessions,
 		&summary.BounceRate, &summary.AvgSessionTime, &summary.PagesPerSession,
 		&summary.GrowthRate, &summary.VisitorsGrowthRate, &summary.SessionsGrowthRate,
@@ -1,14 +1,16 @@
 package repository
 
 import (
+	"analytics-app/models"
 	"context"
-	"fmt"
 )
 
 // GetUTMAnalytics returns UTM campaign performance data
-func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID string, days int) (map[string]interface{}, error) {
+func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (map[string]interface{}, error) {
+	qb := NewQueryBuilder(websiteID, dateRange, filters)
+
 	// Get UTM sources - group NULL and empty values as 'direct'
-	sourcesQuery := fmt.Sprintf(`
+	sourcesQuery := `
 		SELECT 
 			CASE 
 				WHEN utm_source IS NULL OR utm_source = '' THEN 'direct'
@@ -19,16 +21,16 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 			COUNT(DISTINCT session_id) as sessions
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
 		GROUP BY CASE 
 			WHEN utm_source IS NULL OR utm_source = '' THEN 'direct'
 			ELSE utm_source
 		END
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err := da.db.Query(ctx, sourcesQuery, websiteID)
+	rows, err := da.db.Query(ctx, qb.Build(sourcesQuery), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +54,7 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM mediums
-	mediumsQuery := fmt.Sprintf(`
+	mediumsQuery := `
 		SELECT 
 			CASE 
 				WHEN utm_medium IS NULL OR utm_medium = '' THEN 'none'
@@ -62,16 +64,16 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
 		GROUP BY CASE 
 			WHEN utm_medium IS NULL OR utm_medium = '' THEN 'none'
 			ELSE utm_medium
 		END
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, mediumsQuery, websiteID)
+	rows, err = da.db.Query(ctx, qb.Build(mediumsQuery), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -94,22 +96,22 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM campaigns - only show campaigns that actually exist
-	campaignsQuery := fmt.Sprintf(`
+	campaignsQuery := `
 		SELECT 
 			utm_campaign as campaign,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
 		AND utm_campaign IS NOT NULL 
 		AND utm_campaign != ''
 		GROUP BY utm_campaign
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, campaignsQuery, websiteID)
+	rows, err = da.db.Query(ctx, qb.Build(campaignsQuery), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -132,22 +134,22 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM terms - only show terms that actually exist
-	termsQuery := fmt.Sprintf(`
+	termsQuery := `
 		SELECT 
 			utm_term as term,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
 		AND utm_term IS NOT NULL
 		AND utm_term != ''
 		GROUP BY utm_term
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, termsQuery, websiteID)
+	rows, err = da.db.Query(ctx, qb.Build(termsQuery), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -170,22 +172,22 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM content - only show content that actually exists
-	contentQuery := fmt.Sprintf(`
+	contentQuery := `
 		SELECT 
 			utm_content as content,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'{{filters}}
 		AND utm_content IS NOT NULL
 		AND utm_content != ''
 		GROUP BY utm_content
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, contentQuery, websiteID)
+	rows, err = da.db.Query(ctx, qb.Build(contentQuery), qb.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -207,6 +209,31 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 		})
 	}
 
+	// Attribute orders to the UTM values their session landed with
+	revenueReports := []struct {
+		rows       []map[string]interface{}
+		key        string
+		column     string
+		emptyLabel string
+	}{
+		{sources, "source", "utm_source", "direct"},
+		{mediums, "medium", "utm_medium", "none"},
+		{campaigns, "campaign", "utm_campaign", ""},
+		{terms, "term", "utm_term", ""},
+		{content, "content", "utm_content", ""},
+	}
+	for _, report := range revenueReports {
+		totals, err := revenueByTouch(ctx, da.db, websiteID, dateRange, filters, report.column, report.emptyLabel)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range report.rows {
+			value, _ := row[report.key].(string)
+			row["orders"] = totals[value].Orders
+			row["revenue"] = totals[value].Revenue
+		}
+	}
+
 	return map[string]interface{}{
 		"sources":   sources,
 		"mediums":   mediums,
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type VisitorAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewVisitorAnalytics(db *pgxpool.Pool) *VisitorAnalytics {
+	return &VisitorAnalytics{db: db}
+}
+
+// GetVisitorProfile returns a visitor's summary over all of their events and the
+// sessions of their most recent ones, marking the events that completed a goal. It
+// returns pgx.ErrNoRows when the website has no events from the visitor.
+func (va *VisitorAnalytics) GetVisitorProfile(ctx context.Context, websiteID, visitorID string, goals []models.Goal) (*models.VisitorProfile, error) {
+	// A profile covers the visitor's whole history
+	qb := NewQueryBuilder(websiteID, models.DateRange{Start: time.Unix(0, 0).UTC(), End: time.Now()}, nil)
+	visitorArg := qb.Arg(visitorID)
+
+	matches := make([]string, len(goals))
+	counts := make([]string, len(goals))
+	for i, goal := range goals {
+		match := qb.Match(goal.Filters(), "")
+		matches[i] = fmt.Sprintf("CASE WHEN %s THEN %d END", match, i)
+		counts[i] = fmt.Sprintf(",\n\t\t\tCOUNT(*) FILTER (WHERE %s)", match)
+	}
+	goalsExpr := "'{}'::int[]"
+	if len(goals) > 0 {
+		goalsExpr = "array_remove(ARRAY[" + strings.Join(matches, ", ") + "]::int[], NULL)"
+	}
+
+	summaryQuery := `
+		SELECT MIN(timestamp), MAX(timestamp), COUNT(DISTINCT session_id),
+			COUNT(*) FILTER (WHERE event_type = 'pageview'), COUNT(*)` + strings.Join(counts, "") + `
+		FROM events
+		WHERE website_id = $1
+		AND visitor_id = ` + visitorArg + `
+		AND timestamp >= $2 AND timestamp < $3`
+
+	profile := &models.VisitorProfile{VisitorID: visitorID, WebsiteID: websiteID, Goals: []models.VisitorGoal{}}
+	var firstSeen, lastSeen *time.Time
+	completions := make([]int, len(goals))
+	dest := []interface{}{&firstSeen, &lastSeen, &profile.TotalSessions, &profile.Pageviews, &profile.Events}
+	for i := range completions {
+		dest = append(dest, &completions[i])
+	}
+	if err := va.db.QueryRow(ctx, summaryQuery, qb.Args()...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("visitor summary query failed: %w", err)
+	}
+	if firstSeen == nil {
+		return nil, pgx.ErrNoRows
+	}
+	profile.FirstSeen, profile.LastSeen = *firstSeen, *lastSeen
+	profile.Truncated = profile.Events > models.MaxVisitorEvents
+
+	for i, goal := range goals {
+		if completions[i] == 0 {
+			continue
+		}
+		profile.Goals = append(profile.Goals, models.VisitorGoal{GoalID: goal.ID, Name: goal.Name, Completions: completions[i]})
+		profile.Conversions += completions[i]
+	}
+
+	// Sessions are listed by their first event, each with its events in time order
+	eventsQuery := `
+		SELECT session_id, timestamp, event_type, page, referrer, time_on_page, properties,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content,
+			device, browser, os, user_agent, country, region, city, continent, goals
+		FROM (
+			SELECT COALESCE(session_id, '') AS session_id, timestamp, event_type,
+				COALESCE(page, '') AS page, COALESCE(referrer, '') AS referrer, time_on_page, properties,
+				COALESCE(utm_source, '') AS utm_source, COALESCE(utm_medium, '') AS utm_medium,
+				COALESCE(utm_campaign, '') AS utm_campaign, COALESCE(utm_term, '') AS utm_term,
+				COALESCE(utm_content, '') AS utm_content, COALESCE(device, '') AS device,
+				COALESCE(browser, '') AS browser, COALESCE(os, '') AS os, COALESCE(user_agent, '') AS user_agent,
+				COALESCE(country, '') AS country, COALESCE(region, '') AS region,
+				COALESCE(city, '') AS city, COALESCE(continent, '') AS continent,
+				` + goalsExpr + ` AS goals
+			FROM events
+			WHERE website_id = $1
+			AND visitor_id = ` + visitorArg + `
+			AND timestamp >= $2 AND timestamp < $3
+			ORDER BY timestamp DESC
+			LIMIT ` + qb.Arg(models.MaxVisitorEvents) + `
+		) recent
+		ORDER BY MIN(timestamp) OVER (PARTITION BY session_id), session_id, timestamp`
+
+	rows, err := va.db.Query(ctx, eventsQuery, qb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("visitor events query failed: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session models.VisitorSession
+		var event models.VisitorEvent
+		var propertiesJSON []byte
+		var completed []int
+		err := rows.Scan(
+			&session.SessionID, &event.Timestamp, &event.EventType, &event.Page, &event.Referrer,
+			&event.TimeOnPage, &propertiesJSON,
+			&session.UTMSource, &session.UTMMedium, &session.UTMCampaign, &session.UTMTerm, &session.UTMContent,
+			&session.Device, &session.Browser, &session.OS, &session.UserAgent,
+			&session.Country, &session.Region, &session.City, &session.Continent, &completed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan visitor event: %w", err)
+		}
+		if len(propertiesJSON) > 0 {
+			if err := json.Unmarshal(propertiesJSON, &event.Properties); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal visitor event properties: %w", err)
+			}
+		}
+		session.Referrer = event.Referrer
+		for _, i := range completed {
+			event.Goals = append(event.Goals, goals[i].Name)
+		}
+		profile.AddEvent(session, event)
+	}
+
+	return profile, rows.Err()
+}
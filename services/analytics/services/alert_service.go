@@ -0,0 +1,526 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// How often idle delivery workers look for deliveries queued by other instances
+	AlertDeliveryPollInterval = 5 * time.Second
+
+	// Failed deliveries are retried after this long, doubling up to AlertRetryMaxDelay
+	AlertRetryBaseDelay = 30 * time.Second
+	AlertRetryMaxDelay  = time.Hour
+
+	// A delivery is failed for good after this many attempts, about three hours after
+	// the first
+	MaxAlertDeliveryAttempts = 10
+
+	// How many of a rule's deliveries GetDeliveries returns
+	AlertDeliveriesLimit = 50
+)
+
+// ErrInvalidAlert is returned when an alert rule has an unknown metric, operator or
+// baseline, an invalid window or webhook URL, or a goal of another website
+var ErrInvalidAlert = errors.New("invalid alert")
+
+// AlertService evaluates alert rules against the analytics repositories and delivers
+// webhooks when they start or stop firing. Rules and deliveries are claimed from the
+// database, so any number of instances can run it.
+type AlertService struct {
+	repo      *repository.AlertRepository
+	analytics *repository.MainAnalyticsRepository
+	goals     *repository.GoalRepository
+	webhooks  *WebhookSender
+	interval  time.Duration
+	logger    zerolog.Logger
+
+	// wake tells the delivery worker that a delivery was queued
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewAlertService(repo *repository.AlertRepository, analytics *repository.MainAnalyticsRepository, goals *repository.GoalRepository, webhooks *WebhookSender, interval time.Duration, logger zerolog.Logger) *AlertService {
+	return &AlertService{
+		repo:      repo,
+		analytics: analytics,
+		goals:     goals,
+		webhooks:  webhooks,
+		interval:  interval,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Start evaluates due rules every interval and delivers webhooks until Stop is called
+func (s *AlertService) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.EvaluateDue(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("Failed to evaluate alerts")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	go func() {
+		defer s.wg.Done()
+
+		poll := time.NewTicker(AlertDeliveryPollInterval)
+		defer poll.Stop()
+
+		for {
+			if err := s.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("Failed to deliver alert webhooks")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			case <-poll.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the current evaluation and delivery to finish
+func (s *AlertService) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// CreateAlert validates and stores an alert rule with a new webhook secret. Rules
+// compare with a fixed threshold over the last hour unless set.
+func (s *AlertService) CreateAlert(ctx context.Context, req *models.CreateAlertRuleRequest) (*models.AlertRule, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("metric", string(req.Metric)).
+		Msg("Creating alert")
+
+	rule := &models.AlertRule{
+		WebsiteID:     req.WebsiteID,
+		UserID:        req.UserID,
+		Name:          req.Name,
+		Metric:        models.AlertMetric(strings.ToLower(string(req.Metric))),
+		GoalID:        req.GoalID,
+		Operator:      models.AlertOperator(strings.ToLower(string(req.Operator))),
+		Threshold:     req.Threshold,
+		Baseline:      req.Baseline,
+		WindowMinutes: req.WindowMinutes,
+		WebhookURL:    req.WebhookURL,
+		Enabled:       true,
+	}
+	if rule.Baseline == "" {
+		rule.Baseline = models.AlertFixed
+	}
+	if rule.WindowMinutes == 0 {
+		rule.WindowMinutes = 60
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if err := s.validate(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	secret, err := NewWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	rule.WebhookSecret = secret
+
+	if err := s.repo.Create(ctx, rule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create alert")
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *AlertService) GetAlerts(ctx context.Context, websiteID string) ([]models.AlertRule, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting alerts")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *AlertService) GetAlert(ctx context.Context, ruleID uuid.UUID) (*models.AlertRule, error) {
+	s.logger.Info().
+		Str("alert_id", ruleID.String()).
+		Msg("Getting alert")
+
+	return s.repo.GetByID(ctx, ruleID)
+}
+
+// UpdateAlert changes an alert rule, which is evaluated again right away. Disabling a
+// firing rule resets it without notifying.
+func (s *AlertService) UpdateAlert(ctx context.Context, ruleID uuid.UUID, req *models.UpdateAlertRuleRequest) (*models.AlertRule, error) {
+	s.logger.Info().
+		Str("alert_id", ruleID.String()).
+		Msg("Updating alert")
+
+	rule, err := s.repo.GetByID(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.Metric != nil {
+		rule.Metric = models.AlertMetric(strings.ToLower(string(*req.Metric)))
+		if rule.Metric != models.AlertGoalConversions {
+			rule.GoalID = nil
+		}
+	}
+	if req.GoalID != nil {
+		rule.GoalID = req.GoalID
+	}
+	if req.Operator != nil {
+		rule.Operator = models.AlertOperator(strings.ToLower(string(*req.Operator)))
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.Baseline != nil {
+		rule.Baseline = *req.Baseline
+	}
+	if req.WindowMinutes != nil {
+		rule.WindowMinutes = *req.WindowMinutes
+	}
+	if req.WebhookURL != nil {
+		rule.WebhookURL = *req.WebhookURL
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if err := s.validate(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	if req.RotateSecret {
+		if rule.WebhookSecret, err = NewWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	if !rule.Enabled {
+		rule.State = models.AlertOK
+	}
+
+	if err := s.repo.Update(ctx, ruleID, rule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update alert")
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *AlertService) DeleteAlert(ctx context.Context, ruleID uuid.UUID) error {
+	s.logger.Info().
+		Str("alert_id", ruleID.String()).
+		Msg("Deleting alert")
+
+	return s.repo.Delete(ctx, ruleID)
+}
+
+// GetDeliveries returns a rule's most recent webhook deliveries
+func (s *AlertService) GetDeliveries(ctx context.Context, ruleID uuid.UUID) ([]models.AlertDelivery, error) {
+	s.logger.Info().
+		Str("alert_id", ruleID.String()).
+		Msg("Getting alert deliveries")
+
+	if _, err := s.repo.GetByID(ctx, ruleID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveries(ctx, ruleID, AlertDeliveriesLimit)
+}
+
+// TestAlert queues an alert.test delivery to a rule's webhook
+func (s *AlertService) TestAlert(ctx context.Context, ruleID uuid.UUID) (*models.AlertDelivery, error) {
+	s.logger.Info().
+		Str("alert_id", ruleID.String()).
+		Msg("Testing alert webhook")
+
+	rule, err := s.repo.GetByID(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := models.NewAlertDelivery(rule, models.AlertEventTest, nil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	s.notify()
+
+	return delivery, nil
+}
+
+// validate checks a rule and that its goal belongs to its website
+func (s *AlertService) validate(ctx context.Context, rule *models.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlert, err)
+	}
+	if rule.GoalID == nil {
+		return nil
+	}
+
+	goal, err := s.goals.GetByID(ctx, *rule.GoalID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && goal.WebsiteID != rule.WebsiteID) {
+		return fmt.Errorf("%w: goal %s not found", ErrInvalidAlert, rule.GoalID)
+	}
+	return err
+}
+
+// EvaluateDue evaluates every rule that is due and records the changes of state
+func (s *AlertService) EvaluateDue(ctx context.Context) error {
+	for {
+		now := time.Now()
+		rule, err := s.repo.ClaimDue(ctx, now, now.Add(s.interval))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.evaluateRule(ctx, rule, now); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("alert_id", rule.ID.String()).Msg("Failed to evaluate alert")
+		}
+	}
+}
+
+// evaluateRule evaluates a claimed rule and records its state, with a delivery when
+// the state changed
+func (s *AlertService) evaluateRule(ctx context.Context, rule *models.AlertRule, now time.Time) error {
+	evaluation, err := s.Evaluate(ctx, rule, now)
+	if err != nil {
+		return err
+	}
+
+	state, event := rule.Transition(evaluation)
+	rule.LastValue = &evaluation.Value
+	rule.LastEvaluatedAt = &now
+	rule.State = state
+
+	var delivery *models.AlertDelivery
+	if event != "" {
+		if state == models.AlertFiring {
+			rule.FiredAt = &now
+		} else {
+			rule.ResolvedAt = &now
+		}
+		delivery, err = models.NewAlertDelivery(rule, event, evaluation, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.RecordEvaluation(ctx, rule, delivery); err != nil {
+		return err
+	}
+	if delivery != nil {
+		s.logger.Info().
+			Str("alert_id", rule.ID.String()).
+			Str("website_id", rule.WebsiteID).
+			Str("event", string(event)).
+			Float64("value", evaluation.Value).
+			Float64("limit", evaluation.Limit).
+			Msg("Alert changed state")
+		s.notify()
+	}
+
+	return nil
+}
+
+// Evaluate measures a rule's metric over its window ending now and, for rules
+// relative to last week or anomaly rules, over the same window in earlier weeks
+func (s *AlertService) Evaluate(ctx context.Context, rule *models.AlertRule, now time.Time) (*models.AlertEvaluation, error) {
+	window := rule.Window(now)
+	value, err := s.measure(ctx, rule, window)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := &models.AlertEvaluation{
+		Value:       value,
+		WindowStart: window.Start,
+		WindowEnd:   window.End,
+	}
+
+	switch rule.Baseline {
+	case models.AlertAnomaly:
+		samples := make([]float64, models.AnomalyAlertSeasons)
+		for week := range samples {
+			samples[week], err = s.measure(ctx, rule, rule.Window(now.AddDate(0, 0, -7*(week+1))))
+			if err != nil {
+				return nil, err
+			}
+		}
+		score := models.ScoreAnomaly(samples, value, rule.Threshold)
+		evaluation.BaselineValue = &score.Expected
+		evaluation.Score = &score.Score
+		evaluation.Limit, evaluation.Conclusive = rule.AnomalyLimit(score)
+	case models.AlertLastWeek:
+		baseline, err := s.measure(ctx, rule, rule.Window(now.AddDate(0, 0, -7)))
+		if err != nil {
+			return nil, err
+		}
+		evaluation.BaselineValue = &baseline
+		evaluation.Limit, evaluation.Conclusive = rule.Limit(baseline)
+	default:
+		evaluation.Limit, evaluation.Conclusive = rule.Limit(0)
+	}
+
+	if evaluation.Conclusive {
+		evaluation.Breached = rule.Breached(value, evaluation.Limit)
+	}
+
+	return evaluation, nil
+}
+
+// measure returns a rule's metric over a window
+func (s *AlertService) measure(ctx context.Context, rule *models.AlertRule, window models.DateRange) (float64, error) {
+	if rule.Metric == models.AlertGoalConversions {
+		goal, err := s.goals.GetByID(ctx, *rule.GoalID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get alert goal: %w", err)
+		}
+		stats, err := s.analytics.GetGoalStats(ctx, rule.WebsiteID, window, nil, []models.Goal{*goal})
+		if err != nil {
+			return 0, err
+		}
+		if len(stats) == 0 {
+			return 0, nil
+		}
+		return float64(stats[0].Conversions), nil
+	}
+
+	metrics, err := s.analytics.GetAlertMetrics(ctx, rule.WebsiteID, window)
+	if err != nil {
+		return 0, err
+	}
+	switch rule.Metric {
+	case models.AlertVisitors:
+		return float64(metrics.Visitors), nil
+	case models.AlertPageviews:
+		return float64(metrics.Pageviews), nil
+	}
+	return float64(metrics.Events), nil
+}
+
+// DeliverDue delivers every pending delivery that is due
+func (s *AlertService) DeliverDue(ctx context.Context) error {
+	for {
+		now := time.Now()
+		delivery, err := s.repo.ClaimDelivery(ctx, now, now.Add(AlertRetryMaxDelay))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.deliver(ctx, delivery)
+	}
+}
+
+// deliver sends a claimed delivery to its rule's webhook and records the outcome
+func (s *AlertService) deliver(ctx context.Context, delivery *models.AlertDelivery) {
+	log := s.logger.With().
+		Str("delivery_id", delivery.ID.String()).
+		Str("alert_id", delivery.RuleID.String()).
+		Int("attempt", delivery.Attempts).
+		Logger()
+
+	rule, err := s.repo.GetByID(ctx, delivery.RuleID)
+	var status int
+	if err == nil {
+		status, err = s.webhooks.Send(ctx, rule.WebhookURL, rule.WebhookSecret, string(delivery.Event), delivery.ID.String(), delivery.Payload)
+	}
+
+	// The outcome is recorded even while shutting down
+	recordCtx := context.Background()
+	if err == nil {
+		if err := s.repo.MarkDelivered(recordCtx, delivery.ID, status); err != nil {
+			log.Error().Err(err).Msg("Failed to record alert delivery")
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// Interrupted by Stop; the claim's retry time stands
+		return
+	}
+
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	next := AlertRetryAt(delivery.Attempts, time.Now())
+	if next == nil {
+		log.Error().Err(err).Msg("Giving up on alert delivery")
+	} else {
+		log.Warn().Err(err).Time("retry_at", *next).Msg("Alert delivery failed")
+	}
+	if err := s.repo.MarkAttemptFailed(recordCtx, delivery.ID, responseStatus, err.Error(), next); err != nil {
+		log.Error().Err(err).Msg("Failed to record alert delivery failure")
+	}
+}
+
+// AlertRetryAt returns when to retry a delivery after its attempts so far failed, or
+// nil once MaxAlertDeliveryAttempts were made
+func AlertRetryAt(attempts int, now time.Time) *time.Time {
+	if attempts >= MaxAlertDeliveryAttempts {
+		return nil
+	}
+	delay := AlertRetryBaseDelay
+	for i := 1; i < attempts && delay < AlertRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > AlertRetryMaxDelay {
+		delay = AlertRetryMaxDelay
+	}
+	next := now.Add(delay)
+	return &next
+}
+
+// notify wakes the delivery worker
+func (s *AlertService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -5,30 +5,41 @@ import (
 	"analytics-app/repository"
 	"context"
 	"fmt"
-	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog"
 )
 
 type AnalyticsService struct {
 	repo   *repository.MainAnalyticsRepository
-	logger zerolog.Logger
+	goals    *repository.GoalRepository
+	channels *repository.ChannelRuleRepository
+	segments *repository.SegmentRepository
+	logger   zerolog.Logger
+
+	// currency is the reporting currency revenue is stored in
+	currency string
 }
 
-func NewAnalyticsService(repo *repository.MainAnalyticsRepository, logger zerolog.Logger) *AnalyticsService {
+func NewAnalyticsService(repo *repository.MainAnalyticsRepository, goals *repository.GoalRepository, channels *repository.ChannelRuleRepository, segments *repository.SegmentRepository, currency string, logger zerolog.Logger) *AnalyticsService {
 	return &AnalyticsService{
-		repo:   repo,
-		logger: logger,
+		repo:     repo,
+		goals:    goals,
+		channels: channels,
+		segments: segments,
+		logger:   logger,
+		currency: currency,
 	}
 }
 
-func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, days int) (*models.DashboardData, error) {
+func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.DashboardData, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting dashboard data")
 
-	metrics, err := s.repo.GetDashboardMetrics(ctx, websiteID, days)
+	metrics, err := s.repo.GetDashboardMetrics(ctx, websiteID, dateRange, filters)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to get dashboard metrics")
 		return nil, fmt.Errorf("failed to get dashboard metrics: %w", err)
@@ -36,8 +47,8 @@ func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, d
 
 	// Get comparison data if available
 	var comparison *models.ComparisonMetrics
-	if days <= 30 { // Only calculate comparison for reasonable time ranges
-		comparison, _ = s.repo.GetComparisonMetrics(ctx, websiteID, days)
+	if dateRange.Days() <= 30 { // Only calculate comparison for reasonable time ranges
+		comparison, _ = s.repo.GetComparisonMetrics(ctx, websiteID, dateRange, filters)
 	}
 
 	// Get live visitors data
@@ -54,9 +65,26 @@ func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, d
 		Int("live_visitors", liveVisitors).
 		Msg("Retrieved live visitors for dashboard")
 
+	goals, err := s.GetGoalStats(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to get goal conversions for dashboard")
+		goals = nil
+	}
+
+	revenue, err := s.repo.GetRevenueMetrics(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to get revenue for dashboard")
+		revenue = nil
+	} else {
+		revenue.Currency = s.currency
+		if metrics.UniqueVisitors > 0 {
+			revenue.RevenuePerVisitor = revenue.Revenue / float64(metrics.UniqueVisitors)
+		}
+	}
+
 	return &models.DashboardData{
 		WebsiteID:       websiteID,
-		DateRange:       days,
+		DateRange:       dateRange.Days(),
 		TotalVisitors:   metrics.TotalVisitors,
 		UniqueVisitors:  metrics.UniqueVisitors,
 		LiveVisitors:    liveVisitors,
@@ -64,106 +92,139 @@ func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, d
 		SessionDuration: metrics.AvgSessionTime,
 		BounceRate:      metrics.BounceRate,
 		Comparison:      comparison,
+		Goals:           goals,
+		Revenue:         revenue,
 	}, nil
 }
 
-func (s *AnalyticsService) GetTopPages(ctx context.Context, websiteID string, days, limit int) ([]models.PageStat, error) {
+// GetComparisonMetrics compares the period with the one of the same length before it.
+// Unlike GetDashboard it does so for periods of any length.
+func (s *AnalyticsService) GetComparisonMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.ComparisonMetrics, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
+		Msg("Getting comparison metrics")
+
+	return s.repo.GetComparisonMetrics(ctx, websiteID, dateRange, filters)
+}
+
+func (s *AnalyticsService) GetTopPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.PageStat, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top pages")
 
-	return s.repo.GetTopPages(ctx, websiteID, days, limit)
+	return s.repo.GetTopPages(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, days int) (map[string]interface{}, error) {
+func (s *AnalyticsService) GetEntryPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.EntryExitPageStat, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Int("limit", limit).
+		Msg("Getting entry pages")
+
+	return s.repo.GetEntryPages(ctx, websiteID, dateRange, filters, limit)
+}
+
+func (s *AnalyticsService) GetExitPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.EntryExitPageStat, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Int("limit", limit).
+		Msg("Getting exit pages")
+
+	return s.repo.GetExitPages(ctx, websiteID, dateRange, filters, limit)
+}
+
+func (s *AnalyticsService) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, dateRange models.DateRange, filters models.Filters) (map[string]interface{}, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
 		Str("page_path", pagePath).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting page UTM breakdown")
 
-	return s.repo.GetPageUTMBreakdown(ctx, websiteID, pagePath, days)
+	return s.repo.GetPageUTMBreakdown(ctx, websiteID, pagePath, dateRange, filters)
 }
 
-func (s *AnalyticsService) GetTopReferrers(ctx context.Context, websiteID string, days, limit int) ([]models.ReferrerStat, error) {
+func (s *AnalyticsService) GetTopReferrers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.ReferrerStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top referrers")
 
-	return s.repo.GetTopReferrers(ctx, websiteID, days, limit)
+	return s.repo.GetTopReferrers(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTopSources(ctx context.Context, websiteID string, days, limit int) ([]models.SourceStat, error) {
+func (s *AnalyticsService) GetTopSources(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.SourceStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top sources")
 
-	return s.repo.GetTopSources(ctx, websiteID, days, limit)
+	return s.repo.GetTopSources(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTopCountries(ctx context.Context, websiteID string, days, limit int) ([]models.CountryStat, error) {
+func (s *AnalyticsService) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.CountryStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top countries")
 
-	return s.repo.GetTopCountries(ctx, websiteID, days, limit)
+	return s.repo.GetTopCountries(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTopBrowsers(ctx context.Context, websiteID string, days, limit int) ([]models.BrowserStat, error) {
+func (s *AnalyticsService) GetTopBrowsers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.BrowserStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top browsers")
 
-	return s.repo.GetTopBrowsers(ctx, websiteID, days, limit)
+	return s.repo.GetTopBrowsers(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTopDevices(ctx context.Context, websiteID string, days, limit int) ([]models.DeviceStat, error) {
+func (s *AnalyticsService) GetTopDevices(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.DeviceStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top devices")
 
-	return s.repo.GetTopDevices(ctx, websiteID, days, limit)
+	return s.repo.GetTopDevices(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTopOS(ctx context.Context, websiteID string, days, limit int) ([]models.OSStat, error) {
+func (s *AnalyticsService) GetTopOS(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, limit int) ([]models.OSStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Int("limit", limit).
 		Msg("Getting top operating systems")
 
-	return s.repo.GetTopOS(ctx, websiteID, days, limit)
+	return s.repo.GetTopOS(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTrafficSummary(ctx context.Context, websiteID string, days int) (*models.TrafficSummary, error) {
+func (s *AnalyticsService) GetTrafficSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.TrafficSummary, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting traffic summary")
 
-	return s.repo.GetTrafficSummary(ctx, websiteID, days)
+	return s.repo.GetTrafficSummary(ctx, websiteID, dateRange, filters)
 }
 
-func (s *AnalyticsService) GetDailyStats(ctx context.Context, websiteID string, days int) ([]models.DailyStat, error) {
+func (s *AnalyticsService) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.DailyStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting daily statistics from database")
 
 	// Use the repository to get real data from database
-	result, err := s.repo.GetDailyStats(ctx, websiteID, days)
+	result, err := s.repo.GetDailyStats(ctx, websiteID, dateRange, filters)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to get daily stats from repository")
 		return nil, err
@@ -176,22 +237,222 @@ func (s *AnalyticsService) GetDailyStats(ctx context.Context, websiteID string,
 	return result, nil
 }
 
-func (s *AnalyticsService) GetHourlyStats(ctx context.Context, websiteID string, days int, timezone string) ([]models.HourlyStat, error) {
+func (s *AnalyticsService) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.HourlyStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Str("timezone", timezone).
-		Msg("Getting hourly statistics (last 24 hours)")
+		Str("date_range", dateRange.Label()).
+		Str("timezone", dateRange.Timezone).
+		Msg("Getting hourly statistics")
 
-	return s.repo.GetHourlyStats(ctx, websiteID, days, timezone)
+	return s.repo.GetHourlyStats(ctx, websiteID, dateRange, filters)
 }
 
-func (s *AnalyticsService) GetCustomEvents(ctx context.Context, websiteID string, days int) ([]models.CustomEventStat, error) {
+func (s *AnalyticsService) GetCustomEvents(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.CustomEventStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting custom events")
 
-	return s.repo.GetCustomEventStats(ctx, websiteID, days)
+	return s.repo.GetCustomEventStats(ctx, websiteID, dateRange, filters)
+}
+
+// GetRetention returns the retention matrix of visitors grouped by the period they were
+// first seen in. An empty eventType measures returning visits (pageviews).
+func (s *AnalyticsService) GetRetention(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, period models.RetentionPeriod, eventType string) (*models.RetentionReport, error) {
+	if eventType == "" {
+		eventType = "pageview"
+	}
+
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Str("period", string(period)).
+		Str("event_type", eventType).
+		Msg("Getting retention cohorts")
+
+	cohorts, err := s.repo.GetRetention(ctx, websiteID, dateRange, filters, period, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention cohorts: %w", err)
+	}
+	if cohorts == nil {
+		cohorts = []models.RetentionCohort{}
+	}
+
+	return &models.RetentionReport{
+		WebsiteID: websiteID,
+		DateRange: dateRange.Label(),
+		Timezone:  dateRange.Timezone,
+		Period:    period,
+		EventType: eventType,
+		Cohorts:   cohorts,
+	}, nil
+}
+
+// GetPaths returns how sessions moved through the site after a start page or before an end page
+func (s *AnalyticsService) GetPaths(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, q models.PathQuery) (*models.PathReport, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Str("page", q.Page).
+		Str("direction", string(q.Direction)).
+		Int("steps", q.Steps).
+		Msg("Getting user paths")
+
+	nodes, links, err := s.repo.GetPaths(ctx, websiteID, dateRange, filters, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user paths: %w", err)
+	}
+
+	report := &models.PathReport{
+		WebsiteID: websiteID,
+		DateRange: dateRange.Label(),
+		Page:      q.Page,
+		Direction: q.Direction,
+		Steps:     q.Steps,
+		Nodes:     nodes,
+		Links:     links,
+	}
+	for _, node := range nodes {
+		if node.Step == 0 {
+			report.Sessions += node.Sessions
+		}
+	}
+
+	return report, nil
+}
+
+// GetGoalStats returns conversions for each of the website's active goals
+func (s *AnalyticsService) GetGoalStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.GoalStat, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Msg("Getting goal conversions")
+
+	goals, err := s.goals.GetByWebsiteID(ctx, websiteID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goals: %w", err)
+	}
+
+	return s.repo.GetGoalStats(ctx, websiteID, dateRange, filters, goals)
+}
+
+// GetVisitorProfile returns a visitor's sessions and events with their active goal
+// completions. Anonymized visitors are returned without identifying details.
+func (s *AnalyticsService) GetVisitorProfile(ctx context.Context, websiteID, visitorID string) (*models.VisitorProfile, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting visitor profile")
+
+	goals, err := s.goals.GetByWebsiteID(ctx, websiteID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goals: %w", err)
+	}
+
+	profile, err := s.repo.GetVisitorProfile(ctx, websiteID, visitorID, goals)
+	if err != nil {
+		return nil, err
+	}
+	if models.IsAnonymizedVisitor(visitorID) {
+		profile.Anonymize()
+	}
+
+	return profile, nil
+}
+
+// GetGoalBreakdown returns the top values of a dimension with each active goal's
+// conversions next to them
+func (s *AnalyticsService) GetGoalBreakdown(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, dimension string, limit int) ([]models.GoalBreakdownRow, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Str("dimension", dimension).
+		Int("limit", limit).
+		Msg("Getting goal breakdown")
+
+	goals, err := s.goals.GetByWebsiteID(ctx, websiteID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goals: %w", err)
+	}
+
+	return s.repo.GetGoalBreakdown(ctx, websiteID, dateRange, filters, goals, dimension, limit)
+}
+
+// GetAttribution credits goal or funnel conversions to the values of a dimension using
+// the query's attribution model
+func (s *AnalyticsService) GetAttribution(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, q models.AttributionQuery) (*models.AttributionReport, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Str("model", string(q.Model)).
+		Str("dimension", q.Dimension).
+		Int("lookback_days", q.LookbackDays).
+		Msg("Getting attribution")
+
+	var goal *models.Goal
+	if q.GoalID != nil {
+		var err error
+		goal, err = s.goals.GetByID(ctx, *q.GoalID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get goal: %w", err)
+		}
+		if goal.WebsiteID != websiteID {
+			return nil, fmt.Errorf("failed to get goal: %w", pgx.ErrNoRows)
+		}
+	}
+
+	conversions, rows, err := s.repo.GetAttribution(ctx, websiteID, dateRange, filters, q, goal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AttributionReport{
+		WebsiteID:    websiteID,
+		DateRange:    dateRange.Label(),
+		Model:        q.Model,
+		Dimension:    q.Dimension,
+		LookbackDays: q.LookbackDays,
+		GoalID:       q.GoalID,
+		FunnelID:     q.FunnelID,
+		Conversions:  conversions,
+		Rows:         rows,
+	}, nil
+}
+
+// GetChannelRules returns the rules classifying a website's sessions into channels: its
+// own rules followed by the built-in rules
+func (s *AnalyticsService) GetChannelRules(ctx context.Context, websiteID string) (models.ChannelRules, error) {
+	return websiteChannelRules(ctx, s.channels, websiteID)
+}
+
+func websiteChannelRules(ctx context.Context, channels *repository.ChannelRuleRepository, websiteID string) (models.ChannelRules, error) {
+	custom, err := channels.Get(ctx, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel rules: %w", err)
+	}
+
+	rules := make(models.ChannelRules, 0, len(custom.Rules)+len(models.DefaultChannelRules))
+	rules = append(rules, custom.Rules...)
+	return append(rules, models.DefaultChannelRules...), nil
+}
+
+// GetSegment returns one of the website's saved segments to filter its reports by
+func (s *AnalyticsService) GetSegment(ctx context.Context, websiteID string, segmentID uuid.UUID) (*models.Segment, error) {
+	return websiteSegment(ctx, s.segments, websiteID, segmentID)
+}
+
+// GetChannels returns traffic and revenue per channel
+func (s *AnalyticsService) GetChannels(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) ([]models.ChannelStat, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Msg("Getting channels")
+
+	rules, err := s.GetChannelRules(ctx, websiteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetChannels(ctx, websiteID, dateRange, filters, rules)
 }
 
 // GetLiveVisitors returns the number of currently active visitors
@@ -203,34 +464,31 @@ func (s *AnalyticsService) GetLiveVisitors(ctx context.Context, websiteID string
 	return s.repo.GetLiveVisitors(ctx, websiteID)
 }
 
-func (s *AnalyticsService) GetUTMAnalytics(ctx context.Context, websiteID string, days int) (map[string]interface{}, error) {
+func (s *AnalyticsService) GetUTMAnalytics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (map[string]interface{}, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting UTM analytics")
 
-	return s.repo.GetUTMAnalytics(ctx, websiteID, days)
+	return s.repo.GetUTMAnalytics(ctx, websiteID, dateRange, filters)
 }
 
 // GetGeolocationBreakdown returns comprehensive geolocation analytics
-func (s *AnalyticsService) GetGeolocationBreakdown(ctx context.Context, websiteID string, days int) (*models.GeolocationBreakdown, error) {
+func (s *AnalyticsService) GetGeolocationBreakdown(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters) (*models.GeolocationBreakdown, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Str("date_range", dateRange.Label()).
 		Msg("Getting geolocation breakdown")
 
-	// Convert days to time range
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -days)
-
-	breakdown, err := s.repo.GetGeolocationBreakdown(ctx, websiteID, startDate, endDate)
+	breakdown, err := s.repo.GetGeolocationBreakdown(ctx, websiteID, dateRange, filters)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to get geolocation breakdown from repository")
 		return nil, fmt.Errorf("failed to get geolocation breakdown: %w", err)
 	}
 
-	// If no data found, return dummy data for testing (temporary)
-	if breakdown == nil || (len(breakdown.Countries) == 0 && len(breakdown.Cities) == 0 && len(breakdown.Continents) == 0) {
+	// If no data found, return dummy data for testing (temporary). A filtered report
+	// that matches nothing must stay empty.
+	if len(filters) == 0 && (breakdown == nil || (len(breakdown.Countries) == 0 && len(breakdown.Cities) == 0 && len(breakdown.Continents) == 0)) {
 		s.logger.Info().Msg("No geolocation data found, returning dummy data for testing")
 		
 		return &models.GeolocationBreakdown{
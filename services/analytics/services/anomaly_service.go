@@ -0,0 +1,56 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// AnomalyService scores daily and hourly series against their seasonal baselines, fitted
+// on the weeks before the series with the same filters
+type AnomalyService struct {
+	analytics *repository.MainAnalyticsRepository
+	logger    zerolog.Logger
+}
+
+func NewAnomalyService(analytics *repository.MainAnalyticsRepository, logger zerolog.Logger) *AnomalyService {
+	return &AnomalyService{
+		analytics: analytics,
+		logger:    logger,
+	}
+}
+
+// DetectDailyAnomalies scores the days of stats, the website's daily stats over the range
+func (s *AnomalyService) DetectDailyAnomalies(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, stats []models.DailyStat) (*models.TimeSeriesAnomalies, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Msg("Detecting daily anomalies")
+
+	history, err := s.analytics.GetDailyStats(ctx, websiteID, models.DailyAnomalyHistory(dateRange), filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily anomaly history: %w", err)
+	}
+
+	return models.DetectDailyAnomalies(history, stats, dateRange, time.Now()), nil
+}
+
+// DetectHourlyAnomalies scores the hours of stats, the website's hourly stats over the
+// range
+func (s *AnomalyService) DetectHourlyAnomalies(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.Filters, stats []models.HourlyStat) (*models.TimeSeriesAnomalies, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("date_range", dateRange.Label()).
+		Msg("Detecting hourly anomalies")
+
+	history, err := s.analytics.GetHourlyStats(ctx, websiteID, models.HourlyAnomalyHistory(dateRange), filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly anomaly history: %w", err)
+	}
+
+	return models.DetectHourlyAnomalies(history, stats, dateRange, time.Now()), nil
+}
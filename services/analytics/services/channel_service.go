@@ -0,0 +1,59 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidChannelRules is returned when a website's channel rules do not validate
+var ErrInvalidChannelRules = errors.New("invalid channel rules")
+
+type ChannelService struct {
+	repo   *repository.ChannelRuleRepository
+	logger zerolog.Logger
+}
+
+func NewChannelService(repo *repository.ChannelRuleRepository, logger zerolog.Logger) *ChannelService {
+	return &ChannelService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// GetRules returns the rules a website added in front of the built-in rules
+func (s *ChannelService) GetRules(ctx context.Context, websiteID string) (*models.WebsiteChannelRules, error) {
+	return s.repo.Get(ctx, websiteID)
+}
+
+// UpdateRules validates and replaces a website's channel rules
+func (s *ChannelService) UpdateRules(ctx context.Context, websiteID string, rules models.ChannelRules) (*models.WebsiteChannelRules, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Int("rules", len(rules)).
+		Msg("Updating channel rules")
+
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChannelRules, err)
+	}
+
+	if err := s.repo.Save(ctx, websiteID, rules); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to save channel rules")
+		return nil, err
+	}
+
+	return s.repo.Get(ctx, websiteID)
+}
+
+// ResetRules removes a website's channel rules so only the built-in rules apply
+func (s *ChannelService) ResetRules(ctx context.Context, websiteID string) error {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Resetting channel rules")
+
+	return s.repo.Delete(ctx, websiteID)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"analytics-app/models"
+	"bytes"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strconv"
+	texttemplate "text/template"
+)
+
+var (
+	//go:embed templates/digest.html.tmpl
+	digestHTMLSource string
+	//go:embed templates/digest.txt.tmpl
+	digestTextSource string
+
+	digestFuncs = map[string]interface{}{
+		"number":      formatNumber,
+		"change":      formatChange,
+		"changeColor": changeColor,
+	}
+
+	digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(digestFuncs).Parse(digestHTMLSource))
+	digestTextTemplate = texttemplate.Must(texttemplate.New("digest.txt").Funcs(digestFuncs).Parse(digestTextSource))
+)
+
+// digestMetric is a headline number of a digest with its change from the period before
+type digestMetric struct {
+	Label  string
+	Value  string
+	Change *float64
+	// LowerIsBetter marks metrics whose decrease is an improvement
+	LowerIsBetter bool
+}
+
+// digestView is what the digest templates render
+type digestView struct {
+	*models.DigestReport
+	Subject       string
+	Period        string
+	PreviousLabel string
+	Metrics       []digestMetric
+}
+
+// RenderDigest renders a digest's subject and its plain text and HTML bodies. The
+// message has no recipients yet.
+func RenderDigest(report *models.DigestReport) (*EmailMessage, error) {
+	dashboard := report.Dashboard
+	comparison := dashboard.Comparison
+	if comparison == nil {
+		comparison = &models.ComparisonMetrics{}
+	}
+
+	period := report.PeriodLabel()
+	view := digestView{
+		DigestReport:  report,
+		Subject:       fmt.Sprintf("%s %s report: %s", report.Name, report.Frequency, period),
+		Period:        period,
+		PreviousLabel: fmt.Sprintf("%d days", report.Period.Days()),
+		Metrics: []digestMetric{
+			{Label: "Visitors", Value: formatNumber(dashboard.UniqueVisitors), Change: comparison.VisitorChange},
+			{Label: "Pageviews", Value: formatNumber(dashboard.PageViews), Change: comparison.PageviewChange},
+			{Label: "Bounce rate", Value: strconv.FormatFloat(dashboard.BounceRate, 'f', 1, 64) + "%", Change: comparison.BounceChange, LowerIsBetter: true},
+			{Label: "Visit duration", Value: formatDuration(dashboard.SessionDuration), Change: comparison.DurationChange},
+		},
+	}
+
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, view); err != nil {
+		return nil, fmt.Errorf("failed to render digest text: %w", err)
+	}
+	if err := digestHTMLTemplate.Execute(&html, view); err != nil {
+		return nil, fmt.Errorf("failed to render digest HTML: %w", err)
+	}
+
+	return &EmailMessage{
+		Subject: view.Subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// formatNumber formats a count with thousands separators, e.g. 12,345
+func formatNumber(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var out []byte
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, digits[i])
+	}
+	return sign + string(out)
+}
+
+// formatDuration formats seconds as e.g. 45s, 2m 05s or 1h 10m
+func formatDuration(seconds float64) string {
+	s := int(math.Round(seconds))
+	switch {
+	case s < 60:
+		return fmt.Sprintf("%ds", s)
+	case s < 3600:
+		return fmt.Sprintf("%dm %02ds", s/60, s%60)
+	}
+	return fmt.Sprintf("%dh %02dm", s/3600, s%3600/60)
+}
+
+// formatChange formats a percentage change with its sign; changes without enough data
+// in the period before are shown as a dash
+func formatChange(change *float64) string {
+	if change == nil {
+		return "–"
+	}
+	return fmt.Sprintf("%+.1f%%", *change)
+}
+
+// changeColor is green for improvements, red for regressions and grey otherwise
+func changeColor(metric digestMetric) string {
+	if metric.Change == nil || math.Abs(*metric.Change) < 0.05 {
+		return "#6b7280"
+	}
+	if (*metric.Change > 0) != metric.LowerIsBetter {
+		return "#15803d"
+	}
+	return "#b91c1c"
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// How often the scheduler looks for due digests
+	DigestCheckInterval = time.Minute
+
+	// A digest that failed to send, or whose send never completed, is tried again
+	// after this long
+	DigestRetryDelay = 15 * time.Minute
+
+	// A digest is skipped for its period after this many failed sends
+	MaxDigestAttempts = 3
+
+	// How many pages and sources a digest lists
+	DigestTopRows = 5
+
+	// DefaultDigestHour is the local hour digests are sent at unless set
+	DefaultDigestHour = 8
+)
+
+var (
+	// ErrInvalidDigest is returned when a digest schedule has an unknown frequency, an
+	// invalid timezone or hour, or invalid recipients
+	ErrInvalidDigest = errors.New("invalid digest")
+	// ErrEmailDisabled is returned when sending a digest without an email sender
+	ErrEmailDisabled = errors.New("email is not configured")
+)
+
+// DigestService emails each digest schedule's report when it is due. Schedules are
+// claimed from the digest_schedules table, so any number of instances can run the
+// scheduler; it only runs with a sender configured.
+type DigestService struct {
+	repo      *repository.DigestRepository
+	analytics *AnalyticsService
+	sender    EmailSender
+	// dashboardURL is the base URL of the dashboard digests link to; empty for none
+	dashboardURL string
+	logger       zerolog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDigestService(repo *repository.DigestRepository, analytics *AnalyticsService, sender EmailSender, dashboardURL string, logger zerolog.Logger) *DigestService {
+	return &DigestService{
+		repo:         repo,
+		analytics:    analytics,
+		sender:       sender,
+		dashboardURL: strings.TrimRight(dashboardURL, "/"),
+		logger:       logger,
+	}
+}
+
+// Start sends due digests every DigestCheckInterval until Stop is called. Without a
+// sender it does nothing.
+func (s *DigestService) Start() {
+	if s.sender == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(DigestCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("Failed to send due digests")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the digest being sent to finish
+func (s *DigestService) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// CreateDigest validates and stores a digest schedule, sent from the next Monday or
+// 1st of the month. Digests are sent at DefaultDigestHour in UTC unless set.
+func (s *DigestService) CreateDigest(ctx context.Context, req *models.CreateDigestScheduleRequest) (*models.DigestSchedule, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("frequency", string(req.Frequency)).
+		Msg("Creating digest")
+
+	schedule := &models.DigestSchedule{
+		WebsiteID:  req.WebsiteID,
+		UserID:     req.UserID,
+		Name:       req.Name,
+		Frequency:  models.DigestFrequency(strings.ToLower(string(req.Frequency))),
+		Recipients: req.Recipients,
+		Timezone:   req.Timezone,
+		Hour:       DefaultDigestHour,
+		Enabled:    true,
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if req.Hour != nil {
+		schedule.Hour = *req.Hour
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDigest, err)
+	}
+	schedule.NextRunAt = schedule.NextRun(time.Now())
+
+	if err := s.repo.Create(ctx, schedule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create digest")
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *DigestService) GetDigests(ctx context.Context, websiteID string) ([]models.DigestSchedule, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting digests")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *DigestService) GetDigest(ctx context.Context, scheduleID uuid.UUID) (*models.DigestSchedule, error) {
+	s.logger.Info().
+		Str("digest_id", scheduleID.String()).
+		Msg("Getting digest")
+
+	return s.repo.GetByID(ctx, scheduleID)
+}
+
+// UpdateDigest changes a digest schedule and reschedules its next send
+func (s *DigestService) UpdateDigest(ctx context.Context, scheduleID uuid.UUID, req *models.UpdateDigestScheduleRequest) (*models.DigestSchedule, error) {
+	s.logger.Info().
+		Str("digest_id", scheduleID.String()).
+		Msg("Updating digest")
+
+	schedule, err := s.repo.GetByID(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		schedule.Name = *req.Name
+	}
+	if req.Frequency != nil {
+		schedule.Frequency = models.DigestFrequency(strings.ToLower(string(*req.Frequency)))
+	}
+	if req.Recipients != nil {
+		schedule.Recipients = *req.Recipients
+	}
+	if req.Timezone != nil {
+		schedule.Timezone = *req.Timezone
+	}
+	if req.Hour != nil {
+		schedule.Hour = *req.Hour
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDigest, err)
+	}
+	schedule.NextRunAt = schedule.NextRun(time.Now())
+
+	if err := s.repo.Update(ctx, scheduleID, schedule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update digest")
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *DigestService) DeleteDigest(ctx context.Context, scheduleID uuid.UUID) error {
+	s.logger.Info().
+		Str("digest_id", scheduleID.String()).
+		Msg("Deleting digest")
+
+	return s.repo.Delete(ctx, scheduleID)
+}
+
+// PreviewDigest renders the digest a schedule would send now
+func (s *DigestService) PreviewDigest(ctx context.Context, scheduleID uuid.UUID) (*EmailMessage, error) {
+	schedule, err := s.repo.GetByID(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.BuildReport(ctx, schedule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := RenderDigest(report)
+	if err != nil {
+		return nil, err
+	}
+	msg.To = schedule.Recipients
+	return msg, nil
+}
+
+// SendDigest sends a schedule's digest now, without changing when it is next sent
+func (s *DigestService) SendDigest(ctx context.Context, scheduleID uuid.UUID) error {
+	if s.sender == nil {
+		return ErrEmailDisabled
+	}
+
+	s.logger.Info().
+		Str("digest_id", scheduleID.String()).
+		Msg("Sending digest")
+
+	msg, err := s.PreviewDigest(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	return s.sender.Send(ctx, msg)
+}
+
+// SendDue sends every digest that is due. A failed send is retried after
+// DigestRetryDelay, up to MaxDigestAttempts times per period.
+func (s *DigestService) SendDue(ctx context.Context) error {
+	for {
+		now := time.Now()
+		schedule, err := s.repo.ClaimDue(ctx, now, now.Add(DigestRetryDelay))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.send(ctx, schedule, now)
+	}
+}
+
+// send sends a claimed schedule's digest and records the outcome
+func (s *DigestService) send(ctx context.Context, schedule *models.DigestSchedule, now time.Time) {
+	log := s.logger.With().
+		Str("digest_id", schedule.ID.String()).
+		Str("website_id", schedule.WebsiteID).
+		Logger()
+
+	err := func() error {
+		report, err := s.BuildReport(ctx, schedule, now)
+		if err != nil {
+			return err
+		}
+		msg, err := RenderDigest(report)
+		if err != nil {
+			return err
+		}
+		msg.To = schedule.Recipients
+		return s.sender.Send(ctx, msg)
+	}()
+
+	// The outcome is recorded even while shutting down
+	recordCtx := context.Background()
+	if err == nil {
+		if err := s.repo.MarkSent(recordCtx, schedule.ID, time.Now(), schedule.NextRun(now)); err != nil {
+			log.Error().Err(err).Msg("Failed to record sent digest")
+			return
+		}
+		log.Info().Int("recipients", len(schedule.Recipients)).Msg("Digest sent")
+		return
+	}
+	if ctx.Err() != nil {
+		// Interrupted by Stop; the claim's retry time stands
+		return
+	}
+
+	attempts := schedule.FailedAttempts + 1
+	next := now.Add(DigestRetryDelay)
+	if attempts >= MaxDigestAttempts {
+		log.Error().Err(err).Int("attempts", attempts).Msg("Giving up on digest for this period")
+		attempts = 0
+		next = schedule.NextRun(now)
+	} else {
+		log.Error().Err(err).Int("attempts", attempts).Msg("Failed to send digest")
+	}
+	if err := s.repo.MarkFailed(recordCtx, schedule.ID, err.Error(), attempts, next); err != nil {
+		log.Error().Err(err).Msg("Failed to record digest failure")
+	}
+}
+
+// BuildReport gathers a schedule's report for the last complete period before now
+func (s *DigestService) BuildReport(ctx context.Context, schedule *models.DigestSchedule, now time.Time) (*models.DigestReport, error) {
+	period, err := schedule.Period(now)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard, err := s.analytics.GetDashboard(ctx, schedule.WebsiteID, period, nil)
+	if err != nil {
+		return nil, err
+	}
+	// GetDashboard skips the comparison for long periods
+	if dashboard.Comparison == nil {
+		dashboard.Comparison, err = s.analytics.GetComparisonMetrics(ctx, schedule.WebsiteID, period, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comparison metrics: %w", err)
+		}
+	}
+	if dashboard.TopPages, err = s.analytics.GetTopPages(ctx, schedule.WebsiteID, period, nil, DigestTopRows); err != nil {
+		return nil, fmt.Errorf("failed to get top pages: %w", err)
+	}
+	if dashboard.TopSources, err = s.analytics.GetTopSources(ctx, schedule.WebsiteID, period, nil, DigestTopRows); err != nil {
+		return nil, fmt.Errorf("failed to get top sources: %w", err)
+	}
+
+	report := &models.DigestReport{
+		WebsiteID: schedule.WebsiteID,
+		Name:      schedule.Name,
+		Frequency: schedule.Frequency,
+		Period:    period,
+		Dashboard: dashboard,
+	}
+	if s.dashboardURL != "" {
+		report.DashboardURL = s.dashboardURL + "/websites/" + url.PathEscape(schedule.WebsiteID) + "/analytics"
+	}
+
+	return report, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const dedupeKeyPrefix = "event_dedupe"
+
+// idempotencyNamespace scopes event IDs derived from an Idempotency-Key header
+var idempotencyNamespace = uuid.MustParse("6f1c2a9e-8d4b-4c1e-9a57-3b2e7d0f4a61")
+
+// EventDeduplicator remembers client-supplied event IDs for a time window so that
+// retried tracking requests do not store the same event twice
+type EventDeduplicator struct {
+	redis  *redis.Client
+	window time.Duration
+	logger zerolog.Logger
+}
+
+func NewEventDeduplicator(redisClient *redis.Client, window time.Duration, logger zerolog.Logger) *EventDeduplicator {
+	return &EventDeduplicator{
+		redis:  redisClient,
+		window: window,
+		logger: logger,
+	}
+}
+
+// IdempotentEventID derives a stable event ID from an Idempotency-Key header, so the
+// same request retried by the client produces the same IDs. index is the position of
+// the event in a batch (0 for single events).
+func IdempotentEventID(websiteID, key string, index int) uuid.UUID {
+	return uuid.NewSHA1(idempotencyNamespace, []byte(fmt.Sprintf("%s:%s:%d", websiteID, key, index)))
+}
+
+// AssignIdempotentIDs gives every event of a batch without an explicit ID one derived
+// from the Idempotency-Key header and the event's effective website
+func AssignIdempotentIDs(req *models.BatchEventRequest, key string) {
+	for i := range req.Events {
+		if req.Events[i].ID == uuid.Nil {
+			req.Events[i].ID = IdempotentEventID(req.EventWebsiteID(i), key, i)
+		}
+	}
+}
+
+// Claim records the IDs of the given events and reports which ones were seen for the
+// first time within the window. Redis errors fail open: every event is treated as new.
+func (d *EventDeduplicator) Claim(ctx context.Context, events []models.Event) []bool {
+	fresh := make([]bool, len(events))
+	for i := range fresh {
+		fresh[i] = true
+	}
+	if len(events) == 0 {
+		return fresh
+	}
+
+	pipe := d.redis.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(events))
+	for i, event := range events {
+		cmds[i] = pipe.SetNX(ctx, dedupeKey(event), 1, d.window)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		d.logger.Warn().Err(err).Int("events_count", len(events)).Msg("Event dedupe check failed, accepting events")
+		return fresh
+	}
+
+	for i, cmd := range cmds {
+		fresh[i] = cmd.Val()
+	}
+	return fresh
+}
+
+// Release forgets the IDs of claimed events that could not be accepted, so that the
+// client's retry is stored instead of being dropped as a duplicate
+func (d *EventDeduplicator) Release(ctx context.Context, events []models.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	keys := make([]string, len(events))
+	for i, event := range events {
+		keys[i] = dedupeKey(event)
+	}
+	if err := d.redis.Del(ctx, keys...).Err(); err != nil {
+		d.logger.Error().Err(err).Int("events_count", len(events)).Msg("Failed to release event dedupe claims")
+	}
+}
+
+func dedupeKey(event models.Event) string {
+	return fmt.Sprintf("%s:%s:%s", dedupeKeyPrefix, event.WebsiteID, event.ID)
+}
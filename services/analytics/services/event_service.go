@@ -4,12 +4,14 @@ import (
 	"analytics-app/models"
 	"analytics-app/repository"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"analytics-app/utils"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
@@ -18,16 +20,39 @@ const (
 	// Optimized batch collection for better throughput
 	BatchSize     = 1000
 	FlushInterval = 5 * time.Second // Increased from 2s to balance latency vs efficiency
+
+	// How often events released back to the spool are redelivered
+	SpoolRedeliveryInterval = 30 * time.Second
+
+	// Failed events are retried with exponential backoff before being dead-lettered
+	MaxBatchRetries = 4
+	RetryBaseDelay  = 1 * time.Second
+	RetryMaxDelay   = 30 * time.Second
 )
 
+// ErrInvalidDeadLetterRequest is returned for malformed dead-letter replay and purge requests
+var ErrInvalidDeadLetterRequest = errors.New("invalid dead-letter request")
+
+// queuedEvent is an event together with its spool sequence number (0 when spooling is disabled)
+type queuedEvent struct {
+	seq   uint64
+	event models.Event
+}
+
 type EventService struct {
-	repo   *repository.EventRepository
-	db     *pgxpool.Pool
-	logger zerolog.Logger
+	repo        *repository.EventRepository
+	deadLetters *repository.DeadLetterRepository
+	rollups     *repository.RollupRepository
+	dedupe      *EventDeduplicator
+	currencies  *utils.CurrencyConverter
+	db          *pgxpool.Pool
+	spool       *EventSpool
+	realtime    *RealtimeService
+	logger      zerolog.Logger
 
 	// Simple event channel for async processing
-	eventChan chan models.Event
-	batchChan chan []models.Event
+	eventChan chan queuedEvent
+	batchChan chan []queuedEvent
 
 	// Shutdown control
 	ctx        context.Context
@@ -37,22 +62,37 @@ type EventService struct {
 	shutdownMu sync.RWMutex
 }
 
-func NewEventService(repo *repository.EventRepository, db *pgxpool.Pool, logger zerolog.Logger) *EventService {
+// NewEventService creates the event service. spool may be nil, in which case queued
+// events only live in memory and are lost if the process exits before they are written.
+// dedupe may be nil to disable duplicate detection for client-supplied event IDs.
+// rollups may be nil when the hourly rollups are disabled. currencies converts order
+// amounts of purchase events into the reporting currency. Accepted events are
+// published to realtime streams unless realtime is nil.
+func NewEventService(repo *repository.EventRepository, deadLetters *repository.DeadLetterRepository, rollups *repository.RollupRepository, dedupe *EventDeduplicator, currencies *utils.CurrencyConverter, db *pgxpool.Pool, spool *EventSpool, realtime *RealtimeService, logger zerolog.Logger) *EventService {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	service := &EventService{
-		repo:      repo,
-		db:        db,
-		logger:    logger,
-		eventChan: make(chan models.Event, 1000), // Buffered channel
-		batchChan: make(chan []models.Event, 500),
-		ctx:       ctx,
-		cancel:    cancel,
+		repo:        repo,
+		deadLetters: deadLetters,
+		rollups:     rollups,
+		dedupe:      dedupe,
+		currencies:  currencies,
+		db:          db,
+		spool:       spool,
+		realtime:    realtime,
+		logger:      logger,
+		eventChan:   make(chan queuedEvent, 1000), // Buffered channel
+		batchChan:   make(chan []queuedEvent, 500),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 
 	// Start background workers
 	service.startBatchCollector()
 	service.startBatchProcessor()
+	if spool != nil {
+		service.startSpoolRedelivery()
+	}
 
 	return service
 }
@@ -100,6 +140,27 @@ func (s *EventService) ensurePartitionExists(ctx context.Context, targetDate tim
 	return nil
 }
 
+// ensureBatchPartitions ensures partitions exist for the months of all events in a batch
+func (s *EventService) ensureBatchPartitions(ctx context.Context, batch []models.Event) {
+	uniqueDates := make(map[string]time.Time)
+	for _, event := range batch {
+		if !event.Timestamp.IsZero() {
+			dateKey := event.Timestamp.Format("2006-01")
+			uniqueDates[dateKey] = event.Timestamp
+		}
+	}
+
+	// Create partitions for unique months
+	for _, eventTime := range uniqueDates {
+		if err := s.ensurePartitionExists(ctx, eventTime); err != nil {
+			s.logger.Warn().
+				Err(err).
+				Time("event_time", eventTime).
+				Msg("Failed to ensure partition exists, continuing anyway")
+		}
+	}
+}
+
 func (s *EventService) TrackEvent(ctx context.Context, event *models.Event) (*models.EventResponse, error) {
 	// Quick shutdown check
 	s.shutdownMu.RLock()
@@ -117,22 +178,57 @@ func (s *EventService) TrackEvent(ctx context.Context, event *models.Event) (*mo
 		event.Timestamp = time.Now()
 	}
 
+	// A client-supplied ID makes the event idempotent: retries within the window are dropped
+	var claimed []models.Event
+	if event.ID != uuid.Nil && s.dedupe != nil {
+		claimed = []models.Event{*event}
+		if fresh := s.dedupe.Claim(ctx, claimed); !fresh[0] {
+			s.logger.Debug().
+				Str("website_id", event.WebsiteID).
+				Str("event_id", event.ID.String()).
+				Msg("Duplicate event ignored")
+			return &models.EventResponse{
+				Status:    "accepted",
+				EventID:   event.ID.String(),
+				VisitorID: event.VisitorID,
+				SessionID: event.SessionID,
+				Duplicate: true,
+			}, nil
+		}
+	}
+
+	// Assign the ID up front so a replayed event keeps its identity
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+
 	// Enrich event data
 	s.enrichEventData(ctx, event)
 
+	// Persist to the spool before acknowledging the event to the client
+	seqs, err := s.spoolEvents([]models.Event{*event})
+	if err != nil {
+		s.releaseClaims(ctx, claimed)
+		return nil, err
+	}
+
 	// Try to send to channel (non-blocking)
-	select {
-	case s.eventChan <- *event:
+	if !s.enqueue(queuedEvent{seq: seqAt(seqs, 0), event: *event}) {
+		if s.spool == nil {
+			// Channel full, log warning but don't block
+			s.logger.Warn().Msg("Event channel full, dropping event")
+			s.releaseClaims(ctx, claimed)
+			return nil, fmt.Errorf("event queue full")
+		}
+		s.logger.Warn().Msg("Event channel full, event kept in spool for redelivery")
+	} else {
 		s.logger.Debug().
 			Str("website_id", event.WebsiteID).
 			Str("visitor_id", event.VisitorID).
 			Str("event_type", event.EventType).
 			Msg("Event queued")
-	default:
-		// Channel full, log warning but don't block
-		s.logger.Warn().Msg("Event channel full, dropping event")
-		return nil, fmt.Errorf("event queue full")
 	}
+	s.publishRealtime([]models.Event{*event})
 
 	return &models.EventResponse{
 		Status:    "accepted",
@@ -163,6 +259,16 @@ func (s *EventService) TrackBatchEvents(ctx context.Context, req *models.BatchEv
 		Int("events_count", len(req.Events)).
 		Msg("Processing batch events")
 
+	// Drop events whose client-supplied ID was already seen within the window
+	duplicates, claimed := s.removeDuplicates(ctx, req)
+	if len(req.Events) == 0 {
+		return &models.BatchEventResponse{
+			Status:          "accepted",
+			DuplicatesCount: duplicates,
+			ProcessedAt:     time.Now().Unix(),
+		}, nil
+	}
+
 	// Process and enrich all events
 	for i := range req.Events {
 		if req.Events[i].WebsiteID == "" {
@@ -174,6 +280,9 @@ func (s *EventService) TrackBatchEvents(ctx context.Context, req *models.BatchEv
 		if req.Events[i].Timestamp.IsZero() {
 			req.Events[i].Timestamp = time.Now()
 		}
+		if req.Events[i].ID == uuid.Nil {
+			req.Events[i].ID = uuid.New()
+		}
 
 		// Debug logging for each event
 		s.logger.Debug().
@@ -188,37 +297,130 @@ func (s *EventService) TrackBatchEvents(ctx context.Context, req *models.BatchEv
 		s.enrichEventData(ctx, &req.Events[i])
 	}
 
+	// Persist the whole batch with a single fsync before queueing
+	seqs, err := s.spoolEvents(req.Events)
+	if err != nil {
+		var failed []models.Event
+		for _, event := range req.Events {
+			if claimed[event.ID] {
+				failed = append(failed, event)
+			}
+		}
+		s.releaseClaims(ctx, failed)
+		return nil, err
+	}
+
 	// Send each event to the channel
 	accepted := 0
-	for _, event := range req.Events {
-		select {
-		case s.eventChan <- event:
+	published := make([]models.Event, 0, len(req.Events))
+	var dropped []models.Event
+	for i, event := range req.Events {
+		if s.enqueue(queuedEvent{seq: seqAt(seqs, i), event: event}) {
 			accepted++
+			published = append(published, event)
 			s.logger.Debug().
 				Str("event_id", event.ID.String()).
 				Str("event_type", event.EventType).
 				Msg("Event queued successfully")
-		default:
-			s.logger.Warn().
-				Str("event_type", event.EventType).
-				Msg("Event channel full during batch")
-			break
+			continue
+		}
+
+		s.logger.Warn().
+			Str("event_type", event.EventType).
+			Msg("Event channel full during batch")
+		if s.spool != nil {
+			// Spooled events are still durable and will be redelivered
+			accepted++
+			published = append(published, event)
+		} else if claimed[event.ID] {
+			dropped = append(dropped, event)
 		}
 	}
+	s.releaseClaims(ctx, dropped)
+	s.publishRealtime(published)
 
 	s.logger.Info().
 		Str("site_id", req.SiteID).
 		Int("total_events", len(req.Events)).
 		Int("accepted_events", accepted).
+		Int("duplicate_events", duplicates).
 		Msg("Batch events processing completed")
 
 	return &models.BatchEventResponse{
-		Status:      "accepted",
-		EventsCount: accepted,
-		ProcessedAt: time.Now().Unix(),
+		Status:          "accepted",
+		EventsCount:     accepted,
+		DuplicatesCount: duplicates,
+		ProcessedAt:     time.Now().Unix(),
 	}, nil
 }
 
+// publishRealtime queues accepted events for realtime streams, if enabled
+func (s *EventService) publishRealtime(events []models.Event) {
+	if s.realtime != nil {
+		s.realtime.Publish(events)
+	}
+}
+
+// removeDuplicates filters out events with a client-supplied ID that was already seen.
+// It returns how many were removed and the events it claimed the IDs of, which must be
+// released if those events are not accepted after all.
+func (s *EventService) removeDuplicates(ctx context.Context, req *models.BatchEventRequest) (int, map[uuid.UUID]bool) {
+	if s.dedupe == nil {
+		return 0, nil
+	}
+
+	var identified []models.Event
+	var positions []int
+	for i := range req.Events {
+		if req.Events[i].ID == uuid.Nil {
+			continue
+		}
+		if req.Events[i].WebsiteID == "" {
+			req.Events[i].WebsiteID = req.SiteID
+		}
+		identified = append(identified, req.Events[i])
+		positions = append(positions, i)
+	}
+	if len(identified) == 0 {
+		return 0, nil
+	}
+
+	duplicate := make(map[int]bool)
+	claimed := make(map[uuid.UUID]bool)
+	for i, fresh := range s.dedupe.Claim(ctx, identified) {
+		if fresh {
+			claimed[identified[i].ID] = true
+		} else {
+			duplicate[positions[i]] = true
+		}
+	}
+	if len(duplicate) == 0 {
+		return 0, claimed
+	}
+
+	kept := req.Events[:0]
+	for i, event := range req.Events {
+		if !duplicate[i] {
+			kept = append(kept, event)
+		}
+	}
+	req.Events = kept
+
+	s.logger.Info().
+		Str("site_id", req.SiteID).
+		Int("duplicate_events", len(duplicate)).
+		Msg("Duplicate events ignored")
+
+	return len(duplicate), claimed
+}
+
+// releaseClaims releases the dedupe claims of events that were not accepted
+func (s *EventService) releaseClaims(ctx context.Context, events []models.Event) {
+	if s.dedupe != nil {
+		s.dedupe.Release(ctx, events)
+	}
+}
+
 // startBatchCollector collects events into small batches
 func (s *EventService) startBatchCollector() {
 	s.wg.Add(1)
@@ -228,7 +430,7 @@ func (s *EventService) startBatchCollector() {
 		ticker := time.NewTicker(FlushInterval)
 		defer ticker.Stop()
 
-		batch := make([]models.Event, 0, BatchSize)
+		batch := make([]queuedEvent, 0, BatchSize)
 
 		for {
 			select {
@@ -237,7 +439,6 @@ func (s *EventService) startBatchCollector() {
 				if len(batch) > 0 {
 					s.sendBatch(batch)
 				}
-				close(s.batchChan)
 				return
 
 			case event := <-s.eventChan:
@@ -246,7 +447,7 @@ func (s *EventService) startBatchCollector() {
 				// Send batch when it's full
 				if len(batch) >= BatchSize {
 					s.sendBatch(batch)
-					batch = make([]models.Event, 0, BatchSize)
+					batch = make([]queuedEvent, 0, BatchSize)
 					ticker.Reset(FlushInterval) // Reset timer
 				}
 
@@ -254,7 +455,7 @@ func (s *EventService) startBatchCollector() {
 				// Send batch on timer (even if not full)
 				if len(batch) > 0 {
 					s.sendBatch(batch)
-					batch = make([]models.Event, 0, BatchSize)
+					batch = make([]queuedEvent, 0, BatchSize)
 				}
 			}
 		}
@@ -262,17 +463,21 @@ func (s *EventService) startBatchCollector() {
 }
 
 // sendBatch sends a batch to the processor
-func (s *EventService) sendBatch(batch []models.Event) {
-	batchCopy := make([]models.Event, len(batch))
+func (s *EventService) sendBatch(batch []queuedEvent) {
+	batchCopy := make([]queuedEvent, len(batch))
 	copy(batchCopy, batch)
 
 	select {
 	case s.batchChan <- batchCopy:
 		s.logger.Debug().Int("batch_size", len(batchCopy)).Msg("Batch sent for processing")
-	case <-s.ctx.Done():
-		s.logger.Warn().Msg("Batch dropped during shutdown")
 	default:
-		s.logger.Warn().Msg("Batch channel full, dropping batch")
+		if s.ctx.Err() != nil {
+			s.logger.Warn().Msg("Batch dropped during shutdown")
+		} else {
+			s.logger.Warn().Msg("Batch channel full, dropping batch")
+		}
+		// Spooled events survive the drop and are redelivered later
+		s.releaseBatch(batchCopy)
 	}
 }
 
@@ -303,33 +508,21 @@ func (s *EventService) startBatchProcessor() {
 }
 
 // processBatch writes a batch to the database
-func (s *EventService) processBatch(batch []models.Event) {
-	if len(batch) == 0 {
+func (s *EventService) processBatch(queued []queuedEvent) {
+	if len(queued) == 0 {
 		return
 	}
 
+	batch := make([]models.Event, len(queued))
+	for i := range queued {
+		batch[i] = queued[i].event
+	}
+
 	start := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Ensure partitions exist for all events in the batch
-	uniqueDates := make(map[string]time.Time)
-	for _, event := range batch {
-		if !event.Timestamp.IsZero() {
-			dateKey := event.Timestamp.Format("2006-01")
-			uniqueDates[dateKey] = event.Timestamp
-		}
-	}
-
-	// Create partitions for unique months
-	for _, eventTime := range uniqueDates {
-		if err := s.ensurePartitionExists(ctx, eventTime); err != nil {
-			s.logger.Warn().
-				Err(err).
-				Time("event_time", eventTime).
-				Msg("Failed to ensure partition exists, continuing anyway")
-		}
-	}
+	s.ensureBatchPartitions(ctx, batch)
 
 	// Log event types and website IDs in the batch
 	eventTypes := make(map[string]int)
@@ -345,31 +538,286 @@ func (s *EventService) processBatch(batch []models.Event) {
 		Interface("website_ids", websiteIDs).
 		Msg("Processing batch")
 
+	// Write the batch, retrying only the events that failed
+	pending := queued
+	var reasons []string
+	processed := 0
+	attempts := 0
+	for attempts < MaxBatchRetries+1 {
+		if attempts > 0 && !s.waitForRetry(attempts) {
+			break
+		}
+		attempts++
+
+		var failed []queuedEvent
+		failed, reasons = s.writeBatch(pending)
+		processed += len(pending) - len(failed)
+
+		// Only now is it safe to drop the stored events from the spool
+		s.ackBatch(excludeQueued(pending, failed))
+
+		pending = failed
+		if len(pending) == 0 {
+			break
+		}
+
+		s.logger.Warn().
+			Int("failed_count", len(pending)).
+			Int("attempt", attempts).
+			Str("first_error", reasons[0]).
+			Msg("Some events failed in batch, retrying")
+	}
+
+	duration := time.Since(start)
+	s.logger.Info().
+		Int("processed", processed).
+		Int("failed", len(pending)).
+		Int("total", len(batch)).
+		Int("attempts", attempts).
+		Dur("duration", duration).
+		Interface("event_types", eventTypes).
+		Msg("Batch processed")
+
+	if len(pending) == 0 {
+		return
+	}
+
+	// Interrupted by shutdown: leave spooled events for the next start rather than dead-lettering them
+	if s.ctx.Err() != nil && s.spool != nil {
+		s.releaseBatch(pending)
+		return
+	}
+	s.deadLetterBatch(pending, reasons, attempts)
+}
+
+// writeBatch stores events and returns the ones that failed, with the reason for each
+func (s *EventService) writeBatch(queued []queuedEvent) ([]queuedEvent, []string) {
+	batch := make([]models.Event, len(queued))
+	for i := range queued {
+		batch[i] = queued[i].event
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
 	result, err := s.repo.CreateBatch(ctx, batch)
 	if err != nil {
 		s.logger.Error().
 			Err(err).
 			Int("events_count", len(batch)).
-			Interface("event_types", eventTypes).
 			Msg("Failed to process batch")
+
+		reasons := make([]string, len(queued))
+		for i := range reasons {
+			reasons[i] = err.Error()
+		}
+		return queued, reasons
+	}
+
+	written, failures := SplitBatchResult(batch, result)
+	failed := make([]queuedEvent, 0, len(failures))
+	reasons := make([]string, 0, len(failures))
+	for i := range queued {
+		if failErr, ok := failures[i]; ok {
+			failed = append(failed, queued[i])
+			reasons = append(reasons, failErr.Error())
+		}
+	}
+	s.markRollupsDirty(ctx, written)
+
+	return failed, reasons
+}
+
+// SplitBatchResult splits a written batch into the events that were stored and the
+// errors of those that were not, keyed by their index in the batch
+func SplitBatchResult(batch []models.Event, result *repository.BatchResult) ([]models.Event, map[int]error) {
+	failures := make(map[int]error, len(result.Failures))
+	for _, failure := range result.Failures {
+		if failure.Index >= 0 && failure.Index < len(batch) {
+			failures[failure.Index] = failure.Err
+		}
+	}
+
+	written := make([]models.Event, 0, len(batch)-len(failures))
+	for i := range batch {
+		if _, ok := failures[i]; !ok {
+			written = append(written, batch[i])
+		}
+	}
+	return written, failures
+}
+
+// markRollupsDirty queues the hours of stored events for the rollup compactor. A failure
+// is only logged: the events are stored, and reports fall back to raw events meanwhile.
+func (s *EventService) markRollupsDirty(ctx context.Context, events []models.Event) {
+	if s.rollups == nil || len(events) == 0 {
 		return
 	}
+	if err := s.rollups.MarkDirty(ctx, events); err != nil {
+		s.logger.Error().Err(err).Int("events_count", len(events)).Msg("Failed to queue events for rollup")
+	}
+}
+
+// RetryDelay returns the backoff before the given retry of a batch, starting at
+// RetryBaseDelay and doubling up to RetryMaxDelay
+func RetryDelay(retry int) time.Duration {
+	delay := RetryBaseDelay
+	for i := 1; i < retry && delay < RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > RetryMaxDelay {
+		delay = RetryMaxDelay
+	}
+	return delay
+}
+
+// waitForRetry sleeps for the backoff of the given retry, returning false if the service is shutting down
+func (s *EventService) waitForRetry(retry int) bool {
+	timer := time.NewTimer(RetryDelay(retry))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// deadLetterBatch moves events that exhausted their retries to the dead-letter table.
+// If that fails too, spooled events are handed back to the spool so nothing is lost.
+func (s *EventService) deadLetterBatch(queued []queuedEvent, reasons []string, attempts int) {
+	if s.deadLetters == nil {
+		s.logger.Error().Int("events_count", len(queued)).Msg("No dead-letter store configured, events not stored")
+		s.releaseBatch(queued)
+		return
+	}
+
+	entries := make([]models.DeadLetterEvent, len(queued))
+	for i := range queued {
+		entries[i] = models.DeadLetterEvent{
+			Event:    queued[i].event,
+			Error:    reasons[i],
+			Attempts: attempts,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.deadLetters.InsertBatch(ctx, entries); err != nil {
+		s.logger.Error().Err(err).Int("events_count", len(queued)).Msg("Failed to dead-letter events")
+		s.releaseBatch(queued)
+		return
+	}
+
+	s.ackBatch(queued)
+	s.logger.Warn().
+		Int("events_count", len(queued)).
+		Int("attempts", attempts).
+		Msg("Events moved to dead-letter table")
+}
+
+// excludeQueued returns the events in batch that are not in failed
+func excludeQueued(batch, failed []queuedEvent) []queuedEvent {
+	if len(failed) == 0 {
+		return batch
+	}
+
+	failedIDs := make(map[uuid.UUID]bool, len(failed))
+	for _, queued := range failed {
+		failedIDs[queued.event.ID] = true
+	}
+
+	succeeded := make([]queuedEvent, 0, len(batch)-len(failed))
+	for _, queued := range batch {
+		if !failedIDs[queued.event.ID] {
+			succeeded = append(succeeded, queued)
+		}
+	}
+	return succeeded
+}
+
+// ListDeadLetters returns dead-lettered events, optionally for a single website
+func (s *EventService) ListDeadLetters(ctx context.Context, websiteID string, limit, offset int) ([]models.DeadLetterEvent, int, error) {
+	return s.deadLetters.List(ctx, websiteID, limit, offset)
+}
+
+// ReplayDeadLetters writes dead-lettered events back to the events table.
+// Events that are stored are removed from the dead-letter table; the rest stay with an updated error.
+func (s *EventService) ReplayDeadLetters(ctx context.Context, req *models.DeadLetterReplayRequest) (*models.DeadLetterReplayResult, error) {
+	ids, err := models.ParseDeadLetterIDs(req.IDs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDeadLetterRequest, err)
+	}
+
+	entries, err := s.deadLetters.GetForReplay(ctx, ids, req.WebsiteID, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dead-letter events: %w", err)
+	}
+
+	result := &models.DeadLetterReplayResult{Requested: len(entries)}
+	if len(entries) == 0 {
+		return result, nil
+	}
+
+	batch := make([]models.Event, len(entries))
+	for i := range entries {
+		batch[i] = entries[i].Event
+	}
+
+	// Events can be dead-lettered for a month whose partition was never created
+	s.ensureBatchPartitions(ctx, batch)
+
+	writeResult, err := s.repo.CreateBatch(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay events: %w", err)
+	}
+
+	written, failed := SplitBatchResult(batch, writeResult)
+	replayed := make([]uuid.UUID, 0, len(written))
+	for i, entry := range entries {
+		if failErr, ok := failed[i]; ok {
+			if err := s.deadLetters.MarkFailed(ctx, entry.ID, failErr.Error()); err != nil {
+				s.logger.Warn().Err(err).Str("id", entry.ID.String()).Msg("Failed to update dead-letter event")
+			}
+			result.Failed++
+			continue
+		}
+		replayed = append(replayed, entry.ID)
+	}
+	s.markRollupsDirty(ctx, written)
+
+	if _, err := s.deadLetters.Delete(ctx, replayed); err != nil {
+		return nil, fmt.Errorf("failed to remove replayed events: %w", err)
+	}
+	result.Replayed = len(replayed)
 
-	duration := time.Since(start)
 	s.logger.Info().
-		Int("processed", result.Processed).
+		Int("requested", result.Requested).
+		Int("replayed", result.Replayed).
 		Int("failed", result.Failed).
-		Int("total", result.Total).
-		Dur("duration", duration).
-		Interface("event_types", eventTypes).
-		Msg("Batch processed successfully")
+		Msg("Replayed dead-letter events")
 
-	if result.Failed > 0 {
-		s.logger.Warn().
-			Int("failed_count", result.Failed).
-			Interface("errors", result.Errors).
-			Msg("Some events failed in batch")
+	return result, nil
+}
+
+// PurgeDeadLetters deletes dead-lettered events by ID, or by website and age
+func (s *EventService) PurgeDeadLetters(ctx context.Context, req *models.DeadLetterPurgeRequest) (int64, error) {
+	if err := req.Validate(); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidDeadLetterRequest, err)
 	}
+
+	ids, _ := models.ParseDeadLetterIDs(req.IDs)
+	if len(ids) > 0 {
+		return s.deadLetters.Delete(ctx, ids)
+	}
+
+	before := time.Now()
+	if req.Before != nil {
+		before = *req.Before
+	}
+	return s.deadLetters.Purge(ctx, req.WebsiteID, before)
 }
 
 // Shutdown gracefully shuts down the service
@@ -394,6 +842,11 @@ func (s *EventService) Shutdown(timeout time.Duration) error {
 	select {
 	case <-done:
 		s.logger.Info().Msg("Event service shutdown completed")
+		if s.spool != nil {
+			if err := s.spool.Close(); err != nil {
+				return fmt.Errorf("failed to close event spool: %w", err)
+			}
+		}
 		return nil
 	case <-time.After(timeout):
 		s.logger.Warn().Msg("Event service shutdown timed out")
@@ -401,6 +854,117 @@ func (s *EventService) Shutdown(timeout time.Duration) error {
 	}
 }
 
+// startSpoolRedelivery feeds events recovered from the spool on startup, and events
+// released after a dropped or failed batch, back into the batch processor
+func (s *EventService) startSpoolRedelivery() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(SpoolRedeliveryInterval)
+		defer ticker.Stop()
+
+		for {
+			s.redeliverSpooled()
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// redeliverSpooled drains released events from the spool in BatchSize chunks
+func (s *EventService) redeliverSpooled() {
+	for {
+		seqs, events, err := s.spool.TakeReleased(BatchSize)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to read events from spool")
+			return
+		}
+		if len(seqs) == 0 {
+			return
+		}
+
+		batch := make([]queuedEvent, len(seqs))
+		for i := range seqs {
+			batch[i] = queuedEvent{seq: seqs[i], event: events[i]}
+		}
+
+		select {
+		case s.batchChan <- batch:
+			s.logger.Info().Int("events_count", len(batch)).Msg("Redelivering spooled events")
+		case <-s.ctx.Done():
+			s.releaseBatch(batch)
+			return
+		}
+	}
+}
+
+// spoolEvents writes events to the spool, if one is configured
+func (s *EventService) spoolEvents(events []models.Event) ([]uint64, error) {
+	if s.spool == nil {
+		return nil, nil
+	}
+
+	seqs, err := s.spool.Append(events)
+	if err != nil {
+		s.logger.Error().Err(err).Int("events_count", len(events)).Msg("Failed to write events to spool")
+		return nil, fmt.Errorf("failed to persist events: %w", err)
+	}
+	return seqs, nil
+}
+
+// enqueue tries a non-blocking send to the event channel
+func (s *EventService) enqueue(event queuedEvent) bool {
+	select {
+	case s.eventChan <- event:
+		return true
+	default:
+		if event.seq != 0 {
+			s.spool.Release([]uint64{event.seq})
+		}
+		return false
+	}
+}
+
+// ackBatch removes committed events from the spool
+func (s *EventService) ackBatch(batch []queuedEvent) {
+	if s.spool == nil {
+		return
+	}
+	if err := s.spool.Ack(batchSeqs(batch)); err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to acknowledge events in spool")
+	}
+}
+
+// releaseBatch hands uncommitted events back to the spool for redelivery
+func (s *EventService) releaseBatch(batch []queuedEvent) {
+	if s.spool == nil {
+		return
+	}
+	s.spool.Release(batchSeqs(batch))
+}
+
+func batchSeqs(batch []queuedEvent) []uint64 {
+	seqs := make([]uint64, 0, len(batch))
+	for _, queued := range batch {
+		if queued.seq != 0 {
+			seqs = append(seqs, queued.seq)
+		}
+	}
+	return seqs
+}
+
+func seqAt(seqs []uint64, i int) uint64 {
+	if i < len(seqs) {
+		return seqs[i]
+	}
+	return 0
+}
+
 func (s *EventService) GetEvents(ctx context.Context, websiteID string, limit int, offset int) ([]models.Event, error) {
 	if websiteID == "" {
 		return nil, fmt.Errorf("website_id is required")
@@ -417,7 +981,7 @@ func (s *EventService) GetEvents(ctx context.Context, websiteID string, limit in
 
 // GetStats returns service statistics
 func (s *EventService) GetStats() map[string]interface{} {
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"event_queue_size": len(s.eventChan),
 		"event_queue_cap":  cap(s.eventChan),
 		"batch_queue_size": len(s.batchChan),
@@ -425,6 +989,36 @@ func (s *EventService) GetStats() map[string]interface{} {
 		"batch_size":       BatchSize,
 		"flush_interval":   FlushInterval.String(),
 	}
+
+	if s.spool != nil {
+		for key, value := range s.spool.Stats() {
+			stats[key] = value
+		}
+	}
+
+	return stats
+}
+
+// NormalizeRevenue stores a purchase event's amount in the reporting currency as the
+// revenue property. The order must have passed utils.ValidateEvent; only currencies
+// without a rate fail here. Other event types are left unchanged.
+func (s *EventService) NormalizeRevenue(event *models.Event) error {
+	if event.EventType != models.RevenueEventType {
+		return nil
+	}
+
+	order, err := models.ParseOrder(event.Properties)
+	if err != nil {
+		return err
+	}
+	revenue, err := s.currencies.Convert(order.Amount, order.Currency)
+	if err != nil {
+		return err
+	}
+
+	event.Properties[models.RevenueCurrencyProperty] = order.Currency
+	event.Properties[models.RevenueProperty] = revenue
+	return nil
 }
 
 func (s *EventService) enrichEventData(ctx context.Context, event *models.Event) {
@@ -0,0 +1,618 @@
+package services
+
+import (
+	"analytics-app/models"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultSpoolSegmentBytes is the size at which the active segment is sealed
+	DefaultSpoolSegmentBytes = 16 * 1024 * 1024
+
+	spoolSegmentExt = ".log"
+	spoolAckExt     = ".ack"
+)
+
+// spoolRecord is a single line in a spool segment
+type spoolRecord struct {
+	Seq   uint64       `json:"seq"`
+	Event models.Event `json:"event"`
+}
+
+// spoolSegment is one append-only file holding a contiguous range of sequence numbers.
+// Acknowledged sequence numbers are written to a sibling .ack file so that a restart
+// does not replay events that were already committed.
+type spoolSegment struct {
+	first      uint64
+	last       uint64
+	pending    int
+	size       int64
+	syncedSize int64
+	sealed     bool
+	file       *os.File
+	ackFile    *os.File
+}
+
+// spoolSync is a group of appends made durable by a single fsync
+type spoolSync struct {
+	done bool
+	err  error
+}
+
+// EventSpool is an append-only write-ahead log for accepted events.
+// Events are written to disk before TrackEvent returns and are only removed
+// once the batch containing them has been committed to PostgreSQL. Delivery is
+// at-least-once: events whose acknowledgement was lost in a crash are replayed.
+//
+// Appends are group committed: each one writes its records and joins the pending
+// sync, and one appender fsyncs the segment for the whole group outside the lock.
+// Appends arriving during that fsync share the next one.
+type EventSpool struct {
+	dir             string
+	maxSegmentBytes int64
+	logger          zerolog.Logger
+
+	mu       sync.Mutex
+	nextSeq  uint64
+	segments []*spoolSegment // ordered by first sequence number
+	released map[uint64]struct{}
+	closed   bool
+
+	syncCond *sync.Cond
+	syncing  bool
+	pending  *spoolSync // appends written since the last fsync started
+	synced   uint64     // highest sequence number known to be on disk
+}
+
+// NewEventSpool opens (or creates) a spool in dir and recovers any unacknowledged
+// events left behind by a previous run. Recovered events are immediately available
+// through TakeReleased.
+func NewEventSpool(dir string, maxSegmentBytes int64, logger zerolog.Logger) (*EventSpool, error) {
+	if maxSegmentBytes <= 0 {
+		maxSegmentBytes = DefaultSpoolSegmentBytes
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	spool := &EventSpool{
+		dir:             dir,
+		maxSegmentBytes: maxSegmentBytes,
+		logger:          logger,
+		nextSeq:         1,
+		released:        make(map[uint64]struct{}),
+	}
+	spool.syncCond = sync.NewCond(&spool.mu)
+
+	if err := spool.recover(); err != nil {
+		return nil, err
+	}
+	spool.synced = spool.nextSeq - 1
+
+	return spool, nil
+}
+
+// recover loads existing segments, drops the ones that are fully acknowledged and
+// marks every unacknowledged event for redelivery
+func (s *EventSpool) recover() error {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*"+spoolSegmentExt))
+	if err != nil {
+		return fmt.Errorf("failed to list spool segments: %w", err)
+	}
+	sort.Strings(matches)
+
+	recovered := 0
+	for _, path := range matches {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), spoolSegmentExt), 10, 64)
+		if err != nil {
+			s.logger.Warn().Str("path", path).Msg("Ignoring unrecognised file in spool directory")
+			continue
+		}
+
+		acked, err := s.readAcks(first)
+		if err != nil {
+			return err
+		}
+
+		seg := &spoolSegment{first: first, last: first, sealed: true}
+		err = s.scanSegment(first, func(rec spoolRecord) bool {
+			if rec.Seq > seg.last {
+				seg.last = rec.Seq
+			}
+			if _, ok := acked[rec.Seq]; !ok {
+				seg.pending++
+				s.released[rec.Seq] = struct{}{}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		if seg.last >= s.nextSeq {
+			s.nextSeq = seg.last + 1
+		}
+
+		if seg.pending == 0 {
+			s.removeSegmentFiles(first)
+			continue
+		}
+
+		recovered += seg.pending
+		s.segments = append(s.segments, seg)
+	}
+
+	if recovered > 0 {
+		s.logger.Info().
+			Int("events", recovered).
+			Int("segments", len(s.segments)).
+			Msg("Recovered unacknowledged events from spool")
+	}
+
+	return nil
+}
+
+// Append durably writes events to the active segment and returns their sequence numbers.
+// It returns once the fsync covering them has completed.
+func (s *EventSpool) Append(events []models.Event) ([]uint64, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("spool is closed")
+	}
+
+	seg, err := s.activeSegment()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf strings.Builder
+	seqs := make([]uint64, len(events))
+	for i := range events {
+		seq := s.nextSeq + uint64(i)
+		line, err := json.Marshal(spoolRecord{Seq: seq, Event: events[i]})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode spool record: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		seqs[i] = seq
+	}
+
+	n, err := seg.file.WriteString(buf.String())
+	if err != nil {
+		// A partial record would be merged with the next one: cut it off
+		s.truncateSegment(seg, seg.size, err)
+		return nil, fmt.Errorf("failed to write spool segment: %w", err)
+	}
+
+	s.nextSeq += uint64(len(events))
+	seg.last = s.nextSeq - 1
+	seg.pending += len(events)
+	seg.size += int64(n)
+
+	if s.pending == nil {
+		s.pending = &spoolSync{}
+	}
+	group := s.pending
+
+	if seg.size >= s.maxSegmentBytes {
+		s.sealSegment(seg)
+	}
+
+	for !group.done {
+		if s.syncing {
+			s.syncCond.Wait()
+			continue
+		}
+		s.syncPending()
+	}
+	if group.err != nil {
+		return nil, fmt.Errorf("failed to sync spool segment: %w", group.err)
+	}
+
+	return seqs, nil
+}
+
+// syncPending fsyncs the active segment for the pending group, without holding the lock
+// during the fsync. The caller holds s.mu and no other sync is running.
+func (s *EventSpool) syncPending() {
+	group := s.pending
+	s.pending = nil
+	seg := s.segments[len(s.segments)-1]
+	upto, size := s.nextSeq-1, seg.size
+
+	s.syncing = true
+	s.mu.Unlock()
+	err := seg.file.Sync()
+	s.mu.Lock()
+	s.syncing = false
+
+	s.completeSync(group, seg, upto, size, err)
+}
+
+// completeSync records the outcome of an fsync of seg covering records up to upto
+func (s *EventSpool) completeSync(group *spoolSync, seg *spoolSegment, upto uint64, size int64, err error) {
+	if err == nil {
+		s.synced = upto
+		seg.syncedSize = size
+	} else {
+		s.failSync(seg, err)
+	}
+	group.err = err
+	group.done = true
+	s.syncCond.Broadcast()
+}
+
+// failSync handles a failed fsync of the active segment. Whatever was written since the
+// last successful fsync may or may not be on disk, so it is cut off and every append it
+// belongs to fails, including those written while the fsync ran.
+func (s *EventSpool) failSync(seg *spoolSegment, err error) {
+	lost := int(s.nextSeq - 1 - s.synced)
+	if s.truncateSegment(seg, seg.syncedSize, err) {
+		seg.pending -= lost
+		s.nextSeq = s.synced + 1
+		seg.last = seg.first
+		if s.synced > seg.first {
+			seg.last = s.synced
+		}
+	} else {
+		// The segment was sealed with the records in it: they are replayed after a restart
+		s.synced = s.nextSeq - 1
+	}
+
+	if s.pending != nil {
+		s.pending.err = err
+		s.pending.done = true
+		s.pending = nil
+	}
+}
+
+// truncateSegment cuts the active segment back to size after a failed write or fsync,
+// so the next record does not follow a torn one. A segment that cannot be truncated is
+// sealed instead and false is returned. The caller holds s.mu.
+func (s *EventSpool) truncateSegment(seg *spoolSegment, size int64, cause error) bool {
+	// An fsync of this file may be running: let it finish before the file changes
+	for s.syncing {
+		s.syncCond.Wait()
+	}
+	if seg.sealed {
+		return false
+	}
+
+	err := seg.file.Truncate(size)
+	if err == nil {
+		seg.size = size
+		return true
+	}
+
+	s.logger.Error().Err(err).AnErr("cause", cause).Uint64("segment", seg.first).
+		Msg("Failed to truncate spool segment, sealing it")
+	seg.sealed = true
+	seg.file.Close()
+	seg.file = nil
+	return false
+}
+
+// Ack marks events as committed. Segments are deleted once all of their events are acknowledged.
+func (s *EventSpool) Ack(seqs []uint64) error {
+	if len(seqs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bySegment := make(map[*spoolSegment][]uint64)
+	for _, seq := range seqs {
+		if seg := s.findSegment(seq); seg != nil {
+			bySegment[seg] = append(bySegment[seg], seq)
+		}
+		delete(s.released, seq)
+	}
+
+	var firstErr error
+	for seg, acked := range bySegment {
+		seg.pending -= len(acked)
+
+		if seg.pending <= 0 && seg.sealed {
+			s.dropSegment(seg)
+			continue
+		}
+
+		// Ack files are not fsynced: losing an ack only causes a duplicate replay
+		if err := s.writeAcks(seg, acked); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Release marks events that were accepted but could not be delivered (queue full,
+// batch failed) so they are handed out again by TakeReleased
+func (s *EventSpool) Release(seqs []uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seq := range seqs {
+		s.released[seq] = struct{}{}
+	}
+}
+
+// TakeReleased reads up to max released events back from disk
+func (s *EventSpool) TakeReleased(max int) ([]uint64, []models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.released) == 0 || max <= 0 {
+		return nil, nil, nil
+	}
+
+	seqs := make([]uint64, 0, max)
+	events := make([]models.Event, 0, max)
+
+	for _, seg := range s.segments {
+		if len(seqs) >= max {
+			break
+		}
+		if !s.hasReleasedIn(seg) {
+			continue
+		}
+
+		err := s.scanSegment(seg.first, func(rec spoolRecord) bool {
+			if _, ok := s.released[rec.Seq]; ok {
+				seqs = append(seqs, rec.Seq)
+				events = append(events, rec.Event)
+			}
+			return len(seqs) < max
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, seq := range seqs {
+		delete(s.released, seq)
+	}
+
+	return seqs, events, nil
+}
+
+// Close flushes and closes all open segment files
+func (s *EventSpool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	// Finish syncing appends still waiting for an fsync
+	for s.syncing || s.pending != nil {
+		if s.syncing {
+			s.syncCond.Wait()
+			continue
+		}
+		s.syncPending()
+	}
+
+	var firstErr error
+	for _, seg := range s.segments {
+		if err := s.closeSegmentFiles(seg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Stats returns spool statistics
+func (s *EventSpool) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := 0
+	for _, seg := range s.segments {
+		pending += seg.pending
+	}
+
+	return map[string]interface{}{
+		"spool_dir":      s.dir,
+		"spool_segments": len(s.segments),
+		"spool_pending":  pending,
+		"spool_released": len(s.released),
+	}
+}
+
+// Helper methods
+
+func (s *EventSpool) activeSegment() (*spoolSegment, error) {
+	if n := len(s.segments); n > 0 && !s.segments[n-1].sealed {
+		return s.segments[n-1], nil
+	}
+
+	first := s.nextSeq
+	file, err := os.OpenFile(s.segmentPath(first, spoolSegmentExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+
+	seg := &spoolSegment{first: first, last: first, file: file}
+	s.segments = append(s.segments, seg)
+	return seg, nil
+}
+
+// sealSegment closes a full segment. Records not yet synced are synced first, after any
+// fsync already running on the file.
+func (s *EventSpool) sealSegment(seg *spoolSegment) {
+	for s.syncing {
+		s.syncCond.Wait()
+	}
+	if seg.sealed {
+		return
+	}
+
+	if s.pending != nil {
+		group := s.pending
+		s.pending = nil
+		s.completeSync(group, seg, s.nextSeq-1, seg.size, seg.file.Sync())
+		if seg.sealed {
+			return
+		}
+	}
+
+	seg.sealed = true
+	if seg.file != nil {
+		seg.file.Close()
+		seg.file = nil
+	}
+	if seg.pending <= 0 {
+		s.dropSegment(seg)
+	}
+}
+
+func (s *EventSpool) dropSegment(seg *spoolSegment) {
+	s.closeSegmentFiles(seg)
+	s.removeSegmentFiles(seg.first)
+
+	for i, candidate := range s.segments {
+		if candidate == seg {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *EventSpool) findSegment(seq uint64) *spoolSegment {
+	i := sort.Search(len(s.segments), func(i int) bool {
+		return s.segments[i].last >= seq
+	})
+	if i < len(s.segments) && s.segments[i].first <= seq {
+		return s.segments[i]
+	}
+	return nil
+}
+
+func (s *EventSpool) hasReleasedIn(seg *spoolSegment) bool {
+	for seq := range s.released {
+		if seq >= seg.first && seq <= seg.last {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *EventSpool) writeAcks(seg *spoolSegment, seqs []uint64) error {
+	if seg.ackFile == nil {
+		file, err := os.OpenFile(s.segmentPath(seg.first, spoolAckExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open spool ack file: %w", err)
+		}
+		seg.ackFile = file
+	}
+
+	var buf strings.Builder
+	for _, seq := range seqs {
+		buf.WriteString(strconv.FormatUint(seq, 10))
+		buf.WriteByte('\n')
+	}
+
+	if _, err := seg.ackFile.WriteString(buf.String()); err != nil {
+		return fmt.Errorf("failed to write spool acks: %w", err)
+	}
+	return nil
+}
+
+func (s *EventSpool) readAcks(first uint64) (map[uint64]struct{}, error) {
+	acked := make(map[uint64]struct{})
+
+	file, err := os.Open(s.segmentPath(first, spoolAckExt))
+	if os.IsNotExist(err) {
+		return acked, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool ack file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if seq, err := strconv.ParseUint(scanner.Text(), 10, 64); err == nil {
+			acked[seq] = struct{}{}
+		}
+	}
+	return acked, scanner.Err()
+}
+
+// scanSegment calls fn for every decodable record in a segment until fn returns false.
+// A torn final line from a crash mid-write is skipped.
+func (s *EventSpool) scanSegment(first uint64, fn func(spoolRecord) bool) error {
+	file, err := os.Open(s.segmentPath(first, spoolSegmentExt))
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec spoolRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			s.logger.Warn().Err(err).Uint64("segment", first).Msg("Skipping corrupt spool record")
+			continue
+		}
+		if !fn(rec) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *EventSpool) closeSegmentFiles(seg *spoolSegment) error {
+	var firstErr error
+	if seg.file != nil {
+		if err := seg.file.Sync(); err != nil {
+			firstErr = err
+		}
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		seg.file = nil
+	}
+	if seg.ackFile != nil {
+		if err := seg.ackFile.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		seg.ackFile = nil
+	}
+	return firstErr
+}
+
+func (s *EventSpool) removeSegmentFiles(first uint64) {
+	for _, ext := range []string{spoolSegmentExt, spoolAckExt} {
+		if err := os.Remove(s.segmentPath(first, ext)); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn().Err(err).Uint64("segment", first).Msg("Failed to remove spool file")
+		}
+	}
+}
+
+func (s *EventSpool) segmentPath(first uint64, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", first, ext))
+}
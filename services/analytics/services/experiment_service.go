@@ -0,0 +1,273 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidExperiment is returned when an experiment is missing its variants or metric
+var ErrInvalidExperiment = errors.New("invalid experiment")
+
+type ExperimentService struct {
+	repo    *repository.ExperimentRepository
+	goals   *repository.GoalRepository
+	funnels *repository.FunnelRepository
+	logger  zerolog.Logger
+}
+
+func NewExperimentService(repo *repository.ExperimentRepository, goals *repository.GoalRepository, funnels *repository.FunnelRepository, logger zerolog.Logger) *ExperimentService {
+	return &ExperimentService{
+		repo:    repo,
+		goals:   goals,
+		funnels: funnels,
+		logger:  logger,
+	}
+}
+
+// CreateExperiment validates and stores an experiment. Variants are read from the
+// "experiment" and "variant" properties unless others are given, confidence defaults
+// to 95% and the experiment starts now unless started_at is set.
+func (s *ExperimentService) CreateExperiment(ctx context.Context, req *models.CreateExperimentRequest) (*models.Experiment, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("experiment_name", req.Name).
+		Msg("Creating experiment")
+
+	experiment := &models.Experiment{
+		WebsiteID:          req.WebsiteID,
+		UserID:             req.UserID,
+		Name:               req.Name,
+		Key:                req.Key,
+		ExperimentProperty: req.ExperimentProperty,
+		VariantProperty:    req.VariantProperty,
+		ControlVariant:     req.ControlVariant,
+		GoalID:             req.GoalID,
+		FunnelID:           req.FunnelID,
+		Confidence:         req.Confidence,
+		SampleSize:         req.SampleSize,
+		StartedAt:          time.Now(),
+		EndedAt:            req.EndedAt,
+	}
+	if experiment.ExperimentProperty == "" {
+		experiment.ExperimentProperty = models.DefaultExperimentProperty
+	}
+	if experiment.VariantProperty == "" {
+		experiment.VariantProperty = models.DefaultVariantProperty
+	}
+	if experiment.Confidence == 0 {
+		experiment.Confidence = models.DefaultExperimentLevel
+	}
+	if req.StartedAt != nil {
+		experiment.StartedAt = *req.StartedAt
+	}
+	if err := experiment.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExperiment, err)
+	}
+	if err := s.checkMetric(ctx, experiment); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, experiment); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create experiment")
+		return nil, err
+	}
+
+	return experiment, nil
+}
+
+func (s *ExperimentService) GetExperiments(ctx context.Context, websiteID string) ([]models.Experiment, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting experiments")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *ExperimentService) GetExperiment(ctx context.Context, experimentID uuid.UUID) (*models.Experiment, error) {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Getting experiment")
+
+	return s.repo.GetByID(ctx, experimentID)
+}
+
+func (s *ExperimentService) UpdateExperiment(ctx context.Context, experimentID uuid.UUID, req *models.UpdateExperimentRequest) (*models.Experiment, error) {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Updating experiment")
+
+	experiment, err := s.repo.GetByID(ctx, experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		experiment.Name = *req.Name
+	}
+	if req.ControlVariant != nil {
+		experiment.ControlVariant = *req.ControlVariant
+	}
+	if req.Confidence != nil {
+		experiment.Confidence = *req.Confidence
+	}
+	if req.SampleSize != nil {
+		experiment.SampleSize = *req.SampleSize
+	}
+	if req.StartedAt != nil {
+		experiment.StartedAt = *req.StartedAt
+	}
+	if req.EndedAt != nil {
+		experiment.EndedAt = req.EndedAt
+	}
+	if err := experiment.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExperiment, err)
+	}
+
+	if err := s.repo.Update(ctx, experimentID, experiment); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update experiment")
+		return nil, err
+	}
+
+	return experiment, nil
+}
+
+func (s *ExperimentService) DeleteExperiment(ctx context.Context, experimentID uuid.UUID) error {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Deleting experiment")
+
+	return s.repo.Delete(ctx, experimentID)
+}
+
+// GetResults counts each variant's exposed visitors and conversions up to the end of
+// the experiment (or now) and analyses them
+func (s *ExperimentService) GetResults(ctx context.Context, experimentID uuid.UUID) (*models.ExperimentReport, error) {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Getting experiment results")
+
+	experiment, err := s.repo.GetByID(ctx, experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	if experiment.EndedAt != nil && experiment.EndedAt.Before(end) {
+		end = *experiment.EndedAt
+	}
+
+	var counts []models.ExperimentCounts
+	if experiment.GoalID != nil {
+		goal, err := s.goals.GetByID(ctx, *experiment.GoalID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get experiment goal: %w", err)
+		}
+		counts, err = s.repo.GetGoalCounts(ctx, experiment, goal, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count experiment goal conversions: %w", err)
+		}
+	} else {
+		counts, err = s.funnelCounts(ctx, experiment, end)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := models.AnalyzeExperiment(*experiment, counts)
+	return &report, nil
+}
+
+// funnelCounts evaluates the funnel on each exposed visitor's raw events from their
+// exposure on. An exposed visitor converts when they complete the funnel after exposure.
+// Like other retroactive funnels, at most MaxRetroactiveFunnelDays from the start of the
+// experiment are scanned.
+func (s *ExperimentService) funnelCounts(ctx context.Context, experiment *models.Experiment, end time.Time) ([]models.ExperimentCounts, error) {
+	funnel, err := s.funnels.GetByID(ctx, *experiment.FunnelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment funnel: %w", err)
+	}
+
+	if limit := experiment.StartedAt.AddDate(0, 0, models.MaxRetroactiveFunnelDays); end.After(limit) {
+		end = limit
+	}
+
+	exposures, err := s.repo.GetExposures(ctx, experiment, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment exposures: %w", err)
+	}
+
+	exposedAt := make(map[string]time.Time, len(exposures))
+	for _, exposure := range exposures {
+		exposedAt[exposure.VisitorID] = exposure.ExposedAt
+	}
+
+	completed := map[string]bool{}
+	if len(exposedAt) > 0 {
+		dateRange := models.DateRange{Start: experiment.StartedAt, End: end, Timezone: "UTC"}
+		query := models.FunnelQuery{Mode: models.FunnelModeRetroactive, Per: models.FunnelPerVisitor}
+		paths, err := s.funnels.GetFunnelPathsSince(ctx, funnel, dateRange, query, exposedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate experiment funnel: %w", err)
+		}
+		for _, path := range paths {
+			if path.Reached() == len(funnel.Steps) {
+				completed[path.Unit] = true
+			}
+		}
+	}
+
+	byVariant := map[string]int{}
+	var counts []models.ExperimentCounts
+	for _, exposure := range exposures {
+		i, ok := byVariant[exposure.Variant]
+		if !ok {
+			i = len(counts)
+			byVariant[exposure.Variant] = i
+			counts = append(counts, models.ExperimentCounts{Variant: exposure.Variant})
+		}
+		counts[i].Visitors++
+		if completed[exposure.VisitorID] {
+			counts[i].Conversions++
+		}
+	}
+
+	return counts, nil
+}
+
+// checkMetric makes sure the experiment's goal or funnel belongs to its website
+func (s *ExperimentService) checkMetric(ctx context.Context, experiment *models.Experiment) error {
+	var websiteID string
+	if experiment.GoalID != nil {
+		goal, err := s.goals.GetByID(ctx, *experiment.GoalID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: goal not found", ErrInvalidExperiment)
+		}
+		if err != nil {
+			return err
+		}
+		websiteID = goal.WebsiteID
+	} else {
+		funnel, err := s.funnels.GetByID(ctx, *experiment.FunnelID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: funnel not found", ErrInvalidExperiment)
+		}
+		if err != nil {
+			return err
+		}
+		websiteID = funnel.WebsiteID
+	}
+
+	if websiteID != experiment.WebsiteID {
+		return fmt.Errorf("%w: metric belongs to another website", ErrInvalidExperiment)
+	}
+	return nil
+}
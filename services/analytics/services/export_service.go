@@ -0,0 +1,495 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// Raw events are read and written this many rows at a time, and progress is
+	// recorded after each batch
+	ExportBatchSize = 5000
+
+	// How often idle workers look for pending jobs created by other instances
+	ExportPollInterval = 5 * time.Second
+
+	// How often expired files are deleted
+	ExportSweepInterval = 10 * time.Minute
+
+	// Running jobs without progress for this long are assumed abandoned and restarted
+	ExportStaleAfter = 30 * time.Minute
+)
+
+var (
+	// ErrInvalidExport is returned when an export has an unknown source or format, or
+	// an invalid period or filters
+	ErrInvalidExport = errors.New("invalid export")
+	// ErrExportNotReady is returned when downloading an export that has not completed
+	ErrExportNotReady = errors.New("export is not ready")
+	// ErrExportExpired is returned when downloading an export whose file was deleted
+	ErrExportExpired = errors.New("export has expired")
+)
+
+// ExportService runs export jobs in the background. Jobs are queued in the
+// export_jobs table and claimed by the workers of any instance; files are written to
+// dir and deleted ttl after they complete.
+type ExportService struct {
+	repo      *repository.ExportRepository
+	analytics *AnalyticsService
+	dir       string
+	workers   int
+	ttl       time.Duration
+	logger    zerolog.Logger
+
+	// wake tells an idle worker that a job was created
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[uuid.UUID]context.CancelFunc
+}
+
+func NewExportService(repo *repository.ExportRepository, analytics *AnalyticsService, dir string, workers int, ttl time.Duration, logger zerolog.Logger) (*ExportService, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &ExportService{
+		repo:      repo,
+		analytics: analytics,
+		dir:       dir,
+		workers:   workers,
+		ttl:       ttl,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+		running:   make(map[uuid.UUID]context.CancelFunc),
+	}, nil
+}
+
+// Start runs the workers and the sweeper of expired files until Stop is called
+func (s *ExportService) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	if requeued, err := s.repo.RequeueStale(ctx, time.Now().Add(-ExportStaleAfter)); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to requeue stale export jobs")
+	} else if requeued > 0 {
+		s.logger.Warn().Int64("jobs", requeued).Msg("Requeued stale export jobs")
+	}
+
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(ExportSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Error().Err(err).Msg("Failed to delete expired exports")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop interrupts running jobs, which are queued again, and waits for the workers
+func (s *ExportService) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// CreateExport validates and queues an export job
+func (s *ExportService) CreateExport(ctx context.Context, req *models.CreateExportJobRequest) (*models.ExportJob, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("source", req.Source).
+		Str("format", string(req.Format)).
+		Msg("Creating export")
+
+	dateRange, err := models.NewDateRange(req.Start, req.End, req.Days, req.Timezone, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+
+	job := &models.ExportJob{
+		WebsiteID: req.WebsiteID,
+		UserID:    req.UserID,
+		Source:    req.Source,
+		Format:    models.ExportFormat(strings.ToLower(string(req.Format))),
+		StartDate: dateRange.Start,
+		EndDate:   dateRange.End,
+		Timezone:  dateRange.Timezone,
+		Filters:   req.Filters,
+	}
+	if job.Filters == nil {
+		job.Filters = models.Filters{}
+	}
+	if err := job.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+
+	if err := s.repo.Create(ctx, job); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create export")
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+func (s *ExportService) GetExports(ctx context.Context, websiteID string) ([]models.ExportJob, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting exports")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *ExportService) GetExport(ctx context.Context, jobID uuid.UUID) (*models.ExportJob, error) {
+	s.logger.Info().
+		Str("export_id", jobID.String()).
+		Msg("Getting export")
+
+	return s.repo.GetByID(ctx, jobID)
+}
+
+// DeleteExport removes a job and its file, stopping it if it is running here
+func (s *ExportService) DeleteExport(ctx context.Context, jobID uuid.UUID) error {
+	s.logger.Info().
+		Str("export_id", jobID.String()).
+		Msg("Deleting export")
+
+	job, err := s.repo.GetByID(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if cancel, ok := s.running[jobID]; ok {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	if err := s.repo.Delete(ctx, jobID); err != nil {
+		return err
+	}
+	s.removeFile(s.path(job))
+	return nil
+}
+
+// OpenExport returns a completed job and the path of its file. It returns
+// ErrExportNotReady before the job completes and ErrExportExpired after its file was
+// deleted.
+func (s *ExportService) OpenExport(ctx context.Context, jobID uuid.UUID) (*models.ExportJob, string, error) {
+	job, err := s.repo.GetByID(ctx, jobID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case job.Status == models.ExportExpired:
+		return nil, "", ErrExportExpired
+	case job.Status != models.ExportCompleted:
+		return nil, "", ErrExportNotReady
+	case job.ExpiresAt != nil && !time.Now().Before(*job.ExpiresAt):
+		return nil, "", ErrExportExpired
+	}
+
+	path := s.path(job)
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("export file is not available: %w", err)
+	}
+
+	return job, path, nil
+}
+
+// Sweep expires completed jobs past their expiry and deletes every file in the
+// export directory that no pending, running or completed job needs
+func (s *ExportService) Sweep(ctx context.Context) error {
+	expired, err := s.repo.Expire(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		s.logger.Info().Int("jobs", len(expired)).Msg("Expired exports")
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to list export directory: %w", err)
+	}
+
+	files := make(map[uuid.UUID][]string)
+	ids := make([]uuid.UUID, 0, len(entries))
+	for _, entry := range entries {
+		name, _, _ := strings.Cut(entry.Name(), ".")
+		id, err := uuid.Parse(name)
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if _, ok := files[id]; !ok {
+			ids = append(ids, id)
+		}
+		files[id] = append(files[id], filepath.Join(s.dir, entry.Name()))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	live, err := s.repo.Live(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for id, paths := range files {
+		if live[id] {
+			continue
+		}
+		for _, path := range paths {
+			s.removeFile(path)
+		}
+	}
+
+	return nil
+}
+
+// work claims and runs pending jobs until ctx is cancelled
+func (s *ExportService) work(ctx context.Context) {
+	poll := time.NewTicker(ExportPollInterval)
+	defer poll.Stop()
+
+	for {
+		job, err := s.repo.ClaimNext(ctx)
+		if err == nil {
+			s.run(ctx, job)
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) && ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to claim export job")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// run writes a claimed job's file and records the outcome. A job interrupted by Stop is
+// queued again; one interrupted by DeleteExport is left alone.
+func (s *ExportService) run(ctx context.Context, job *models.ExportJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	s.logger.Info().
+		Str("export_id", job.ID.String()).
+		Str("website_id", job.WebsiteID).
+		Str("source", job.Source).
+		Msg("Running export")
+
+	started := time.Now()
+	rows, size, err := s.write(jobCtx, job)
+
+	// The outcome is recorded even while shutting down
+	recordCtx := context.Background()
+	switch {
+	case err == nil:
+		expiresAt := time.Now().Add(s.ttl)
+		if err := s.repo.Complete(recordCtx, job.ID, rows, size, expiresAt); err != nil {
+			s.logger.Error().Err(err).Str("export_id", job.ID.String()).Msg("Failed to complete export")
+			return
+		}
+		s.logger.Info().
+			Str("export_id", job.ID.String()).
+			Int64("rows", rows).
+			Int64("bytes", size).
+			Dur("duration", time.Since(started)).
+			Msg("Export completed")
+	case ctx.Err() != nil:
+		if err := s.repo.Requeue(recordCtx, job.ID); err != nil {
+			s.logger.Error().Err(err).Str("export_id", job.ID.String()).Msg("Failed to requeue interrupted export")
+		}
+	case jobCtx.Err() != nil:
+		// Deleted while running
+	default:
+		s.logger.Error().Err(err).Str("export_id", job.ID.String()).Msg("Export failed")
+		if err := s.repo.Fail(recordCtx, job.ID, err.Error()); err != nil {
+			s.logger.Error().Err(err).Str("export_id", job.ID.String()).Msg("Failed to record export failure")
+		}
+	}
+}
+
+// write writes a job's rows to a temporary file that replaces the job's file once it
+// is complete, and returns the number of rows and the file size
+func (s *ExportService) write(ctx context.Context, job *models.ExportJob) (int64, int64, error) {
+	filters := job.Filters
+	if filters.HasChannel() {
+		rules, err := s.analytics.GetChannelRules(ctx, job.WebsiteID)
+		if err != nil {
+			return 0, 0, err
+		}
+		filters = filters.WithChannels(rules)
+	}
+
+	path := s.path(job)
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer func() {
+		file.Close()
+		s.removeFile(tmp)
+	}()
+
+	var rows int64
+	if job.IsReport() {
+		columns, table, err := s.reportTable(ctx, job, filters)
+		if err != nil {
+			return 0, 0, err
+		}
+		writer, err := NewExportWriter(job.Format, file, columns)
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := writer.Write(table); err != nil {
+			return 0, 0, fmt.Errorf("failed to write export: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return 0, 0, fmt.Errorf("failed to write export: %w", err)
+		}
+		rows = int64(len(table))
+	} else {
+		writer, err := NewExportWriter(job.Format, file, models.ExportEventColumns)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = s.repo.StreamEvents(ctx, job.WebsiteID, job.DateRange(), filters, ExportBatchSize, func(batch [][]interface{}) error {
+			if err := writer.Write(batch); err != nil {
+				return fmt.Errorf("failed to write export: %w", err)
+			}
+			rows += int64(len(batch))
+			return s.repo.UpdateProgress(ctx, job.ID, rows)
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := writer.Close(); err != nil {
+			return 0, 0, fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("failed to sync export file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, 0, fmt.Errorf("failed to store export file: %w", err)
+	}
+
+	return rows, info.Size(), nil
+}
+
+// reportTable runs the job's report and returns its columns and rows
+func (s *ExportService) reportTable(ctx context.Context, job *models.ExportJob, filters models.Filters) ([]models.ExportColumn, [][]interface{}, error) {
+	dateRange := job.DateRange()
+	limit := models.MaxExportReportRows
+
+	var report interface{}
+	var err error
+	switch job.Source {
+	case "pages":
+		report, err = s.analytics.GetTopPages(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "entry-pages":
+		report, err = s.analytics.GetEntryPages(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "exit-pages":
+		report, err = s.analytics.GetExitPages(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "referrers":
+		report, err = s.analytics.GetTopReferrers(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "sources":
+		report, err = s.analytics.GetTopSources(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "countries":
+		report, err = s.analytics.GetTopCountries(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "browsers":
+		report, err = s.analytics.GetTopBrowsers(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "devices":
+		report, err = s.analytics.GetTopDevices(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "os":
+		report, err = s.analytics.GetTopOS(ctx, job.WebsiteID, dateRange, filters, limit)
+	case "channels":
+		report, err = s.analytics.GetChannels(ctx, job.WebsiteID, dateRange, filters)
+	case "daily":
+		report, err = s.analytics.GetDailyStats(ctx, job.WebsiteID, dateRange, filters)
+	case "hourly":
+		report, err = s.analytics.GetHourlyStats(ctx, job.WebsiteID, dateRange, filters)
+	case "custom-events":
+		report, err = s.analytics.GetCustomEvents(ctx, job.WebsiteID, dateRange, filters)
+	default:
+		return nil, nil, fmt.Errorf("unknown export report %q", job.Source)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return models.ExportTable(report)
+}
+
+// path is where a job's file is stored
+func (s *ExportService) path(job *models.ExportJob) string {
+	return filepath.Join(s.dir, job.ID.String()+"."+job.Format.Extension())
+}
+
+func (s *ExportService) removeFile(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Warn().Err(err).Str("path", path).Msg("Failed to delete export file")
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"analytics-app/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Parquet files are written in row groups of this many rows, which bounds how much of
+// an export is held in memory
+const exportParquetRowGroupRows = 100000
+
+// ExportWriter writes the rows of an export in a file format. Each row has a value for
+// every column, typed as ExportColumn describes.
+type ExportWriter interface {
+	Write(rows [][]interface{}) error
+	// Close writes anything still buffered. It does not close the underlying writer.
+	Close() error
+}
+
+// NewExportWriter returns a writer for the given format and columns
+func NewExportWriter(format models.ExportFormat, w io.Writer, columns []models.ExportColumn) (ExportWriter, error) {
+	switch format {
+	case models.ExportCSV:
+		return newCSVExportWriter(w, columns)
+	case models.ExportNDJSON:
+		return &ndjsonExportWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case models.ExportParquet:
+		return newParquetExportWriter(w, columns), nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// csvExportWriter writes a header and a record per row. Timestamps are RFC 3339 in UTC
+// and missing values are empty.
+type csvExportWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVExportWriter(w io.Writer, columns []models.ExportColumn) (*csvExportWriter, error) {
+	cw := &csvExportWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, column := range columns {
+		cw.record[i] = column.Name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvExportWriter) Write(rows [][]interface{}) error {
+	for _, row := range rows {
+		for i, value := range row {
+			switch v := value.(type) {
+			case nil:
+				cw.record[i] = ""
+			case string:
+				cw.record[i] = v
+			case int64:
+				cw.record[i] = strconv.FormatInt(v, 10)
+			case float64:
+				cw.record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case time.Time:
+				cw.record[i] = v.UTC().Format(time.RFC3339)
+			default:
+				return fmt.Errorf("unsupported export value %T", value)
+			}
+		}
+		if err := cw.w.Write(cw.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvExportWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonExportWriter writes a JSON object per line with the columns in order
+type ndjsonExportWriter struct {
+	w       *bufio.Writer
+	columns []models.ExportColumn
+}
+
+func (nw *ndjsonExportWriter) Write(rows [][]interface{}) error {
+	for _, row := range rows {
+		nw.w.WriteByte('{')
+		for i, value := range row {
+			if i > 0 {
+				nw.w.WriteByte(',')
+			}
+			name, _ := json.Marshal(nw.columns[i].Name)
+			nw.w.Write(name)
+			nw.w.WriteByte(':')
+			if t, ok := value.(time.Time); ok {
+				value = t.UTC()
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			nw.w.Write(encoded)
+		}
+		if _, err := nw.w.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (nw *ndjsonExportWriter) Close() error {
+	return nw.w.Flush()
+}
+
+// parquetExportWriter writes every column as optional, with timestamps in milliseconds
+// (UTC) and Snappy compression
+type parquetExportWriter struct {
+	w       *parquet.Writer
+	columns []models.ExportColumn
+	// indexes maps the export's columns to the schema's, which are ordered by name
+	indexes []int
+}
+
+func newParquetExportWriter(w io.Writer, columns []models.ExportColumn) *parquetExportWriter {
+	group := parquet.Group{}
+	for _, column := range columns {
+		var node parquet.Node
+		switch column.Type {
+		case models.ExportInt:
+			node = parquet.Int(64)
+		case models.ExportFloat:
+			node = parquet.Leaf(parquet.DoubleType)
+		case models.ExportTimestamp:
+			node = parquet.Timestamp(parquet.Millisecond)
+		default:
+			node = parquet.String()
+		}
+		group[column.Name] = parquet.Optional(node)
+	}
+	schema := parquet.NewSchema("export", group)
+
+	positions := make(map[string]int)
+	for i, path := range schema.Columns() {
+		positions[path[0]] = i
+	}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = positions[column.Name]
+	}
+
+	return &parquetExportWriter{
+		w:       parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy), parquet.MaxRowsPerRowGroup(exportParquetRowGroupRows)),
+		columns: columns,
+		indexes: indexes,
+	}
+}
+
+func (pw *parquetExportWriter) Write(rows [][]interface{}) error {
+	batch := make([]parquet.Row, len(rows))
+	for r, row := range rows {
+		values := make(parquet.Row, len(pw.columns))
+		for i, value := range row {
+			var v parquet.Value
+			definition := 1
+			switch x := value.(type) {
+			case nil:
+				definition = 0
+			case string:
+				v = parquet.ByteArrayValue([]byte(x))
+			case int64:
+				v = parquet.Int64Value(x)
+			case float64:
+				v = parquet.DoubleValue(x)
+			case time.Time:
+				v = parquet.Int64Value(x.UnixMilli())
+			default:
+				return fmt.Errorf("unsupported export value %T", value)
+			}
+			index := pw.indexes[i]
+			values[index] = v.Level(0, definition, index)
+		}
+		batch[r] = values
+	}
+
+	_, err := pw.w.WriteRows(batch)
+	return err
+}
+
+func (pw *parquetExportWriter) Close() error {
+	return pw.w.Close()
+}
@@ -3,7 +3,9 @@ package services
 import (
 	"analytics-app/models"
 	"analytics-app/repository"
+	"analytics-app/utils"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -13,6 +15,9 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// ErrInvalidFunnel is returned when a funnel definition fails validation
+var ErrInvalidFunnel = errors.New("invalid funnel")
+
 const (
 	// Funnel batch processing constants
 	FunnelBatchSize     = 100 // Smaller batch size for funnel events
@@ -20,8 +25,10 @@ const (
 )
 
 type FunnelService struct {
-	repo   *repository.FunnelRepository
-	logger zerolog.Logger
+	repo     *repository.FunnelRepository
+	segments *repository.SegmentRepository
+	channels *repository.ChannelRuleRepository
+	logger   zerolog.Logger
 
 	// Batch processing channels
 	eventChan chan models.FunnelEvent
@@ -35,11 +42,13 @@ type FunnelService struct {
 	shutdownMu sync.RWMutex
 }
 
-func NewFunnelService(repo *repository.FunnelRepository, logger zerolog.Logger, redisClient *redis.Client) *FunnelService {
+func NewFunnelService(repo *repository.FunnelRepository, segments *repository.SegmentRepository, channels *repository.ChannelRuleRepository, logger zerolog.Logger, redisClient *redis.Client) *FunnelService {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	service := &FunnelService{
 		repo:      repo,
+		segments:  segments,
+		channels:  channels,
 		logger:    logger,
 		eventChan: make(chan models.FunnelEvent, 1000), // Buffered channel
 		batchChan: make(chan []models.FunnelEvent, 200),
@@ -61,12 +70,20 @@ func (s *FunnelService) CreateFunnel(ctx context.Context, req *models.CreateFunn
 		Msg("Creating funnel")
 
 	funnel := &models.Funnel{
-		Name:        req.Name,
-		Description: req.Description,
-		WebsiteID:   req.WebsiteID,
-		UserID:      req.UserID,
-		Steps:       req.Steps,
-		IsActive:    req.IsActive,
+		Name:                    req.Name,
+		Description:             req.Description,
+		WebsiteID:               req.WebsiteID,
+		UserID:                  req.UserID,
+		Steps:                   req.Steps,
+		IsActive:                req.IsActive,
+		ConversionWindowSeconds: req.ConversionWindowSeconds,
+		Ordering:                req.Ordering,
+	}
+	if funnel.Ordering == "" {
+		funnel.Ordering = models.FunnelOrderLoose
+	}
+	if err := utils.ValidateFunnel(funnel); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFunnel, err)
 	}
 
 	err := s.repo.Create(ctx, funnel)
@@ -118,6 +135,18 @@ func (s *FunnelService) UpdateFunnel(ctx context.Context, funnelID uuid.UUID, re
 	if req.IsActive != nil {
 		funnel.IsActive = *req.IsActive
 	}
+	if req.ConversionWindowSeconds != nil {
+		funnel.ConversionWindowSeconds = *req.ConversionWindowSeconds
+	}
+	if req.Ordering != nil {
+		funnel.Ordering = *req.Ordering
+	}
+	if funnel.Ordering == "" {
+		funnel.Ordering = models.FunnelOrderLoose
+	}
+	if err := utils.ValidateFunnel(funnel); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFunnel, err)
+	}
 
 	err = s.repo.Update(ctx, funnelID, funnel)
 	if err != nil {
@@ -227,13 +256,23 @@ func (s *FunnelService) GetFunnelAnalytics(ctx context.Context, funnelID uuid.UU
 	return analytics, nil
 }
 
-func (s *FunnelService) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, days int) (*models.DetailedFunnelAnalytics, error) {
+func (s *FunnelService) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, q models.FunnelQuery) (*models.DetailedFunnelAnalytics, error) {
 	s.logger.Info().
 		Str("funnel_id", funnelID.String()).
-		Int("days", days).
+		Int("days", q.Days).
+		Str("mode", string(q.Mode)).
+		Str("per", string(q.Per)).
 		Msg("Getting detailed funnel analytics")
 
-	analytics, err := s.repo.GetDetailedFunnelAnalytics(ctx, funnelID, days)
+	if q.SegmentID != nil {
+		segment, err := s.funnelSegment(ctx, funnelID, *q.SegmentID)
+		if err != nil {
+			return nil, err
+		}
+		q.Segment = segment
+	}
+
+	analytics, err := s.repo.GetDetailedFunnelAnalytics(ctx, funnelID, q)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get detailed funnel analytics: %w", err)
 	}
@@ -241,6 +280,30 @@ func (s *FunnelService) GetDetailedFunnelAnalytics(ctx context.Context, funnelID
 	return analytics, nil
 }
 
+// funnelSegment loads a segment of the funnel's website, with the website's channel
+// rules for its channel filters
+func (s *FunnelService) funnelSegment(ctx context.Context, funnelID, segmentID uuid.UUID) (*models.Segment, error) {
+	funnel, err := s.repo.GetByID(ctx, funnelID)
+	if err != nil {
+		return nil, err
+	}
+
+	segment, err := websiteSegment(ctx, s.segments, funnel.WebsiteID, segmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funnel segment: %w", err)
+	}
+
+	if segment.Filters.HasChannel() {
+		rules, err := websiteChannelRules(ctx, s.channels, funnel.WebsiteID)
+		if err != nil {
+			return nil, err
+		}
+		segment.Filters = segment.Filters.WithChannels(rules)
+	}
+
+	return segment, nil
+}
+
 func (s *FunnelService) CompareFunnels(ctx context.Context, websiteID string, funnelIDs []string, days int) ([]models.FunnelComparisonResult, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidGoal is returned when a goal is missing what its type needs
+var ErrInvalidGoal = errors.New("invalid goal")
+
+type GoalService struct {
+	repo   *repository.GoalRepository
+	logger zerolog.Logger
+}
+
+func NewGoalService(repo *repository.GoalRepository, logger zerolog.Logger) *GoalService {
+	return &GoalService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// CreateGoal validates and stores a goal. Goals are active unless is_active is false.
+func (s *GoalService) CreateGoal(ctx context.Context, req *models.CreateGoalRequest) (*models.Goal, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("goal_name", req.Name).
+		Msg("Creating goal")
+
+	goal := &models.Goal{
+		WebsiteID:   req.WebsiteID,
+		UserID:      req.UserID,
+		Name:        req.Name,
+		Type:        req.Type,
+		PagePattern: req.PagePattern,
+		EventName:   req.EventName,
+		Properties:  req.Properties,
+		Value:       req.Value,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+	if err := goal.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGoal, err)
+	}
+
+	if err := s.repo.Create(ctx, goal); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create goal")
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+func (s *GoalService) GetGoals(ctx context.Context, websiteID string) ([]models.Goal, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting goals")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID, false)
+}
+
+func (s *GoalService) GetGoal(ctx context.Context, goalID uuid.UUID) (*models.Goal, error) {
+	s.logger.Info().
+		Str("goal_id", goalID.String()).
+		Msg("Getting goal")
+
+	return s.repo.GetByID(ctx, goalID)
+}
+
+func (s *GoalService) UpdateGoal(ctx context.Context, goalID uuid.UUID, req *models.UpdateGoalRequest) (*models.Goal, error) {
+	s.logger.Info().
+		Str("goal_id", goalID.String()).
+		Msg("Updating goal")
+
+	goal, err := s.repo.GetByID(ctx, goalID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		goal.Name = *req.Name
+	}
+	if req.Type != nil {
+		goal.Type = *req.Type
+	}
+	if req.PagePattern != nil {
+		goal.PagePattern = req.PagePattern
+	}
+	if req.EventName != nil {
+		goal.EventName = req.EventName
+	}
+	if req.Properties != nil {
+		goal.Properties = *req.Properties
+	}
+	if req.Value != nil {
+		goal.Value = req.Value
+	}
+	if req.IsActive != nil {
+		goal.IsActive = *req.IsActive
+	}
+	if err := goal.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGoal, err)
+	}
+
+	if err := s.repo.Update(ctx, goalID, goal); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update goal")
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+func (s *GoalService) DeleteGoal(ctx context.Context, goalID uuid.UUID) error {
+	s.logger.Info().
+		Str("goal_id", goalID.String()).
+		Msg("Deleting goal")
+
+	return s.repo.Delete(ctx, goalID)
+}
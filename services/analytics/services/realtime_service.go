@@ -0,0 +1,266 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+)
+
+const realtimeChannelPrefix = "realtime:events"
+
+// How long loading the initial state of a realtime stream may take
+const realtimeSeedTimeout = 5 * time.Second
+
+const (
+	// Batches of accepted events waiting to be published; more are dropped
+	realtimePublishQueueSize = 1024
+	// Events published to Redis in one pipeline
+	realtimePublishBatchSize = 500
+	// How long publishing one pipeline may take
+	realtimePublishTimeout = 2 * time.Second
+)
+
+// realtimeStream is the shared state of a website's realtime subscribers
+type realtimeStream struct {
+	subscribers map[chan models.RealtimeSnapshot]struct{}
+	cancel      context.CancelFunc
+}
+
+// RealtimeService publishes accepted events to Redis and streams per-website snapshots
+// of live visitors, top pages and recent events to subscribers. Each instance follows a
+// website only while it has subscribers, and sends every one of them the same snapshot
+// once per interval, however many there are. Events are published in the background,
+// off the tracking request path.
+type RealtimeService struct {
+	redis    *redis.Client
+	repo     *repository.MainAnalyticsRepository
+	interval time.Duration
+	logger   zerolog.Logger
+
+	queue chan []models.Event
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	streams map[string]*realtimeStream
+	closed  bool
+}
+
+func NewRealtimeService(redisClient *redis.Client, repo *repository.MainAnalyticsRepository, interval time.Duration, logger zerolog.Logger) *RealtimeService {
+	s := &RealtimeService{
+		redis:    redisClient,
+		repo:     repo,
+		interval: interval,
+		logger:   logger,
+		queue:    make(chan []models.Event, realtimePublishQueueSize),
+		done:     make(chan struct{}),
+		streams:  make(map[string]*realtimeStream),
+	}
+
+	s.wg.Add(1)
+	go s.publishLoop()
+
+	return s
+}
+
+func realtimeChannel(websiteID string) string {
+	return fmt.Sprintf("%s:%s", realtimeChannelPrefix, websiteID)
+}
+
+// Publish queues accepted events for the realtime subscribers of every instance and
+// returns without waiting for Redis. When the queue is full the events are dropped:
+// realtime streams are best effort and never slow down or fail tracking.
+func (s *RealtimeService) Publish(events []models.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	select {
+	case s.queue <- events:
+	default:
+		s.logger.Warn().Int("events_count", len(events)).Msg("Realtime publish queue full, events dropped")
+	}
+}
+
+// publishLoop publishes queued events until the service is closed, combining batches
+// that queued up while the previous pipeline ran
+func (s *RealtimeService) publishLoop() {
+	defer s.wg.Done()
+
+	for {
+		var events []models.Event
+		select {
+		case <-s.done:
+			return
+		case events = <-s.queue:
+		}
+
+	collect:
+		for len(events) < realtimePublishBatchSize {
+			select {
+			case more := <-s.queue:
+				events = append(events, more...)
+			default:
+				break collect
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), realtimePublishTimeout)
+		s.publish(ctx, events)
+		cancel()
+	}
+}
+
+// publish sends events to their websites' channels in one pipeline
+func (s *RealtimeService) publish(ctx context.Context, events []models.Event) {
+	pipe := s.redis.Pipeline()
+	for i := range events {
+		payload, err := json.Marshal(models.NewRealtimeEvent(&events[i]))
+		if err != nil {
+			s.logger.Warn().Err(err).Str("event_id", events[i].ID.String()).Msg("Failed to encode realtime event")
+			continue
+		}
+		pipe.Publish(ctx, realtimeChannel(events[i].WebsiteID), payload)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.Warn().Err(err).Int("events_count", len(events)).Msg("Failed to publish realtime events")
+	}
+}
+
+// Subscribe returns a channel of a website's realtime snapshots and a function that
+// ends the subscription. A subscriber that falls behind only gets the latest snapshot.
+// The channel is closed when the service is closed.
+func (s *RealtimeService) Subscribe(websiteID string) (<-chan models.RealtimeSnapshot, func()) {
+	updates := make(chan models.RealtimeSnapshot, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		close(updates)
+		return updates, func() {}
+	}
+
+	stream, ok := s.streams[websiteID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream = &realtimeStream{
+			subscribers: make(map[chan models.RealtimeSnapshot]struct{}),
+			cancel:      cancel,
+		}
+		s.streams[websiteID] = stream
+		go s.run(ctx, websiteID, stream)
+
+		s.logger.Info().Str("website_id", websiteID).Msg("Realtime stream started")
+	}
+	stream.subscribers[updates] = struct{}{}
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if _, ok := stream.subscribers[updates]; !ok {
+			return
+		}
+		delete(stream.subscribers, updates)
+		if len(stream.subscribers) == 0 {
+			stream.cancel()
+			delete(s.streams, websiteID)
+			s.logger.Info().Str("website_id", websiteID).Msg("Realtime stream stopped")
+		}
+	}
+
+	return updates, unsubscribe
+}
+
+// run follows a website's events until ctx is cancelled. It subscribes before loading
+// the initial state so that no event falls in between; events seen twice are ignored.
+func (s *RealtimeService) run(ctx context.Context, websiteID string, stream *realtimeStream) {
+	pubsub := s.redis.Subscribe(ctx, realtimeChannel(websiteID))
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil && ctx.Err() == nil {
+		s.logger.Error().Err(err).Str("website_id", websiteID).Msg("Failed to subscribe to realtime events")
+	}
+
+	state := models.NewRealtimeState()
+	seedCtx, cancel := context.WithTimeout(ctx, realtimeSeedTimeout)
+	events, err := s.repo.GetRealtimeEvents(seedCtx, websiteID, time.Now().Add(-models.RealtimeWindow))
+	cancel()
+	if err != nil && ctx.Err() == nil {
+		s.logger.Error().Err(err).Str("website_id", websiteID).Msg("Failed to load recent realtime events")
+	}
+	for _, event := range events {
+		state.Add(event)
+	}
+	s.broadcast(stream, state.Snapshot(websiteID, time.Now()))
+
+	messages := pubsub.Channel()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event models.RealtimeEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				s.logger.Warn().Err(err).Str("website_id", websiteID).Msg("Invalid realtime event")
+				continue
+			}
+			state.Add(event)
+		case now := <-ticker.C:
+			s.broadcast(stream, state.Snapshot(websiteID, now))
+		}
+	}
+}
+
+// broadcast hands a snapshot to every subscriber, replacing one it has not taken yet
+func (s *RealtimeService) broadcast(stream *realtimeStream, snapshot models.RealtimeSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for updates := range stream.subscribers {
+		select {
+		case <-updates:
+		default:
+		}
+		updates <- snapshot
+	}
+}
+
+// Close stops every stream and closes the subscribers' channels so that open
+// connections end before the server shuts down
+func (s *RealtimeService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	// Events still queued are dropped, like those that arrive after this
+	close(s.done)
+	s.wg.Wait()
+
+	for websiteID, stream := range s.streams {
+		stream.cancel()
+		for updates := range stream.subscribers {
+			close(updates)
+		}
+		stream.subscribers = nil
+		delete(s.streams, websiteID)
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"analytics-app/repository"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// RollupCompactBatchSize is how many dirty hours the compactor takes per pass
+const RollupCompactBatchSize = 500
+
+// RollupCompactor keeps the hourly rollups current by recomputing the hours that
+// EventService marked dirty after writing their events
+type RollupCompactor struct {
+	repo     *repository.RollupRepository
+	interval time.Duration
+	logger   zerolog.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRollupCompactor(repo *repository.RollupRepository, interval time.Duration, logger zerolog.Logger) *RollupCompactor {
+	return &RollupCompactor{
+		repo:     repo,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start compacts dirty hours every interval until Stop is called
+func (c *RollupCompactor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.Compact(ctx); err != nil && ctx.Err() == nil {
+				c.logger.Error().Err(err).Msg("Failed to compact hourly rollups")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop waits for the current pass to finish
+func (c *RollupCompactor) Stop() {
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	c.wg.Wait()
+}
+
+// Compact recomputes every dirty hour, one website and UTC day at a time, and removes
+// them from the queue. Hours that fail stay queued for the next pass.
+func (c *RollupCompactor) Compact(ctx context.Context) error {
+	for {
+		dirty, err := c.repo.TakeDirty(ctx, RollupCompactBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(dirty) == 0 {
+			return nil
+		}
+
+		type groupKey struct {
+			websiteID string
+			day       time.Time
+		}
+		groups := make(map[groupKey][]repository.DirtyHour)
+		var order []groupKey
+		for _, h := range dirty {
+			key := groupKey{websiteID: h.WebsiteID, day: h.Hour.UTC().Truncate(24 * time.Hour)}
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], h)
+		}
+
+		refreshed := make([]repository.DirtyHour, 0, len(dirty))
+		for _, key := range order {
+			group := groups[key]
+			hours := make([]time.Time, len(group))
+			for i, h := range group {
+				hours[i] = h.Hour
+			}
+
+			if err := c.repo.Refresh(ctx, key.websiteID, hours); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				c.logger.Error().
+					Err(err).
+					Str("website_id", key.websiteID).
+					Time("day", key.day).
+					Msg("Failed to refresh hourly rollup")
+				continue
+			}
+			refreshed = append(refreshed, group...)
+		}
+
+		if err := c.repo.ClearDirty(ctx, refreshed); err != nil {
+			return err
+		}
+
+		c.logger.Debug().
+			Int("hours", len(refreshed)).
+			Int("failed", len(dirty)-len(refreshed)).
+			Msg("Compacted hourly rollups")
+
+		// Stop when nothing made progress, so failing hours are not retried in a tight loop
+		if len(dirty) < RollupCompactBatchSize || len(refreshed) == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidSegment is returned when a segment has an unknown scope or invalid filters
+var ErrInvalidSegment = errors.New("invalid segment")
+
+type SegmentService struct {
+	repo   *repository.SegmentRepository
+	logger zerolog.Logger
+}
+
+func NewSegmentService(repo *repository.SegmentRepository, logger zerolog.Logger) *SegmentService {
+	return &SegmentService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// CreateSegment validates and stores a segment. Segments select sessions unless the
+// scope is visitor.
+func (s *SegmentService) CreateSegment(ctx context.Context, req *models.CreateSegmentRequest) (*models.Segment, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("segment_name", req.Name).
+		Msg("Creating segment")
+
+	segment := &models.Segment{
+		WebsiteID:   req.WebsiteID,
+		UserID:      req.UserID,
+		Name:        req.Name,
+		Description: req.Description,
+		Scope:       req.Scope,
+		Filters:     req.Filters,
+	}
+	if segment.Scope == "" {
+		segment.Scope = models.SegmentSession
+	}
+	if err := segment.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSegment, err)
+	}
+
+	if err := s.repo.Create(ctx, segment); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create segment")
+		return nil, err
+	}
+
+	return segment, nil
+}
+
+func (s *SegmentService) GetSegments(ctx context.Context, websiteID string) ([]models.Segment, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting segments")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *SegmentService) GetSegment(ctx context.Context, segmentID uuid.UUID) (*models.Segment, error) {
+	s.logger.Info().
+		Str("segment_id", segmentID.String()).
+		Msg("Getting segment")
+
+	return s.repo.GetByID(ctx, segmentID)
+}
+
+func (s *SegmentService) UpdateSegment(ctx context.Context, segmentID uuid.UUID, req *models.UpdateSegmentRequest) (*models.Segment, error) {
+	s.logger.Info().
+		Str("segment_id", segmentID.String()).
+		Msg("Updating segment")
+
+	segment, err := s.repo.GetByID(ctx, segmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		segment.Name = *req.Name
+	}
+	if req.Description != nil {
+		segment.Description = req.Description
+	}
+	if req.Scope != nil {
+		segment.Scope = *req.Scope
+	}
+	if req.Filters != nil {
+		segment.Filters = *req.Filters
+	}
+	if err := segment.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSegment, err)
+	}
+
+	if err := s.repo.Update(ctx, segmentID, segment); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update segment")
+		return nil, err
+	}
+
+	return segment, nil
+}
+
+func (s *SegmentService) DeleteSegment(ctx context.Context, segmentID uuid.UUID) error {
+	s.logger.Info().
+		Str("segment_id", segmentID.String()).
+		Msg("Deleting segment")
+
+	return s.repo.Delete(ctx, segmentID)
+}
+
+// websiteSegment returns a website's segment, or pgx.ErrNoRows when the segment belongs
+// to another website
+func websiteSegment(ctx context.Context, segments *repository.SegmentRepository, websiteID string, segmentID uuid.UUID) (*models.Segment, error) {
+	segment, err := segments.GetByID(ctx, segmentID)
+	if err != nil {
+		return nil, err
+	}
+	if segment.WebsiteID != websiteID {
+		return nil, pgx.ErrNoRows
+	}
+	return segment, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" of
+	// "<t>.<body>" keyed with the rule's webhook secret
+	WebhookSignatureHeader = "X-Seentics-Signature"
+	WebhookEventHeader     = "X-Seentics-Event"
+	WebhookDeliveryHeader  = "X-Seentics-Delivery"
+)
+
+// WebhookError is a response outside 2xx from a webhook
+type WebhookError struct {
+	StatusCode int
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.StatusCode)
+}
+
+// WebhookSender posts signed JSON to webhooks. Unless private addresses are allowed it
+// refuses to connect to loopback, private and link-local addresses, including after
+// redirects, so webhooks cannot reach internal services.
+type WebhookSender struct {
+	client *http.Client
+}
+
+func NewWebhookSender(timeout time.Duration, allowPrivate bool) *WebhookSender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &WebhookSender{
+		client: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+// Send posts a delivery's body to the URL and returns the response status. Responses
+// outside 2xx are returned as a *WebhookError.
+func (s *WebhookSender) Send(ctx context.Context, url, secret, event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Seentics-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookDeliveryHeader, deliveryID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, time.Now().Unix(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &WebhookError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value for a body sent at the given time
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookSecret returns a random secret for signing webhook deliveries
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,273 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAlertRule() *models.AlertRule {
+	return &models.AlertRule{
+		ID:            uuid.New(),
+		WebsiteID:     "site",
+		Name:          "Traffic drop",
+		Metric:        models.AlertVisitors,
+		Operator:      models.AlertBelow,
+		Threshold:     20,
+		Baseline:      models.AlertLastWeek,
+		WindowMinutes: 60,
+		WebhookURL:    "https://hooks.example.com/seentics",
+		WebhookSecret: "whsec_test",
+		Enabled:       true,
+		State:         models.AlertOK,
+	}
+}
+
+func TestAlertRuleValidate(t *testing.T) {
+	rule := newAlertRule()
+	require.NoError(t, rule.Validate())
+
+	rule.Metric = "sessions"
+	assert.Error(t, rule.Validate())
+
+	rule.Metric = models.AlertGoalConversions
+	assert.Error(t, rule.Validate(), "goal_conversions needs a goal")
+	goalID := uuid.New()
+	rule.GoalID = &goalID
+	assert.NoError(t, rule.Validate())
+
+	rule.Metric = models.AlertEvents
+	assert.Error(t, rule.Validate(), "only goal_conversions takes a goal")
+	rule.GoalID = nil
+
+	rule.Operator = "gte"
+	assert.Error(t, rule.Validate())
+	rule.Operator = models.AlertAbove
+
+	rule.Threshold = 0
+	assert.Error(t, rule.Validate(), "last_week needs a positive percentage")
+	rule.Baseline = models.AlertFixed
+	assert.NoError(t, rule.Validate())
+
+	rule.WindowMinutes = 1
+	assert.Error(t, rule.Validate())
+	rule.WindowMinutes = models.MaxAlertWindowMinutes + 1
+	assert.Error(t, rule.Validate())
+	rule.WindowMinutes = 30
+
+	rule.WebhookURL = "ftp://hooks.example.com"
+	assert.Error(t, rule.Validate())
+	rule.WebhookURL = "https://"
+	assert.Error(t, rule.Validate())
+}
+
+func TestAlertRuleLimit(t *testing.T) {
+	rule := newAlertRule()
+
+	limit, ok := rule.Limit(500)
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, limit)
+	assert.True(t, rule.Breached(99, limit))
+	assert.False(t, rule.Breached(100, limit))
+
+	_, ok = rule.Limit(0)
+	assert.False(t, ok, "an empty baseline window leaves nothing to compare with")
+
+	rule.Baseline = models.AlertFixed
+	rule.Operator = models.AlertAbove
+	rule.Threshold = 50
+	limit, ok = rule.Limit(0)
+	assert.True(t, ok)
+	assert.Equal(t, 50.0, limit)
+	assert.True(t, rule.Breached(51, limit))
+	assert.False(t, rule.Breached(50, limit))
+}
+
+func TestAlertRuleWindow(t *testing.T) {
+	rule := newAlertRule()
+	rule.WindowMinutes = 30
+	end := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	window := rule.Window(end)
+	assert.Equal(t, time.Date(2024, 3, 4, 11, 30, 0, 0, time.UTC), window.Start)
+	assert.Equal(t, end, window.End)
+}
+
+func TestAlertRuleTransition(t *testing.T) {
+	rule := newAlertRule()
+	breached := &models.AlertEvaluation{Breached: true, Conclusive: true}
+	recovered := &models.AlertEvaluation{Breached: false, Conclusive: true}
+
+	state, event := rule.Transition(breached)
+	assert.Equal(t, models.AlertFiring, state)
+	assert.Equal(t, models.AlertEventFiring, event)
+
+	rule.State = models.AlertFiring
+	state, event = rule.Transition(breached)
+	assert.Equal(t, models.AlertFiring, state)
+	assert.Empty(t, event, "a rule that keeps firing notifies once")
+
+	state, event = rule.Transition(&models.AlertEvaluation{Conclusive: false})
+	assert.Equal(t, models.AlertFiring, state)
+	assert.Empty(t, event)
+
+	state, event = rule.Transition(recovered)
+	assert.Equal(t, models.AlertOK, state)
+	assert.Equal(t, models.AlertEventResolved, event)
+
+	rule.State = models.AlertOK
+	_, event = rule.Transition(recovered)
+	assert.Empty(t, event)
+}
+
+func TestNewAlertDelivery(t *testing.T) {
+	rule := newAlertRule()
+	rule.State = models.AlertFiring
+	now := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	baseline := 500.0
+	evaluation := &models.AlertEvaluation{
+		Value:         42,
+		BaselineValue: &baseline,
+		Limit:         100,
+		Breached:      true,
+		Conclusive:    true,
+		WindowStart:   now.Add(-time.Hour),
+		WindowEnd:     now,
+	}
+
+	delivery, err := models.NewAlertDelivery(rule, models.AlertEventFiring, evaluation, now)
+	require.NoError(t, err)
+	assert.Equal(t, rule.ID, delivery.RuleID)
+	assert.Equal(t, models.AlertDeliveryPending, delivery.Status)
+	assert.Equal(t, now, delivery.NextAttemptAt)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(delivery.Payload, &payload))
+	assert.Equal(t, delivery.ID.String(), payload["id"])
+	assert.Equal(t, "alert.firing", payload["event"])
+
+	alert := payload["alert"].(map[string]interface{})
+	assert.Equal(t, rule.ID.String(), alert["id"])
+	assert.Equal(t, "firing", alert["state"])
+	assert.NotContains(t, alert, "webhook_secret")
+	assert.NotContains(t, string(delivery.Payload), rule.WebhookSecret)
+
+	result := payload["evaluation"].(map[string]interface{})
+	assert.Equal(t, 42.0, result["value"])
+	assert.Equal(t, 500.0, result["baseline_value"])
+	assert.Equal(t, 100.0, result["limit"])
+}
+
+func TestAlertRetryAt(t *testing.T) {
+	now := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, now.Add(30*time.Second), *services.AlertRetryAt(1, now))
+	assert.Equal(t, now.Add(time.Minute), *services.AlertRetryAt(2, now))
+	assert.Equal(t, now.Add(2*time.Minute), *services.AlertRetryAt(3, now))
+	assert.Equal(t, now.Add(time.Hour), *services.AlertRetryAt(9, now), "delays are capped")
+	assert.Nil(t, services.AlertRetryAt(services.MaxAlertDeliveryAttempts, now))
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"alert.test"}`)
+
+	signature := services.SignWebhook("whsec_test", 1700000000, body)
+	assert.True(t, strings.HasPrefix(signature, "t=1700000000,v1="))
+	assert.Len(t, strings.TrimPrefix(signature, "t=1700000000,v1="), 64)
+
+	assert.Equal(t, signature, services.SignWebhook("whsec_test", 1700000000, body))
+	assert.NotEqual(t, signature, services.SignWebhook("whsec_other", 1700000000, body))
+	assert.NotEqual(t, signature, services.SignWebhook("whsec_test", 1700000001, body))
+	assert.NotEqual(t, signature, services.SignWebhook("whsec_test", 1700000000, []byte(`{}`)))
+
+	secret, err := services.NewWebhookSecret()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+	assert.Len(t, secret, len("whsec_")+64)
+}
+
+func TestWebhookSenderSend(t *testing.T) {
+	body := []byte(`{"event":"alert.test"}`)
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sender := services.NewWebhookSender(5*time.Second, true)
+	status, err := sender.Send(context.Background(), server.URL, "whsec_test", "alert.test", "delivery-1", body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	require.NotNil(t, received)
+	assert.Equal(t, body, receivedBody)
+	assert.Equal(t, "alert.test", received.Header.Get(services.WebhookEventHeader))
+	assert.Equal(t, "delivery-1", received.Header.Get(services.WebhookDeliveryHeader))
+
+	signature := received.Header.Get(services.WebhookSignatureHeader)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, services.SignWebhook("whsec_test", timestamp, body), signature)
+}
+
+func TestWebhookSenderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	sender := services.NewWebhookSender(5*time.Second, true)
+	status, err := sender.Send(context.Background(), server.URL, "whsec_test", "alert.test", "delivery-1", []byte(`{}`))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	var webhookErr *services.WebhookError
+	require.True(t, errors.As(err, &webhookErr))
+	assert.Equal(t, http.StatusServiceUnavailable, webhookErr.StatusCode)
+
+	// httptest listens on loopback, which webhooks may not reach by default
+	sender = services.NewWebhookSender(5*time.Second, false)
+	status, err = sender.Send(context.Background(), server.URL, "whsec_test", "alert.test", "delivery-1", []byte(`{}`))
+	assert.Error(t, err)
+	assert.Zero(t, status)
+}
+
+func TestAlertRuleAnomalyLimit(t *testing.T) {
+	rule := newAlertRule()
+	rule.Baseline = models.AlertAnomaly
+	rule.Threshold = 3.5
+	require.NoError(t, rule.Validate())
+
+	rule.Threshold = 0
+	assert.Error(t, rule.Validate(), "anomaly rules need a positive score")
+	rule.Threshold = 3.5
+
+	score := models.ScoreAnomaly([]float64{100, 110, 90, 100}, 10, rule.Threshold)
+	limit, ok := rule.AnomalyLimit(score)
+	assert.True(t, ok)
+	assert.Equal(t, score.Lower, limit)
+	assert.True(t, rule.Breached(10, limit))
+
+	rule.Operator = models.AlertAbove
+	limit, ok = rule.AnomalyLimit(score)
+	assert.True(t, ok)
+	assert.Equal(t, score.Upper, limit)
+	assert.False(t, rule.Breached(10, limit))
+
+	_, ok = rule.AnomalyLimit(models.ScoreAnomaly([]float64{0, 0, 0, 0}, 5, rule.Threshold))
+	assert.False(t, ok, "earlier weeks without traffic leave nothing to compare with")
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreAnomaly(t *testing.T) {
+	samples := []float64{100, 110, 90, 105, 95}
+
+	score := models.ScoreAnomaly(samples, 102, 3.5)
+	assert.Equal(t, 100.0, score.Expected)
+	assert.False(t, score.Anomaly)
+	assert.Less(t, score.Lower, 100.0)
+	assert.Greater(t, score.Upper, 100.0)
+
+	spike := models.ScoreAnomaly(samples, 200, 3.5)
+	assert.True(t, spike.Anomaly)
+	assert.Greater(t, spike.Score, 3.5)
+	assert.Greater(t, 200.0, spike.Upper)
+
+	drop := models.ScoreAnomaly(samples, 10, 3.5)
+	assert.True(t, drop.Anomaly)
+	assert.Less(t, drop.Score, -3.5)
+}
+
+func TestScoreAnomalyIsRobust(t *testing.T) {
+	// An earlier spike barely moves the baseline
+	score := models.ScoreAnomaly([]float64{100, 100, 100, 5000}, 130, 3.5)
+	assert.Equal(t, 100.0, score.Expected)
+	assert.False(t, score.Anomaly)
+
+	// Steady series get a spread of at least the square root of the count
+	score = models.ScoreAnomaly([]float64{100, 100, 100}, 120, 3.5)
+	assert.Equal(t, 2.0, score.Score)
+	assert.False(t, score.Anomaly)
+
+	// Tiny series get a spread of at least one and never expect below zero
+	score = models.ScoreAnomaly([]float64{0, 0, 1}, 3, 3.5)
+	assert.Equal(t, 0.0, score.Lower)
+	assert.False(t, score.Anomaly)
+}
+
+func TestSeasonalBaselineScore(t *testing.T) {
+	baseline := models.SeasonalBaseline{Period: 2, Seasons: 3, MinSamples: 2, Threshold: 3.5}
+	values := []float64{0, 0, 50, 10, 50, 10, 50, 10, 500}
+	observed := []bool{false, false, true, true, true, true, true, true, true}
+
+	score := baseline.Score(values, observed, 8)
+	require.NotNil(t, score)
+	assert.Equal(t, 50.0, score.Expected, "compares with the same point of earlier seasons")
+	assert.True(t, score.Anomaly)
+
+	score = baseline.Score(values, observed, 7)
+	require.NotNil(t, score)
+	assert.Equal(t, 10.0, score.Expected)
+
+	assert.Nil(t, baseline.Score(values, observed, 4), "unobserved points are not samples")
+}
+
+func dailyStats(start time.Time, views []int) []models.DailyStat {
+	var stats []models.DailyStat
+	for i, v := range views {
+		if v == 0 {
+			continue
+		}
+		stats = append(stats, models.DailyStat{
+			Date:   start.AddDate(0, 0, i).Format("2006-01-02"),
+			Views:  v,
+			Unique: v / 2,
+		})
+	}
+	return stats
+}
+
+func TestDetectDailyAnomalies(t *testing.T) {
+	dateRange, err := models.NewDateRange("2024-03-04", "2024-03-10", 0, "UTC", time.Now())
+	require.NoError(t, err)
+
+	history := models.DailyAnomalyHistory(dateRange)
+	assert.Equal(t, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), history.Start)
+	assert.Equal(t, dateRange.Start, history.End)
+
+	// Weekdays get 1000 pageviews and weekends 200
+	week := []int{1000, 1000, 1000, 1000, 1000, 200, 200}
+	var views []int
+	for i := 0; i < models.DailyAnomalyBaseline.Seasons; i++ {
+		views = append(views, week...)
+	}
+	// The range has a Wednesday with no traffic at all and a Saturday spike
+	rangeViews := []int{1000, 1000, 0, 1000, 1000, 900, 200}
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	anomalies := models.DetectDailyAnomalies(
+		dailyStats(history.Start, views),
+		dailyStats(dateRange.Start, rangeViews),
+		dateRange, now,
+	)
+
+	require.Len(t, anomalies.Views, 6, "Sunday is not over yet")
+	require.Len(t, anomalies.Unique, 6)
+	assert.Equal(t, 4, anomalies.Count)
+
+	assert.Equal(t, "2024-03-04", anomalies.Views[0].Date)
+	assert.False(t, anomalies.Views[0].Anomaly)
+	assert.Equal(t, 1000.0, anomalies.Views[0].Expected)
+
+	assert.Equal(t, "2024-03-06", anomalies.Views[2].Date)
+	assert.True(t, anomalies.Views[2].Anomaly)
+	assert.Equal(t, 0.0, anomalies.Views[2].Value)
+	assert.Less(t, anomalies.Views[2].Score, 0.0)
+
+	assert.Equal(t, "2024-03-09", anomalies.Views[5].Date)
+	assert.True(t, anomalies.Views[5].Anomaly)
+	assert.Equal(t, 200.0, anomalies.Views[5].Expected, "weekends are compared with weekends")
+	assert.Greater(t, anomalies.Views[5].Score, 0.0)
+}
+
+func TestDetectDailyAnomaliesNeedsHistory(t *testing.T) {
+	dateRange, err := models.NewDateRange("2024-03-04", "2024-03-10", 0, "UTC", time.Now())
+	require.NoError(t, err)
+
+	// The website was only tracked from two weeks before the range
+	start := dateRange.Start.AddDate(0, 0, -14)
+	history := dailyStats(start, []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10})
+	anomalies := models.DetectDailyAnomalies(history, nil, dateRange, dateRange.End)
+
+	assert.Empty(t, anomalies.Views)
+	assert.Empty(t, anomalies.Unique)
+	assert.Zero(t, anomalies.Count)
+}
+
+func TestDetectHourlyAnomalies(t *testing.T) {
+	dateRange, err := models.NewDateRange("2024-03-04T00:00:00Z", "2024-03-04T03:00:00Z", 0, "UTC", time.Now())
+	require.NoError(t, err)
+
+	history := models.HourlyAnomalyHistory(dateRange)
+	assert.Equal(t, dateRange.Start.Add(-4*7*24*time.Hour), history.Start)
+
+	var stats []models.HourlyStat
+	for hour := history.Start; hour.Before(history.End); hour = hour.Add(time.Hour) {
+		stats = append(stats, models.HourlyStat{Timestamp: hour, Views: 40, Unique: 20})
+	}
+	current := []models.HourlyStat{
+		{Timestamp: dateRange.Start, Views: 42, Unique: 21},
+		{Timestamp: dateRange.Start.Add(time.Hour), Views: 400, Unique: 21},
+		{Timestamp: dateRange.Start.Add(2 * time.Hour), Views: 40, Unique: 20},
+	}
+
+	anomalies := models.DetectHourlyAnomalies(stats, current, dateRange, dateRange.End)
+	require.Len(t, anomalies.Views, 3)
+	assert.Equal(t, 1, anomalies.Count)
+	assert.False(t, anomalies.Views[0].Anomaly)
+	assert.True(t, anomalies.Views[1].Anomaly)
+	assert.Equal(t, dateRange.Start.Add(time.Hour), *anomalies.Views[1].Timestamp)
+	assert.Equal(t, 40.0, anomalies.Views[1].Expected)
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAttributionQuery(t *testing.T) {
+	goalID := "6f1c1f0e-8f1a-4c55-9d0e-3b1f5d1c2a10"
+
+	q, err := models.NewAttributionQuery("", "", 0, goalID, "")
+	require.NoError(t, err)
+	assert.Equal(t, models.AttributionLastTouch, q.Model)
+	assert.Equal(t, "source", q.Dimension)
+	assert.Equal(t, models.DefaultAttributionLookbackDays, q.LookbackDays)
+	require.NotNil(t, q.GoalID)
+	assert.Nil(t, q.FunnelID)
+
+	_, err = models.NewAttributionQuery("linear", "source", 30, "", "")
+	assert.Error(t, err)
+	_, err = models.NewAttributionQuery("linear", "source", 30, goalID, goalID)
+	assert.Error(t, err)
+	_, err = models.NewAttributionQuery("u_shaped", "source", 30, goalID, "")
+	assert.Error(t, err)
+	_, err = models.NewAttributionQuery("linear", "country", 30, goalID, "")
+	assert.Error(t, err)
+	_, err = models.NewAttributionQuery("linear", "source", models.MaxAttributionLookbackDays+1, goalID, "")
+	assert.Error(t, err)
+}
+
+func TestAttributionWeights(t *testing.T) {
+	converted := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	touches := []time.Time{
+		converted.Add(-14 * 24 * time.Hour),
+		converted.Add(-7 * 24 * time.Hour),
+		converted.Add(-3 * 24 * time.Hour),
+		converted,
+	}
+
+	assert.Equal(t, []float64{1, 0, 0, 0}, models.AttributionWeights(models.AttributionFirstTouch, touches, converted))
+	assert.Equal(t, []float64{0, 0, 0, 1}, models.AttributionWeights(models.AttributionLastTouch, touches, converted))
+	assert.Equal(t, []float64{0.25, 0.25, 0.25, 0.25}, models.AttributionWeights(models.AttributionLinear, touches, converted))
+
+	positions := models.AttributionWeights(models.AttributionPositionBased, touches, converted)
+	assert.InDeltaSlice(t, []float64{0.4, 0.1, 0.1, 0.4}, positions, 1e-9)
+
+	// Each half-life halves a touch's weight relative to the conversion-time touch
+	decay := models.AttributionWeights(models.AttributionTimeDecay, touches, converted)
+	assert.InDelta(t, 0.5, decay[1]/decay[3], 1e-9)
+	assert.InDelta(t, 0.25, decay[0]/decay[3], 1e-9)
+	assert.InDelta(t, 1.0, decay[0]+decay[1]+decay[2]+decay[3], 1e-9)
+
+	// A single touch always gets all the credit
+	for _, model := range []models.AttributionModel{models.AttributionFirstTouch, models.AttributionPositionBased, models.AttributionTimeDecay} {
+		assert.Equal(t, []float64{1}, models.AttributionWeights(model, touches[:1], converted))
+	}
+	assert.Equal(t, []float64{0.5, 0.5}, models.AttributionWeights(models.AttributionPositionBased, touches[:2], converted))
+	assert.Empty(t, models.AttributionWeights(models.AttributionLinear, nil, converted))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// defaultRuleRegex returns the regex of the first built-in rule for channel on field
+func defaultRuleRegex(t *testing.T, channel string, field models.ChannelField) *regexp.Regexp {
+	for _, rule := range models.DefaultChannelRules {
+		if rule.Channel != channel || len(rule.Conditions) != 1 {
+			continue
+		}
+		if c := rule.Conditions[0]; c.Field == field && c.Operator == models.FilterRegex {
+			return regexp.MustCompile("(?i)" + c.Value)
+		}
+	}
+	t.Fatalf("no built-in %s rule on %s", channel, field)
+	return nil
+}
+
+func TestDefaultChannelRules(t *testing.T) {
+	require.NoError(t, models.DefaultChannelRules.Validate())
+
+	search := defaultRuleRegex(t, models.ChannelOrganicSearch, models.ChannelFieldReferrer)
+	for _, host := range []string{"google.com", "google.co.uk", "search.yahoo.com", "duckduckgo.com", "search.brave.com"} {
+		assert.True(t, search.MatchString(host), host)
+	}
+	for _, host := range []string{"googleblog.com", "notgoogle.com", "bing.com.example.org", "brave.com"} {
+		assert.False(t, search.MatchString(host), host)
+	}
+
+	social := defaultRuleRegex(t, models.ChannelOrganicSocial, models.ChannelFieldSource)
+	assert.True(t, social.MatchString("facebook"))
+	assert.True(t, social.MatchString("LinkedIn.com"))
+	assert.False(t, social.MatchString("facebook-ads-test"))
+
+	email := defaultRuleRegex(t, models.ChannelEmail, models.ChannelFieldMedium)
+	assert.True(t, email.MatchString("e-mail"))
+	assert.True(t, email.MatchString("Newsletter"))
+	assert.False(t, email.MatchString("emailing"))
+}
+
+func TestChannelRulesValidate(t *testing.T) {
+	valid := models.ChannelRules{{
+		Channel: "Partners",
+		Conditions: []models.ChannelCondition{
+			{Field: models.ChannelFieldSource, Operator: models.FilterEquals, Value: "acme"},
+		},
+	}}
+	assert.NoError(t, valid.Validate())
+
+	invalid := map[string]models.ChannelRule{
+		"no channel":    {Conditions: valid[0].Conditions},
+		"no conditions": {Channel: "Partners"},
+		"unknown field": {Channel: "Partners", Conditions: []models.ChannelCondition{{Field: "country", Operator: models.FilterEquals, Value: "x"}}},
+		"bad operator":  {Channel: "Partners", Conditions: []models.ChannelCondition{{Field: models.ChannelFieldSource, Operator: "in", Value: "x"}}},
+		"bad regex":     {Channel: "Partners", Conditions: []models.ChannelCondition{{Field: models.ChannelFieldSource, Operator: models.FilterRegex, Value: "("}}},
+		"go-only regex": {Channel: "Partners", Conditions: []models.ChannelCondition{{Field: models.ChannelFieldReferrer, Operator: models.FilterRegex, Value: `\bacme\b`}}},
+	}
+	assert.NoError(t, models.DefaultChannelRules.Validate(), "the built-in rules stay within the supported syntax")
+	for name, rule := range invalid {
+		assert.Error(t, models.ChannelRules{rule}.Validate(), name)
+	}
+}
+
+func TestChannelFilter(t *testing.T) {
+	filters, err := models.ParseFilters([]string{"channel:eq:Partners", "country:eq:Germany"})
+	require.NoError(t, err)
+	assert.True(t, filters.HasChannel())
+
+	rules := models.ChannelRules{{
+		Channel: "Partners",
+		Conditions: []models.ChannelCondition{
+			{Field: models.ChannelFieldSource, Operator: models.FilterEquals, Value: "ACME"},
+		},
+	}}
+	filters = filters.WithChannels(rules)
+	assert.Equal(t, rules, filters[0].Channels)
+	assert.Nil(t, filters[1].Channels)
+
+	qb := repository.NewQueryBuilder("site", models.LastDays(7), filters)
+	query := qb.Build("WHERE website_id = $1{{filters:e}}")
+
+	// Events match through their session's channel; rule values are compared lower-cased
+	assert.Contains(t, query, "AND e.session_id IN (")
+	assert.Contains(t, query, "WHEN COALESCE(lower(utm_source), '') = $4 THEN $5")
+	assert.Contains(t, query, "WHERE landing.channel = $7")
+	assert.Contains(t, query, "AND e.country = $8")
+	assert.Equal(t, []interface{}{"acme", "Partners", models.ChannelOther, "Partners", "Germany"}, qb.Args()[3:])
+
+	assert.False(t, models.Filters{{Dimension: "page", Operator: models.FilterEquals, Value: "/"}}.HasChannel())
+}
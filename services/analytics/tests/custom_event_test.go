@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSystemEvent(t *testing.T) {
+	for _, eventType := range []string{"pageview", "session_start", "session_end"} {
+		assert.True(t, repository.IsSystemEvent(eventType), eventType)
+	}
+	for _, eventType := range []string{"signup", "click", models.RevenueEventType, "Pageview", ""} {
+		assert.False(t, repository.IsSystemEvent(eventType), eventType)
+	}
+}
+
+func TestEventSignature(t *testing.T) {
+	signature := repository.EventSignature("signup")
+	assert.Len(t, signature, 64)
+	assert.Equal(t, signature, repository.EventSignature("SignUp"), "signatures ignore case")
+	assert.NotEqual(t, signature, repository.EventSignature("download"))
+}
+
+func TestCustomEventBuckets(t *testing.T) {
+	hour := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	paris := time.FixedZone("CET", 3600)
+	events := []models.Event{
+		{WebsiteID: "site", EventType: "signup", Timestamp: hour.Add(5 * time.Minute), Properties: models.Properties{"plan": "pro"}},
+		{WebsiteID: "site", EventType: "pageview", Timestamp: hour},
+		{WebsiteID: "site", EventType: "session_start", Timestamp: hour},
+		// Same bucket: other properties, other case and a non-UTC timestamp in the same hour
+		{WebsiteID: "site", EventType: "SignUp", Timestamp: hour.Add(50 * time.Minute).In(paris), Properties: models.Properties{"plan": "free"}},
+		{WebsiteID: "site", EventType: "signup", Timestamp: hour.Add(time.Hour)},
+		{WebsiteID: "other-site", EventType: "signup", Timestamp: hour},
+		{WebsiteID: "site", EventType: "download", Timestamp: hour.Add(59 * time.Minute)},
+	}
+
+	buckets := repository.CustomEventBuckets(events)
+	require.Len(t, buckets, 4)
+
+	assert.Equal(t, repository.CustomEventBucket{
+		WebsiteID: "site",
+		Signature: repository.EventSignature("signup"),
+		EventType: "signup",
+		Hour:      hour,
+	}, buckets[0])
+	assert.Equal(t, hour.Add(time.Hour), buckets[1].Hour)
+	assert.Equal(t, "other-site", buckets[2].WebsiteID)
+	assert.Equal(t, "download", buckets[3].EventType)
+	assert.Equal(t, hour, buckets[3].Hour)
+
+	assert.Empty(t, repository.CustomEventBuckets([]models.Event{{WebsiteID: "site", EventType: "pageview", Timestamp: hour}}))
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateRangeExplicitDatesInTimezone(t *testing.T) {
+	r, err := models.NewDateRange("2025-07-01", "2025-09-30", 0, "America/New_York", time.Now())
+	require.NoError(t, err)
+
+	// Day boundaries are midnight in the site's zone, and the end date is inclusive
+	assert.Equal(t, time.Date(2025, 7, 1, 4, 0, 0, 0, time.UTC), r.Start.UTC())
+	assert.Equal(t, time.Date(2025, 10, 1, 4, 0, 0, 0, time.UTC), r.End.UTC())
+	assert.Equal(t, 92, r.Days())
+	assert.Equal(t, "2025-07-01 to 2025-09-30", r.Label())
+
+	previous := r.Previous()
+	assert.Equal(t, r.Start, previous.End)
+	assert.Equal(t, r.End.Sub(r.Start), previous.End.Sub(previous.Start))
+}
+
+func TestDateRangeRelativeDays(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	r, err := models.NewDateRange("", "", 30, "", now)
+	require.NoError(t, err)
+	assert.Equal(t, now, r.End)
+	assert.Equal(t, now.AddDate(0, 0, -30), r.Start)
+	assert.Equal(t, "UTC", r.Timezone)
+	assert.Equal(t, 30, r.Days())
+	assert.Equal(t, "30 days", r.Label())
+}
+
+func TestDateRangeValidation(t *testing.T) {
+	now := time.Now()
+
+	_, err := models.NewDateRange("", "", 7, "Mars/Olympus_Mons", now)
+	assert.Error(t, err)
+
+	_, err = models.NewDateRange("2025-02-01", "2025-01-01", 7, "UTC", now)
+	assert.Error(t, err)
+
+	_, err = models.NewDateRange("01/02/2025", "", 7, "UTC", now)
+	assert.Error(t, err)
+
+	_, err = models.NewDateRange("2020-01-01", "2025-01-01", 7, "UTC", now)
+	assert.Error(t, err)
+}
+
+func TestDateRangeValidateHourly(t *testing.T) {
+	now := time.Now()
+
+	month, err := models.NewDateRange("", "", models.MaxHourlyRangeDays, "UTC", now)
+	require.NoError(t, err)
+	assert.NoError(t, month.ValidateHourly())
+
+	longer, err := models.NewDateRange("", "", models.MaxHourlyRangeDays+1, "UTC", now)
+	require.NoError(t, err)
+	assert.Error(t, longer.ValidateHourly())
+}
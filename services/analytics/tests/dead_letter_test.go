@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/services"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, services.RetryBaseDelay, services.RetryDelay(1))
+	assert.Equal(t, 2*time.Second, services.RetryDelay(2))
+	assert.Equal(t, 4*time.Second, services.RetryDelay(3))
+	assert.Equal(t, 8*time.Second, services.RetryDelay(services.MaxBatchRetries))
+	assert.Equal(t, services.RetryMaxDelay, services.RetryDelay(6), "delays are capped")
+	assert.Equal(t, services.RetryMaxDelay, services.RetryDelay(100), "large retries do not overflow")
+}
+
+func TestSplitBatchResult(t *testing.T) {
+	batch := make([]models.Event, 4)
+	for i := range batch {
+		batch[i] = models.Event{ID: uuid.New(), WebsiteID: "test-site"}
+	}
+	result := &repository.BatchResult{
+		Total:  4,
+		Failed: 2,
+		Failures: []repository.EventFailure{
+			{Index: 3, Err: errors.New("invalid page")},
+			{Index: 1, Err: errors.New("constraint violation")},
+		},
+	}
+
+	written, failed := services.SplitBatchResult(batch, result)
+	assert.Equal(t, []models.Event{batch[0], batch[2]}, written)
+	assert.Len(t, failed, 2)
+	assert.EqualError(t, failed[1], "constraint violation")
+	assert.EqualError(t, failed[3], "invalid page")
+
+	written, failed = services.SplitBatchResult(batch, &repository.BatchResult{Total: 4})
+	assert.Equal(t, batch, written)
+	assert.Empty(t, failed)
+}
+
+func TestDeadLetterPurgeRequestValidate(t *testing.T) {
+	assert.Error(t, (&models.DeadLetterPurgeRequest{}).Validate(), "an empty purge would empty the table")
+	assert.NoError(t, (&models.DeadLetterPurgeRequest{All: true}).Validate())
+	assert.NoError(t, (&models.DeadLetterPurgeRequest{WebsiteID: "test-site"}).Validate())
+
+	before := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, (&models.DeadLetterPurgeRequest{Before: &before}).Validate())
+
+	assert.NoError(t, (&models.DeadLetterPurgeRequest{IDs: []string{uuid.NewString()}}).Validate())
+	assert.Error(t, (&models.DeadLetterPurgeRequest{IDs: []string{"not-a-uuid"}}).Validate())
+	assert.Error(t, (&models.DeadLetterPurgeRequest{IDs: []string{"not-a-uuid"}, All: true}).Validate())
+}
+
+// newDeadLetterService returns an event service whose database is unreachable
+func newDeadLetterService(t *testing.T) *services.EventService {
+	db, err := pgxpool.New(context.Background(), "postgres://analytics@127.0.0.1:1/analytics?connect_timeout=1")
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	service := services.NewEventService(repository.NewEventRepository(db, zerolog.Nop()), repository.NewDeadLetterRepository(db, zerolog.Nop()), nil, nil, nil, db, nil, nil, zerolog.Nop())
+	t.Cleanup(func() { service.Shutdown(time.Second) })
+	return service
+}
+
+func TestPurgeDeadLettersGuard(t *testing.T) {
+	service := newDeadLetterService(t)
+	ctx := context.Background()
+
+	// Rejected requests never reach the database
+	_, err := service.PurgeDeadLetters(ctx, &models.DeadLetterPurgeRequest{})
+	assert.ErrorIs(t, err, services.ErrInvalidDeadLetterRequest)
+	_, err = service.PurgeDeadLetters(ctx, &models.DeadLetterPurgeRequest{IDs: []string{"1"}})
+	assert.ErrorIs(t, err, services.ErrInvalidDeadLetterRequest)
+
+	_, err = service.PurgeDeadLetters(ctx, &models.DeadLetterPurgeRequest{All: true})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, services.ErrInvalidDeadLetterRequest)
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	service := newDeadLetterService(t)
+	ctx := context.Background()
+
+	_, err := service.ReplayDeadLetters(ctx, &models.DeadLetterReplayRequest{IDs: []string{"not-a-uuid"}})
+	assert.ErrorIs(t, err, services.ErrInvalidDeadLetterRequest)
+
+	// A replay that cannot load its events fails as a whole rather than reporting nothing replayed
+	result, err := service.ReplayDeadLetters(ctx, &models.DeadLetterReplayRequest{IDs: []string{uuid.NewString()}})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, services.ErrInvalidDeadLetterRequest)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestScheduleValidate(t *testing.T) {
+	schedule := &models.DigestSchedule{
+		Name:       "Weekly numbers",
+		Frequency:  models.DigestWeekly,
+		Recipients: []string{"owner@example.com", "team@example.com"},
+		Timezone:   "Europe/Berlin",
+		Hour:       8,
+	}
+	require.NoError(t, schedule.Validate())
+
+	schedule.Frequency = "daily"
+	assert.Error(t, schedule.Validate())
+
+	schedule.Frequency = models.DigestMonthly
+	schedule.Timezone = "Mars/Olympus"
+	assert.Error(t, schedule.Validate())
+
+	schedule.Timezone = "UTC"
+	schedule.Hour = 24
+	assert.Error(t, schedule.Validate())
+
+	schedule.Hour = 0
+	schedule.Recipients = []string{"owner@example.com", "Team <team@example.com>"}
+	assert.ErrorContains(t, schedule.Validate(), "recipient 2")
+
+	schedule.Recipients = nil
+	assert.Error(t, schedule.Validate())
+}
+
+func TestDigestSchedulePeriod(t *testing.T) {
+	weekly := &models.DigestSchedule{Frequency: models.DigestWeekly, Timezone: "Europe/Berlin", Hour: 8}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// Monday morning covers the previous Monday to Sunday
+	period, err := weekly.Period(time.Date(2025, 3, 10, 8, 0, 0, 0, berlin))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, berlin), period.Start)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, berlin), period.End)
+
+	// Sunday night still covers the week before
+	period, err = weekly.Period(time.Date(2025, 3, 16, 23, 0, 0, 0, berlin))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, berlin), period.Start)
+
+	monthly := &models.DigestSchedule{Frequency: models.DigestMonthly, Timezone: "UTC", Hour: 8}
+	period, err = monthly.Period(time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), period.Start)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), period.End)
+	assert.Equal(t, 28, period.Days())
+}
+
+func TestDigestScheduleNextRun(t *testing.T) {
+	weekly := &models.DigestSchedule{Frequency: models.DigestWeekly, Timezone: "America/New_York", Hour: 9}
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// Wednesday: the following Monday
+	assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, newYork), weekly.NextRun(time.Date(2025, 3, 5, 12, 0, 0, 0, newYork)))
+	// Monday before the hour: the same day
+	assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, newYork), weekly.NextRun(time.Date(2025, 3, 10, 8, 0, 0, 0, newYork)))
+	// Exactly at the hour: a week later, across the switch to daylight saving time
+	next := weekly.NextRun(time.Date(2025, 3, 3, 9, 0, 0, 0, newYork))
+	assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, newYork), next)
+	assert.Equal(t, 9, next.In(newYork).Hour())
+
+	monthly := &models.DigestSchedule{Frequency: models.DigestMonthly, Timezone: "UTC", Hour: 6}
+	assert.Equal(t, time.Date(2025, 2, 1, 6, 0, 0, 0, time.UTC), monthly.NextRun(time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC), monthly.NextRun(time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2025, 2, 1, 6, 0, 0, 0, time.UTC), monthly.NextRun(time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)))
+}
+
+func digestReport(t *testing.T, frequency models.DigestFrequency, start, end string) *models.DigestReport {
+	period, err := models.NewDateRange(start, end, 0, "UTC", time.Now())
+	require.NoError(t, err)
+
+	visitorChange := 12.5
+	bounceChange := 4.0
+	return &models.DigestReport{
+		WebsiteID: "site",
+		Name:      "example.com",
+		Frequency: frequency,
+		Period:    period,
+		Dashboard: &models.DashboardData{
+			UniqueVisitors:  12345,
+			PageViews:       40210,
+			BounceRate:      41.25,
+			SessionDuration: 125,
+			Comparison: &models.ComparisonMetrics{
+				VisitorChange: &visitorChange,
+				BounceChange:  &bounceChange,
+			},
+			TopPages: []models.PageStat{
+				{Page: "/pricing", Views: 900, Unique: 700},
+				{Page: "/<script>", Views: 3, Unique: 1},
+			},
+			TopSources: []models.SourceStat{{Source: "Google", Views: 5000, UniqueVisitors: 3200}},
+		},
+		DashboardURL: "https://app.example.com/websites/site/analytics",
+	}
+}
+
+func TestDigestPeriodLabel(t *testing.T) {
+	assert.Equal(t, "Mar 3 – Mar 9, 2025", digestReport(t, models.DigestWeekly, "2025-03-03", "2025-03-09").PeriodLabel())
+	assert.Equal(t, "Dec 30, 2024 – Jan 5, 2025", digestReport(t, models.DigestWeekly, "2024-12-30", "2025-01-05").PeriodLabel())
+	assert.Equal(t, "February 2025", digestReport(t, models.DigestMonthly, "2025-02-01", "2025-02-28").PeriodLabel())
+}
+
+func TestRenderDigest(t *testing.T) {
+	msg, err := services.RenderDigest(digestReport(t, models.DigestWeekly, "2025-03-03", "2025-03-09"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com weekly report: Mar 3 – Mar 9, 2025", msg.Subject)
+
+	assert.Contains(t, msg.Text, "12,345")
+	assert.Contains(t, msg.Text, "+12.5%")
+	assert.Contains(t, msg.Text, "2m 05s")
+	assert.Contains(t, msg.Text, "/pricing: 700 visitors, 900 pageviews")
+	assert.Contains(t, msg.Text, "Google: 3,200 visitors, 5,000 pageviews")
+	assert.Contains(t, msg.Text, "compare with the 7 days before")
+	assert.Contains(t, msg.Text, "https://app.example.com/websites/site/analytics")
+
+	assert.Contains(t, msg.HTML, "<title>example.com weekly report")
+	assert.Contains(t, msg.HTML, "40,210")
+	assert.Contains(t, msg.HTML, "&lt;script&gt;")
+	assert.NotContains(t, msg.HTML, "/<script>")
+	// Higher bounce rates are a regression; missing changes are neutral
+	assert.Contains(t, msg.HTML, "color:#b91c1c;\">&#43;4.0%")
+	assert.Contains(t, msg.HTML, "color:#6b7280;\">–")
+
+	// Without data and without a dashboard link
+	report := digestReport(t, models.DigestMonthly, "2025-02-01", "2025-02-28")
+	report.Dashboard.TopPages = nil
+	report.Dashboard.Comparison = nil
+	report.DashboardURL = ""
+	msg, err = services.RenderDigest(report)
+	require.NoError(t, err)
+	assert.Contains(t, msg.Text, "No pageviews in this period")
+	assert.NotContains(t, msg.Text, "View the full dashboard")
+	assert.NotContains(t, msg.HTML, "View the full dashboard")
+}
+
+// smtpSink accepts one SMTP session without TLS or authentication and records the
+// envelope and message it receives
+type smtpSink struct {
+	addr string
+	from string
+	to   []string
+	data []byte
+	done chan struct{}
+}
+
+func startSMTPSink(t *testing.T) *smtpSink {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	sink := &smtpSink{addr: listener.Addr().String(), done: make(chan struct{})}
+	go func() {
+		defer close(sink.done)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		text := textproto.NewConn(conn)
+		defer text.Close()
+
+		text.PrintfLine("220 sink ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				text.PrintfLine("250 sink")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				sink.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				text.PrintfLine("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				sink.to = append(sink.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				text.PrintfLine("250 OK")
+			case command == "DATA":
+				text.PrintfLine("354 Go ahead")
+				sink.data, err = text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				text.PrintfLine("250 OK")
+			case command == "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	return sink
+}
+
+func TestSMTPSender(t *testing.T) {
+	sink := startSMTPSink(t)
+	host, port, err := net.SplitHostPort(sink.addr)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	sender, err := services.NewSMTPSender(services.SMTPConfig{Host: host, Port: portNumber, From: "Reports <reports@example.com>"})
+	require.NoError(t, err)
+
+	msg, err := services.RenderDigest(digestReport(t, models.DigestWeekly, "2025-03-03", "2025-03-09"))
+	require.NoError(t, err)
+	msg.To = []string{"owner@example.com", "team@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sender.Send(ctx, msg))
+	<-sink.done
+
+	assert.Equal(t, "reports@example.com", sink.from)
+	assert.Equal(t, []string{"owner@example.com", "team@example.com"}, sink.to)
+
+	received, err := mail.ReadMessage(strings.NewReader(string(sink.data)))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(received.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, msg.Subject, subject)
+	assert.Equal(t, "owner@example.com, team@example.com", received.Header.Get("To"))
+	assert.Equal(t, `"Reports" <reports@example.com>`, received.Header.Get("From"))
+
+	mediaType, params, err := mime.ParseMediaType(received.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(received.Body, params["boundary"])
+	var bodies []string
+	var types []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, types)
+	assert.Equal(t, msg.Text, bodies[0])
+	assert.Equal(t, msg.HTML, bodies[1])
+}
+
+func TestSMTPSenderConfig(t *testing.T) {
+	_, err := services.NewSMTPSender(services.SMTPConfig{Host: "", From: "reports@example.com"})
+	assert.Error(t, err)
+	_, err = services.NewSMTPSender(services.SMTPConfig{Host: "localhost", From: "not an address"})
+	assert.Error(t, err)
+}
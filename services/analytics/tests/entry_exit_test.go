@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"analytics-app/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeEntryExitPages(t *testing.T) {
+	tp := repository.NewTopPagesAnalytics(nil)
+	totals := []repository.EntryExitPageTotals{
+		{Page: "/pricing", Entries: 30, Exits: 10, Views: 50, Unique: 40, Bounces: 6, TimeOnPageTotal: 400, TimeOnPageCount: 20},
+		{Page: "/blog", Entries: 10, Exits: 40, Views: 80, Unique: 70, Bounces: 10},
+		// Variants of /pricing merge before the rates are computed
+		{Page: "/pricing/", Entries: 10, Exits: 10, Views: 30, Unique: 25, Bounces: 6, TimeOnPageTotal: 200, TimeOnPageCount: 5},
+		{Page: "https://example.com/pricing?ref=x", Entries: 0, Exits: 5, Views: 20, Unique: 15, Bounces: 0},
+	}
+
+	pages := tp.SummarizeEntryExitPages(totals, "entries")
+	require.Len(t, pages, 2)
+
+	pricing := pages[0]
+	assert.Equal(t, "/pricing", pricing.Page)
+	assert.Equal(t, 40, pricing.Entries)
+	assert.Equal(t, 25, pricing.Exits)
+	assert.Equal(t, 100, pricing.Views)
+	assert.Equal(t, 80, pricing.Unique)
+	assert.InDelta(t, 30.0, *pricing.BounceRate, 0.001, "bounces per entry")
+	assert.InDelta(t, 25.0, *pricing.ExitRate, 0.001, "exits per view")
+	assert.InDelta(t, 24.0, *pricing.AvgTime, 0.001)
+
+	blog := pages[1]
+	assert.Equal(t, "/blog", blog.Page)
+	assert.InDelta(t, 100.0, *blog.BounceRate, 0.001)
+	assert.InDelta(t, 50.0, *blog.ExitRate, 0.001)
+	assert.Zero(t, *blog.AvgTime, "no reported time on page")
+
+	exits := tp.SummarizeEntryExitPages(totals, "exits")
+	require.Len(t, exits, 2)
+	assert.Equal(t, "/blog", exits[0].Page)
+	assert.Equal(t, "/pricing", exits[1].Page)
+}
+
+func TestSummarizeEntryExitPagesRates(t *testing.T) {
+	tp := repository.NewTopPagesAnalytics(nil)
+
+	// A page only ever exited from has no entries to bounce from
+	pages := tp.SummarizeEntryExitPages([]repository.EntryExitPageTotals{{Page: "/thanks", Exits: 4, Views: 4}}, "exits")
+	require.Len(t, pages, 1)
+	assert.Zero(t, *pages[0].BounceRate)
+	assert.InDelta(t, 100.0, *pages[0].ExitRate, 0.001)
+
+	// Bounce rates are capped at 100%
+	pages = tp.SummarizeEntryExitPages([]repository.EntryExitPageTotals{{Page: "/", Entries: 2, Exits: 3, Views: 3, Bounces: 3}}, "entries")
+	require.Len(t, pages, 1)
+	assert.Equal(t, 100.0, *pages[0].BounceRate)
+
+	assert.Empty(t, tp.SummarizeEntryExitPages(nil, "entries"))
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/services"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentEventID(t *testing.T) {
+	first := services.IdempotentEventID("test-site", "retry-key", 0)
+
+	// A retried request with the same key maps to the same IDs
+	assert.Equal(t, first, services.IdempotentEventID("test-site", "retry-key", 0))
+
+	// Events in a batch, other keys and other websites get distinct IDs
+	assert.NotEqual(t, first, services.IdempotentEventID("test-site", "retry-key", 1))
+	assert.NotEqual(t, first, services.IdempotentEventID("test-site", "other-key", 0))
+	assert.NotEqual(t, first, services.IdempotentEventID("other-site", "retry-key", 0))
+}
+
+func TestAssignIdempotentIDs(t *testing.T) {
+	explicit := uuid.New()
+	req := &models.BatchEventRequest{
+		SiteID: "batch-site",
+		Events: []models.Event{
+			{VisitorID: "v1"},
+			{VisitorID: "v2", WebsiteID: "event-site"},
+			{VisitorID: "v3", ID: explicit},
+		},
+	}
+
+	services.AssignIdempotentIDs(req, "retry-key")
+
+	// Events without a website_id are keyed by the batch's siteId
+	assert.Equal(t, services.IdempotentEventID("batch-site", "retry-key", 0), req.Events[0].ID)
+	assert.Equal(t, services.IdempotentEventID("event-site", "retry-key", 1), req.Events[1].ID)
+	assert.Equal(t, explicit, req.Events[2].ID, "explicit IDs are kept")
+}
+
+// startRedisStub serves the SET NX and DEL commands the deduplicator uses from memory
+func startRedisStub(t *testing.T) *redis.Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	keys := make(map[string]bool)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readRedisCommand(reader)
+					if err != nil {
+						return
+					}
+
+					mu.Lock()
+					var reply string
+					switch strings.ToUpper(args[0]) {
+					case "SET":
+						nx := false
+						for _, arg := range args[3:] {
+							nx = nx || strings.EqualFold(arg, "NX")
+						}
+						if nx && keys[args[1]] {
+							reply = "$-1\r\n"
+						} else {
+							keys[args[1]] = true
+							reply = "+OK\r\n"
+						}
+					case "DEL":
+						deleted := 0
+						for _, key := range args[1:] {
+							if keys[key] {
+								delete(keys, key)
+								deleted++
+							}
+						}
+						reply = fmt.Sprintf(":%d\r\n", deleted)
+					default:
+						reply = "+OK\r\n"
+					}
+					mu.Unlock()
+
+					if _, err := io.WriteString(conn, reply); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestEventDeduplicatorRelease(t *testing.T) {
+	dedupe := services.NewEventDeduplicator(startRedisStub(t), time.Hour, zerolog.Nop())
+	ctx := context.Background()
+	events := []models.Event{{ID: uuid.New(), WebsiteID: "test-site"}, {ID: uuid.New(), WebsiteID: "test-site"}}
+
+	assert.Equal(t, []bool{true, true}, dedupe.Claim(ctx, events))
+	assert.Equal(t, []bool{false, false}, dedupe.Claim(ctx, events))
+
+	dedupe.Release(ctx, events[:1])
+	assert.Equal(t, []bool{true, false}, dedupe.Claim(ctx, events))
+}
+
+func TestTrackEventRetryAfterFailedAccept(t *testing.T) {
+	dedupe := services.NewEventDeduplicator(startRedisStub(t), time.Hour, zerolog.Nop())
+
+	// Every append opens a new segment, so the spool fails while its directory is missing
+	dir := filepath.Join(t.TempDir(), "spool")
+	spool, err := services.NewEventSpool(dir, 1, zerolog.Nop())
+	require.NoError(t, err)
+
+	// Nothing listens here: batches fail and stay in the spool
+	db, err := pgxpool.New(context.Background(), "postgres://analytics@127.0.0.1:1/analytics?connect_timeout=1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	service := services.NewEventService(repository.NewEventRepository(db, zerolog.Nop()), nil, nil, dedupe, nil, db, spool, nil, zerolog.Nop())
+	defer service.Shutdown(10 * time.Second)
+
+	eventID := uuid.New()
+	newEvent := func() *models.Event {
+		return &models.Event{ID: eventID, WebsiteID: "test-site", VisitorID: "visitor", SessionID: "session", Page: "/"}
+	}
+
+	require.NoError(t, os.RemoveAll(dir))
+	_, err = service.TrackEvent(context.Background(), newEvent())
+	require.Error(t, err)
+	assert.Equal(t, 0, spool.Stats()["spool_pending"])
+
+	// The client's retry is stored rather than answered as a duplicate
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	response, err := service.TrackEvent(context.Background(), newEvent())
+	require.NoError(t, err)
+	assert.False(t, response.Duplicate)
+	assert.Equal(t, 1, spool.Stats()["spool_pending"])
+
+	response, err = service.TrackEvent(context.Background(), newEvent())
+	require.NoError(t, err)
+	assert.True(t, response.Duplicate)
+}
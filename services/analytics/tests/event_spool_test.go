@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spoolEvents(n int) []models.Event {
+	events := make([]models.Event, n)
+	for i := range events {
+		events[i] = models.Event{
+			WebsiteID: "test-site",
+			VisitorID: "visitor-123",
+			SessionID: "session-456",
+			EventType: "pageview",
+			Page:      "/page",
+		}
+	}
+	return events
+}
+
+func TestEventSpoolReplaysUnackedEvents(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := services.NewEventSpool(dir, 0, zerolog.Nop())
+	require.NoError(t, err)
+
+	seqs, err := spool.Append(spoolEvents(3))
+	require.NoError(t, err)
+	require.Len(t, seqs, 3)
+
+	// Commit the first event only, then simulate a crash
+	require.NoError(t, spool.Ack(seqs[:1]))
+	require.NoError(t, spool.Close())
+
+	reopened, err := services.NewEventSpool(dir, 0, zerolog.Nop())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	replayed, events, err := reopened.TakeReleased(10)
+	require.NoError(t, err)
+	assert.Equal(t, seqs[1:], replayed)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "test-site", events[0].WebsiteID)
+
+	// New events continue the sequence instead of reusing numbers
+	next, err := reopened.Append(spoolEvents(1))
+	require.NoError(t, err)
+	assert.Greater(t, next[0], seqs[2])
+}
+
+func TestEventSpoolRemovesCommittedSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	// A tiny segment size forces a new segment for every append
+	spool, err := services.NewEventSpool(dir, 1, zerolog.Nop())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	first, err := spool.Append(spoolEvents(2))
+	require.NoError(t, err)
+	second, err := spool.Append(spoolEvents(2))
+	require.NoError(t, err)
+
+	require.NoError(t, spool.Ack(first))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	require.NoError(t, spool.Ack(second))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestEventSpoolReleaseRedelivers(t *testing.T) {
+	spool, err := services.NewEventSpool(t.TempDir(), 0, zerolog.Nop())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	seqs, err := spool.Append(spoolEvents(2))
+	require.NoError(t, err)
+
+	taken, _, err := spool.TakeReleased(10)
+	require.NoError(t, err)
+	assert.Empty(t, taken)
+
+	spool.Release(seqs[1:])
+
+	taken, events, err := spool.TakeReleased(10)
+	require.NoError(t, err)
+	assert.Equal(t, seqs[1:], taken)
+	assert.Len(t, events, 1)
+}
+
+func TestEventSpoolConcurrentAppends(t *testing.T) {
+	dir := t.TempDir()
+
+	// Small segments so that group commits and segment rollovers interleave
+	spool, err := services.NewEventSpool(dir, 2048, zerolog.Nop())
+	require.NoError(t, err)
+
+	const writers, appends = 8, 25
+	var (
+		mu   sync.Mutex
+		all  []uint64
+		wg   sync.WaitGroup
+		errs = make(chan error, writers*appends)
+	)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < appends; i++ {
+				seqs, err := spool.Append(spoolEvents(2))
+				if err != nil {
+					errs <- err
+					return
+				}
+				mu.Lock()
+				all = append(all, seqs...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.NoError(t, spool.Close())
+
+	require.Len(t, all, writers*appends*2)
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	for i := 1; i < len(all); i++ {
+		require.NotEqual(t, all[i-1], all[i], "sequence numbers are unique")
+	}
+
+	// Every acknowledged append survives a restart
+	reopened, err := services.NewEventSpool(dir, 2048, zerolog.Nop())
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	replayed, events, err := reopened.TakeReleased(len(all) + 10)
+	require.NoError(t, err)
+	assert.Equal(t, all, replayed)
+	assert.Len(t, events, len(all))
+}
@@ -71,6 +71,41 @@ func TestValidateEvent(t *testing.T) {
 			wantErr: true,
 			errMsg:  "scroll_depth must be between 0 and 100",
 		},
+		{
+			name: "valid purchase",
+			event: purchaseEvent(models.Properties{
+				"order_id": "1042", "amount": 59.9, "currency": "eur",
+				"items": []interface{}{map[string]interface{}{"id": "sku-1", "price": 29.95, "quantity": 2.0}},
+			}),
+			wantErr: false,
+		},
+		{
+			name:    "purchase without order_id",
+			event:   purchaseEvent(models.Properties{"amount": 10.0, "currency": "USD"}),
+			wantErr: true,
+			errMsg:  "order_id is required",
+		},
+		{
+			name:    "purchase with negative amount",
+			event:   purchaseEvent(models.Properties{"order_id": "1", "amount": -1.0, "currency": "USD"}),
+			wantErr: true,
+			errMsg:  "amount must be a non-negative number",
+		},
+		{
+			name:    "purchase with unknown currency format",
+			event:   purchaseEvent(models.Properties{"order_id": "1", "amount": 10.0, "currency": "dollars"}),
+			wantErr: true,
+			errMsg:  "currency must be a 3-letter ISO 4217 code",
+		},
+		{
+			name: "purchase with malformed item",
+			event: purchaseEvent(models.Properties{
+				"order_id": "1", "amount": 10.0, "currency": "USD",
+				"items": []interface{}{map[string]interface{}{"id": "a", "price": 1.0, "quantity": 0.0}},
+			}),
+			wantErr: true,
+			errMsg:  "items[0]: quantity must be a positive number",
+		},
 		{
 			name: "valid relative URL",
 			event: models.Event{
@@ -229,6 +264,17 @@ func TestEventTimestamp(t *testing.T) {
 }
 
 // Helper functions for tests
+func purchaseEvent(properties models.Properties) models.Event {
+	return models.Event{
+		WebsiteID:  "test-site",
+		VisitorID:  "visitor-123",
+		SessionID:  "session-456",
+		Page:       "/checkout/complete",
+		EventType:  models.RevenueEventType,
+		Properties: properties,
+	}
+}
+
 func intPtr(i int) *int {
 	return &i
 }
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pricingExperiment() models.Experiment {
+	goalID := uuid.New()
+	return models.Experiment{
+		WebsiteID:          "test-site",
+		Name:               "Pricing page copy",
+		Key:                "pricing-copy",
+		ExperimentProperty: models.DefaultExperimentProperty,
+		VariantProperty:    models.DefaultVariantProperty,
+		ControlVariant:     "A",
+		GoalID:             &goalID,
+		Confidence:         0.95,
+		StartedAt:          time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestExperimentValidate(t *testing.T) {
+	valid := pricingExperiment()
+	require.NoError(t, valid.Validate())
+
+	funnelID := uuid.New()
+	bothMetrics := pricingExperiment()
+	bothMetrics.FunnelID = &funnelID
+	assert.Error(t, bothMetrics.Validate())
+
+	noMetric := pricingExperiment()
+	noMetric.GoalID = nil
+	assert.Error(t, noMetric.Validate())
+
+	oddConfidence := pricingExperiment()
+	oddConfidence.Confidence = 0.8
+	assert.Error(t, oddConfidence.Validate())
+
+	sameProperty := pricingExperiment()
+	sameProperty.VariantProperty = sameProperty.ExperimentProperty
+	assert.Error(t, sameProperty.Validate())
+
+	endedEarly := pricingExperiment()
+	ended := endedEarly.StartedAt.Add(-time.Hour)
+	endedEarly.EndedAt = &ended
+	assert.Error(t, endedEarly.Validate())
+}
+
+func TestAnalyzeExperiment(t *testing.T) {
+	experiment := pricingExperiment()
+	counts := []models.ExperimentCounts{
+		{Variant: "B", Visitors: 1000, Conversions: 150},
+		{Variant: "A", Visitors: 1000, Conversions: 100},
+	}
+
+	report := models.AnalyzeExperiment(experiment, counts)
+	require.Len(t, report.Variants, 2)
+
+	control, variant := report.Variants[0], report.Variants[1]
+	assert.Equal(t, "A", control.Variant)
+	assert.True(t, control.Control)
+	assert.InDelta(t, 10.0, control.ConversionRate, 0.001)
+	assert.Less(t, control.ConfidenceLow, 10.0)
+	assert.Greater(t, control.ConfidenceHigh, 10.0)
+	assert.Nil(t, control.PValue)
+
+	require.NotNil(t, variant.ZScore)
+	assert.InDelta(t, 3.38, *variant.ZScore, 0.01)
+	assert.Less(t, *variant.PValue, 0.001)
+	assert.InDelta(t, 50.0, *variant.Uplift, 0.001)
+	assert.True(t, variant.Significant)
+	assert.Greater(t, variant.ProbabilityToBeBest, 0.99)
+	assert.InDelta(t, 1.0, control.ProbabilityToBeBest+variant.ProbabilityToBeBest, 0.001)
+
+	require.NotNil(t, report.Winner)
+	assert.Equal(t, "B", *report.Winner)
+	assert.InDelta(t, 0.05, report.Alpha, 0.0001)
+	// Without a planned sample size, results are not protected against peeking
+	assert.NotEmpty(t, report.Warnings)
+}
+
+func TestAnalyzeExperimentSequentialGuard(t *testing.T) {
+	experiment := pricingExperiment()
+	experiment.SampleSize = 4000
+	counts := []models.ExperimentCounts{
+		{Variant: "A", Visitors: 1000, Conversions: 100},
+		{Variant: "B", Visitors: 1000, Conversions: 150},
+	}
+
+	report := models.AnalyzeExperiment(experiment, counts)
+	require.NotNil(t, report.InformationFraction)
+	assert.InDelta(t, 0.25, *report.InformationFraction, 0.0001)
+	assert.Less(t, report.Alpha, 0.001)
+	assert.False(t, report.Variants[1].Significant)
+	assert.Nil(t, report.Winner)
+
+	// Once the planned sample is in, the nominal alpha applies
+	experiment.SampleSize = 1000
+	report = models.AnalyzeExperiment(experiment, counts)
+	assert.InDelta(t, 0.05, report.Alpha, 0.0001)
+	assert.True(t, report.Variants[1].Significant)
+}
+
+func TestAnalyzeExperimentSmallSample(t *testing.T) {
+	experiment := pricingExperiment()
+	counts := []models.ExperimentCounts{
+		{Variant: "A", Visitors: 40, Conversions: 2},
+		{Variant: "B", Visitors: 40, Conversions: 12},
+	}
+
+	report := models.AnalyzeExperiment(experiment, counts)
+	assert.False(t, report.Variants[1].Significant)
+	assert.Nil(t, report.Winner)
+	assert.Len(t, report.Warnings, 3)
+
+	missingControl := models.AnalyzeExperiment(experiment, counts[1:])
+	assert.Contains(t, missingControl.Warnings[0], "control variant")
+	assert.Nil(t, missingControl.Variants[0].PValue)
+}
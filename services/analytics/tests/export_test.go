@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportJobValidate(t *testing.T) {
+	job := &models.ExportJob{
+		Source:  models.ExportEventsSource,
+		Format:  models.ExportParquet,
+		Filters: models.Filters{{Dimension: "country", Operator: models.FilterEquals, Value: "Germany"}},
+	}
+	require.NoError(t, job.Validate())
+	assert.False(t, job.IsReport())
+
+	job.Source = "entry-pages"
+	require.NoError(t, job.Validate())
+	assert.True(t, job.IsReport())
+
+	job.Source = "sessions"
+	assert.Error(t, job.Validate())
+
+	job.Source = "pages"
+	job.Format = "xlsx"
+	assert.Error(t, job.Validate())
+
+	job.Format = models.ExportCSV
+	job.Filters = append(job.Filters, models.Filter{Dimension: "ip_address", Operator: models.FilterEquals, Value: "1.2.3.4"})
+	assert.ErrorContains(t, job.Validate(), "filter 2")
+
+	job.Filters = nil
+	job.Source = "hourly"
+	job.StartDate = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	job.EndDate = job.StartDate.AddDate(0, 0, models.MaxHourlyRangeDays)
+	require.NoError(t, job.Validate())
+	job.EndDate = job.StartDate.AddDate(0, 0, models.MaxHourlyRangeDays+1)
+	assert.ErrorContains(t, job.Validate(), "hourly")
+}
+
+func TestExportJobFileName(t *testing.T) {
+	dateRange, err := models.NewDateRange("2025-03-01", "2025-03-31", 0, "Europe/Berlin", time.Now())
+	require.NoError(t, err)
+
+	job := &models.ExportJob{WebsiteID: "site", Source: "pages", Format: models.ExportNDJSON,
+		StartDate: dateRange.Start, EndDate: dateRange.End, Timezone: dateRange.Timezone}
+	assert.Equal(t, "site-pages-2025-03-01-2025-03-31.ndjson", job.FileName())
+	assert.Equal(t, "application/x-ndjson", job.Format.ContentType())
+}
+
+func TestExportTable(t *testing.T) {
+	bounce := 42.5
+	columns, rows, err := models.ExportTable([]models.PageStat{
+		{Page: "/", Views: 10, Unique: 7, BounceRate: &bounce},
+		{Page: "/pricing", Views: 3, Unique: 3},
+	})
+	require.NoError(t, err)
+	require.Len(t, columns, 7)
+	assert.Equal(t, models.ExportColumn{Name: "page", Type: models.ExportString}, columns[0])
+	assert.Equal(t, models.ExportColumn{Name: "views", Type: models.ExportInt}, columns[1])
+	assert.Equal(t, models.ExportColumn{Name: "bounce_rate", Type: models.ExportFloat}, columns[3])
+	assert.Equal(t, []interface{}{"/", int64(10), int64(7), 42.5, nil, nil, nil}, rows[0])
+	assert.Nil(t, rows[1][3])
+
+	columns, rows, err = models.ExportTable([]models.CustomEventStat{
+		{EventType: "signup", Count: 2, SampleProperties: models.Properties{"plan": "pro"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportString, columns[2].Type)
+	assert.Equal(t, `{"plan":"pro"}`, rows[0][2])
+	assert.Nil(t, rows[0][3])
+
+	// Empty reports still have columns
+	columns, rows, err = models.ExportTable([]models.HourlyStat{})
+	require.NoError(t, err)
+	assert.Len(t, columns, 5)
+	assert.Equal(t, models.ExportTimestamp, columns[3].Type)
+	assert.Empty(t, rows)
+
+	_, _, err = models.ExportTable(map[string]int{})
+	assert.Error(t, err)
+}
+
+func exportSample() ([]models.ExportColumn, [][]interface{}) {
+	at := time.Date(2025, 3, 1, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+	columns := []models.ExportColumn{
+		{Name: "page", Type: models.ExportString},
+		{Name: "timestamp", Type: models.ExportTimestamp},
+		{Name: "views", Type: models.ExportInt},
+		{Name: "bounce_rate", Type: models.ExportFloat},
+	}
+	rows := [][]interface{}{
+		{"/pricing, plans", at, int64(12), 33.5},
+		{"/", at.Add(time.Hour), int64(3), nil},
+	}
+	return columns, rows
+}
+
+func writeExport(t *testing.T, format models.ExportFormat) []byte {
+	columns, rows := exportSample()
+	var buf bytes.Buffer
+	writer, err := services.NewExportWriter(format, &buf, columns)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(rows[:1]))
+	require.NoError(t, writer.Write(rows[1:]))
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestExportWriterCSV(t *testing.T) {
+	assert.Equal(t, "page,timestamp,views,bounce_rate\n"+
+		"\"/pricing, plans\",2025-03-01T09:30:00Z,12,33.5\n"+
+		"/,2025-03-01T10:30:00Z,3,\n", string(writeExport(t, models.ExportCSV)))
+}
+
+func TestExportWriterNDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeExport(t, models.ExportNDJSON))), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"page":"/pricing, plans","timestamp":"2025-03-01T09:30:00Z","views":12,"bounce_rate":33.5}`, lines[0])
+	assert.Equal(t, `{"page":"/","timestamp":"2025-03-01T10:30:00Z","views":3,"bounce_rate":null}`, lines[1])
+}
+
+func TestExportWriterParquet(t *testing.T) {
+	data := writeExport(t, models.ExportParquet)
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), file.NumRows())
+
+	schema := file.Schema()
+	page, ok := schema.Lookup("page")
+	require.True(t, ok)
+	views, ok := schema.Lookup("views")
+	require.True(t, ok)
+	bounce, ok := schema.Lookup("bounce_rate")
+	require.True(t, ok)
+	timestamp, ok := schema.Lookup("timestamp")
+	require.True(t, ok)
+
+	reader := parquet.NewReader(bytes.NewReader(data))
+	rows := make([]parquet.Row, 2)
+	n, err := reader.ReadRows(rows)
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, n)
+
+	assert.Equal(t, "/pricing, plans", rows[0][page.ColumnIndex].String())
+	assert.Equal(t, int64(12), rows[0][views.ColumnIndex].Int64())
+	assert.Equal(t, 33.5, rows[0][bounce.ColumnIndex].Double())
+	assert.Equal(t, time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC).UnixMilli(), rows[0][timestamp.ColumnIndex].Int64())
+	assert.True(t, rows[1][bounce.ColumnIndex].IsNull())
+}
+
+func TestExportWriterUnknownFormat(t *testing.T) {
+	_, err := services.NewExportWriter("xlsx", io.Discard, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := models.ParseFilter("page:contains:/blog:2025")
+	require.NoError(t, err)
+	assert.Equal(t, "page", f.Dimension)
+	assert.Equal(t, models.FilterContains, f.Operator)
+	assert.Equal(t, "/blog:2025", f.Value)
+
+	f, err = models.ParseFilter("properties.plan:eq:pro")
+	require.NoError(t, err)
+	key, ok := f.PropertyKey()
+	assert.True(t, ok)
+	assert.Equal(t, "plan", key)
+
+	for _, raw := range []string{
+		"country",
+		"ip_address:eq:1.2.3.4",
+		"country:like:Germany",
+		"properties.:eq:x",
+		"page:regex:(unclosed",
+	} {
+		_, err := models.ParseFilter(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestValidateRegex(t *testing.T) {
+	for _, pattern := range []string{
+		`^/blog/[a-z0-9-]+$`,
+		`^(|\(?direct\)?)$`,
+		`(^|\.)(google\.com$|bing\.com$)`,
+		`^/docs/(?:api|guides)/\d{1,3}$`,
+		`[[:alpha:]_\s]+`,
+		`[]a]`,
+		`\W+`,
+	} {
+		assert.NoError(t, models.ValidateRegex(pattern), pattern)
+	}
+
+	for _, pattern := range []string{
+		`(unclosed`,
+		`(?i)pricing`,
+		`(?P<slug>[a-z]+)`,
+		`\bsignup\b`,
+		`\pL+`,
+		`\Qa.b\E`,
+		`end\z`,
+		`[\W]`,
+		`a{256}`,
+	} {
+		assert.Error(t, models.ValidateRegex(pattern), pattern)
+	}
+}
+
+func TestQueryBuilderFilters(t *testing.T) {
+	filters := models.Filters{
+		{Dimension: "country", Operator: models.FilterEquals, Value: "Germany"},
+		{Dimension: "properties.plan", Operator: models.FilterNotEquals, Value: "free"},
+		{Dimension: "page", Operator: models.FilterContains, Value: "50%_off"},
+	}
+
+	qb := repository.NewQueryBuilder("site", models.LastDays(7), filters)
+	assert.Equal(t, "$4", qb.Arg(10))
+
+	query := qb.Build("WHERE website_id = $1{{filters:e}} LIMIT $4")
+	assert.Equal(t, "WHERE website_id = $1"+
+		"\n\t\tAND e.country = $5"+
+		"\n\t\tAND (e.properties->>$6) IS DISTINCT FROM $7"+
+		"\n\t\tAND e.page ILIKE $8 LIMIT $4", query)
+
+	args := qb.Args()
+	require.Len(t, args, 8)
+	assert.Equal(t, []interface{}{"Germany", "plan", "free", `%50\%\_off%`}, args[4:])
+
+	// Building again reuses the same placeholders
+	assert.Contains(t, qb.Build("{{filters}}"), "AND country = $5")
+	assert.Len(t, qb.Args(), 8)
+}
+
+func TestQueryBuilderRegexFilter(t *testing.T) {
+	filters := models.Filters{
+		{Dimension: "page", Operator: models.FilterRegex, Value: "^/Blog/"},
+		{Dimension: "properties.plan", Operator: models.FilterRegex, Value: "^pro"},
+	}
+
+	qb := repository.NewQueryBuilder("site", models.LastDays(7), filters)
+	query := qb.Build("WHERE website_id = $1{{filters:e}}")
+
+	// Regex filters are case-insensitive, like contains
+	assert.Equal(t, "WHERE website_id = $1"+
+		"\n\t\tAND e.page ~* $4"+
+		"\n\t\tAND (e.properties->>$5) ~* $6", query)
+	assert.Equal(t, []interface{}{"^/Blog/", "plan", "^pro"}, qb.Args()[3:])
+}
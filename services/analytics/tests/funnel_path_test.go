@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func threeStepFunnel() models.Funnel {
+	return models.Funnel{
+		Name:      "Checkout",
+		WebsiteID: "test-site",
+		Steps: models.FunnelSteps{
+			{ID: "view", Name: "Product", Type: models.FunnelStepPage, Condition: models.FunnelCondition{Page: stringPtr("/product/*")}, Order: 1},
+			{ID: "cart", Name: "Cart", Type: models.FunnelStepPage, Condition: models.FunnelCondition{Page: stringPtr("/cart")}, Order: 2},
+			{ID: "signup", Name: "Pro signup", Type: models.FunnelStepCustom, Condition: models.FunnelCondition{
+				Custom:     stringPtr("signup"),
+				Properties: []models.GoalPropertyCondition{{Key: "plan", Operator: models.FilterEquals, Value: "pro"}},
+			}, Order: 3},
+		},
+	}
+}
+
+// touchesAt builds a timeline where each entry is minutes after the base time with the
+// steps it matches; nil steps is an unrelated event
+func touchesAt(base time.Time, entries ...interface{}) []models.FunnelTouch {
+	var touches []models.FunnelTouch
+	for i := 0; i < len(entries); i += 2 {
+		touch := models.FunnelTouch{Timestamp: base.Add(time.Duration(entries[i].(int)) * time.Minute)}
+		if steps, ok := entries[i+1].([]int); ok {
+			touch.Steps = steps
+		}
+		touches = append(touches, touch)
+	}
+	return touches
+}
+
+func TestFunnelStepFilters(t *testing.T) {
+	funnel := threeStepFunnel()
+
+	product := funnel.Steps[0].Filters()
+	require.Len(t, product, 2)
+	assert.Equal(t, models.FilterRegex, product[1].Operator)
+	assert.Equal(t, models.PagePatternRegex("/product/*"), product[1].Value)
+
+	signup := funnel.Steps[2].Filters()
+	require.Len(t, signup, 2)
+	assert.Equal(t, models.Filter{Dimension: "event_type", Operator: models.FilterEquals, Value: "signup"}, signup[0])
+	assert.Equal(t, "properties.plan", signup[1].Dimension)
+
+	contains := models.FunnelStep{Type: models.FunnelStepPage, Condition: models.FunnelCondition{Page: stringPtr("checkout"), Operator: models.FilterContains}}
+	assert.Equal(t, models.FilterContains, contains.Filters()[1].Operator)
+
+	click := models.FunnelStep{Type: models.FunnelStepEvent, Condition: models.FunnelCondition{Event: stringPtr("#buy")}}
+	assert.True(t, click.Tracked())
+	assert.Nil(t, click.Filters())
+}
+
+func TestFunnelEvaluateOrdering(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	touches := touchesAt(base, 0, []int{0}, 1, nil, 2, []int{1}, 3, nil, 4, []int{2})
+
+	loose := threeStepFunnel()
+	path := loose.Evaluate(touches)
+	assert.Equal(t, 3, path.Reached())
+	assert.Equal(t, base.Add(4*time.Minute), path.StepTimes[2])
+
+	strict := threeStepFunnel()
+	strict.Ordering = models.FunnelOrderStrict
+	assert.Equal(t, 1, strict.Evaluate(touches).Reached())
+
+	adjacent := touchesAt(base, 0, []int{0}, 1, []int{1}, 2, []int{2})
+	assert.Equal(t, 3, strict.Evaluate(adjacent).Reached())
+
+	// Steps out of order do not count
+	reversed := touchesAt(base, 0, []int{1}, 1, []int{0}, 2, []int{2})
+	assert.Equal(t, 1, loose.Evaluate(reversed).Reached())
+}
+
+func TestFunnelEvaluateWindows(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	funnel := threeStepFunnel()
+	funnel.ConversionWindowSeconds = 30 * 60
+	touches := touchesAt(base, 0, []int{0}, 10, []int{1}, 45, []int{2})
+	assert.Equal(t, 2, funnel.Evaluate(touches).Reached())
+
+	// A later start brings the whole path inside the window
+	restarted := touchesAt(base, 0, []int{0}, 20, []int{0}, 30, []int{1}, 45, []int{2})
+	path := funnel.Evaluate(restarted)
+	assert.Equal(t, 3, path.Reached())
+	assert.Equal(t, base.Add(20*time.Minute), path.StepTimes[0])
+
+	// A step window counts from the latest time the previous step was reached
+	stepWindow := threeStepFunnel()
+	stepWindow.Steps[2].WindowSeconds = 5 * 60
+	repeated := touchesAt(base, 0, []int{0}, 1, []int{1}, 30, []int{1}, 33, []int{2})
+	assert.Equal(t, 3, stepWindow.Evaluate(repeated).Reached())
+
+	late := touchesAt(base, 0, []int{0}, 1, []int{1}, 30, []int{2})
+	assert.Equal(t, 2, stepWindow.Evaluate(late).Reached())
+}
+
+func TestFunnelEvaluateSince(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	funnel := threeStepFunnel()
+	touches := touchesAt(base, 0, []int{0}, 1, []int{1}, 2, []int{2}, 60, []int{0}, 61, []int{1}, 62, []int{2})
+
+	// Evaluate keeps the earliest path, which happened before exposure
+	assert.Equal(t, base, funnel.Evaluate(touches).StepTimes[0])
+
+	path := funnel.EvaluateSince(touches, base.Add(30*time.Minute))
+	assert.Equal(t, 3, path.Reached())
+	assert.Equal(t, base.Add(60*time.Minute), path.StepTimes[0])
+
+	// Later steps without a first step after since reach nothing
+	assert.Zero(t, funnel.EvaluateSince(touches, base.Add(61*time.Minute)).Reached())
+}
+
+func TestFunnelStepAnalytics(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	funnel := threeStepFunnel()
+
+	paths := []models.FunnelPath{
+		{StepTimes: []time.Time{base, base.Add(time.Minute), base.Add(2 * time.Minute)}},
+		{StepTimes: []time.Time{base, base.Add(3 * time.Minute)}},
+		{StepTimes: []time.Time{base}},
+		{StepTimes: []time.Time{base}},
+	}
+
+	steps := funnel.StepAnalytics(paths)
+	require.Len(t, steps, 3)
+
+	assert.Equal(t, 4, steps[0].VisitorsReached)
+	assert.InDelta(t, 50.0, steps[0].ConversionRate, 0.01)
+	assert.InDelta(t, 50.0, steps[0].DropOffRate, 0.01)
+	require.NotNil(t, steps[0].AvgTimeOnStep)
+	assert.InDelta(t, 120.0, *steps[0].AvgTimeOnStep, 0.01)
+
+	assert.Equal(t, 2, steps[1].VisitorsReached)
+	assert.InDelta(t, 50.0, steps[1].ConversionRate, 0.01)
+
+	assert.Equal(t, 1, steps[2].VisitorsReached)
+	assert.InDelta(t, 100.0, steps[2].ConversionRate, 0.01)
+	assert.Nil(t, steps[2].AvgTimeOnStep)
+}
+
+func TestValidateFunnelRules(t *testing.T) {
+	valid := threeStepFunnel()
+	valid.Ordering = models.FunnelOrderStrict
+	valid.ConversionWindowSeconds = 1800
+	valid.Steps[1].WindowSeconds = 600
+	require.NoError(t, utils.ValidateFunnel(&valid))
+
+	badOrdering := threeStepFunnel()
+	badOrdering.Ordering = "any"
+	assert.ErrorContains(t, utils.ValidateFunnel(&badOrdering), "ordering")
+
+	badRegex := threeStepFunnel()
+	badRegex.Steps[0].Condition.Operator = models.FilterRegex
+	badRegex.Steps[0].Condition.Page = stringPtr("/product/(")
+	assert.ErrorContains(t, utils.ValidateFunnel(&badRegex), "step 1")
+
+	badOperator := threeStepFunnel()
+	badOperator.Steps[2].Condition.Properties[0].Operator = "gt"
+	assert.ErrorContains(t, utils.ValidateFunnel(&badOperator), "step 3")
+
+	firstWindow := threeStepFunnel()
+	firstWindow.Steps[0].WindowSeconds = 60
+	assert.Error(t, utils.ValidateFunnel(&firstWindow))
+
+	clickProperties := threeStepFunnel()
+	clickProperties.Steps[1] = models.FunnelStep{Name: "Buy", Type: models.FunnelStepEvent, Condition: models.FunnelCondition{
+		Event:      stringPtr("#buy"),
+		Properties: []models.GoalPropertyCondition{{Key: "plan", Operator: models.FilterEquals, Value: "pro"}},
+	}}
+	assert.ErrorContains(t, utils.ValidateFunnel(&clickProperties), "step 2")
+}
+
+func TestNewFunnelQuery(t *testing.T) {
+	q, err := models.NewFunnelQuery(0, "", "", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.FunnelQuery{Days: 7, Mode: models.FunnelModeTracked, Per: models.FunnelPerVisitor}, q)
+
+	q, err = models.NewFunnelQuery(0, "retroactive", "session", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.FunnelQuery{Days: 90, Mode: models.FunnelModeRetroactive, Per: models.FunnelPerSession}, q)
+
+	// Tracked funnels keep their history, retroactive ones scan raw events
+	_, err = models.NewFunnelQuery(365, "tracked", "", "", 0)
+	assert.NoError(t, err)
+	_, err = models.NewFunnelQuery(120, "retroactive", "", "", 0)
+	assert.Error(t, err)
+
+	_, err = models.NewFunnelQuery(7, "live", "", "", 0)
+	assert.Error(t, err)
+	_, err = models.NewFunnelQuery(7, "", "device", "", 0)
+	assert.Error(t, err)
+
+	q, err = models.NewFunnelQuery(7, "", "", "device", 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultFunnelBreakdownLimit, q.Limit)
+
+	q, err = models.NewFunnelQuery(7, "", "", "source", 500)
+	require.NoError(t, err)
+	assert.Equal(t, models.MaxFunnelBreakdownLimit, q.Limit)
+
+	_, err = models.NewFunnelQuery(7, "", "", "ip_address", 0)
+	assert.Error(t, err)
+}
+
+func TestFunnelBreakdown(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	funnel := threeStepFunnel()
+
+	touches := touchesAt(base, 0, []int{0}, 1, []int{1})
+	touches[0].Segment = "mobile"
+	touches[1].Segment = "desktop"
+	// The segment is the one of the event that started the path
+	assert.Equal(t, "mobile", funnel.Evaluate(touches).Segment)
+
+	complete := []time.Time{base, base.Add(time.Minute), base.Add(2 * time.Minute)}
+	paths := []models.FunnelPath{
+		{StepTimes: complete, Segment: "desktop"},
+		{StepTimes: complete[:2], Segment: "desktop"},
+		{StepTimes: complete[:1], Segment: "mobile"},
+		{StepTimes: complete[:1], Segment: "mobile"},
+		{StepTimes: complete[:2], Segment: "mobile"},
+		{StepTimes: complete, Segment: "tablet"},
+	}
+
+	segments := funnel.Breakdown(paths, 2)
+	require.Len(t, segments, 2)
+
+	assert.Equal(t, "mobile", segments[0].Value)
+	assert.Equal(t, 3, segments[0].Entries)
+	assert.Equal(t, 0, segments[0].Conversions)
+	assert.InDelta(t, 33.33, segments[0].StepAnalytics[0].ConversionRate, 0.01)
+
+	assert.Equal(t, "desktop", segments[1].Value)
+	assert.Equal(t, 1, segments[1].Conversions)
+	assert.InDelta(t, 50.0, segments[1].ConversionRate, 0.01)
+}
+
+func TestFunnelStepTimings(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	funnel := threeStepFunnel()
+
+	var paths []models.FunnelPath
+	for _, seconds := range []int{5, 20, 40, 120, 7200} {
+		second := base.Add(time.Duration(seconds) * time.Second)
+		paths = append(paths, models.FunnelPath{StepTimes: []time.Time{base, second, second.Add(time.Minute)}})
+	}
+	paths = append(paths, models.FunnelPath{StepTimes: []time.Time{base}})
+
+	timings := funnel.StepTimings(paths)
+	require.Len(t, timings, 2)
+
+	first := timings[0]
+	assert.Equal(t, 1, first.FromStep)
+	assert.Equal(t, 2, first.ToStep)
+	assert.Equal(t, 5, first.Count)
+	assert.InDelta(t, 20.0, first.P25, 0.01)
+	assert.InDelta(t, 40.0, first.Median, 0.01)
+	assert.InDelta(t, 120.0, first.P75, 0.01)
+	assert.InDelta(t, 4368.0, first.P90, 0.01)
+
+	require.Len(t, first.Histogram, len(models.FunnelTimingBuckets)+1)
+	assert.Equal(t, 1, first.Histogram[0].Count) // under 10s
+	assert.Equal(t, 1, first.Histogram[1].Count) // 10s to 30s
+	assert.Equal(t, 1, first.Histogram[2].Count) // 30s to 1m
+	assert.Equal(t, 1, first.Histogram[3].Count) // 1m to 5m
+	assert.Equal(t, 1, first.Histogram[6].Count) // 1h to 6h
+	assert.Nil(t, first.Histogram[len(first.Histogram)-1].MaxSeconds)
+
+	convert := funnel.TimeToConvert(paths)
+	require.NotNil(t, convert)
+	assert.Equal(t, 3, convert.ToStep)
+	assert.InDelta(t, 100.0, convert.Median, 0.01)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagePatternRegex(t *testing.T) {
+	blog := regexp.MustCompile(models.PagePatternRegex("/blog/*"))
+	assert.True(t, blog.MatchString("/blog/hello-world"))
+	assert.False(t, blog.MatchString("/about"))
+
+	pricing := regexp.MustCompile(models.PagePatternRegex("/pricing"))
+	assert.True(t, pricing.MatchString("/pricing"))
+	assert.True(t, pricing.MatchString("/pricing/"))
+	assert.True(t, pricing.MatchString("/pricing?ref=nav"))
+	assert.False(t, pricing.MatchString("/pricing-old"))
+	assert.False(t, pricing.MatchString("/en/pricing"))
+
+	// Regex metacharacters in the pattern are literal
+	dotted := regexp.MustCompile(models.PagePatternRegex("/docs/v1.2"))
+	assert.False(t, dotted.MatchString("/docs/v102"))
+}
+
+func TestGoalValidate(t *testing.T) {
+	page := "/thank-you"
+	event := "signup"
+	negative := -1.0
+
+	valid := []models.Goal{
+		{Name: "Thank you page", Type: models.GoalPageview, PagePattern: &page},
+		{Name: "Pro signup", Type: models.GoalEvent, EventName: &event, Properties: []models.GoalPropertyCondition{
+			{Key: "plan", Operator: models.FilterEquals, Value: "pro"},
+		}},
+	}
+	for _, goal := range valid {
+		assert.NoError(t, goal.Validate(), goal.Name)
+	}
+
+	invalid := []models.Goal{
+		{Name: "", Type: models.GoalPageview, PagePattern: &page},
+		{Name: "No pattern", Type: models.GoalPageview},
+		{Name: "No event", Type: models.GoalEvent},
+		{Name: "Unknown type", Type: "click"},
+		{Name: "Negative", Type: models.GoalPageview, PagePattern: &page, Value: &negative},
+		{Name: "Page properties", Type: models.GoalPageview, PagePattern: &page, Properties: []models.GoalPropertyCondition{
+			{Key: "plan", Operator: models.FilterEquals, Value: "pro"},
+		}},
+		{Name: "Bad operator", Type: models.GoalEvent, EventName: &event, Properties: []models.GoalPropertyCondition{
+			{Key: "plan", Operator: "gt", Value: "1"},
+		}},
+	}
+	for _, goal := range invalid {
+		assert.Error(t, goal.Validate(), goal.Name)
+	}
+}
+
+func TestGoalFiltersCompile(t *testing.T) {
+	event := "signup"
+	goal := models.Goal{Name: "Pro signup", Type: models.GoalEvent, EventName: &event, Properties: []models.GoalPropertyCondition{
+		{Key: "plan", Operator: models.FilterEquals, Value: "pro"},
+	}}
+
+	dateRange := models.DateRange{Start: time.Now().Add(-time.Hour), End: time.Now(), Timezone: "UTC"}
+	qb := repository.NewQueryBuilder("site", dateRange, nil)
+	condition := qb.Match(goal.Filters(), "e.")
+
+	assert.Equal(t, "(e.event_type = $4 AND (e.properties->>$5) = $6)", condition)
+	require.Len(t, qb.Args(), 6)
+	assert.Equal(t, []interface{}{"signup", "plan", "pro"}, qb.Args()[3:])
+
+	assert.Equal(t, "TRUE", qb.Match(nil, ""))
+}
@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPathQuery(t *testing.T) {
+	q, err := models.NewPathQuery("/pricing", "", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.PathForward, q.Direction)
+	assert.Equal(t, "/pricing", q.Page)
+	assert.Equal(t, models.DefaultPathSteps, q.Steps)
+	assert.Equal(t, models.DefaultPathPagesPerStep, q.PagesPerStep)
+
+	q, err = models.NewPathQuery("", "/signup", 5, 500)
+	require.NoError(t, err)
+	assert.Equal(t, models.PathBackward, q.Direction)
+	assert.Equal(t, 5, q.Steps)
+	assert.Equal(t, models.MaxPathPagesPerStep, q.PagesPerStep)
+
+	_, err = models.NewPathQuery("", "", 3, 10)
+	assert.Error(t, err)
+	_, err = models.NewPathQuery("/a", "/b", 3, 10)
+	assert.Error(t, err)
+	_, err = models.NewPathQuery("/a", "", models.MaxPathSteps+1, 10)
+	assert.Error(t, err)
+
+	assert.Equal(t, "-2:/blog", models.PathNodeID(-2, "/blog"))
+}
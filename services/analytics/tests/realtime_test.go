@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func realtimePageview(visitorID, page string, at time.Time) models.RealtimeEvent {
+	return models.RealtimeEvent{ID: uuid.New(), WebsiteID: "site", VisitorID: visitorID, EventType: "pageview", Page: page, Timestamp: at}
+}
+
+func TestRealtimeStateLiveVisitors(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	state := models.NewRealtimeState()
+
+	state.Add(realtimePageview("v1", "/", now.Add(-10*time.Minute)))
+	state.Add(realtimePageview("v2", "/", now.Add(-3*time.Minute)))
+	state.Add(realtimePageview("v2", "/pricing", now.Add(-time.Minute)))
+	state.Add(realtimePageview("v3", "/pricing", now.Add(-30*time.Second)))
+	state.Add(realtimePageview("v4", "/docs", now.Add(-20*time.Second)))
+	state.Add(models.RealtimeEvent{ID: uuid.New(), VisitorID: "v5", EventType: "signup", Page: "/signup", Timestamp: now})
+
+	snapshot := state.Snapshot("site", now)
+	assert.Equal(t, "site", snapshot.WebsiteID)
+	assert.Equal(t, 3, snapshot.LiveVisitors, "only pageviews within the window count")
+	assert.Equal(t, []models.RealtimePage{{Page: "/pricing", Visitors: 2}, {Page: "/docs", Visitors: 1}}, snapshot.TopPages)
+
+	// An older pageview arriving late does not move the visitor back
+	state.Add(realtimePageview("v3", "/", now.Add(-2*time.Minute)))
+	assert.Equal(t, 2, state.Snapshot("site", now).TopPages[0].Visitors)
+
+	later := state.Snapshot("site", now.Add(4*time.Minute+35*time.Second))
+	assert.Equal(t, 1, later.LiveVisitors)
+	assert.Equal(t, []models.RealtimePage{{Page: "/docs", Visitors: 1}}, later.TopPages)
+}
+
+func TestRealtimeStateRecentEvents(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	state := models.NewRealtimeState()
+
+	var events []models.RealtimeEvent
+	for i := 0; i < models.RealtimeRecentEvents+5; i++ {
+		event := realtimePageview(fmt.Sprintf("v%d", i), "/", now.Add(time.Duration(i)*time.Second))
+		events = append(events, event)
+		state.Add(event)
+	}
+	// Replayed and out-of-order events
+	state.Add(events[len(events)-1])
+	state.Add(realtimePageview("late", "/", now.Add(-time.Hour)))
+
+	recent := state.Snapshot("site", now.Add(time.Minute)).RecentEvents
+	require.Len(t, recent, models.RealtimeRecentEvents)
+	assert.Equal(t, events[len(events)-1].ID, recent[0].ID, "newest first")
+	assert.Equal(t, events[5].ID, recent[len(recent)-1].ID)
+	for _, event := range recent {
+		assert.Empty(t, event.VisitorID)
+	}
+}
+
+func TestNewRealtimeEvent(t *testing.T) {
+	country := "Germany"
+	event := models.NewRealtimeEvent(&models.Event{ID: uuid.New(), WebsiteID: "site", VisitorID: "v1", EventType: "pageview", Page: "/", Country: &country})
+	assert.Equal(t, "Germany", event.Country)
+	assert.Empty(t, event.Referrer)
+}
+
+func TestRealtimePublishDoesNotWaitForRedis(t *testing.T) {
+	// A Redis that accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), MaxRetries: -1})
+	defer client.Close()
+	realtime := services.NewRealtimeService(client, nil, time.Second, zerolog.Nop())
+
+	// Publishing returns at once, and drops events once the queue is full
+	start := time.Now()
+	for i := 0; i < 5000; i++ {
+		realtime.Publish([]models.Event{{ID: uuid.New(), WebsiteID: "site", EventType: "pageview", Timestamp: start}})
+	}
+	assert.Less(t, time.Since(start), time.Second)
+
+	realtime.Close()
+	realtime.Publish([]models.Event{{ID: uuid.New(), WebsiteID: "site"}})
+}
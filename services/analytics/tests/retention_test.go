@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetentionPeriod(t *testing.T) {
+	period, err := models.ParseRetentionPeriod("")
+	require.NoError(t, err)
+	assert.Equal(t, models.RetentionWeek, period)
+
+	period, err = models.ParseRetentionPeriod("month")
+	require.NoError(t, err)
+	assert.Equal(t, models.RetentionMonth, period)
+
+	_, err = models.ParseRetentionPeriod("year")
+	assert.Error(t, err)
+}
+
+func TestRetentionPeriodSteps(t *testing.T) {
+	// Wednesday
+	ts := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), models.RetentionDay.Truncate(ts))
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), models.RetentionWeek.Truncate(ts))
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), models.RetentionMonth.Truncate(ts))
+
+	// Sunday belongs to the week that started the previous Monday
+	sunday := time.Date(2025, 3, 16, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), models.RetentionWeek.Truncate(sunday))
+
+	from := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 63, models.RetentionDay.Between(from, to))
+	assert.Equal(t, 9, models.RetentionWeek.Between(from, to))
+	assert.Equal(t, 2, models.RetentionMonth.Between(from, to))
+
+	// A DST change does not shorten the day count
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, 1, models.RetentionDay.Between(
+		time.Date(2025, 3, 30, 0, 0, 0, 0, berlin),
+		time.Date(2025, 3, 31, 0, 0, 0, 0, berlin),
+	))
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrder(t *testing.T) {
+	order, err := models.ParseOrder(models.Properties{
+		"order_id": "1042",
+		"amount":   59.9,
+		"currency": "eur",
+		"items": []interface{}{
+			map[string]interface{}{"id": "sku-1", "price": 29.95, "quantity": 2.0},
+			map[string]interface{}{"name": "Gift wrap", "price": 0.0},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1042", order.OrderID)
+	assert.Equal(t, "EUR", order.Currency)
+	require.Len(t, order.Items, 2)
+	assert.Equal(t, 2.0, order.Items[0].Quantity)
+	assert.Equal(t, 1.0, order.Items[1].Quantity)
+
+	invalid := map[string]models.Properties{
+		"missing order_id": {"amount": 10.0, "currency": "USD"},
+		"negative amount":  {"order_id": "1", "amount": -1.0, "currency": "USD"},
+		"string amount":    {"order_id": "1", "amount": "10", "currency": "USD"},
+		"bad currency":     {"order_id": "1", "amount": 10.0, "currency": "dollars"},
+		"items not array":  {"order_id": "1", "amount": 10.0, "currency": "USD", "items": "sku-1"},
+		"item no price":    {"order_id": "1", "amount": 10.0, "currency": "USD", "items": []interface{}{map[string]interface{}{"id": "a"}}},
+		"item zero qty":    {"order_id": "1", "amount": 10.0, "currency": "USD", "items": []interface{}{map[string]interface{}{"price": 1.0, "quantity": 0.0}}},
+	}
+	for name, properties := range invalid {
+		_, err := models.ParseOrder(properties)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestNormalizeRevenue(t *testing.T) {
+	converter := utils.NewCurrencyConverter("USD", map[string]float64{"EUR": 1.08})
+	service := services.NewEventService(nil, nil, nil, nil, converter, nil, nil, nil, zerolog.Nop())
+	defer service.Shutdown(time.Second)
+
+	event := models.Event{
+		WebsiteID:  "test-site",
+		VisitorID:  "visitor-123",
+		SessionID:  "session-456",
+		Page:       "/checkout/complete",
+		EventType:  models.RevenueEventType,
+		Properties: models.Properties{"order_id": "1042", "amount": 10.0, "currency": "GBP"},
+	}
+	require.NoError(t, utils.ValidateEvent(&event))
+	assert.Error(t, service.NormalizeRevenue(&event), "currencies without a rate are rejected")
+
+	event.Properties["currency"] = "eur"
+	require.NoError(t, service.NormalizeRevenue(&event))
+	assert.Equal(t, "EUR", event.Properties[models.RevenueCurrencyProperty])
+	assert.Equal(t, 10.8, event.Properties[models.RevenueProperty])
+	assert.Equal(t, models.RevenueEventType, event.EventType)
+
+	// Other event types are not checked for an order
+	assert.NoError(t, service.NormalizeRevenue(&models.Event{EventType: "click"}))
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	rates, err := utils.ParseCurrencyRates("EUR=1.08, gbp=1.27")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"EUR": 1.08, "GBP": 1.27}, rates)
+
+	converter := utils.NewCurrencyConverter("usd", rates)
+	assert.Equal(t, "USD", converter.Base())
+
+	amount, err := converter.Convert(59.9, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 64.69, amount)
+
+	amount, err = converter.Convert(12.5, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, amount)
+
+	_, err = converter.Convert(10, "JPY")
+	assert.Error(t, err)
+
+	_, err = utils.ParseCurrencyRates("EUR")
+	assert.Error(t, err)
+	_, err = utils.ParseCurrencyRates("EUR=-1")
+	assert.Error(t, err)
+
+	rates, err = utils.ParseCurrencyRates("")
+	require.NoError(t, err)
+	assert.Empty(t, rates)
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollupCovers(t *testing.T) {
+	rollups := repository.NewRollupRepository(nil)
+	now := time.Date(2025, 3, 10, 14, 25, 0, 0, time.UTC)
+
+	closed := models.DateRange{
+		Start: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+	}
+	assert.True(t, rollups.Covers(closed, now))
+
+	rolling, err := models.NewDateRange("", "", 7, "UTC", now)
+	assert.NoError(t, err)
+	assert.True(t, rollups.Covers(rolling, now), "rolling ranges read their closed hours from the rollups")
+
+	withinHour := models.DateRange{
+		Start: time.Date(2025, 3, 10, 10, 5, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 10, 10, 55, 0, 0, time.UTC),
+	}
+	assert.False(t, rollups.Covers(withinHour, now))
+
+	currentHour := models.DateRange{
+		Start: time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC),
+	}
+	assert.False(t, rollups.Covers(currentHour, now))
+}
+
+func TestRollupClosedHours(t *testing.T) {
+	rollups := repository.NewRollupRepository(nil)
+	now := time.Date(2025, 3, 10, 14, 25, 0, 0, time.UTC)
+
+	rolling, err := models.NewDateRange("", "", 7, "UTC", now)
+	assert.NoError(t, err)
+	start, end := rollups.ClosedHours(rolling, now)
+	assert.Equal(t, time.Date(2025, 3, 3, 15, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC), end)
+
+	aligned := models.DateRange{
+		Start: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+	}
+	start, end = rollups.ClosedHours(aligned, now)
+	assert.Equal(t, aligned.Start, start)
+	assert.Equal(t, aligned.End, end)
+
+	future := aligned
+	future.End = time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)
+	_, end = rollups.ClosedHours(future, now)
+	assert.Equal(t, time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC), end)
+}
+
+func TestRollupDimensionStatRates(t *testing.T) {
+	stat := repository.RollupDimensionStat{
+		Views:           20,
+		Sessions:        8,
+		Bounces:         2,
+		Entries:         6,
+		TimeOnPageTotal: 300,
+		TimeOnPageCount: 10,
+	}
+	assert.InDelta(t, 25.0, *stat.BounceRate(), 0.001)
+	assert.InDelta(t, 75.0, *stat.EntryRate(), 0.001)
+	assert.InDelta(t, 30.0, *stat.AvgTime(), 0.001)
+
+	empty := repository.RollupDimensionStat{}
+	assert.Equal(t, 0.0, *empty.BounceRate())
+	assert.Equal(t, 0.0, *empty.AvgTime())
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func paidMobileSegment() *models.Segment {
+	return &models.Segment{
+		ID:        uuid.New(),
+		WebsiteID: "site",
+		Name:      "Mobile visitors from paid search",
+		Scope:     models.SegmentVisitor,
+		Filters: models.Filters{
+			{Dimension: "device", Operator: models.FilterEquals, Value: "mobile"},
+			{Dimension: models.ChannelDimension, Operator: models.FilterEquals, Value: "Paid Search"},
+		},
+	}
+}
+
+func TestSegmentValidate(t *testing.T) {
+	require.NoError(t, paidMobileSegment().Validate())
+
+	noScope := paidMobileSegment()
+	noScope.Scope = "account"
+	assert.Error(t, noScope.Validate())
+
+	noFilters := paidMobileSegment()
+	noFilters.Filters = nil
+	assert.Error(t, noFilters.Validate())
+
+	badFilter := paidMobileSegment()
+	badFilter.Filters = append(badFilter.Filters, models.Filter{Dimension: "ip_address", Operator: models.FilterEquals, Value: "1.2.3.4"})
+	assert.ErrorContains(t, badFilter.Validate(), "filter 3")
+
+	nested := paidMobileSegment()
+	nested.Filters = models.Filters{paidMobileSegment().Filter()}
+	assert.Error(t, nested.Validate())
+}
+
+func TestSegmentFilter(t *testing.T) {
+	segment := paidMobileSegment()
+	rules := models.ChannelRules{{
+		Channel: "Partners",
+		Conditions: []models.ChannelCondition{
+			{Field: models.ChannelFieldSource, Operator: models.FilterEquals, Value: "acme"},
+		},
+	}}
+
+	filters := models.Filters{
+		{Dimension: "country", Operator: models.FilterEquals, Value: "Germany"},
+		segment.Filter(),
+	}
+	require.True(t, filters.HasChannel())
+
+	filters = filters.WithChannels(rules)
+	assert.Equal(t, rules, filters[1].Segment.Filters[1].Channels)
+	assert.Nil(t, segment.Filters[1].Channels, "the saved segment is not modified")
+
+	qb := repository.NewQueryBuilder("site", models.LastDays(7), filters)
+	query := qb.Build("WHERE website_id = $1{{filters:e}}")
+
+	// Visitor segments select every event of a visitor with a matching event
+	assert.Contains(t, query, "AND e.country = $4")
+	assert.Contains(t, query, "AND e.visitor_id IN (\n\t\t\tSELECT visitor_id")
+	assert.Contains(t, query, "AND (device = $5 AND session_id IN (")
+	assert.Equal(t, "mobile", qb.Args()[4])
+
+	session := paidMobileSegment()
+	session.Scope = models.SegmentSession
+	session.Filters = session.Filters[:1]
+	qb = repository.NewQueryBuilder("site", models.LastDays(7), models.Filters{session.Filter()})
+	assert.Contains(t, qb.Build("{{filters}}"), "AND session_id IN (")
+}
+
+func TestFunnelQuerySegment(t *testing.T) {
+	q, err := models.NewFunnelQuery(0, "", "", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, q.Filters())
+
+	q.Segment = paidMobileSegment()
+	filters := q.Filters()
+	require.Len(t, filters, 1)
+	assert.Equal(t, models.SegmentDimension, filters[0].Dimension)
+	assert.Equal(t, q.Segment.ID.String(), filters[0].Value)
+}
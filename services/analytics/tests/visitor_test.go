@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisitorProfileSessions(t *testing.T) {
+	start := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	search := models.VisitorSession{SessionID: "s1", Referrer: "https://google.com/", Device: "mobile", City: "Berlin", Region: "Berlin", UserAgent: "Mozilla/5.0"}
+	direct := models.VisitorSession{SessionID: "s2", Device: "desktop", City: "Berlin"}
+
+	profile := &models.VisitorProfile{VisitorID: "v1"}
+	profile.AddEvent(search, models.VisitorEvent{Timestamp: start, EventType: "signup_started", Page: "/"})
+	profile.AddEvent(search, models.VisitorEvent{Timestamp: start.Add(10 * time.Second), EventType: "pageview", Page: "/pricing"})
+	profile.AddEvent(search, models.VisitorEvent{Timestamp: start.Add(95 * time.Second), EventType: "pageview", Page: "/signup", Goals: []string{"Signup"}})
+	profile.AddEvent(direct, models.VisitorEvent{Timestamp: start.Add(48 * time.Hour), EventType: "pageview", Page: "/docs"})
+
+	require.Len(t, profile.Sessions, 2)
+	first := profile.Sessions[0]
+	assert.Equal(t, "s1", first.SessionID)
+	assert.Equal(t, start, first.StartedAt)
+	assert.Equal(t, 95, first.DurationSeconds)
+	assert.Equal(t, 2, first.Pageviews)
+	assert.Equal(t, "/pricing", first.EntryPage, "the entry page is the first pageview")
+	assert.Equal(t, "https://google.com/", first.Referrer)
+	assert.Len(t, first.Events, 3)
+	assert.Equal(t, []string{"Signup"}, first.Events[2].Goals)
+
+	second := profile.Sessions[1]
+	assert.Equal(t, "desktop", second.Device)
+	assert.Equal(t, 0, second.DurationSeconds)
+	assert.Len(t, second.Events, 1)
+}
+
+func TestVisitorProfileAnonymize(t *testing.T) {
+	assert.True(t, models.IsAnonymizedVisitor("anon_1a2b3c4d"))
+	assert.False(t, models.IsAnonymizedVisitor("v_anon"))
+
+	profile := &models.VisitorProfile{VisitorID: "anon_1a2b3c4d"}
+	profile.AddEvent(models.VisitorSession{SessionID: "anon_9f8e7d6c", Country: "Germany", City: "Berlin", Region: "Berlin", UserAgent: "Mozilla/5.0"},
+		models.VisitorEvent{Timestamp: time.Now(), EventType: "pageview", Page: "/"})
+	profile.Anonymize()
+
+	assert.True(t, profile.Anonymized)
+	session := profile.Sessions[0]
+	assert.Equal(t, "Germany", session.Country)
+	assert.Empty(t, session.City)
+	assert.Empty(t, session.Region)
+	assert.Empty(t, session.UserAgent)
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CurrencyConverter converts order amounts into a single reporting currency using a
+// fixed rate table
+type CurrencyConverter struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewCurrencyConverter creates a converter into base. rates holds the value of one unit
+// of each currency in the base currency; the base currency itself is always 1.
+func NewCurrencyConverter(base string, rates map[string]float64) *CurrencyConverter {
+	base = strings.ToUpper(base)
+	table := map[string]float64{base: 1}
+	for currency, rate := range rates {
+		table[strings.ToUpper(currency)] = rate
+	}
+	return &CurrencyConverter{base: base, rates: table}
+}
+
+// ParseCurrencyRates parses a rate table written as "EUR=1.08,GBP=1.27"
+func ParseCurrencyRates(value string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		currency, rawRate, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid currency rate %q: expected CODE=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rawRate), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid currency rate %q: rate must be a positive number", entry)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+	return rates, nil
+}
+
+// Base returns the reporting currency
+func (c *CurrencyConverter) Base() string {
+	return c.base
+}
+
+// Convert returns amount in the reporting currency, rounded to cents
+func (c *CurrencyConverter) Convert(amount float64, currency string) (float64, error) {
+	rate, ok := c.rates[strings.ToUpper(currency)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %s", currency)
+	}
+	return math.Round(amount*rate*100) / 100, nil
+}
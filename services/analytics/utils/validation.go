@@ -3,6 +3,7 @@ package utils
 import (
 	"analytics-app/models"
 	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 )
@@ -31,7 +32,7 @@ func ValidateEvent(event *models.Event) error {
 	}
 
 	// Validate event_type
-	validEventTypes := []string{"pageview", "click", "form_submit", "download", "custom"}
+	validEventTypes := []string{"pageview", "click", "form_submit", "download", "custom", models.RevenueEventType}
 	if event.EventType != "" && !contains(validEventTypes, event.EventType) {
 		event.EventType = "custom" // Default to custom for unknown types
 	}
@@ -46,6 +47,14 @@ func ValidateEvent(event *models.Event) error {
 		return errors.New("time_on_page must be non-negative")
 	}
 
+	// Purchases must carry a well-formed order: an order_id, a non-negative amount, an
+	// ISO 4217 currency and valid line items
+	if event.EventType == models.RevenueEventType {
+		if _, err := models.ParseOrder(event.Properties); err != nil {
+			return fmt.Errorf("invalid purchase: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -63,36 +72,65 @@ func ValidateFunnel(funnel *models.Funnel) error {
 		return errors.New("funnel must have at least one step")
 	}
 
+	switch funnel.Ordering {
+	case "", models.FunnelOrderLoose, models.FunnelOrderStrict:
+	default:
+		return fmt.Errorf("funnel ordering must be loose or strict, got %q", funnel.Ordering)
+	}
+
+	if funnel.ConversionWindowSeconds < 0 {
+		return errors.New("conversion window cannot be negative")
+	}
+
 	// Validate each step
-	for _, step := range funnel.Steps {
+	for i, step := range funnel.Steps {
+		number := i + 1
+
 		if step.Name == "" {
-			return errors.New("step name is required")
+			return fmt.Errorf("step %d name is required", number)
 		}
 
 		if step.Type == "" {
-			return errors.New("step type is required")
+			return fmt.Errorf("step %d type is required", number)
 		}
 
-		validStepTypes := []string{"page", "event", "custom"}
+		validStepTypes := []string{models.FunnelStepPage, models.FunnelStepEvent, models.FunnelStepCustom}
 		if !contains(validStepTypes, step.Type) {
-			return errors.New("step has invalid type")
+			return fmt.Errorf("step %d has invalid type %q", number, step.Type)
 		}
 
 		// Validate step conditions based on type
 		switch step.Type {
-		case "page":
+		case models.FunnelStepPage:
 			if step.Condition.Page == nil || *step.Condition.Page == "" {
-				return errors.New("step of type 'page' requires page condition")
+				return fmt.Errorf("step %d of type 'page' requires page condition", number)
 			}
-		case "event":
+		case models.FunnelStepEvent:
 			if step.Condition.Event == nil || *step.Condition.Event == "" {
-				return errors.New("step of type 'event' requires event condition")
+				return fmt.Errorf("step %d of type 'event' requires event condition", number)
 			}
-		case "custom":
+			// Click selectors are matched by the tracker, which knows no operators or properties
+			if step.Condition.Operator != "" || len(step.Condition.Properties) > 0 {
+				return fmt.Errorf("step %d of type 'event' does not support operators or property conditions", number)
+			}
+		case models.FunnelStepCustom:
 			if step.Condition.Custom == nil || *step.Condition.Custom == "" {
-				return errors.New("step of type 'custom' requires custom condition")
+				return fmt.Errorf("step %d of type 'custom' requires custom condition", number)
+			}
+		}
+
+		for _, filter := range step.Filters() {
+			if err := filter.Validate(); err != nil {
+				return fmt.Errorf("step %d: %w", number, err)
 			}
 		}
+
+		if step.WindowSeconds < 0 {
+			return fmt.Errorf("step %d window cannot be negative", number)
+		}
+		if step.WindowSeconds > 0 && i == 0 {
+			return errors.New("step 1 cannot have a window, it starts the funnel")
+		}
 	}
 
 	return nil
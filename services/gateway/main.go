@@ -52,6 +52,41 @@ func main() {
 		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
 	})
 
+	// Goal routes - route to analytics service
+	mux.HandleFunc("/api/v1/goals/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
+	// Channel routes - route to analytics service
+	mux.HandleFunc("/api/v1/channels/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
+	// Experiment routes - route to analytics service
+	mux.HandleFunc("/api/v1/experiments/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
+	// Segment routes - route to analytics service
+	mux.HandleFunc("/api/v1/segments/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
+	// Export routes - route to analytics service
+	mux.HandleFunc("/api/v1/exports/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
+	// Digest routes - route to analytics service
+	mux.HandleFunc("/api/v1/digests/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
+	// Alert routes - route to analytics service
+	mux.HandleFunc("/api/v1/alerts/", func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	})
+
 	// Privacy routes - route to appropriate services based on GDPR requirements
 	mux.HandleFunc("/api/v1/privacy/", func(w http.ResponseWriter, r *http.Request) {
 		// Route privacy requests based on the specific endpoint
@@ -83,6 +118,13 @@ func main() {
 	mux.HandleFunc("/api/v1/admin/users", handlers.GetUsersList)
 	mux.HandleFunc("/api/v1/admin/websites", handlers.GetWebsitesList)
 
+	// Dead-letter list, replay and purge - route to analytics service behind admin auth
+	deadLetters := middlewares.AdminCORSMiddleware(middlewares.AdminOrJWTMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		proxyTo(w, r, os.Getenv("ANALYTICS_SERVICE_URL"))
+	}))
+	mux.HandleFunc("/api/v1/admin/dead-letter", deadLetters)
+	mux.HandleFunc("/api/v1/admin/dead-letter/", deadLetters)
+
 	// Initialize events tracker
 	middlewares.InitEventsTracker()
 
@@ -118,6 +160,13 @@ func proxyTo(w http.ResponseWriter, r *http.Request, target string) {
 	}
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
 
+	// Server-Sent Events must reach the client as soon as they are written, and export
+	// downloads are streamed rather than held in the gateway. Only the response headers
+	// have a timeout, so a large download is never cut off here.
+	if isStreamRequest(r) || isDownloadRequest(r) {
+		proxy.FlushInterval = -1
+	}
+
 	// Add timeout configuration
 	proxy.Transport = &http.Transport{
 		ResponseHeaderTimeout: 30 * time.Second,
@@ -135,6 +184,16 @@ func proxyTo(w http.ResponseWriter, r *http.Request, target string) {
 	proxy.ServeHTTP(w, r)
 }
 
+// isStreamRequest reports whether the client asked for a Server-Sent Events stream
+func isStreamRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// isDownloadRequest reports whether the request downloads an export's file
+func isDownloadRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/v1/exports/") && strings.HasSuffix(r.URL.Path, "/download")
+}
+
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)